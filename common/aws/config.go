@@ -14,8 +14,8 @@ type AWSConfig struct {
 // AWS Credentials for temporary
 type AWSCredentials struct {
 	AccessKey    string    `yaml:"access_key" json:"access_key"`
-	AccessSecret string    `yaml:"access_secret" json:"access_secret"`
-	SessionToken string    `yaml:"session_token,omitempty"`
+	AccessSecret string    `yaml:"access_secret" json:"access_secret" secret:"true"`
+	SessionToken string    `yaml:"session_token,omitempty" secret:"true"`
 	Region       AWSRegion `yaml:"region" json:"region"`
 }
 
@@ -0,0 +1,189 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/aws/imds"
+)
+
+// Fake IMDS that hands out numbered credentials expiring at the time set in expiration
+type fakeIMDS struct {
+	fetches    atomic.Int32
+	failing    atomic.Bool
+	mu         sync.Mutex
+	expiration time.Time
+}
+
+func (f *fakeIMDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/latest/api/token":
+		w.Write([]byte("token"))
+	case "/latest/meta-data/iam/security-credentials/":
+		w.Write([]byte("enclave-role"))
+	case "/latest/meta-data/iam/security-credentials/enclave-role":
+		if f.failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		n := f.fetches.Add(1)
+
+		f.mu.Lock()
+		expiration := f.expiration
+		f.mu.Unlock()
+
+		json.NewEncoder(w).Encode(imdsCredentials{
+			Code:            "Success",
+			AccessKeyId:     "ASIA" + string(rune('0'+n)),
+			SecretAccessKey: "secret",
+			Token:           "session",
+			Expiration:      expiration,
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestIMDSProvider(t *testing.T, now *time.Time) (*IMDSProvider, *fakeIMDS) {
+	t.Helper()
+
+	fake := &fakeIMDS{expiration: now.Add(time.Hour)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	provider := NewIMDSProvider(imds.NewClient(server.Client(), server.URL))
+	provider.now = func() time.Time { return *now }
+	return provider, fake
+}
+
+func TestIMDSProvider_Refresh(t *testing.T) {
+	now := time.Now()
+	provider, fake := newTestIMDSProvider(t, &now)
+
+	creds, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if creds.AccessKey != "ASIA1" || creds.SessionToken != "session" {
+		t.Errorf("Retrieve() = %+v, want the first credentials", creds)
+	}
+
+	// Cached until the refresh window
+	now = now.Add(40 * time.Minute)
+	creds, _ = provider.Retrieve(context.Background())
+	if creds.AccessKey != "ASIA1" || fake.fetches.Load() != 1 {
+		t.Errorf("Retrieve() fetched again before the refresh window")
+	}
+
+	// Renewed inside the refresh window
+	fake.mu.Lock()
+	fake.expiration = now.Add(2 * time.Hour)
+	fake.mu.Unlock()
+	now = now.Add(10 * time.Minute)
+	creds, err = provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if creds.AccessKey != "ASIA2" {
+		t.Errorf("Retrieve() = %s, want renewed credentials", creds.AccessKey)
+	}
+}
+
+func TestIMDSProvider_RefreshFailure(t *testing.T) {
+	now := time.Now()
+	provider, fake := newTestIMDSProvider(t, &now)
+
+	if _, err := provider.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	// Still valid credentials are used when a refresh fails
+	fake.failing.Store(true)
+	now = now.Add(50 * time.Minute)
+	creds, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() with valid cached credentials error = %v", err)
+	}
+	if creds.AccessKey != "ASIA1" {
+		t.Errorf("Retrieve() = %s, want the cached credentials", creds.AccessKey)
+	}
+
+	// Expired credentials are never returned
+	now = now.Add(time.Hour)
+	if _, err := provider.Retrieve(context.Background()); err == nil {
+		t.Error("Retrieve() with expired credentials and a failing IMDS should fail")
+	}
+}
+
+func TestIMDSProvider_Concurrent(t *testing.T) {
+	now := time.Now()
+	provider, fake := newTestIMDSProvider(t, &now)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.Retrieve(context.Background()); err != nil {
+				t.Errorf("Retrieve() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := fake.fetches.Load(); got != 1 {
+		t.Errorf("credential fetches = %d, want 1", got)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv(EnvAccessKeyId, "")
+	t.Setenv(EnvSecretAccessKey, "")
+
+	if _, err := NewEnvProvider().Retrieve(context.Background()); err == nil {
+		t.Error("Retrieve() without env credentials should fail")
+	}
+
+	t.Setenv(EnvAccessKeyId, "AKIDEXAMPLE")
+	t.Setenv(EnvSecretAccessKey, "secret")
+	t.Setenv(EnvSessionToken, "session")
+	t.Setenv(EnvRegion, "us-west-2")
+
+	creds, err := NewEnvProvider().Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if creds.AccessKey != "AKIDEXAMPLE" || creds.AccessSecret != "secret" || creds.SessionToken != "session" || creds.Region != "us-west-2" {
+		t.Errorf("Retrieve() = %+v", creds)
+	}
+}
+
+func TestStaticFileProvider(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(cfgPath, []byte("aws_credentials:\n  access_key: AKIDEXAMPLE\n  access_secret: secret\n"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	provider, err := NewStaticFileProvider(cfgPath)
+	if err != nil {
+		t.Fatalf("NewStaticFileProvider() error = %v", err)
+	}
+
+	creds, err := provider.Retrieve(context.Background())
+	if err != nil || creds.AccessKey != "AKIDEXAMPLE" || creds.AccessSecret != "secret" {
+		t.Errorf("Retrieve() = %+v, %v", creds, err)
+	}
+
+	if _, err := NewStaticFileProvider(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("NewStaticFileProvider() with a missing file should fail")
+	}
+}
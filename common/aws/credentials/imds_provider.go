@@ -0,0 +1,117 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/imds"
+	"github.com/getaxal/verified-signer/common/redact"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	iamCredentialsPath = "iam/security-credentials/"
+
+	// DefaultRefreshWindow is how long before expiry IMDS credentials are renewed. IMDS hands out new credentials well before
+	// the old ones expire, so this leaves room for a few failed attempts.
+	DefaultRefreshWindow = 15 * time.Minute
+)
+
+// Temporary credentials as returned by IMDS
+type imdsCredentials struct {
+	Code            string    `json:"Code"`
+	AccessKeyId     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// IMDSProvider returns the instance role credentials from IMDSv2 and renews them before they expire. Concurrent callers share a
+// single refresh.
+type IMDSProvider struct {
+	client        *imds.Client
+	refreshWindow time.Duration
+
+	mu         sync.Mutex
+	creds      aws.AWSCredentials
+	expiration time.Time
+	now        func() time.Time
+}
+
+// Creates a new IMDSProvider, credentials are fetched on the first Retrieve
+func NewIMDSProvider(client *imds.Client) *IMDSProvider {
+	return &IMDSProvider{
+		client:        client,
+		refreshWindow: DefaultRefreshWindow,
+		now:           time.Now,
+	}
+}
+
+func (p *IMDSProvider) Retrieve(ctx context.Context) (aws.AWSCredentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if !p.expiration.IsZero() && now.Before(p.expiration.Add(-p.refreshWindow)) {
+		return p.creds, nil
+	}
+
+	creds, expiration, err := p.fetch(ctx)
+	if err != nil {
+		// Keep using the old credentials while they are still valid, the next call will try again
+		if now.Before(p.expiration) {
+			log.Warnf("Failed to refresh ec2 credentials, using credentials expiring at %s: %v", p.expiration, err)
+			return p.creds, nil
+		}
+		return aws.AWSCredentials{}, err
+	}
+
+	redact.RegisterTagged(creds)
+	p.creds = creds
+	p.expiration = expiration
+	log.Infof("Fetched ec2 credentials expiring at %s", expiration)
+
+	return p.creds, nil
+}
+
+func (p *IMDSProvider) fetch(ctx context.Context) (aws.AWSCredentials, time.Time, error) {
+	roleName, err := p.client.GetMetadata(ctx, iamCredentialsPath)
+	if err != nil {
+		return aws.AWSCredentials{}, time.Time{}, fmt.Errorf("failed to get IAM role: %w", err)
+	}
+
+	// IMDS lists one role per line, an instance profile only ever has one
+	roleName = strings.TrimSpace(strings.SplitN(roleName, "\n", 2)[0])
+	if roleName == "" {
+		return aws.AWSCredentials{}, time.Time{}, fmt.Errorf("instance has no IAM role")
+	}
+
+	body, err := p.client.GetMetadata(ctx, iamCredentialsPath+roleName)
+	if err != nil {
+		return aws.AWSCredentials{}, time.Time{}, fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	var ec2Creds imdsCredentials
+	if err := json.Unmarshal([]byte(body), &ec2Creds); err != nil {
+		return aws.AWSCredentials{}, time.Time{}, fmt.Errorf("failed to decode credentials: %w", err)
+	}
+
+	if ec2Creds.Code != "" && ec2Creds.Code != "Success" {
+		return aws.AWSCredentials{}, time.Time{}, fmt.Errorf("IMDS returned credentials with code %s", ec2Creds.Code)
+	}
+
+	if ec2Creds.AccessKeyId == "" || ec2Creds.SecretAccessKey == "" || ec2Creds.Expiration.IsZero() {
+		return aws.AWSCredentials{}, time.Time{}, fmt.Errorf("IMDS returned incomplete credentials")
+	}
+
+	return aws.AWSCredentials{
+		AccessKey:    ec2Creds.AccessKeyId,
+		AccessSecret: ec2Creds.SecretAccessKey,
+		SessionToken: ec2Creds.Token,
+	}, ec2Creds.Expiration, nil
+}
@@ -0,0 +1,77 @@
+// Package credentials provides the AWS credentials used to sign requests. Providers for temporary credentials renew them
+// before they expire, so callers should Retrieve credentials for every request instead of holding on to them.
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/redact"
+)
+
+// Provider returns AWS credentials that are valid for at least the next request. Implementations must be safe for concurrent use.
+type Provider interface {
+	Retrieve(ctx context.Context) (aws.AWSCredentials, error)
+}
+
+// StaticProvider always returns the same credentials
+type StaticProvider struct {
+	creds aws.AWSCredentials
+}
+
+// Creates a new StaticProvider for creds
+func NewStaticProvider(creds aws.AWSCredentials) *StaticProvider {
+	redact.RegisterTagged(creds)
+	return &StaticProvider{creds: creds}
+}
+
+// Creates a new StaticProvider with the aws_credentials in the config file at cfgPath
+func NewStaticFileProvider(cfgPath string) (*StaticProvider, error) {
+	cfg, err := aws.NewAWSConfigFromYAML(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStaticProvider(cfg.AWSCredentials), nil
+}
+
+func (p *StaticProvider) Retrieve(ctx context.Context) (aws.AWSCredentials, error) {
+	return p.creds, nil
+}
+
+const (
+	EnvAccessKeyId     = "AWS_ACCESS_KEY_ID"
+	EnvSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	EnvSessionToken    = "AWS_SESSION_TOKEN"
+	EnvRegion          = "AWS_REGION"
+)
+
+// EnvProvider reads the standard AWS environment variables on every Retrieve
+type EnvProvider struct{}
+
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+// EnvCredentialsSet reports whether the AWS credential environment variables are set
+func EnvCredentialsSet() bool {
+	return os.Getenv(EnvAccessKeyId) != "" && os.Getenv(EnvSecretAccessKey) != ""
+}
+
+func (p *EnvProvider) Retrieve(ctx context.Context) (aws.AWSCredentials, error) {
+	if !EnvCredentialsSet() {
+		return aws.AWSCredentials{}, fmt.Errorf("%s and %s must be set", EnvAccessKeyId, EnvSecretAccessKey)
+	}
+
+	creds := aws.AWSCredentials{
+		AccessKey:    os.Getenv(EnvAccessKeyId),
+		AccessSecret: os.Getenv(EnvSecretAccessKey),
+		SessionToken: os.Getenv(EnvSessionToken),
+		Region:       aws.AWSRegion(os.Getenv(EnvRegion)),
+	}
+	redact.RegisterTagged(creds)
+
+	return creds, nil
+}
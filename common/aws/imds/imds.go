@@ -0,0 +1,152 @@
+// Package imds is a small IMDSv2 client for the EC2 instance metadata service
+package imds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/network"
+)
+
+const (
+	// DefaultEndpoint is the link local address of the instance metadata service
+	DefaultEndpoint = "http://169.254.169.254"
+
+	tokenPath    = "/latest/api/token"
+	metadataPath = "/latest/meta-data/"
+
+	defaultTokenTTL = 6 * time.Hour
+	// Refresh the session token a little before IMDS stops accepting it
+	tokenRefreshWindow = time.Minute
+)
+
+type Client struct {
+	httpClient *http.Client
+	endpoint   string
+	tokenTTL   time.Duration
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	now         func() time.Time
+}
+
+// Creates a new IMDS client that sends its requests to endpoint
+func NewClient(httpClient *http.Client, endpoint string) *Client {
+	return &Client{
+		httpClient: httpClient,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		tokenTTL:   defaultTokenTTL,
+		now:        time.Now,
+	}
+}
+
+// Creates a new IMDS client for the enclave that reaches IMDS through the host proxy on vsockPort
+func NewVsockClient(vsockPort uint32) *Client {
+	return NewClient(network.InitHttpClientWithVsockTransportEnclave(vsockPort), DefaultEndpoint)
+}
+
+// GetMetadata returns the metadata at path, relative to /latest/meta-data/. eg. "placement/region"
+func (c *Client) GetMetadata(ctx context.Context, path string) (string, error) {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	body, status, err := c.get(ctx, path, token)
+	if err != nil {
+		return "", err
+	}
+
+	// The token was revoked or expired early, get a new one and try once more
+	if status == http.StatusUnauthorized {
+		c.invalidateToken(token)
+
+		token, err = c.getToken(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		body, status, err = c.get(ctx, path, token)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if status != http.StatusOK {
+		return "", fmt.Errorf("failed to get metadata %s, status: %d", path, status)
+	}
+
+	return string(body), nil
+}
+
+func (c *Client) get(ctx context.Context, path string, token string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoint+metadataPath+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get metadata %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read metadata %s: %w", path, err)
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// Returns the cached IMDSv2 session token, fetching a new one if it is about to expire
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && c.now().Before(c.tokenExpiry.Add(-tokenRefreshWindow)) {
+		return c.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.endpoint+tokenPath, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(int(c.tokenTTL/time.Second)))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get metadata token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get metadata token, status: %d", resp.StatusCode)
+	}
+
+	token, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata token: %w", err)
+	}
+
+	c.token = string(token)
+	c.tokenExpiry = c.now().Add(c.tokenTTL)
+	return c.token, nil
+}
+
+func (c *Client) invalidateToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
@@ -0,0 +1,64 @@
+package imds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetMetadata(t *testing.T) {
+	var tokenRequests atomic.Int32
+	var revoked atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/latest/api/token":
+			if r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			n := tokenRequests.Add(1)
+			w.Write([]byte("token-" + string(rune('0'+n))))
+		case r.URL.Path == "/latest/meta-data/placement/region":
+			token := r.Header.Get("X-aws-ec2-metadata-token")
+			if token == "" || (revoked.Load() && token == "token-1") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("us-east-2"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Client(), server.URL)
+
+	for i := 0; i < 3; i++ {
+		region, err := client.GetMetadata(context.Background(), "placement/region")
+		if err != nil {
+			t.Fatalf("GetMetadata() error = %v", err)
+		}
+		if region != "us-east-2" {
+			t.Errorf("GetMetadata() = %s, want us-east-2", region)
+		}
+	}
+	if got := tokenRequests.Load(); got != 1 {
+		t.Errorf("token requests = %d, want the token reused", got)
+	}
+
+	// A rejected token is replaced once
+	revoked.Store(true)
+	if _, err := client.GetMetadata(context.Background(), "placement/region"); err != nil {
+		t.Fatalf("GetMetadata() after token revocation error = %v", err)
+	}
+	if got := tokenRequests.Load(); got != 2 {
+		t.Errorf("token requests = %d, want 2", got)
+	}
+
+	if _, err := client.GetMetadata(context.Background(), "missing"); err == nil {
+		t.Error("GetMetadata() of a missing path should fail")
+	}
+}
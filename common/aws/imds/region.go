@@ -0,0 +1,40 @@
+package imds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/getaxal/verified-signer/common/aws"
+)
+
+const regionPath = "placement/region"
+
+// GetRegion returns the region the instance runs in
+func (c *Client) GetRegion(ctx context.Context) (aws.AWSRegion, error) {
+	region, err := c.GetMetadata(ctx, regionPath)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.FromString(strings.TrimSpace(region))
+}
+
+// ResolveRegion returns the configured region if one is set, otherwise the instance region from IMDS. The region must be one of
+// the known regions in regions.go either way. client may be nil when IMDS is not reachable, eg. for local runs.
+func ResolveRegion(ctx context.Context, configured string, client *Client) (aws.AWSRegion, error) {
+	if configured != "" {
+		return aws.FromString(configured)
+	}
+
+	if client == nil {
+		return "", fmt.Errorf("no region configured and no instance metadata to discover it from")
+	}
+
+	region, err := client.GetRegion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to discover region: %w", err)
+	}
+
+	return region, nil
+}
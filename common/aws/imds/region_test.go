@@ -0,0 +1,54 @@
+package imds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getaxal/verified-signer/common/aws"
+)
+
+func TestResolveRegion(t *testing.T) {
+	newClient := func(region string) *Client {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/latest/api/token":
+				w.Write([]byte("token"))
+			case "/latest/meta-data/placement/region":
+				w.Write([]byte(region))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+		return NewClient(server.Client(), server.URL)
+	}
+
+	tests := []struct {
+		name       string
+		configured string
+		client     *Client
+		want       aws.AWSRegion
+		wantErr    bool
+	}{
+		{"configured", "eu-west-1", nil, aws.EUWest1, false},
+		{"configured wins over imds", "eu-west-1", newClient("us-east-2"), aws.EUWest1, false},
+		{"invalid configured", "eu-nowhere-1", nil, "", true},
+		{"from imds", "", newClient("eu-west-1"), aws.EUWest1, false},
+		{"invalid from imds", "", newClient("mars-1"), "", true},
+		{"nothing to resolve from", "", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveRegion(context.Background(), tt.configured, tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveRegion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveRegion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
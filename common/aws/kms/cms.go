@@ -0,0 +1,289 @@
+package kms
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// KMS returns CiphertextForRecipient as a BER encoded CMS EnvelopedData (RFC 5652) with indefinite lengths, which
+// encoding/asn1 can not parse. This file holds the small BER reader needed to open it.
+
+var ErrInvalidEnvelope = errors.New("kms: invalid recipient envelope")
+
+var (
+	OIDEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	OIDData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDRSAESOAEP     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	OIDAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagOID         = 0x06
+	tagSequence    = 0x10
+	tagSet         = 0x11
+
+	classUniversal       = 0
+	classContextSpecific = 2
+
+	maxBERDepth = 32
+)
+
+// A single decoded BER element. Primitive elements keep their content, constructed ones their children.
+type berElement struct {
+	class       int
+	constructed bool
+	tag         int
+	content     []byte
+	children    []berElement
+}
+
+func (e berElement) is(class int, tag int) bool {
+	return e.class == class && e.tag == tag
+}
+
+// Parses a single BER element from data and returns the unread remainder
+func parseBER(data []byte, depth int) (berElement, []byte, error) {
+	var elem berElement
+	if depth > maxBERDepth {
+		return elem, nil, fmt.Errorf("%w: nesting too deep", ErrInvalidEnvelope)
+	}
+	if len(data) < 2 {
+		return elem, nil, fmt.Errorf("%w: truncated element", ErrInvalidEnvelope)
+	}
+
+	elem.class = int(data[0] >> 6)
+	elem.constructed = data[0]&0x20 != 0
+	elem.tag = int(data[0] & 0x1f)
+	offset := 1
+
+	// High tag number form
+	if elem.tag == 0x1f {
+		elem.tag = 0
+		for {
+			if offset >= len(data) || offset > 4 {
+				return elem, nil, fmt.Errorf("%w: bad tag", ErrInvalidEnvelope)
+			}
+			b := data[offset]
+			offset++
+			elem.tag = elem.tag<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	if offset >= len(data) {
+		return elem, nil, fmt.Errorf("%w: truncated length", ErrInvalidEnvelope)
+	}
+	lengthByte := data[offset]
+	offset++
+
+	// Indefinite length, children run until the end-of-contents marker
+	if lengthByte == 0x80 {
+		if !elem.constructed {
+			return elem, nil, fmt.Errorf("%w: indefinite length on primitive", ErrInvalidEnvelope)
+		}
+		rest := data[offset:]
+		for {
+			if len(rest) >= 2 && rest[0] == 0 && rest[1] == 0 {
+				return elem, rest[2:], nil
+			}
+			child, next, err := parseBER(rest, depth+1)
+			if err != nil {
+				return elem, nil, err
+			}
+			elem.children = append(elem.children, child)
+			rest = next
+		}
+	}
+
+	length := int(lengthByte)
+	if lengthByte&0x80 != 0 {
+		numBytes := int(lengthByte & 0x7f)
+		if numBytes > 4 || offset+numBytes > len(data) {
+			return elem, nil, fmt.Errorf("%w: bad length", ErrInvalidEnvelope)
+		}
+		length = 0
+		for _, b := range data[offset : offset+numBytes] {
+			length = length<<8 | int(b)
+		}
+		offset += numBytes
+	}
+
+	if length < 0 || length > len(data)-offset {
+		return elem, nil, fmt.Errorf("%w: length exceeds data", ErrInvalidEnvelope)
+	}
+	body := data[offset : offset+length]
+	rest := data[offset+length:]
+
+	if !elem.constructed {
+		elem.content = body
+		return elem, rest, nil
+	}
+
+	for len(body) > 0 {
+		child, next, err := parseBER(body, depth+1)
+		if err != nil {
+			return elem, nil, err
+		}
+		elem.children = append(elem.children, child)
+		body = next
+	}
+
+	return elem, rest, nil
+}
+
+// Returns the bytes of an octet string, joining the segments of a constructed one
+func octets(e berElement) []byte {
+	if !e.constructed {
+		return e.content
+	}
+	var out []byte
+	for _, child := range e.children {
+		out = append(out, octets(child)...)
+	}
+	return out
+}
+
+func oidEquals(e berElement, oid asn1.ObjectIdentifier) bool {
+	if !e.is(classUniversal, tagOID) || e.constructed {
+		return false
+	}
+	encoded, err := asn1.Marshal(oid)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(encoded[2:], e.content)
+}
+
+// DecryptCiphertextForRecipient opens the CMS EnvelopedData KMS returns for a recipient request. The content encryption key is
+// RSA-OAEP-SHA256 encrypted to recipientKey and the content is AES-256-CBC encrypted.
+func DecryptCiphertextForRecipient(envelope []byte, recipientKey *rsa.PrivateKey) ([]byte, error) {
+	if len(envelope) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidEnvelope)
+	}
+
+	contentInfo, _, err := parseBER(envelope, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// ContentInfo ::= SEQUENCE { contentType, [0] EXPLICIT content }
+	if !contentInfo.is(classUniversal, tagSequence) || len(contentInfo.children) != 2 {
+		return nil, fmt.Errorf("%w: bad content info", ErrInvalidEnvelope)
+	}
+	if !oidEquals(contentInfo.children[0], OIDEnvelopedData) {
+		return nil, fmt.Errorf("%w: not enveloped data", ErrInvalidEnvelope)
+	}
+	explicit := contentInfo.children[1]
+	if !explicit.is(classContextSpecific, 0) || len(explicit.children) != 1 {
+		return nil, fmt.Errorf("%w: bad content", ErrInvalidEnvelope)
+	}
+
+	// EnvelopedData ::= SEQUENCE { version, [0] originatorInfo OPTIONAL, recipientInfos, encryptedContentInfo, ... }
+	envelopedData := explicit.children[0]
+	if !envelopedData.is(classUniversal, tagSequence) {
+		return nil, fmt.Errorf("%w: bad enveloped data", ErrInvalidEnvelope)
+	}
+	fields := envelopedData.children
+	if len(fields) < 3 || !fields[0].is(classUniversal, tagInteger) {
+		return nil, fmt.Errorf("%w: bad enveloped data", ErrInvalidEnvelope)
+	}
+	fields = fields[1:]
+	if fields[0].is(classContextSpecific, 0) {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 || !fields[0].is(classUniversal, tagSet) {
+		return nil, fmt.Errorf("%w: missing recipient infos", ErrInvalidEnvelope)
+	}
+
+	cek, err := decryptContentKey(fields[0], recipientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptContent(fields[1], cek)
+}
+
+// Finds the key transport recipient info and decrypts the content encryption key with it
+func decryptContentKey(recipientInfos berElement, recipientKey *rsa.PrivateKey) ([]byte, error) {
+	for _, info := range recipientInfos.children {
+		// KeyTransRecipientInfo ::= SEQUENCE { version, rid, keyEncryptionAlgorithm, encryptedKey }
+		if !info.is(classUniversal, tagSequence) || len(info.children) != 4 {
+			continue
+		}
+		algorithm := info.children[2]
+		if !algorithm.is(classUniversal, tagSequence) || len(algorithm.children) == 0 || !oidEquals(algorithm.children[0], OIDRSAESOAEP) {
+			continue
+		}
+		encryptedKey := info.children[3]
+		if !encryptedKey.is(classUniversal, tagOctetString) {
+			continue
+		}
+
+		cek, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, recipientKey, octets(encryptedKey), nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decrypt content key", ErrInvalidEnvelope)
+		}
+		return cek, nil
+	}
+
+	return nil, fmt.Errorf("%w: no RSAES-OAEP recipient", ErrInvalidEnvelope)
+}
+
+// Decrypts EncryptedContentInfo ::= SEQUENCE { contentType, contentEncryptionAlgorithm, [0] IMPLICIT encryptedContent }
+func decryptContent(encryptedContentInfo berElement, cek []byte) ([]byte, error) {
+	fields := encryptedContentInfo.children
+	if !encryptedContentInfo.is(classUniversal, tagSequence) || len(fields) != 3 || !oidEquals(fields[0], OIDData) {
+		return nil, fmt.Errorf("%w: bad encrypted content info", ErrInvalidEnvelope)
+	}
+
+	algorithm := fields[1]
+	if !algorithm.is(classUniversal, tagSequence) || len(algorithm.children) != 2 || !oidEquals(algorithm.children[0], OIDAES256CBC) {
+		return nil, fmt.Errorf("%w: unsupported content encryption algorithm", ErrInvalidEnvelope)
+	}
+	iv := algorithm.children[1]
+	if !iv.is(classUniversal, tagOctetString) || len(octets(iv)) != aes.BlockSize {
+		return nil, fmt.Errorf("%w: bad iv", ErrInvalidEnvelope)
+	}
+
+	if !fields[2].is(classContextSpecific, 0) {
+		return nil, fmt.Errorf("%w: missing encrypted content", ErrInvalidEnvelope)
+	}
+	ciphertext := octets(fields[2])
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: bad ciphertext length", ErrInvalidEnvelope)
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad content key", ErrInvalidEnvelope)
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, octets(iv)).CryptBlocks(plaintext, ciphertext)
+
+	return pkcs7Unpad(plaintext)
+}
+
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(data) {
+		return nil, fmt.Errorf("%w: bad padding", ErrInvalidEnvelope)
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, fmt.Errorf("%w: bad padding", ErrInvalidEnvelope)
+		}
+	}
+	return data[:len(data)-padding], nil
+}
@@ -0,0 +1,15 @@
+package kms
+
+import (
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+)
+
+type KMSConfig struct {
+	Credentials credentials.Provider
+	Region      aws.AWSRegion
+}
+
+func (cfg *KMSConfig) GetKMSEndpoint() string {
+	return "https://kms." + cfg.Region.String() + ".amazonaws.com/"
+}
@@ -0,0 +1,223 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+	"github.com/getaxal/verified-signer/common/aws/sigv4"
+	"github.com/getaxal/verified-signer/common/network"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	service = "kms"
+
+	// The only key encryption algorithm KMS supports for recipients
+	KeyEncryptionAlgorithm = "RSAES_OAEP_SHA_256"
+	recipientKeyBits       = 2048
+)
+
+// Attester returns a signed attestation document that embeds the given DER encoded public key. Inside a Nitro enclave this is
+// the NSM attestation, KMS checks it against the key policy before encrypting anything to the key.
+type Attester func(publicKey []byte) ([]byte, error)
+
+type KMSClient struct {
+	KmsClient *http.Client
+	Config    *KMSConfig
+	Attester  Attester
+}
+
+// RecipientInfo tells KMS to encrypt the response to the enclave's ephemeral key instead of returning the plaintext
+type RecipientInfo struct {
+	AttestationDocument    []byte `json:"AttestationDocument"`
+	KeyEncryptionAlgorithm string `json:"KeyEncryptionAlgorithm"`
+}
+
+// DecryptRequest represents the Decrypt request payload
+type DecryptRequest struct {
+	CiphertextBlob []byte         `json:"CiphertextBlob"`
+	KeyId          string         `json:"KeyId,omitempty"`
+	Recipient      *RecipientInfo `json:"Recipient,omitempty"`
+}
+
+// DecryptResponse represents the Decrypt API response. With a recipient, Plaintext is empty and CiphertextForRecipient holds
+// the plaintext enveloped to the recipient key.
+type DecryptResponse struct {
+	KeyId                  string `json:"KeyId"`
+	EncryptionAlgorithm    string `json:"EncryptionAlgorithm"`
+	Plaintext              []byte `json:"Plaintext,omitempty"`
+	CiphertextForRecipient []byte `json:"CiphertextForRecipient,omitempty"`
+}
+
+// GenerateDataKeyRequest represents the GenerateDataKey request payload
+type GenerateDataKeyRequest struct {
+	KeyId     string         `json:"KeyId"`
+	KeySpec   string         `json:"KeySpec"`
+	Recipient *RecipientInfo `json:"Recipient,omitempty"`
+}
+
+// GenerateDataKeyResponse represents the GenerateDataKey API response
+type GenerateDataKeyResponse struct {
+	KeyId                  string `json:"KeyId"`
+	CiphertextBlob         []byte `json:"CiphertextBlob"`
+	Plaintext              []byte `json:"Plaintext,omitempty"`
+	CiphertextForRecipient []byte `json:"CiphertextForRecipient,omitempty"`
+}
+
+// Creates a new KMS client that routes its requests through kmsPort. attester must produce an attestation document for the
+// recipient key, every call on this client goes through the attested recipient flow.
+func NewKMSClient(creds credentials.Provider, region aws.AWSRegion, kmsPort uint32, attester Attester) *KMSClient {
+	return &KMSClient{
+		KmsClient: network.InitHttpsClientWithTLSVsockTransport(kmsPort, fmt.Sprintf("kms.%s.amazonaws.com", region.String())),
+		Config: &KMSConfig{
+			Credentials: creds,
+			Region:      region,
+		},
+		Attester: attester,
+	}
+}
+
+// Decrypt decrypts a KMS ciphertext inside the enclave. KMS only returns the plaintext enveloped to an ephemeral RSA key whose
+// public half is bound into a fresh attestation document, so the host relaying the request never sees it.
+func (kc *KMSClient) Decrypt(ctx context.Context, ciphertextBlob []byte, keyId string) ([]byte, error) {
+	recipientKey, recipient, err := kc.newRecipient()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DecryptResponse
+	err = kc.call(ctx, "TrentService.Decrypt", DecryptRequest{
+		CiphertextBlob: ciphertextBlob,
+		KeyId:          keyId,
+		Recipient:      recipient,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Plaintext) != 0 {
+		return nil, fmt.Errorf("KMS returned plaintext for a recipient request")
+	}
+
+	return DecryptCiphertextForRecipient(resp.CiphertextForRecipient, recipientKey)
+}
+
+// GenerateDataKey creates a new AES-256 data key under keyId. The plaintext key is only returned enveloped to the enclave, the
+// CiphertextBlob can be stored anywhere and turned back into the key later with Decrypt.
+func (kc *KMSClient) GenerateDataKey(ctx context.Context, keyId string) (plaintext []byte, ciphertextBlob []byte, err error) {
+	recipientKey, recipient, err := kc.newRecipient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resp GenerateDataKeyResponse
+	err = kc.call(ctx, "TrentService.GenerateDataKey", GenerateDataKeyRequest{
+		KeyId:     keyId,
+		KeySpec:   "AES_256",
+		Recipient: recipient,
+	}, &resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(resp.Plaintext) != 0 {
+		return nil, nil, fmt.Errorf("KMS returned plaintext for a recipient request")
+	}
+
+	plaintext, err = DecryptCiphertextForRecipient(resp.CiphertextForRecipient, recipientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return plaintext, resp.CiphertextBlob, nil
+}
+
+// Creates an ephemeral RSA key and attests to its public key
+func (kc *KMSClient) newRecipient() (*rsa.PrivateKey, *RecipientInfo, error) {
+	if kc.Attester == nil {
+		return nil, nil, fmt.Errorf("KMS client has no attester")
+	}
+
+	recipientKey, err := rsa.GenerateKey(rand.Reader, recipientKeyBits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate recipient key: %w", err)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&recipientKey.PublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal recipient key: %w", err)
+	}
+
+	attestationDoc, err := kc.Attester(publicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to attest recipient key: %w", err)
+	}
+
+	return recipientKey, &RecipientInfo{
+		AttestationDocument:    attestationDoc,
+		KeyEncryptionAlgorithm: KeyEncryptionAlgorithm,
+	}, nil
+}
+
+// Sends a signed KMS API call and decodes the response
+func (kc *KMSClient) call(ctx context.Context, target string, reqPayload interface{}, respPayload interface{}) error {
+	payloadBytes, err := json.Marshal(reqPayload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", kc.Config.GetKMSEndpoint(), bytes.NewReader(payloadBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Amz-Target", target)
+	if err := kc.signRequest(req, string(payloadBytes)); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := kc.KmsClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Errorf("KMS %s failed with status %d", target, resp.StatusCode)
+		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, respPayload); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return nil
+}
+
+// Use this function to sign the HTTP request to AWS. Uses AWS sig4 found here https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv.html.
+func (kc *KMSClient) signRequest(req *http.Request, payload string) error {
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+
+	creds, err := kc.Config.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	signer := sigv4.NewSigner(creds, kc.Config.Region, service)
+	return signer.Sign(req, sigv4.PayloadHash([]byte(payload)), time.Now())
+}
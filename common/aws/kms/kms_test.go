@@ -0,0 +1,160 @@
+package kms_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+	"github.com/getaxal/verified-signer/common/aws/kms"
+	"github.com/getaxal/verified-signer/common/aws/kms/kmstest"
+)
+
+func newTestClient(t *testing.T) (*kms.KMSClient, *kmstest.Server) {
+	t.Helper()
+
+	server := kmstest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddKey("test-key")
+
+	client := &kms.KMSClient{
+		KmsClient: server.Client(),
+		Config: &kms.KMSConfig{
+			Credentials: credentials.NewStaticProvider(aws.AWSCredentials{AccessKey: "AKIDEXAMPLE", AccessSecret: "secret"}),
+			Region:      aws.USEast2,
+		},
+		Attester: kmstest.FakeAttester,
+	}
+	return client, server
+}
+
+func TestDecrypt(t *testing.T) {
+	client, server := newTestClient(t)
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+	}{
+		{"short", []byte("secret")},
+		{"block sized", bytes.Repeat([]byte{0x42}, 32)},
+		{"long", bytes.Repeat([]byte("privy app secret "), 40)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blob, err := server.Encrypt("test-key", tt.plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+
+			got, err := client.Decrypt(context.Background(), blob, "test-key")
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.plaintext) {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	client, server := newTestClient(t)
+	blob, err := server.Encrypt("test-key", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	if _, err := client.Decrypt(context.Background(), blob, "other-key"); err == nil {
+		t.Error("Decrypt() with the wrong key id should fail")
+	}
+
+	tampered := append([]byte{}, blob...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := client.Decrypt(context.Background(), tampered, "test-key"); err == nil {
+		t.Error("Decrypt() of a tampered ciphertext should fail")
+	}
+
+	server.VerifyAttestation = func(doc []byte) ([]byte, error) {
+		return nil, errors.New("attestation rejected")
+	}
+	if _, err := client.Decrypt(context.Background(), blob, "test-key"); err == nil {
+		t.Error("Decrypt() should fail when KMS rejects the attestation")
+	}
+
+	client.Attester = nil
+	if _, err := client.Decrypt(context.Background(), blob, "test-key"); err == nil {
+		t.Error("Decrypt() without an attester should fail")
+	}
+}
+
+func TestGenerateDataKey(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	dataKey, blob, err := client.GenerateDataKey(context.Background(), "test-key")
+	if err != nil {
+		t.Fatalf("GenerateDataKey() error = %v", err)
+	}
+	if len(dataKey) != 32 {
+		t.Fatalf("GenerateDataKey() key length = %d, want 32", len(dataKey))
+	}
+
+	got, err := client.Decrypt(context.Background(), blob, "test-key")
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !bytes.Equal(got, dataKey) {
+		t.Error("Decrypt() of the data key blob does not match the generated key")
+	}
+
+	if _, _, err := client.GenerateDataKey(context.Background(), "missing-key"); err == nil {
+		t.Error("GenerateDataKey() with an unknown key should fail")
+	}
+}
+
+func TestDecryptCiphertextForRecipient(t *testing.T) {
+	recipientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	envelope, err := kmstest.Envelope([]byte("plaintext"), &recipientKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Envelope() error = %v", err)
+	}
+
+	got, err := kms.DecryptCiphertextForRecipient(envelope, recipientKey)
+	if err != nil {
+		t.Fatalf("DecryptCiphertextForRecipient() error = %v", err)
+	}
+	if string(got) != "plaintext" {
+		t.Errorf("DecryptCiphertextForRecipient() = %q, want %q", got, "plaintext")
+	}
+
+	tests := []struct {
+		name     string
+		envelope []byte
+		key      *rsa.PrivateKey
+	}{
+		{"empty", nil, recipientKey},
+		{"truncated", envelope[:len(envelope)/2], recipientKey},
+		{"not ber", []byte("not an envelope"), recipientKey},
+		{"wrong recipient", envelope, otherKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kms.DecryptCiphertextForRecipient(tt.envelope, tt.key); !errors.Is(err, kms.ErrInvalidEnvelope) {
+				t.Errorf("DecryptCiphertextForRecipient() error = %v, want %v", err, kms.ErrInvalidEnvelope)
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+// Package kmstest provides an in-process stand-in for the parts of the KMS API the enclave uses, so the recipient flow can be
+// tested without AWS or a Nitro enclave.
+package kmstest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/getaxal/verified-signer/common/aws/kms"
+)
+
+const fakeAttestationPrefix = "kmstest-attestation:"
+
+// FakeAttester stands in for the NSM, its documents just carry the public key and are accepted by VerifyFakeAttestation.
+func FakeAttester(publicKey []byte) ([]byte, error) {
+	return append([]byte(fakeAttestationPrefix), publicKey...), nil
+}
+
+// VerifyFakeAttestation returns the public key from a document made by FakeAttester
+func VerifyFakeAttestation(doc []byte) ([]byte, error) {
+	if !bytes.HasPrefix(doc, []byte(fakeAttestationPrefix)) {
+		return nil, errors.New("not a kmstest attestation document")
+	}
+	return doc[len(fakeAttestationPrefix):], nil
+}
+
+// Server is a KMS stand-in holding AES keys in memory. Like KMS with an attestation key policy, it only returns plaintext
+// enveloped to the recipient key in a verified attestation document.
+type Server struct {
+	*httptest.Server
+
+	// VerifyAttestation checks an attestation document and returns the recipient public key in it
+	VerifyAttestation func(doc []byte) ([]byte, error)
+
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func NewServer() *Server {
+	s := &Server{
+		VerifyAttestation: VerifyFakeAttestation,
+		keys:              make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// AddKey creates a KMS key with the given id
+func (s *Server) AddKey(keyId string) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[keyId] = key
+}
+
+// Encrypt encrypts plaintext under keyId the same way KMS Encrypt would, for seeding ciphertexts in tests
+func (s *Server) Encrypt(keyId string, plaintext []byte) ([]byte, error) {
+	key, err := s.key(keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte{byte(len(keyId))}, keyId...)
+	blob = append(blob, nonce...)
+	return gcm.Seal(blob, nonce, plaintext, []byte(keyId)), nil
+}
+
+// Client returns an http client that sends every request to this server whatever the host, like the enclave vsock transports
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (s *Server) key(keyId string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("key %s does not exist", keyId)
+	}
+	return key, nil
+}
+
+func (s *Server) decrypt(blob []byte, keyId string) ([]byte, string, error) {
+	if len(blob) < 1 || len(blob) < 1+int(blob[0]) {
+		return nil, "", errors.New("invalid ciphertext")
+	}
+	blobKeyId := string(blob[1 : 1+int(blob[0])])
+	if keyId != "" && keyId != blobKeyId {
+		return nil, "", errors.New("incorrect key")
+	}
+
+	key, err := s.key(blobKeyId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rest := blob[1+int(blob[0]):]
+	if len(rest) < gcm.NonceSize() {
+		return nil, "", errors.New("invalid ciphertext")
+	}
+
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], []byte(blobKeyId))
+	if err != nil {
+		return nil, "", errors.New("invalid ciphertext")
+	}
+	return plaintext, blobKeyId, nil
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") {
+		writeError(w, http.StatusForbidden, "MissingAuthenticationTokenException")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ValidationException")
+		return
+	}
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "TrentService.Decrypt":
+		var req kms.DecryptRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "ValidationException")
+			return
+		}
+
+		plaintext, keyId, err := s.decrypt(req.CiphertextBlob, req.KeyId)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidCiphertextException")
+			return
+		}
+
+		resp := kms.DecryptResponse{KeyId: keyId, EncryptionAlgorithm: "SYMMETRIC_DEFAULT"}
+		resp.Plaintext, resp.CiphertextForRecipient, err = s.forRecipient(req.Recipient, plaintext)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "ValidationException")
+			return
+		}
+		writeJSON(w, resp)
+	case "TrentService.GenerateDataKey":
+		var req kms.GenerateDataKeyRequest
+		if err := json.Unmarshal(body, &req); err != nil || req.KeySpec != "AES_256" {
+			writeError(w, http.StatusBadRequest, "ValidationException")
+			return
+		}
+
+		dataKey := make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
+			writeError(w, http.StatusInternalServerError, "KMSInternalException")
+			return
+		}
+
+		blob, err := s.Encrypt(req.KeyId, dataKey)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "NotFoundException")
+			return
+		}
+
+		resp := kms.GenerateDataKeyResponse{KeyId: req.KeyId, CiphertextBlob: blob}
+		resp.Plaintext, resp.CiphertextForRecipient, err = s.forRecipient(req.Recipient, dataKey)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "ValidationException")
+			return
+		}
+		writeJSON(w, resp)
+	default:
+		writeError(w, http.StatusBadRequest, "UnknownOperationException")
+	}
+}
+
+// Returns the plaintext as is without a recipient, otherwise enveloped to the attested recipient key
+func (s *Server) forRecipient(recipient *kms.RecipientInfo, plaintext []byte) ([]byte, []byte, error) {
+	if recipient == nil {
+		return plaintext, nil, nil
+	}
+	if recipient.KeyEncryptionAlgorithm != kms.KeyEncryptionAlgorithm {
+		return nil, nil, errors.New("unsupported key encryption algorithm")
+	}
+
+	publicKeyDER, err := s.VerifyAttestation(recipient.AttestationDocument)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyDER)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("recipient key is not RSA")
+	}
+
+	envelope, err := Envelope(plaintext, rsaKey)
+	return nil, envelope, err
+}
+
+// Envelope builds a BER CMS EnvelopedData like KMS CiphertextForRecipient, with indefinite lengths and the content split into
+// octet string segments.
+func Envelope(plaintext []byte, recipient *rsa.PublicKey) ([]byte, error) {
+	cek := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, recipient, cek, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	var segments [][]byte
+	for len(ciphertext) > 0 {
+		n := min(len(ciphertext), 32)
+		segments = append(segments, tlv(0x04, ciphertext[:n]))
+		ciphertext = ciphertext[n:]
+	}
+
+	oaepParams := indefinite(0x30,
+		tlv(0xa0, indefinite(0x30, oid(asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}))),
+		tlv(0xa1, indefinite(0x30, oid(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 8}), indefinite(0x30, oid(asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1})))),
+	)
+
+	recipientInfo := indefinite(0x30,
+		tlv(0x02, []byte{2}),
+		tlv(0x80, make([]byte, 32)), // subjectKeyIdentifier
+		indefinite(0x30, oid(kms.OIDRSAESOAEP), oaepParams),
+		tlv(0x04, encryptedKey),
+	)
+
+	encryptedContentInfo := indefinite(0x30,
+		oid(kms.OIDData),
+		indefinite(0x30, oid(kms.OIDAES256CBC), tlv(0x04, iv)),
+		indefinite(0xa0, segments...),
+	)
+
+	envelopedData := indefinite(0x30,
+		tlv(0x02, []byte{2}),
+		indefinite(0x31, recipientInfo),
+		encryptedContentInfo,
+	)
+
+	return indefinite(0x30, oid(kms.OIDEnvelopedData), indefinite(0xa0, envelopedData)), nil
+}
+
+// Encodes a definite length element
+func tlv(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// Encodes an indefinite length constructed element
+func indefinite(tag byte, children ...[]byte) []byte {
+	out := []byte{tag, 0x80}
+	for _, child := range children {
+		out = append(out, child...)
+	}
+	return append(out, 0, 0)
+}
+
+func oid(o asn1.ObjectIdentifier) []byte {
+	encoded, _ := asn1.Marshal(o)
+	return encoded
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, errType string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"__type": errType})
+}
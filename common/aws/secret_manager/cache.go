@@ -0,0 +1,245 @@
+package secretmananger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultRefreshInterval = 5 * time.Minute
+	DefaultGraceWindow     = time.Hour
+)
+
+// SecretRef names a secret and optionally pins it. A VersionId pins an exact version that is never refreshed, a VersionStage
+// follows a staging label such as AWSCURRENT.
+type SecretRef struct {
+	Name         string
+	VersionId    string
+	VersionStage string
+}
+
+func (r SecretRef) String() string {
+	switch {
+	case r.VersionId != "":
+		return r.Name + "@" + r.VersionId
+	case r.VersionStage != "":
+		return r.Name + ":" + r.VersionStage
+	default:
+		return r.Name
+	}
+}
+
+// A fetched version of a secret. Value holds the bytes of binary secrets.
+type Secret struct {
+	Name      string
+	VersionId string
+	Value     string
+	FetchedAt time.Time
+}
+
+// SecretUpdate is sent to subscribers when a secret changes. Previous is the version that was replaced, it should still be
+// accepted until PreviousValidUntil so a rotation does not reject requests signed with the old version.
+type SecretUpdate struct {
+	Ref                SecretRef
+	Current            Secret
+	Previous           *Secret
+	PreviousValidUntil time.Time
+}
+
+// Subscriber is called with every change of a secret it subscribed to
+type Subscriber func(update SecretUpdate)
+
+type cacheEntry struct {
+	current            Secret
+	previous           *Secret
+	previousValidUntil time.Time
+	subscribers        []Subscriber
+}
+
+// SecretCache caches secrets and refreshes them in the background, so rotated secrets are picked up without a restart
+type SecretCache struct {
+	provider        SecretProvider
+	refreshInterval time.Duration
+	graceWindow     time.Duration
+
+	mu      sync.RWMutex
+	entries map[SecretRef]*cacheEntry
+	now     func() time.Time
+}
+
+// Creates a new SecretCache. Zero durations use DefaultRefreshInterval and DefaultGraceWindow.
+func NewSecretCache(provider SecretProvider, refreshInterval time.Duration, graceWindow time.Duration) *SecretCache {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	if graceWindow <= 0 {
+		graceWindow = DefaultGraceWindow
+	}
+
+	return &SecretCache{
+		provider:        provider,
+		refreshInterval: refreshInterval,
+		graceWindow:     graceWindow,
+		entries:         make(map[SecretRef]*cacheEntry),
+		now:             time.Now,
+	}
+}
+
+// Get returns the cached secret, fetching it on first use
+func (c *SecretCache) Get(ctx context.Context, ref SecretRef) (Secret, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[ref]
+	c.mu.RUnlock()
+	if ok {
+		return entry.current, nil
+	}
+
+	secret, err := c.fetch(ctx, ref)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another caller may have loaded it in the meantime
+	if entry, ok := c.entries[ref]; ok {
+		return entry.current, nil
+	}
+	c.entries[ref] = &cacheEntry{current: secret}
+
+	return secret, nil
+}
+
+// Previous returns the version replaced by the last rotation while it is still inside the grace window
+func (c *SecretCache) Previous(ref SecretRef) (Secret, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[ref]
+	if !ok || entry.previous == nil || !c.now().Before(entry.previousValidUntil) {
+		return Secret{}, false
+	}
+	return *entry.previous, true
+}
+
+// Subscribe calls fn whenever ref changes. The secret is loaded first so later refreshes have a version to compare against.
+func (c *SecretCache) Subscribe(ctx context.Context, ref SecretRef, fn Subscriber) error {
+	if _, err := c.Get(ctx, ref); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entries[ref]
+	entry.subscribers = append(entry.subscribers, fn)
+	return nil
+}
+
+// Refresh refetches every cached secret that is not pinned to a VersionId and notifies the subscribers of those that changed.
+// Secrets that fail to refresh keep their cached version.
+func (c *SecretCache) Refresh(ctx context.Context) error {
+	c.mu.RLock()
+	refs := make([]SecretRef, 0, len(c.entries))
+	for ref := range c.entries {
+		if ref.VersionId == "" {
+			refs = append(refs, ref)
+		}
+	}
+	c.mu.RUnlock()
+
+	var errs []error
+	for _, ref := range refs {
+		secret, err := c.fetch(ctx, ref)
+		if err != nil {
+			log.Errorf("Failed to refresh secret %s: %v", ref, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		update, changed := c.rotate(ref, secret)
+		if !changed {
+			continue
+		}
+
+		log.Infof("Secret %s rotated to version %s", ref, secret.VersionId)
+		for _, fn := range update.subscribers {
+			fn(update.SecretUpdate)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Start refreshes the cache every refresh interval until ctx is done
+func (c *SecretCache) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+type pendingUpdate struct {
+	SecretUpdate
+	subscribers []Subscriber
+}
+
+// Swaps in secret if it is a new version, keeping the replaced version for the grace window
+func (c *SecretCache) rotate(ref SecretRef, secret Secret) (pendingUpdate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[ref]
+	if !ok {
+		return pendingUpdate{}, false
+	}
+
+	if entry.current.VersionId == secret.VersionId && entry.current.Value == secret.Value {
+		entry.current.FetchedAt = secret.FetchedAt
+		return pendingUpdate{}, false
+	}
+
+	previous := entry.current
+	entry.previous = &previous
+	entry.previousValidUntil = c.now().Add(c.graceWindow)
+	entry.current = secret
+
+	return pendingUpdate{
+		SecretUpdate: SecretUpdate{
+			Ref:                ref,
+			Current:            secret,
+			Previous:           &previous,
+			PreviousValidUntil: entry.previousValidUntil,
+		},
+		subscribers: append([]Subscriber(nil), entry.subscribers...),
+	}, true
+}
+
+func (c *SecretCache) fetch(ctx context.Context, ref SecretRef) (Secret, error) {
+	resp, err := c.provider.GetSecretVersion(ctx, ref.Name, ref.VersionId, ref.VersionStage)
+	if err != nil {
+		return Secret{}, fmt.Errorf("failed to fetch secret %s: %w", ref, err)
+	}
+
+	return Secret{
+		Name:      ref.Name,
+		VersionId: resp.VersionId,
+		Value:     string(resp.Value()),
+		FetchedAt: c.now(),
+	}, nil
+}
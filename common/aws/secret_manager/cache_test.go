@@ -0,0 +1,191 @@
+package secretmananger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// In-memory stand-in for Secrets Manager that keeps every version of a secret
+type fakeFetcher struct {
+	mu       sync.Mutex
+	versions map[string][]string // secret name to values, the last one is AWSCURRENT
+	fetches  int
+	failing  bool
+}
+
+func (f *fakeFetcher) put(name string, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.versions[name] = append(f.versions[name], value)
+}
+
+func (f *fakeFetcher) GetSecretVersion(ctx context.Context, name string, versionId string, versionStage string) (*GetSecretValueResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.fetches++
+	if f.failing {
+		return nil, errors.New("secrets manager unavailable")
+	}
+
+	versions := f.versions[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+
+	index := len(versions) - 1
+	switch {
+	case versionId != "":
+		if _, err := fmt.Sscanf(versionId, "v%d", &index); err != nil || index >= len(versions) {
+			return nil, fmt.Errorf("version %s not found", versionId)
+		}
+	case versionStage == "AWSPREVIOUS":
+		index--
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("version not found")
+	}
+
+	return &GetSecretValueResponse{Name: name, VersionId: fmt.Sprintf("v%d", index), SecretString: versions[index]}, nil
+}
+
+func TestSecretCache_GetAndPinning(t *testing.T) {
+	fetcher := &fakeFetcher{versions: map[string][]string{}}
+	fetcher.put("dev/axal", "first")
+	fetcher.put("dev/axal", "second")
+	cache := NewSecretCache(fetcher, 0, 0)
+
+	tests := []struct {
+		name      string
+		ref       SecretRef
+		wantValue string
+	}{
+		{"current", SecretRef{Name: "dev/axal"}, "second"},
+		{"version stage", SecretRef{Name: "dev/axal", VersionStage: "AWSPREVIOUS"}, "first"},
+		{"version id", SecretRef{Name: "dev/axal", VersionId: "v0"}, "first"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := cache.Get(context.Background(), tt.ref)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if secret.Value != tt.wantValue {
+				t.Errorf("Get() = %s, want %s", secret.Value, tt.wantValue)
+			}
+		})
+	}
+
+	fetches := fetcher.fetches
+	if _, err := cache.Get(context.Background(), SecretRef{Name: "dev/axal"}); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if fetcher.fetches != fetches {
+		t.Error("Get() of a cached secret fetched it again")
+	}
+
+	if _, err := cache.Get(context.Background(), SecretRef{Name: "missing"}); err == nil {
+		t.Error("Get() of a missing secret should fail")
+	}
+
+	// Secrets pinned to a version id are never refreshed
+	fetcher.put("dev/axal", "third")
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	pinned, _ := cache.Get(context.Background(), SecretRef{Name: "dev/axal", VersionId: "v0"})
+	current, _ := cache.Get(context.Background(), SecretRef{Name: "dev/axal"})
+	if pinned.Value != "first" || current.Value != "third" {
+		t.Errorf("after Refresh() pinned = %s, current = %s, want first and third", pinned.Value, current.Value)
+	}
+}
+
+func TestSecretCache_RefreshNotifiesAndGrace(t *testing.T) {
+	fetcher := &fakeFetcher{versions: map[string][]string{}}
+	fetcher.put("dev/privy", "old")
+
+	now := time.Now()
+	cache := NewSecretCache(fetcher, time.Minute, 10*time.Minute)
+	cache.now = func() time.Time { return now }
+
+	ref := SecretRef{Name: "dev/privy"}
+	var updates []SecretUpdate
+	if err := cache.Subscribe(context.Background(), ref, func(update SecretUpdate) {
+		updates = append(updates, update)
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	// Nothing changed, nobody is notified
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("Refresh() without a change notified %d times", len(updates))
+	}
+
+	fetcher.put("dev/privy", "new")
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("Refresh() notified %d times, want 1", len(updates))
+	}
+	update := updates[0]
+	if update.Current.Value != "new" || update.Previous == nil || update.Previous.Value != "old" {
+		t.Errorf("update = %+v, want old to new", update)
+	}
+	if !update.PreviousValidUntil.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("PreviousValidUntil = %s, want the end of the grace window", update.PreviousValidUntil)
+	}
+
+	if previous, ok := cache.Previous(ref); !ok || previous.Value != "old" {
+		t.Error("Previous() should return the old version inside the grace window")
+	}
+	now = now.Add(11 * time.Minute)
+	if _, ok := cache.Previous(ref); ok {
+		t.Error("Previous() should not return the old version after the grace window")
+	}
+
+	// A failed refresh keeps the cached version
+	fetcher.failing = true
+	if err := cache.Refresh(context.Background()); err == nil {
+		t.Error("Refresh() should report fetch failures")
+	}
+	if secret, _ := cache.Get(context.Background(), ref); secret.Value != "new" {
+		t.Errorf("Get() after a failed refresh = %s, want new", secret.Value)
+	}
+}
+
+func TestSecretCache_Start(t *testing.T) {
+	fetcher := &fakeFetcher{versions: map[string][]string{}}
+	fetcher.put("dev/axal", "old")
+	cache := NewSecretCache(fetcher, 10*time.Millisecond, time.Minute)
+
+	rotated := make(chan SecretUpdate, 1)
+	err := cache.Subscribe(context.Background(), SecretRef{Name: "dev/axal"}, func(update SecretUpdate) {
+		rotated <- update
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache.Start(ctx)
+
+	fetcher.put("dev/axal", "new")
+	select {
+	case update := <-rotated:
+		if update.Current.Value != "new" {
+			t.Errorf("background refresh got %s, want new", update.Current.Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("background refresh did not pick up the rotated secret")
+	}
+}
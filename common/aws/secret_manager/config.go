@@ -3,8 +3,7 @@ package secretmananger
 import "github.com/getaxal/verified-signer/common/aws"
 
 type SecretManagerConfig struct {
-	Credentials aws.AWSCredentials
-	Region      aws.AWSRegion
+	Region aws.AWSRegion
 }
 
 func (cfg *SecretManagerConfig) GetSecretManagerEndpoint() string {
@@ -0,0 +1,477 @@
+package secretmananger
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/getaxal/verified-signer/common/redact"
+)
+
+var (
+	ErrMissingField = errors.New("missing required field")
+	ErrPathNotFound = errors.New("path not found")
+)
+
+// DecodeError says which field of a JSON secret could not be decoded. It never carries the value of the field, the value is a
+// secret.
+type DecodeError struct {
+	Path string // JSON path of the field, eg. privy.app_id or keys[0]
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("field %s: %v", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Value returns the secret, SecretString for string secrets and SecretBinary for binary secrets
+func (r *GetSecretValueResponse) Value() []byte {
+	if r.SecretString != "" {
+		return []byte(r.SecretString)
+	}
+	return r.SecretBinary
+}
+
+// GetSecretJSON fetches the current version of a JSON secret and decodes the value at path into T, see DecodeJSON
+func GetSecretJSON[T any](ctx context.Context, provider SecretProvider, secretName string, path string) (*T, error) {
+	resp, err := provider.GetSecretVersion(ctx, secretName, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch secret %s: %w", secretName, err)
+	}
+
+	value, err := DecodeJSON[T](resp.Value(), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret %s: %w", secretName, err)
+	}
+	return value, nil
+}
+
+// DecodeSecret decodes the value at path of a cached JSON secret into T, see DecodeJSON
+func DecodeSecret[T any](secret Secret, path string) (*T, error) {
+	value, err := DecodeJSON[T]([]byte(secret.Value), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret %s: %w", secret.Name, err)
+	}
+	return value, nil
+}
+
+// DecodeJSON decodes the value at path in a JSON document into T. The path is a dot separated list of object keys with optional
+// array indexes, eg. privy.keys[0].id, and an empty path is the whole document.
+//
+// Decoding is strict about types but coerces strings holding numbers or booleans into numeric and boolean fields, since secrets
+// edited in the console often quote them. Struct fields are matched by their json tag like encoding/json, fields tagged
+// `validate:"required"` must be present and not empty. Errors are DecodeErrors naming the field at fault. Fields tagged
+// `secret:"true"` are registered with redact so they never show up in logs.
+func DecodeJSON[T any](data []byte, path string) (*T, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, &DecodeError{Err: fmt.Errorf("invalid json: %w", err)}
+	}
+	if dec.More() {
+		return nil, &DecodeError{Err: errors.New("invalid json: data after the top level value")}
+	}
+
+	node, err := extractPath(doc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var out T
+	if err := decodeValue(reflect.ValueOf(&out).Elem(), node, path); err != nil {
+		return nil, err
+	}
+
+	// Keep the fields tagged secret out of the logs from now on
+	redact.RegisterTagged(&out)
+
+	return &out, nil
+}
+
+// Walks path down doc
+func extractPath(doc any, path string) (any, error) {
+	node := doc
+	walked := ""
+
+	for _, part := range splitPath(path) {
+		switch n := node.(type) {
+		case map[string]any:
+			if part.index >= 0 {
+				return nil, &DecodeError{Path: walked, Err: fmt.Errorf("%w: want array, got object", ErrPathNotFound)}
+			}
+			walked = joinPath(walked, part.key)
+			child, ok := n[part.key]
+			if !ok {
+				return nil, &DecodeError{Path: walked, Err: ErrPathNotFound}
+			}
+			node = child
+		case []any:
+			if part.index < 0 {
+				return nil, &DecodeError{Path: walked, Err: fmt.Errorf("%w: want object, got array", ErrPathNotFound)}
+			}
+			walked = fmt.Sprintf("%s[%d]", walked, part.index)
+			if part.index >= len(n) {
+				return nil, &DecodeError{Path: walked, Err: ErrPathNotFound}
+			}
+			node = n[part.index]
+		default:
+			return nil, &DecodeError{Path: walked, Err: fmt.Errorf("%w: want object or array, got %s", ErrPathNotFound, jsonType(node))}
+		}
+	}
+
+	return node, nil
+}
+
+type pathPart struct {
+	key   string
+	index int // -1 for object keys
+}
+
+// Splits a path like a.b[0][1].c into its keys and indexes. Anything in brackets that is not an index is used as a key so keys
+// with dots can be written as a["b.c"].
+func splitPath(path string) []pathPart {
+	var parts []pathPart
+	var key strings.Builder
+
+	flush := func() {
+		if key.Len() > 0 {
+			parts = append(parts, pathPart{key: key.String(), index: -1})
+			key.Reset()
+		}
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '.':
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				end = len(path) - i
+			}
+			inner := path[i+1 : i+end]
+			if n, err := strconv.Atoi(inner); err == nil && n >= 0 {
+				parts = append(parts, pathPart{index: n})
+			} else {
+				parts = append(parts, pathPart{key: strings.Trim(inner, `"'`), index: -1})
+			}
+			i += end
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+	flush()
+
+	return parts
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Decodes node, as decoded by a json.Decoder with UseNumber, into v
+func decodeValue(v reflect.Value, node any, path string) error {
+	if node == nil {
+		// null leaves the zero value like encoding/json
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeValue(v.Elem(), node, path)
+	}
+
+	if reflect.PointerTo(v.Type()).Implements(jsonUnmarshalerType) {
+		// Types with their own decoding, like json.RawMessage or time.Time
+		raw, err := json.Marshal(node)
+		if err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		if err := v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(raw); err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		return nil
+	}
+
+	if s, ok := node.(string); ok && reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return &DecodeError{Path: path, Err: fmt.Errorf("can not decode into %s", v.Type())}
+		}
+		v.Set(reflect.ValueOf(node))
+		return nil
+
+	case reflect.String:
+		s, ok := node.(string)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		v.SetString(s)
+		return nil
+
+	case reflect.Bool:
+		switch n := node.(type) {
+		case bool:
+			v.SetBool(n)
+			return nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(n))
+			if err != nil {
+				return mistyped(path, v, node)
+			}
+			v.SetBool(b)
+			return nil
+		}
+		return mistyped(path, v, node)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s, ok := numberString(node)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return numberError(path, v, err)
+		}
+		v.SetInt(i)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s, ok := numberString(node)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return numberError(path, v, err)
+		}
+		v.SetUint(u)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		s, ok := numberString(node)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return numberError(path, v, err)
+		}
+		v.SetFloat(f)
+		return nil
+
+	case reflect.Struct:
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		return decodeStruct(v, obj, path)
+
+	case reflect.Map:
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return &DecodeError{Path: path, Err: fmt.Errorf("can not decode into %s, map keys must be strings", v.Type())}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(obj)))
+		}
+		for key, child := range obj {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(elem, child, joinPath(path, key)); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		return nil
+
+	case reflect.Slice:
+		// Byte slices are base64 strings like encoding/json
+		if s, ok := node.(string); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return &DecodeError{Path: path, Err: errors.New("invalid base64")}
+			}
+			v.SetBytes(b)
+			return nil
+		}
+
+		arr, ok := node.([]any)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(arr), len(arr))
+		for i, child := range arr {
+			if err := decodeValue(slice.Index(i), child, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+
+	case reflect.Array:
+		arr, ok := node.([]any)
+		if !ok {
+			return mistyped(path, v, node)
+		}
+		if len(arr) != v.Len() {
+			return &DecodeError{Path: path, Err: fmt.Errorf("want %d elements, got %d", v.Len(), len(arr))}
+		}
+		for i, child := range arr {
+			if err := decodeValue(v.Index(i), child, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return &DecodeError{Path: path, Err: fmt.Errorf("can not decode into %s", v.Type())}
+}
+
+func hasUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// Decodes obj into the fields of struct v, embedded structs without a json name are flattened like encoding/json
+func decodeStruct(v reflect.Value, obj map[string]any, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := v.Field(i)
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					embedded.Set(reflect.New(field.Type.Elem()))
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := decodeStruct(embedded, obj, path); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := joinPath(path, name)
+
+		// An absent struct is decoded as an empty object so its own required fields are reported
+		child, ok := lookupKey(obj, name)
+		if !ok && field.Type.Kind() == reflect.Struct && !hasUnmarshaler(field.Type) {
+			child, ok = map[string]any{}, true
+		}
+		if ok {
+			if err := decodeValue(v.Field(i), child, fieldPath); err != nil {
+				return err
+			}
+		}
+
+		if field.Tag.Get("validate") == "required" && v.Field(i).IsZero() {
+			return &DecodeError{Path: fieldPath, Err: ErrMissingField}
+		}
+	}
+	return nil
+}
+
+// Finds the key for a field, preferring an exact match and then a case insensitive one like encoding/json
+func lookupKey(obj map[string]any, name string) (any, bool) {
+	if child, ok := obj[name]; ok {
+		return child, true
+	}
+	for key, child := range obj {
+		if strings.EqualFold(key, name) {
+			return child, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the text of a JSON number, or of a string holding one
+func numberString(node any) (string, bool) {
+	switch n := node.(type) {
+	case json.Number:
+		return n.String(), true
+	case string:
+		s := strings.TrimSpace(n)
+		return s, s != ""
+	}
+	return "", false
+}
+
+func numberError(path string, v reflect.Value, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return &DecodeError{Path: path, Err: fmt.Errorf("value out of range for %s", v.Type())}
+	}
+	return &DecodeError{Path: path, Err: fmt.Errorf("want %s, got a value that is not one", v.Type())}
+}
+
+func mistyped(path string, v reflect.Value, node any) error {
+	return &DecodeError{Path: path, Err: fmt.Errorf("want %s, got %s", v.Type(), jsonType(node))}
+}
+
+// Name of the JSON type of node, used in errors instead of the value so secrets are not leaked
+func jsonType(node any) string {
+	switch node.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", node)
+}
@@ -0,0 +1,275 @@
+package secretmananger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testPorts struct {
+	SecretManagerPort uint32 `json:"aws_secret_manager_vsock_port" validate:"required"`
+	RouterPort        int    `json:"router_vsock_port"`
+}
+
+type testConfig struct {
+	AppID   string            `json:"app_id" validate:"required"`
+	Enabled bool              `json:"enabled"`
+	Ratio   float64           `json:"ratio"`
+	Ports   testPorts         `json:"ports"`
+	Keys    []string          `json:"keys"`
+	Labels  map[string]string `json:"labels"`
+	Limit   *int64            `json:"limit"`
+	Raw     json.RawMessage   `json:"raw"`
+	Ignored string            `json:"-"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		wantErr   string
+		wantPath  string
+		wantIs    error
+		checkFunc func(t *testing.T, got *testConfig)
+	}{
+		{
+			name: "typed values",
+			json: `{"app_id": "app", "enabled": true, "ratio": 0.5, "ports": {"aws_secret_manager_vsock_port": 50001, "router_vsock_port": 50003}, "keys": ["a", "b"], "labels": {"team": "axal"}, "limit": 10, "raw": {"x": 1}}`,
+			checkFunc: func(t *testing.T, got *testConfig) {
+				if got.AppID != "app" || !got.Enabled || got.Ratio != 0.5 || got.Ports.SecretManagerPort != 50001 || got.Ports.RouterPort != 50003 {
+					t.Errorf("DecodeJSON() = %+v", got)
+				}
+				if len(got.Keys) != 2 || got.Labels["team"] != "axal" || got.Limit == nil || *got.Limit != 10 || string(got.Raw) != `{"x":1}` {
+					t.Errorf("DecodeJSON() = %+v", got)
+				}
+			},
+		},
+		{
+			name: "numbers and booleans in strings are coerced",
+			json: `{"app_id": "app", "enabled": "true", "ratio": "0.25", "ports": {"aws_secret_manager_vsock_port": "50001", "router_vsock_port": " 50003 "}, "limit": "7"}`,
+			checkFunc: func(t *testing.T, got *testConfig) {
+				if !got.Enabled || got.Ratio != 0.25 || got.Ports.SecretManagerPort != 50001 || got.Ports.RouterPort != 50003 || *got.Limit != 7 {
+					t.Errorf("DecodeJSON() = %+v", got)
+				}
+			},
+		},
+		{
+			name: "case insensitive keys and unknown keys",
+			json: `{"APP_ID": "app", "extra": 1, "ports": {"aws_secret_manager_vsock_port": 1}}`,
+			checkFunc: func(t *testing.T, got *testConfig) {
+				if got.AppID != "app" {
+					t.Errorf("DecodeJSON() AppID = %s, want app", got.AppID)
+				}
+			},
+		},
+		{
+			name:     "missing required field",
+			json:     `{"ports": {"aws_secret_manager_vsock_port": 1}}`,
+			wantPath: "app_id",
+			wantIs:   ErrMissingField,
+		},
+		{
+			name:     "empty required field",
+			json:     `{"app_id": "", "ports": {"aws_secret_manager_vsock_port": 1}}`,
+			wantPath: "app_id",
+			wantIs:   ErrMissingField,
+		},
+		{
+			name:     "missing nested required field",
+			json:     `{"app_id": "app", "ports": {}}`,
+			wantPath: "ports.aws_secret_manager_vsock_port",
+			wantIs:   ErrMissingField,
+		},
+		{
+			name:     "mistyped string",
+			json:     `{"app_id": 12, "ports": {"aws_secret_manager_vsock_port": 1}}`,
+			wantPath: "app_id",
+			wantErr:  "want string, got number",
+		},
+		{
+			name:     "mistyped number",
+			json:     `{"app_id": "app", "ports": {"aws_secret_manager_vsock_port": "vsock-port"}}`,
+			wantPath: "ports.aws_secret_manager_vsock_port",
+			wantErr:  "want uint32",
+		},
+		{
+			name:     "number out of range",
+			json:     `{"app_id": "app", "ports": {"aws_secret_manager_vsock_port": 4294967296}}`,
+			wantPath: "ports.aws_secret_manager_vsock_port",
+			wantErr:  "out of range",
+		},
+		{
+			name:     "negative unsigned number",
+			json:     `{"app_id": "app", "ports": {"aws_secret_manager_vsock_port": -1}}`,
+			wantPath: "ports.aws_secret_manager_vsock_port",
+		},
+		{
+			name:     "fractional int",
+			json:     `{"app_id": "app", "ports": {"aws_secret_manager_vsock_port": 1, "router_vsock_port": 1.5}}`,
+			wantPath: "ports.router_vsock_port",
+		},
+		{
+			name:     "mistyped bool",
+			json:     `{"app_id": "app", "enabled": "yes please", "ports": {"aws_secret_manager_vsock_port": 1}}`,
+			wantPath: "enabled",
+			wantErr:  "want bool, got string",
+		},
+		{
+			name:     "mistyped array element",
+			json:     `{"app_id": "app", "keys": ["a", 2], "ports": {"aws_secret_manager_vsock_port": 1}}`,
+			wantPath: "keys[1]",
+			wantErr:  "want string, got number",
+		},
+		{
+			name:     "mistyped object",
+			json:     `{"app_id": "app", "ports": [1]}`,
+			wantPath: "ports",
+			wantErr:  "want secretmananger.testPorts, got array",
+		},
+		{
+			name:    "invalid json",
+			json:    `{"app_id": `,
+			wantErr: "invalid json",
+		},
+		{
+			name:    "trailing data",
+			json:    `{"app_id": "app"} {}`,
+			wantErr: "invalid json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeJSON[testConfig]([]byte(tt.json), "")
+			if tt.checkFunc != nil {
+				if err != nil {
+					t.Fatalf("DecodeJSON() error = %v", err)
+				}
+				tt.checkFunc(t, got)
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("DecodeJSON() = %+v, want error", got)
+			}
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("DecodeJSON() error = %v, want a DecodeError", err)
+			}
+			if decodeErr.Path != tt.wantPath {
+				t.Errorf("DecodeError.Path = %q, want %q", decodeErr.Path, tt.wantPath)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("DecodeJSON() error = %v, want %v", err, tt.wantIs)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecodeJSON() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON_ErrorsDoNotLeakValues(t *testing.T) {
+	_, err := DecodeJSON[testConfig]([]byte(`{"app_id": "app", "enabled": "hunter2", "ports": {"aws_secret_manager_vsock_port": 1}}`), "")
+	if err == nil || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("DecodeJSON() error = %v, want an error without the value", err)
+	}
+}
+
+func TestDecodeJSON_Path(t *testing.T) {
+	doc := []byte(`{"privy": {"apps": [{"app_id": "first"}, {"app_id": "second", "ports": {"aws_secret_manager_vsock_port": "1"}}]}, "dotted.key": {"n": 3}}`)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantErr  bool
+		wantPath string
+		want     string
+	}{
+		{name: "nested array element", path: "privy.apps[1]", want: "second"},
+		{name: "missing key", path: "privy.wallets", wantErr: true, wantPath: "privy.wallets"},
+		{name: "index out of range", path: "privy.apps[2]", wantErr: true, wantPath: "privy.apps[2]"},
+		{name: "index into object", path: "privy[0]", wantErr: true, wantPath: "privy"},
+		{name: "key into array", path: "privy.apps.app_id", wantErr: true, wantPath: "privy.apps"},
+		{name: "key into string", path: "privy.apps[0].app_id.x", wantErr: true, wantPath: "privy.apps[0].app_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeJSON[testConfig](doc, tt.path)
+			if tt.wantErr {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) || !errors.Is(err, ErrPathNotFound) {
+					t.Fatalf("DecodeJSON() error = %v, want a path not found DecodeError", err)
+				}
+				if decodeErr.Path != tt.wantPath {
+					t.Errorf("DecodeError.Path = %q, want %q", decodeErr.Path, tt.wantPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeJSON() error = %v", err)
+			}
+			if got.AppID != tt.want {
+				t.Errorf("DecodeJSON() AppID = %q, want %q", got.AppID, tt.want)
+			}
+		})
+	}
+
+	// Keys with dots are written in brackets
+	n, err := DecodeJSON[int](doc, `["dotted.key"].n`)
+	if err != nil || *n != 3 {
+		t.Errorf("DecodeJSON() of a bracketed key = %v, %v, want 3", n, err)
+	}
+
+	// Errors below the path name the full path
+	_, err = DecodeJSON[testConfig](doc, "privy.apps[0]")
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Path != "privy.apps[0].ports.aws_secret_manager_vsock_port" {
+		t.Errorf("DecodeJSON() error = %v, want the missing port under privy.apps[0]", err)
+	}
+}
+
+func TestGetSecretJSON_Binary(t *testing.T) {
+	var resp GetSecretValueResponse
+	err := json.Unmarshal([]byte(`{"Name": "dev/axal", "SecretBinary": "eyJwb3J0cyI6IHsicm91dGVyX3Zzb2NrX3BvcnQiOiAiNTAwMDMifX0=", "VersionId": "v1"}`), &resp)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if string(resp.Value()) != `{"ports": {"router_vsock_port": "50003"}}` {
+		t.Fatalf("Value() = %q, want the decoded SecretBinary", resp.Value())
+	}
+
+	provider := &staticResponseProvider{resp: &resp}
+	ports, err := GetSecretJSON[testPorts](context.Background(), provider, "dev/axal", "ports")
+	if err == nil || !errors.Is(err, ErrMissingField) {
+		t.Errorf("GetSecretJSON() error = %v, want the missing required port", err)
+	}
+
+	router, err := GetSecretJSON[int](context.Background(), provider, "dev/axal", "ports.router_vsock_port")
+	if err != nil {
+		t.Fatalf("GetSecretJSON() error = %v, ports = %+v", err, ports)
+	}
+	if *router != 50003 {
+		t.Errorf("GetSecretJSON() = %d, want 50003", *router)
+	}
+
+	cache := NewSecretCache(provider, 0, 0)
+	secret, err := cache.Get(context.Background(), SecretRef{Name: "dev/axal"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if _, err := DecodeSecret[map[string]any](secret, "ports"); err != nil {
+		t.Errorf("DecodeSecret() of a cached binary secret error = %v", err)
+	}
+}
+
+type staticResponseProvider struct {
+	resp *GetSecretValueResponse
+}
+
+func (p *staticResponseProvider) GetSecretVersion(ctx context.Context, secretName string, versionId string, versionStage string) (*GetSecretValueResponse, error) {
+	return p.resp, nil
+}
@@ -0,0 +1,198 @@
+package secretmananger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+
+	// Prefix of the environment variables EnvProvider reads secrets from when none is given
+	DefaultEnvPrefix = "AXAL_SECRET_"
+)
+
+// SecretProvider fetches a version of a secret. SecretManager fetches from AWS Secrets Manager, MemoryProvider, FileProvider and
+// EnvProvider stand in for it in local runs and tests.
+type SecretProvider interface {
+	GetSecretVersion(ctx context.Context, secretName string, versionId string, versionStage string) (*GetSecretValueResponse, error)
+}
+
+// MemoryProvider keeps every version of its secrets in memory, the last version put is AWSCURRENT and the one before it
+// AWSPREVIOUS
+type MemoryProvider struct {
+	mu       sync.RWMutex
+	versions map[string][]string
+}
+
+// Creates a new MemoryProvider seeded with a single version of each of secrets
+func NewMemoryProvider(secrets map[string]string) *MemoryProvider {
+	p := &MemoryProvider{versions: make(map[string][]string)}
+	for name, value := range secrets {
+		p.Put(name, value)
+	}
+	return p
+}
+
+// Put adds a new version of a secret, making it AWSCURRENT, and returns its version id
+func (p *MemoryProvider) Put(secretName string, value string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.versions[secretName] = append(p.versions[secretName], value)
+	return strconv.Itoa(len(p.versions[secretName]))
+}
+
+func (p *MemoryProvider) GetSecretVersion(ctx context.Context, secretName string, versionId string, versionStage string) (*GetSecretValueResponse, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	versions := p.versions[secretName]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("secret %s not found", secretName)
+	}
+
+	index := len(versions) - 1
+	if versionId != "" {
+		n, err := strconv.Atoi(versionId)
+		if err != nil || n < 1 || n > len(versions) {
+			return nil, fmt.Errorf("version %s of secret %s not found", versionId, secretName)
+		}
+		index = n - 1
+	}
+
+	switch versionStage {
+	case "":
+	case StageCurrent:
+		if index != len(versions)-1 {
+			return nil, fmt.Errorf("version %s of secret %s is not %s", versionId, secretName, StageCurrent)
+		}
+	case StagePrevious:
+		if versionId == "" {
+			index--
+		}
+		if index != len(versions)-2 {
+			return nil, fmt.Errorf("secret %s has no %s version", secretName, StagePrevious)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported version stage %s", versionStage)
+	}
+
+	var stages []string
+	if index == len(versions)-1 {
+		stages = []string{StageCurrent}
+	} else if index == len(versions)-2 {
+		stages = []string{StagePrevious}
+	}
+
+	return &GetSecretValueResponse{
+		Name:          secretName,
+		SecretString:  versions[index],
+		VersionId:     strconv.Itoa(index + 1),
+		VersionStages: stages,
+	}, nil
+}
+
+// FileProvider reads secrets from a JSON file mapping secret names to values. String values are used as they are, any other
+// value is used as its JSON encoding so a JSON secret can be written inline. The file is read on every fetch so edits are picked
+// up by the secret cache.
+type FileProvider struct {
+	Path string
+}
+
+// Creates a new FileProvider reading from path
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{Path: path}
+}
+
+func (p *FileProvider) GetSecretVersion(ctx context.Context, secretName string, versionId string, versionStage string) (*GetSecretValueResponse, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file %s: %w", p.Path, err)
+	}
+
+	var secrets map[string]json.RawMessage
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file %s: %w", p.Path, err)
+	}
+
+	raw, ok := secrets[secretName]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found in %s", secretName, p.Path)
+	}
+
+	value := string(raw)
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		value = str
+	}
+
+	return currentVersion(secretName, value, versionId, versionStage)
+}
+
+// EnvProvider reads secrets from environment variables. The variable for a secret is its name upper cased with every character
+// that is not a letter or digit replaced by an underscore, after the prefix, so dev/privy is read from AXAL_SECRET_DEV_PRIVY.
+type EnvProvider struct {
+	Prefix string
+}
+
+// Creates a new EnvProvider, an empty prefix uses DefaultEnvPrefix
+func NewEnvProvider(prefix string) *EnvProvider {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	return &EnvProvider{Prefix: prefix}
+}
+
+// EnvVar returns the environment variable secretName is read from
+func (p *EnvProvider) EnvVar(secretName string) string {
+	var b strings.Builder
+	b.WriteString(p.Prefix)
+	for _, r := range strings.ToUpper(secretName) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func (p *EnvProvider) GetSecretVersion(ctx context.Context, secretName string, versionId string, versionStage string) (*GetSecretValueResponse, error) {
+	name := p.EnvVar(secretName)
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found, %s is not set", secretName, name)
+	}
+
+	return currentVersion(secretName, value, versionId, versionStage)
+}
+
+// Builds the response for providers that only hold the current version of a secret. The version id is derived from the value so
+// a changed value is seen as a rotation.
+func currentVersion(secretName string, value string, versionId string, versionStage string) (*GetSecretValueResponse, error) {
+	sum := sha256.Sum256([]byte(value))
+	id := hex.EncodeToString(sum[:16])
+
+	if versionId != "" && versionId != id {
+		return nil, fmt.Errorf("version %s of secret %s not found", versionId, secretName)
+	}
+	if versionStage != "" && versionStage != StageCurrent {
+		return nil, fmt.Errorf("secret %s has no %s version", secretName, versionStage)
+	}
+
+	return &GetSecretValueResponse{
+		Name:          secretName,
+		SecretString:  value,
+		VersionId:     id,
+		VersionStages: []string{StageCurrent},
+	}, nil
+}
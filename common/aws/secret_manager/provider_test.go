@@ -0,0 +1,157 @@
+package secretmananger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	_ SecretProvider = (*SecretManager)(nil)
+	_ SecretProvider = (*MemoryProvider)(nil)
+	_ SecretProvider = (*FileProvider)(nil)
+	_ SecretProvider = (*EnvProvider)(nil)
+)
+
+func TestMemoryProvider_Versions(t *testing.T) {
+	p := NewMemoryProvider(map[string]string{"dev/axal": "first"})
+	second := p.Put("dev/axal", "second")
+
+	tests := []struct {
+		name      string
+		versionId string
+		stage     string
+		want      string
+		wantErr   bool
+	}{
+		{name: "current by default", want: "second"},
+		{name: "current by stage", stage: StageCurrent, want: "second"},
+		{name: "previous by stage", stage: StagePrevious, want: "first"},
+		{name: "pinned version", versionId: "1", want: "first"},
+		{name: "pinned current version", versionId: second, stage: StageCurrent, want: "second"},
+		{name: "pinned version not at stage", versionId: "1", stage: StageCurrent, wantErr: true},
+		{name: "unknown version", versionId: "3", wantErr: true},
+		{name: "unknown stage", stage: "AWSPENDING", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := p.GetSecretVersion(context.Background(), "dev/axal", tt.versionId, tt.stage)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetSecretVersion() = %q, want error", resp.SecretString)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetSecretVersion() error = %v", err)
+			}
+			if resp.SecretString != tt.want {
+				t.Errorf("GetSecretVersion() = %q, want %q", resp.SecretString, tt.want)
+			}
+		})
+	}
+
+	if _, err := p.GetSecretVersion(context.Background(), "dev/privy", "", ""); err == nil {
+		t.Error("GetSecretVersion() of a missing secret should fail")
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	err := os.WriteFile(path, []byte(`{"dev/axal": {"axal_request_secret_key": "key"}, "dev/token": "plain"}`), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	p := NewFileProvider(path)
+	ctx := context.Background()
+
+	resp, err := p.GetSecretVersion(ctx, "dev/axal", "", "")
+	if err != nil {
+		t.Fatalf("GetSecretVersion() error = %v", err)
+	}
+	if resp.SecretString != `{"axal_request_secret_key": "key"}` {
+		t.Errorf("object secret = %q, want its JSON encoding", resp.SecretString)
+	}
+
+	token, err := p.GetSecretVersion(ctx, "dev/token", "", "")
+	if err != nil {
+		t.Fatalf("GetSecretVersion() error = %v", err)
+	}
+	if token.SecretString != "plain" {
+		t.Errorf("string secret = %q, want plain", token.SecretString)
+	}
+
+	if _, err := p.GetSecretVersion(ctx, "dev/token", token.VersionId, StageCurrent); err != nil {
+		t.Errorf("GetSecretVersion() pinned to the current version error = %v", err)
+	}
+	if _, err := p.GetSecretVersion(ctx, "dev/token", "", StagePrevious); err == nil {
+		t.Error("GetSecretVersion() of AWSPREVIOUS should fail, files only hold the current version")
+	}
+	if _, err := p.GetSecretVersion(ctx, "dev/privy", "", ""); err == nil {
+		t.Error("GetSecretVersion() of a missing secret should fail")
+	}
+
+	// Edits are seen as a new version
+	if err := os.WriteFile(path, []byte(`{"dev/token": "rotated"}`), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	rotated, err := p.GetSecretVersion(ctx, "dev/token", "", "")
+	if err != nil {
+		t.Fatalf("GetSecretVersion() error = %v", err)
+	}
+	if rotated.SecretString != "rotated" || rotated.VersionId == token.VersionId {
+		t.Errorf("GetSecretVersion() after edit = %q version %s, want a new version", rotated.SecretString, rotated.VersionId)
+	}
+	if _, err := p.GetSecretVersion(ctx, "dev/token", token.VersionId, ""); err == nil {
+		t.Error("GetSecretVersion() pinned to a replaced version should fail")
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	p := NewEnvProvider("")
+	if got := p.EnvVar("dev/privy-app"); got != "AXAL_SECRET_DEV_PRIVY_APP" {
+		t.Errorf("EnvVar() = %s, want AXAL_SECRET_DEV_PRIVY_APP", got)
+	}
+
+	t.Setenv("AXAL_SECRET_DEV_PRIVY_APP", `{"app_id": "app"}`)
+
+	resp, err := p.GetSecretVersion(context.Background(), "dev/privy-app", "", "")
+	if err != nil {
+		t.Fatalf("GetSecretVersion() error = %v", err)
+	}
+	if resp.SecretString != `{"app_id": "app"}` {
+		t.Errorf("GetSecretVersion() = %q", resp.SecretString)
+	}
+
+	if _, err := p.GetSecretVersion(context.Background(), "dev/axal", "", ""); err == nil {
+		t.Error("GetSecretVersion() with the variable unset should fail")
+	}
+
+	custom := NewEnvProvider("TEST_")
+	if got := custom.EnvVar("prod/axal"); got != "TEST_PROD_AXAL" {
+		t.Errorf("EnvVar() = %s, want TEST_PROD_AXAL", got)
+	}
+}
+
+func TestSecretCache_WithMemoryProvider(t *testing.T) {
+	p := NewMemoryProvider(map[string]string{"dev/privy": "v1"})
+	cache := NewSecretCache(p, 0, 0)
+	ref := SecretRef{Name: "dev/privy"}
+
+	var updates []SecretUpdate
+	if err := cache.Subscribe(context.Background(), ref, func(u SecretUpdate) { updates = append(updates, u) }); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	p.Put("dev/privy", "v2")
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if len(updates) != 1 || updates[0].Current.Value != "v2" || updates[0].Previous.Value != "v1" {
+		t.Errorf("updates = %+v, want one rotation from v1 to v2", updates)
+	}
+}
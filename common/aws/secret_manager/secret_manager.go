@@ -3,39 +3,36 @@ package secretmananger
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"sort"
-	"strings"
 	"time"
 
 	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+	"github.com/getaxal/verified-signer/common/aws/imds"
+	"github.com/getaxal/verified-signer/common/aws/sigv4"
 	"github.com/getaxal/verified-signer/common/network"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
-	algorithm       = "AWS4-HMAC-SHA256"
-	service         = "secretsmanager"
-	terminationChar = "aws4_request"
-	// EC2 metadata endpoints for IAM role credentials
-	ec2MetadataTokenURL = "http://169.254.169.254/latest/api/token"
-	ec2MetadataRoleURL  = "http://169.254.169.254/latest/meta-data/iam/security-credentials/"
+	service = "secretsmanager"
 )
 
 type SecretManager struct {
-	SmClient             *http.Client
-	EC2CredentialsClient *http.Client
-	Config               *SecretManagerConfig
-	Environment          string // "local", "dev", or "prod"
+	SmClient    *http.Client
+	Credentials credentials.Provider
+	Config      *SecretManagerConfig
+	Environment string // "local", "dev", or "prod"
 }
 
 // GetSecretValueRequest represents the request payload
 type GetSecretValueRequest struct {
-	SecretId string `json:"SecretId"`
+	SecretId     string `json:"SecretId"`
+	VersionId    string `json:"VersionId,omitempty"`
+	VersionStage string `json:"VersionStage,omitempty"`
 }
 
 // GetSecretValueResponse represents the API response
@@ -43,226 +40,112 @@ type GetSecretValueResponse struct {
 	ARN           string   `json:"ARN"`
 	Name          string   `json:"Name"`
 	SecretString  string   `json:"SecretString"`
+	SecretBinary  []byte   `json:"SecretBinary,omitempty"` // Set instead of SecretString for binary secrets, base64 in the API
 	VersionId     string   `json:"VersionId"`
 	VersionStages []string `json:"VersionStages"`
 }
 
-// EC2Credentials represents temporary credentials from EC2 metadata
-type EC2Credentials struct {
-	AccessKeyId     string    `json:"AccessKeyId"`
-	SecretAccessKey string    `json:"SecretAccessKey"`
-	Token           string    `json:"Token"`
-	Expiration      time.Time `json:"Expiration"`
-}
-
 // Creates a new Secret Manager instance with specified environment
-// environment should be "dev", "prod", or "local"
-func NewSecretManager(cfgPath string, environment string, smPort uint32, ec2Port uint32) (*SecretManager, error) {
-	sm := &SecretManager{
-		EC2CredentialsClient: network.InitHttpClientWithVsockTransportEnclave(ec2Port),
-		Environment:          environment,
-	}
-
-	creds, err := sm.getCredentials(cfgPath)
+// environment should be "dev", "prod", or "local". region is the region of the Secrets Manager endpoint, if empty the region
+// of the credentials is used and then us-east-2.
+func NewSecretManager(cfgPath string, environment string, region aws.AWSRegion, smPort uint32, ec2Port uint32) (*SecretManager, error) {
+	provider, err := NewCredentialsProvider(cfgPath, environment, ec2Port)
 	if err != nil {
 		log.Errorf("Unable to create a SM manager with err: %v", err)
 		return nil, fmt.Errorf("Unable to create a New Secrets Manager Client")
 	}
 
-	sm.Config = &SecretManagerConfig{
-		Credentials: *creds,
-		Region:      creds.Region,
-	}
-
-	// Default to us east 2
-	if sm.Config.Region == "" {
-		sm.Config.Region = aws.USEast2
-		sm.Config.Credentials.Region = aws.USEast2
-	}
-
-	sm.SmClient = network.InitHttpsClientWithTLSVsockTransport(smPort, fmt.Sprintf("secretsmanager.%s.amazonaws.com", sm.Config.Region.String()))
-
-	return sm, nil
-
+	return NewSecretManagerWithProvider(provider, environment, region, smPort)
 }
 
-// getEC2Credentials fetches temporary credentials from EC2 instance metadata
-func (sm *SecretManager) getEC2Credentials() (*aws.AWSCredentials, error) {
-	// Get IMDSv2 token for secure access
-	tokenReq, err := http.NewRequest("PUT", ec2MetadataTokenURL, http.NoBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create token request: %w", err)
-	}
-	tokenReq.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
-
-	tokenResp, err := sm.EC2CredentialsClient.Do(tokenReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get metadata token: %w", err)
-	}
-	defer tokenResp.Body.Close()
-
-	if tokenResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get metadata token, status: %d", tokenResp.StatusCode)
-	}
-
-	token, err := io.ReadAll(tokenResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata token: %w", err)
-	}
-
-	// Get IAM role name
-	roleReq, err := http.NewRequest("GET", ec2MetadataRoleURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create role request: %w", err)
+// Creates a new Secret Manager instance that signs its requests with credentials from provider
+func NewSecretManagerWithProvider(provider credentials.Provider, environment string, region aws.AWSRegion, smPort uint32) (*SecretManager, error) {
+	sm := &SecretManager{
+		Credentials: provider,
+		Environment: environment,
 	}
-	roleReq.Header.Set("X-aws-ec2-metadata-token", string(token))
 
-	roleResp, err := sm.EC2CredentialsClient.Do(roleReq)
+	// Fetch credentials up front so a misconfigured instance fails on startup
+	creds, err := provider.Retrieve(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get IAM role: %w", err)
+		log.Errorf("Unable to create a SM manager with err: %v", err)
+		return nil, fmt.Errorf("Unable to create a New Secrets Manager Client")
 	}
-	defer roleResp.Body.Close()
 
-	if roleResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get IAM role, status: %d", roleResp.StatusCode)
+	if region == "" {
+		region = creds.Region
 	}
 
-	roleName, err := io.ReadAll(roleResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read IAM role name: %w", err)
+	// Default to us east 2
+	if region == "" {
+		region = aws.USEast2
 	}
 
-	// Get temporary credentials for the role
-	credReq, err := http.NewRequest("GET", ec2MetadataRoleURL+string(roleName), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create credentials request: %w", err)
+	if !region.IsValid() {
+		return nil, fmt.Errorf("invalid AWS region: %s", region)
 	}
-	credReq.Header.Set("X-aws-ec2-metadata-token", string(token))
 
-	credResp, err := sm.EC2CredentialsClient.Do(credReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	sm.Config = &SecretManagerConfig{
+		Region: region,
 	}
-	defer credResp.Body.Close()
 
-	if credResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get credentials, status: %d", credResp.StatusCode)
-	}
+	sm.SmClient = network.InitHttpsClientWithTLSVsockTransport(smPort, fmt.Sprintf("secretsmanager.%s.amazonaws.com", sm.Config.Region.String()))
 
-	var ec2Creds EC2Credentials
-	if err := json.NewDecoder(credResp.Body).Decode(&ec2Creds); err != nil {
-		return nil, fmt.Errorf("failed to decode credentials: %w", err)
-	}
+	return sm, nil
 
-	return &aws.AWSCredentials{
-		AccessKey:    ec2Creds.AccessKeyId,
-		AccessSecret: ec2Creds.SecretAccessKey,
-		SessionToken: ec2Creds.Token,
-		Region:       aws.USEast2,
-	}, nil
 }
 
-// getCredentials returns the appropriate credentials based on environment
-func (sm *SecretManager) getCredentials(cfgPath string) (*aws.AWSCredentials, error) {
-	switch sm.Environment {
+// NewCredentialsProvider returns the credentials provider for the environment. Deployed environments use the instance role
+// from IMDS through ec2Port, local uses the AWS environment variables if set and the config file otherwise.
+func NewCredentialsProvider(cfgPath string, environment string, ec2Port uint32) (credentials.Provider, error) {
+	switch environment {
 	case "dev", "prod", "staging":
 		// Use IAM role credentials from EC2 metadata
-		log.Info("Fetching ec2 credentials")
-		return sm.getEC2Credentials()
-	case "local":
-		creds, err := aws.NewAWSConfigFromYAML(cfgPath)
-		if err != nil {
-			return nil, fmt.Errorf("Unable to fetch local credentials")
+		log.Info("Using ec2 credentials")
+		return credentials.NewIMDSProvider(imds.NewVsockClient(ec2Port)), nil
+	default:
+		if credentials.EnvCredentialsSet() {
+			log.Info("Using aws credentials from the environment")
+			return credentials.NewEnvProvider(), nil
 		}
 
-		return &creds.AWSCredentials, nil
-	default:
-		creds, err := aws.NewAWSConfigFromYAML(cfgPath)
+		provider, err := credentials.NewStaticFileProvider(cfgPath)
 		if err != nil {
 			return nil, fmt.Errorf("Unable to fetch local credentials")
 		}
 
-		return &creds.AWSCredentials, nil
+		return provider, nil
 	}
 }
 
 // Use this function to sign the HTTP request to AWS. Uses AWS sig4 found here https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv.html.
 func (sm *SecretManager) signRequest(req *http.Request, payload string) error {
-	creds := sm.Config.Credentials
-
-	t := time.Now().UTC()
-	amzDate := t.Format("20060102T150405Z")
-	dateStamp := t.Format("20060102")
-
-	// Get host from URL
-	host := req.URL.Host
-	if host == "" {
-		host = req.Host
-	}
-
-	// Set required headers BEFORE creating canonical headers
-	req.Header.Set("Host", host)
-	req.Header.Set("X-Amz-Date", amzDate)
 	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
 	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
 
-	// Add session token if present (for IAM role credentials)
-	if creds.SessionToken != "" {
-		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
-	}
-
-	// Create canonical request
-	canonicalURI := req.URL.Path
-	if canonicalURI == "" {
-		canonicalURI = "/"
-	}
-	canonicalQueryString := req.URL.RawQuery
-
-	// Create canonical headers - MUST include Host
-	var headerNames []string
-	headerMap := make(map[string]string)
-	for name, values := range req.Header {
-		lowerName := strings.ToLower(name)
-		headerNames = append(headerNames, lowerName)
-		headerMap[lowerName] = strings.TrimSpace(strings.Join(values, ","))
-	}
-	sort.Strings(headerNames)
-
-	var canonicalHeaders strings.Builder
-	for _, name := range headerNames {
-		canonicalHeaders.WriteString(fmt.Sprintf("%s:%s\n", name, headerMap[name]))
+	creds, err := sm.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
 	}
 
-	signedHeaders := strings.Join(headerNames, ";")
-	payloadHash := sha256Hash(payload)
-
-	canonicalRequest := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s",
-		req.Method, canonicalURI, canonicalQueryString,
-		canonicalHeaders.String(), signedHeaders, payloadHash)
-
-	// Create string to sign
-	credentialScope := fmt.Sprintf("%s/%s/%s/%s", dateStamp, sm.Config.Region, service, terminationChar)
-	stringToSign := fmt.Sprintf("%s\n%s\n%s\n%s",
-		algorithm, amzDate, credentialScope, sha256Hash(canonicalRequest))
-
-	// Create signature using the obtained credentials
-	signingKey := createSignatureKey(creds.AccessSecret, dateStamp, sm.Config.Region.String(), service)
-	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
-
-	// Create authorization header
-	authorizationHeader := fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
-		algorithm, creds.AccessKey, credentialScope, signedHeaders, signature)
-
-	req.Header.Set("Authorization", authorizationHeader)
-	return nil
+	signer := sigv4.NewSigner(creds, sm.Config.Region, service)
+	return signer.Sign(req, sigv4.PayloadHash([]byte(payload)), time.Now())
 }
 
 // GetSecret retrieves a secret from AWS Secrets Manager with a given secretName by directly sending a request to the AWS HTTPS APIs.
 // We sign the request with AWS sig4.
 func (sm *SecretManager) GetSecret(ctx context.Context, secretName string) (*GetSecretValueResponse, error) {
+	return sm.GetSecretVersion(ctx, secretName, "", "")
+}
+
+// GetSecretVersion retrieves a specific version of a secret. versionId pins an exact version and versionStage a staging label
+// such as AWSCURRENT or AWSPREVIOUS, leave both empty for the current version.
+func (sm *SecretManager) GetSecretVersion(ctx context.Context, secretName string, versionId string, versionStage string) (*GetSecretValueResponse, error) {
 	// Prepare request payload
 	reqPayload := GetSecretValueRequest{
-		SecretId: secretName,
+		SecretId:     secretName,
+		VersionId:    versionId,
+		VersionStage: versionStage,
 	}
 	payloadBytes, err := json.Marshal(reqPayload)
 	if err != nil {
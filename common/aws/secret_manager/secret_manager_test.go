@@ -0,0 +1,62 @@
+package secretmananger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/getaxal/verified-signer/common/aws"
+)
+
+// Provider that hands out new credentials on every call, like a provider that has just renewed them
+type rotatingProvider struct {
+	calls int
+}
+
+func (p *rotatingProvider) Retrieve(ctx context.Context) (aws.AWSCredentials, error) {
+	p.calls++
+	return aws.AWSCredentials{AccessKey: fmt.Sprintf("ASIA%d", p.calls), AccessSecret: "secret"}, nil
+}
+
+func TestSignRequest_UsesCurrentCredentials(t *testing.T) {
+	provider := &rotatingProvider{}
+	sm, err := NewSecretManagerWithProvider(provider, "dev", "", 50001)
+	if err != nil {
+		t.Fatalf("NewSecretManagerWithProvider() error = %v", err)
+	}
+
+	if sm.Config.Region != aws.USEast2 {
+		t.Errorf("Region = %s, want default %s", sm.Config.Region, aws.USEast2)
+	}
+
+	euSm, err := NewSecretManagerWithProvider(provider, "dev", aws.EUWest1, 50001)
+	if err != nil {
+		t.Fatalf("NewSecretManagerWithProvider() error = %v", err)
+	}
+	if euSm.Config.GetSecretManagerEndpoint() != "https://secretsmanager.eu-west-1.amazonaws.com/" {
+		t.Errorf("endpoint = %s, want the eu-west-1 endpoint", euSm.Config.GetSecretManagerEndpoint())
+	}
+
+	if _, err := NewSecretManagerWithProvider(provider, "dev", "eu-nowhere-1", 50001); err == nil {
+		t.Error("NewSecretManagerWithProvider() with an unknown region should fail")
+	}
+
+	for _, want := range []string{"ASIA4", "ASIA5"} {
+		req, err := http.NewRequest("POST", sm.Config.GetSecretManagerEndpoint(), nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+		if err := sm.signRequest(req, "{}"); err != nil {
+			t.Fatalf("signRequest() error = %v", err)
+		}
+
+		if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "Credential="+want+"/") {
+			t.Errorf("Authorization = %s, want credentials %s", auth, want)
+		}
+		if req.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			t.Errorf("X-Amz-Target = %s", req.Header.Get("X-Amz-Target"))
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package sigv4 signs HTTP requests to AWS with Signature Version 4, see
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv.html. It works for any service and region and supports
+// signed headers, unsigned payloads and presigned URLs.
+package sigv4
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/aws"
+)
+
+const (
+	Algorithm       = "AWS4-HMAC-SHA256"
+	terminationChar = "aws4_request"
+
+	TimeFormat      = "20060102T150405Z"
+	ShortTimeFormat = "20060102"
+
+	// UnsignedPayload is used in place of the payload hash when the body is not signed, eg. S3 presigned URLs
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// EmptyPayloadHash is the hex SHA256 of an empty body
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// MaxPresignExpiry is the longest a presigned URL can be valid for
+	MaxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// Headers that proxies and clients are free to change, signing them would break the signature in transit
+var ignoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+}
+
+type Signer struct {
+	Credentials aws.AWSCredentials
+	Region      aws.AWSRegion
+	Service     string
+
+	// DisableURIPathEscaping encodes the path once without normalizing it, instead of escaping the already escaped path. S3
+	// needs this.
+	DisableURIPathEscaping bool
+}
+
+// Creates a new signer for service in region
+func NewSigner(creds aws.AWSCredentials, region aws.AWSRegion, service string) *Signer {
+	return &Signer{
+		Credentials:            creds,
+		Region:                 region,
+		Service:                service,
+		DisableURIPathEscaping: service == "s3",
+	}
+}
+
+// PayloadHash returns the hex SHA256 of a request body
+func PayloadHash(payload []byte) string {
+	h := sha256.Sum256(payload)
+	return hex.EncodeToString(h[:])
+}
+
+// Sign adds the X-Amz-Date, session token and Authorization headers to req. payloadHash is the hex SHA256 of the body, or
+// UnsignedPayload. Every header already on req is signed, so set them all before calling Sign.
+func (s *Signer) Sign(req *http.Request, payloadHash string, signTime time.Time) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	signTime = signTime.UTC()
+	req.Header.Set("X-Amz-Date", signTime.Format(TimeFormat))
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+	canonicalRequest := s.canonicalRequest(req, req.URL.Query(), canonicalHeaders, signedHeaders, payloadHash)
+	credentialScope := s.credentialScope(signTime)
+	signature := s.signature(signTime, credentialScope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, s.Credentials.AccessKey, credentialScope, signedHeaders, signature))
+	return nil
+}
+
+// Presign returns a URL for req with the signature in the query string that is valid for expires. Headers on req are signed
+// and must be sent along with the URL. payloadHash is usually UnsignedPayload.
+func (s *Signer) Presign(req *http.Request, payloadHash string, expires time.Duration, signTime time.Time) (*url.URL, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if expires < time.Second || expires > MaxPresignExpiry {
+		return nil, fmt.Errorf("presign expiry must be between 1s and %s, got %s", MaxPresignExpiry, expires)
+	}
+
+	signTime = signTime.UTC()
+	credentialScope := s.credentialScope(signTime)
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", Algorithm)
+	query.Set("X-Amz-Credential", s.Credentials.AccessKey+"/"+credentialScope)
+	query.Set("X-Amz-Date", signTime.Format(TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+	if s.Credentials.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+
+	canonicalRequest := s.canonicalRequest(req, query, canonicalHeaders, signedHeaders, payloadHash)
+	signature := s.signature(signTime, credentialScope, canonicalRequest)
+
+	presigned := *req.URL
+	presigned.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return &presigned, nil
+}
+
+func (s *Signer) validate() error {
+	if s.Credentials.AccessKey == "" || s.Credentials.AccessSecret == "" {
+		return fmt.Errorf("sigv4: missing credentials")
+	}
+	if s.Region == "" || s.Service == "" {
+		return fmt.Errorf("sigv4: missing region or service")
+	}
+	return nil
+}
+
+func (s *Signer) credentialScope(signTime time.Time) string {
+	return fmt.Sprintf("%s/%s/%s/%s", signTime.Format(ShortTimeFormat), s.Region, s.Service, terminationChar)
+}
+
+func (s *Signer) signature(signTime time.Time, credentialScope string, canonicalRequest string) string {
+	stringToSign := fmt.Sprintf("%s\n%s\n%s\n%s",
+		Algorithm, signTime.Format(TimeFormat), credentialScope, sha256Hash(canonicalRequest))
+
+	signingKey := createSignatureKey(s.Credentials.AccessSecret, signTime.Format(ShortTimeFormat), s.Region.String(), s.Service)
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+func (s *Signer) canonicalRequest(req *http.Request, query url.Values, canonicalHeaders string, signedHeaders string, payloadHash string) string {
+	return fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s",
+		req.Method, s.canonicalURI(req.URL), canonicalQuery(query),
+		canonicalHeaders, signedHeaders, payloadHash)
+}
+
+// Returns the signed header list and the canonical headers block, the host header is always signed
+func (s *Signer) canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lowerName := strings.ToLower(name)
+		if ignoredHeaders[lowerName] || lowerName == "host" {
+			continue
+		}
+
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[lowerName] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	return strings.Join(names, ";"), canonical.String()
+}
+
+func (s *Signer) canonicalURI(u *url.URL) string {
+	uri := u.EscapedPath()
+	if u.Opaque != "" {
+		uri = u.Opaque
+	}
+	if uri == "" {
+		return "/"
+	}
+	if s.DisableURIPathEscaping {
+		return uriEncode(u.Path, false)
+	}
+
+	// Remove relative and empty path segments, keeping a trailing slash
+	cleaned := path.Clean(uri)
+	if strings.HasSuffix(uri, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return uriEncode(cleaned, false)
+}
+
+// Sorts the query by key then value and encodes it the way SigV4 expects
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	encoded := make(map[string][]string, len(query))
+	for key, values := range query {
+		encodedKey := uriEncode(key, true)
+		keys = append(keys, encodedKey)
+		for _, value := range values {
+			encoded[encodedKey] = append(encoded[encodedKey], uriEncode(value, true))
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := encoded[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Percent encodes everything but the RFC 3986 unreserved characters, and '/' unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -1,4 +1,4 @@
-package secretmananger
+package sigv4
 
 import (
 	"crypto/hmac"
@@ -0,0 +1,193 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when there is no blob with the given name
+var ErrNotFound = errors.New("blobstore: blob not found")
+
+// Blob names are restricted so they can be used directly as file names
+var blobNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,128}$`)
+
+// Backend stores opaque blobs by name. The host side of the enclave state store, it never sees plaintext.
+type Backend interface {
+	Get(name string) ([]byte, error)
+	Put(name string, data []byte) error
+	Delete(name string) error
+	List() ([]string, error)
+}
+
+// Checks that a blob name is safe to use
+func ValidateName(name string) error {
+	if !blobNamePattern.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid blob name: %q", name)
+	}
+	return nil
+}
+
+// MemoryBackend keeps blobs in memory, it is used as the host stand-in for tests
+type MemoryBackend struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+// Creates a new empty MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		blobs: make(map[string][]byte),
+	}
+}
+
+func (b *MemoryBackend) Get(name string) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, ok := b.blobs[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out, nil
+}
+
+func (b *MemoryBackend) Put(name string, data []byte) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stored := make([]byte, len(data))
+	copy(stored, data)
+	b.blobs[name] = stored
+	return nil
+}
+
+func (b *MemoryBackend) Delete(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.blobs, name)
+	return nil
+}
+
+func (b *MemoryBackend) List() ([]string, error) {
+	return b.Names(), nil
+}
+
+// Names returns the names of all stored blobs
+func (b *MemoryBackend) Names() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	names := make([]string, 0, len(b.blobs))
+	for name := range b.blobs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// FileBackend keeps each blob in its own file inside a directory
+type FileBackend struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Creates a new FileBackend that stores blobs in dir, creating it if needed
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create blob dir %s: %w", dir, err)
+	}
+
+	return &FileBackend{dir: dir}, nil
+}
+
+func (b *FileBackend) Get(name string) ([]byte, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(b.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", name, err)
+	}
+
+	return data, nil
+}
+
+// Writes to a temp file and renames it over the old blob so a crash never leaves a half written blob
+func (b *FileBackend) Put(name string, data []byte) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tmp, err := os.CreateTemp(b.dir, ".tmp-"+name+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob %s: %w", name, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync blob %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close blob %s: %w", name, err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(b.dir, name)); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (b *FileBackend) Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	err := os.Remove(filepath.Join(b.dir, name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Lists the blobs in the directory, skipping temp files of writes in progress
+func (b *FileBackend) List() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") || ValidateName(entry.Name()) != nil {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
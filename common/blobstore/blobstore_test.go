@@ -0,0 +1,102 @@
+package blobstore
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func testBackend(t *testing.T, backend Backend) {
+	t.Helper()
+
+	if _, err := backend.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() missing blob error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := backend.Put("blob-1", []byte("first")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := backend.Put("blob-1", []byte("second")); err != nil {
+		t.Fatalf("Put() overwrite error = %v", err)
+	}
+
+	got, err := backend.Get("blob-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !bytes.Equal(got, []byte("second")) {
+		t.Errorf("Get() = %q, want %q", got, "second")
+	}
+
+	if err := backend.Put("blob-2", []byte("other")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	names, err := backend.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"blob-1", "blob-2"}) {
+		t.Errorf("List() = %v, want [blob-1 blob-2]", names)
+	}
+
+	if err := backend.Delete("blob-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := backend.Get("blob-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want %v", err, ErrNotFound)
+	}
+	if err := backend.Delete("blob-1"); err != nil {
+		t.Errorf("Delete() missing blob error = %v, want nil", err)
+	}
+
+	if err := backend.Put("../escape", []byte("data")); err == nil {
+		t.Error("Put() expected error for invalid name")
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, NewMemoryBackend())
+}
+
+func TestFileBackend(t *testing.T) {
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileBackend() error = %v", err)
+	}
+
+	testBackend(t, backend)
+}
+
+func TestClientAgainstHandler(t *testing.T) {
+	server := httptest.NewServer(NewHandler(NewMemoryBackend()))
+	defer server.Close()
+
+	testBackend(t, NewClient(server.Client(), server.URL))
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		blob    string
+		wantErr bool
+	}{
+		{name: "simple", blob: "manifest", wantErr: false},
+		{name: "hex", blob: "r-0123456789abcdef", wantErr: false},
+		{name: "empty", blob: "", wantErr: true},
+		{name: "dot", blob: ".", wantErr: true},
+		{name: "dot dot", blob: "..", wantErr: true},
+		{name: "path separator", blob: "a/b", wantErr: true},
+		{name: "too long", blob: string(bytes.Repeat([]byte("a"), 129)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateName(tt.blob); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateName(%q) error = %v, wantErr %v", tt.blob, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package blobstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/getaxal/verified-signer/common/network"
+)
+
+// Client is a Backend that talks to a blob server over http
+type Client struct {
+	httpClient *http.Client
+	baseUrl    string
+}
+
+// Creates a new Client for the blob server at baseUrl
+func NewClient(httpClient *http.Client, baseUrl string) *Client {
+	return &Client{
+		httpClient: httpClient,
+		baseUrl:    baseUrl,
+	}
+}
+
+// Creates a new Client that reaches the host blob server from inside the enclave through vsockPort
+func NewVsockClient(vsockPort uint32) *Client {
+	return NewClient(network.InitHttpClientWithVsockTransportEnclave(vsockPort), "http://host")
+}
+
+func (c *Client) Get(name string) ([]byte, error) {
+	res, err := c.do(http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("blob server returned status %d for %s", res.StatusCode, name)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, MaxBlobSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", name, err)
+	}
+	if len(data) > MaxBlobSize {
+		return nil, fmt.Errorf("blob %s is too large", name)
+	}
+
+	return data, nil
+}
+
+func (c *Client) Put(name string, data []byte) error {
+	res, err := c.do(http.MethodPut, name, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return fmt.Errorf("blob server returned status %d for %s", res.StatusCode, name)
+	}
+
+	return nil
+}
+
+func (c *Client) Delete(name string) error {
+	res, err := c.do(http.MethodDelete, name, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return fmt.Errorf("blob server returned status %d for %s", res.StatusCode, name)
+	}
+
+	return nil
+}
+
+func (c *Client) List() ([]string, error) {
+	res, err := c.do(http.MethodGet, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("blob server returned status %d listing blobs", res.StatusCode)
+	}
+
+	var names []string
+	if err := json.NewDecoder(io.LimitReader(res.Body, MaxBlobSize)).Decode(&names); err != nil {
+		return nil, fmt.Errorf("failed to read blob list: %w", err)
+	}
+	return names, nil
+}
+
+// Sends a request for a blob, an empty name is the list of all blobs
+func (c *Client) do(method string, name string, body []byte) (*http.Response, error) {
+	if name != "" {
+		if err := ValidateName(name); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseUrl+BlobPathPrefix+name, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob request: %w", err)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach blob server: %w", err)
+	}
+
+	return res, nil
+}
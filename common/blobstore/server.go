@@ -0,0 +1,88 @@
+package blobstore
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	BlobPathPrefix = "/blobs/"
+	// Largest blob the server accepts
+	MaxBlobSize = 1 << 20
+)
+
+// Creates a http handler serving the blob API for a backend:
+//
+//	GET    /blobs/        returns the names of all blobs as a JSON array
+//	GET    /blobs/{name}  returns the blob, 404 if there is none
+//	PUT    /blobs/{name}  stores the request body as the blob
+//	DELETE /blobs/{name}  removes the blob
+func NewHandler(backend Backend) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutPrefix(r.URL.Path, BlobPathPrefix)
+		if ok && name == "" && r.Method == http.MethodGet {
+			names, err := backend.List()
+			if err != nil {
+				log.Errorf("Unable to list blobs with err: %v", err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(names)
+			return
+		}
+		if !ok || ValidateName(name) != nil {
+			http.Error(w, "invalid blob name", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			data, err := backend.Get(name)
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, "blob not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Errorf("Unable to read blob %s with err: %v", name, err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.WriteHeader(http.StatusOK)
+			w.Write(data)
+
+		case http.MethodPut:
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBlobSize))
+			if err != nil {
+				http.Error(w, "blob is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			if err := backend.Put(name, data); err != nil {
+				log.Errorf("Unable to write blob %s with err: %v", name, err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case http.MethodDelete:
+			if err := backend.Delete(name); err != nil {
+				log.Errorf("Unable to delete blob %s with err: %v", name, err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
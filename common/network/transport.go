@@ -18,6 +18,8 @@ type VsockHTTPSRoundTripper struct {
 	CID       uint32
 	Port      uint32
 	TLSConfig *tls.Config
+
+	dial func() (net.Conn, error) // Connects to the host, tests replace the vsock dial
 }
 
 // Implement the round trip function with TLS support.
@@ -30,18 +32,18 @@ func (v *VsockHTTPSRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 
 	log.Infof("Sending HTTPS request to %s via vsock port: %d", req.URL.Host, v.Port)
 
-	// Add timeout to context if not present
+	// Add timeout to context if not present, it lasts until the response body is closed
 	ctx := req.Context()
+	cancel := context.CancelFunc(func() {})
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
-		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
 		req = req.WithContext(ctx)
 	}
 
 	// Dial vsock connection
-	conn, err := vsock.Dial(v.CID, v.Port, &vsock.Config{})
+	conn, err := v.dialHost()
 	if err != nil {
+		cancel()
 		log.Errorf("Unable to connect to vsock port %d: %v", v.Port, err)
 		return nil, err
 	}
@@ -56,6 +58,14 @@ func (v *VsockHTTPSRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 	// Create TLS connection
 	tlsConn := tls.Client(conn, tlsConfig)
 
+	// Closing the connection is the only way to abort a write or read in progress, so it is closed as soon as the request is
+	// cancelled. release stops that once the response body is closed.
+	stop := context.AfterFunc(ctx, func() { tlsConn.Close() })
+	release := func() {
+		stop()
+		cancel()
+	}
+
 	// Set deadline on the connection if we have one
 	if deadline, ok := ctx.Deadline(); ok {
 		tlsConn.SetDeadline(deadline)
@@ -64,6 +74,7 @@ func (v *VsockHTTPSRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 	// Perform TLS handshake with timeout
 	if err := v.handshakeWithTimeout(ctx, tlsConn); err != nil {
 		log.Errorf("TLS handshake failed: %v", err)
+		release()
 		tlsConn.Close()
 		return nil, fmt.Errorf("TLS handshake failed: %w", err)
 	}
@@ -73,16 +84,18 @@ func (v *VsockHTTPSRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 	// Send HTTP request over TLS connection
 	if err := req.Write(tlsConn); err != nil {
 		log.Errorf("Failed to write request over TLS: %v", err)
+		release()
 		tlsConn.Close()
-		return nil, fmt.Errorf("failed to write request: %w", err)
+		return nil, fmt.Errorf("failed to write request: %w", contextError(ctx, err))
 	}
 
 	// Read HTTP response over TLS
 	resp, err := http.ReadResponse(bufio.NewReader(tlsConn), req)
 	if err != nil {
 		log.Errorf("Failed to read response over TLS: %v", err)
+		release()
 		tlsConn.Close()
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", contextError(ctx, err))
 	}
 
 	// CRITICAL FIX: Wrap the response body to handle connection cleanup
@@ -90,12 +103,29 @@ func (v *VsockHTTPSRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 	resp.Body = &httpsConnectionAwareBody{
 		ReadCloser: resp.Body,
 		conn:       tlsConn,
+		ctx:        ctx,
+		release:    release,
 	}
 
 	log.Infof("Successfully received HTTP response, status: %d", resp.StatusCode)
 	return resp, nil
 }
 
+func (v *VsockHTTPSRoundTripper) dialHost() (net.Conn, error) {
+	if v.dial != nil {
+		return v.dial()
+	}
+	return vsock.Dial(v.CID, v.Port, &vsock.Config{})
+}
+
+// Returns the context error if the request was cancelled, the connection was closed because of it and its error says less
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
 // Helper function to perform TLS handshake with timeout
 func (v *VsockHTTPSRoundTripper) handshakeWithTimeout(ctx context.Context, tlsConn *tls.Conn) error {
 	type result struct {
@@ -120,7 +150,18 @@ func (v *VsockHTTPSRoundTripper) handshakeWithTimeout(ctx context.Context, tlsCo
 // httpsConnectionAwareBody wraps the response body and ensures the connection is closed
 type httpsConnectionAwareBody struct {
 	io.ReadCloser
-	conn *tls.Conn
+	conn    *tls.Conn
+	ctx     context.Context
+	release func() // Stops watching the request context
+}
+
+// Read returns the context error once a cancelled request closed the connection
+func (cab *httpsConnectionAwareBody) Read(p []byte) (int, error) {
+	n, err := cab.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = contextError(cab.ctx, err)
+	}
+	return n, err
 }
 
 func (cab *httpsConnectionAwareBody) Close() error {
@@ -130,6 +171,12 @@ func (cab *httpsConnectionAwareBody) Close() error {
 	// Then close the TLS connection
 	connErr := cab.conn.Close()
 
+	// A cancelled request has closed the connection already, neither can close cleanly
+	if cab.ctx.Err() != nil {
+		bodyErr, connErr = nil, nil
+	}
+	cab.release()
+
 	// Return the first error encountered
 	if bodyErr != nil {
 		log.Errorf("Error closing response body: %v", bodyErr)
@@ -0,0 +1,129 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Starts a TLS server running handler and a round tripper that reaches it over TCP instead of vsock
+func newTestTLSRoundTripper(t *testing.T, handler http.HandlerFunc) (*VsockHTTPSRoundTripper, string) {
+	t.Helper()
+
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	return &VsockHTTPSRoundTripper{
+		TLSConfig: &tls.Config{RootCAs: roots, ServerName: "example.com"},
+		dial: func() (net.Conn, error) {
+			return net.Dial("tcp", server.Listener.Addr().String())
+		},
+	}, server.URL
+}
+
+// Handler that writes body and then hangs until the client goes away or the test ends
+func hangingHandler(t *testing.T, arrived chan<- struct{}, body string) http.HandlerFunc {
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if body != "" {
+			w.Write([]byte(body))
+			w.(http.Flusher).Flush()
+		}
+		arrived <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}
+}
+
+func TestVsockHTTPSRoundTripper(t *testing.T) {
+	rt, url := newTestTLSRoundTripper(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "ok" {
+		t.Errorf("body = %q, %v, want ok", body, err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("Body.Close() error = %v", err)
+	}
+}
+
+func TestVsockHTTPSRoundTripper_CancelWaitingForResponse(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	rt, url := newTestTLSRoundTripper(t, hangingHandler(t, arrived, ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := rt.RoundTrip(req)
+		errs <- err
+	}()
+
+	// The handshake is done and the request written once the handler runs
+	<-arrived
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("RoundTrip() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("RoundTrip() kept waiting for the response after the request was cancelled")
+	}
+}
+
+func TestVsockHTTPSRoundTripper_CancelReadingBody(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	rt, url := newTestTLSRoundTripper(t, hangingHandler(t, arrived, "partial"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	defer resp.Body.Close()
+	<-arrived
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp.Body)
+		errs <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("reading the body error = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reading the body kept going after the request was cancelled")
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("Body.Close() error = %v after cancellation", err)
+	}
+}
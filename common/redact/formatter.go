@@ -0,0 +1,54 @@
+package redact
+
+import (
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Formatter wraps a logrus formatter. Struct fields are formatted with their tagged fields redacted and every registered secret
+// is scrubbed from the formatted line, whichever way it was logged.
+type Formatter struct {
+	Formatter logrus.Formatter
+}
+
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	redacted := *entry
+	redacted.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		redacted.Data[key] = redactField(value)
+	}
+
+	line, err := f.Formatter.Format(&redacted)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(Scrub(string(line))), nil
+}
+
+// Install wraps the formatter of logger in a Formatter, the standard logger if logger is nil
+func Install(logger *logrus.Logger) {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	if _, ok := logger.Formatter.(*Formatter); ok {
+		return
+	}
+	logger.SetFormatter(&Formatter{Formatter: logger.Formatter})
+}
+
+func redactField(value any) any {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if _, ok := value.(error); ok {
+			return value
+		}
+		return String(value)
+	}
+	return value
+}
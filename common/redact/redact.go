@@ -0,0 +1,280 @@
+// Package redact keeps secrets out of logs. Struct fields tagged `secret:"true"` are redacted when a value is formatted with
+// String or Value, and every value passed to Register is scrubbed from log lines by the Formatter, which catches secrets logged
+// any other way.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in log output
+const Redacted = "[REDACTED]"
+
+// Shorter values are not registered, scrubbing them would mangle unrelated log text
+const minSecretLength = 6
+
+// Formatting gives up below this depth so cyclic values can not loop forever
+const maxDepth = 10
+
+var (
+	mu       sync.RWMutex
+	secrets  = make(map[string]struct{})
+	replacer *strings.Replacer
+)
+
+// Register marks values as secrets so they are scrubbed from every log line. The escaped forms the text and JSON formatters
+// write for values with quotes or newlines are registered too.
+func Register(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	added := false
+	for _, value := range values {
+		for _, form := range escapedForms(value) {
+			if len(form) < minSecretLength {
+				continue
+			}
+			if _, ok := secrets[form]; !ok {
+				secrets[form] = struct{}{}
+				added = true
+			}
+		}
+	}
+
+	if added {
+		replacer = newReplacer()
+	}
+}
+
+// RegisterTagged registers every non-empty string field tagged `secret:"true"` in v, following pointers, structs, slices and maps
+func RegisterTagged(v any) {
+	var values []string
+	collectTagged(reflect.ValueOf(v), false, 0, &values)
+	Register(values...)
+}
+
+// Scrub replaces every registered secret in s with Redacted
+func Scrub(s string) string {
+	mu.RLock()
+	r := replacer
+	mu.RUnlock()
+
+	if r == nil {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// String formats v like %+v with the fields tagged `secret:"true"` redacted
+func String(v any) string {
+	var b strings.Builder
+	write(&b, reflect.ValueOf(v), 0)
+	return b.String()
+}
+
+type value struct {
+	v any
+}
+
+func (r value) String() string {
+	return String(r.v)
+}
+
+// Value wraps v so it is logged with the fields tagged `secret:"true"` redacted, eg. log.Infof("config: %v", redact.Value(cfg))
+func Value(v any) fmt.Stringer {
+	return value{v: v}
+}
+
+func escapedForms(value string) []string {
+	forms := []string{value}
+
+	quoted := strconv.Quote(value)
+	forms = append(forms, quoted[1:len(quoted)-1])
+
+	if encoded, err := json.Marshal(value); err == nil {
+		forms = append(forms, string(encoded[1:len(encoded)-1]))
+	}
+	return forms
+}
+
+// Longest secrets first so a secret containing another is replaced whole
+func newReplacer() *strings.Replacer {
+	values := make([]string, 0, len(secrets))
+	for s := range secrets {
+		values = append(values, s)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, s := range values {
+		pairs = append(pairs, s, Redacted)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true"
+}
+
+func collectTagged(v reflect.Value, tagged bool, depth int, values *[]string) {
+	if !v.IsValid() || depth > maxDepth {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			collectTagged(v.Elem(), tagged, depth+1, values)
+		}
+	case reflect.String:
+		if tagged && v.Len() > 0 {
+			*values = append(*values, v.String())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			collectTagged(v.Field(i), tagged || isSecret(v.Type().Field(i)), depth+1, values)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			collectTagged(v.Index(i), tagged, depth+1, values)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			collectTagged(iter.Value(), tagged, depth+1, values)
+		}
+	}
+}
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Whether t formats itself and has no tagged fields of its own, like time.Time or *big.Int
+func formatsItself(t reflect.Type) bool {
+	if !t.Implements(stringerType) && !t.Implements(errorType) {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			if isSecret(t.Field(i)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func write(b *strings.Builder, v reflect.Value, depth int) {
+	if !v.IsValid() {
+		b.WriteString("<nil>")
+		return
+	}
+	if depth > maxDepth {
+		b.WriteString("...")
+		return
+	}
+
+	if v.Kind() != reflect.Interface && v.CanInterface() && formatsItself(v.Type()) {
+		fmt.Fprint(b, v.Interface())
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		if depth == 0 && v.Elem().Kind() == reflect.Struct {
+			b.WriteByte('&')
+		}
+		write(b, v.Elem(), depth+1)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		write(b, v.Elem(), depth+1)
+
+	case reflect.Struct:
+		b.WriteByte('{')
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if !first {
+				b.WriteByte(' ')
+			}
+			first = false
+
+			b.WriteString(field.Name)
+			b.WriteByte(':')
+			if isSecret(field) && !v.Field(i).IsZero() {
+				b.WriteString(Redacted)
+				continue
+			}
+			write(b, v.Field(i), depth+1)
+		}
+		b.WriteByte('}')
+
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteString("map[]")
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		b.WriteString("map[")
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			write(b, key, depth+1)
+			b.WriteByte(':')
+			write(b, v.MapIndex(key), depth+1)
+		}
+		b.WriteByte(']')
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			fmt.Fprintf(b, "<%d bytes>", v.Len())
+			return
+		}
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			write(b, v.Index(i), depth+1)
+		}
+		b.WriteByte(']')
+
+	default:
+		if v.CanInterface() {
+			fmt.Fprint(b, v.Interface())
+		} else {
+			b.WriteString(v.Type().String())
+		}
+	}
+}
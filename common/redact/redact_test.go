@@ -0,0 +1,127 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type testCredentials struct {
+	User     string
+	Password string `secret:"true"`
+	Empty    string `secret:"true"`
+}
+
+type testConfig struct {
+	Name    string
+	Creds   testCredentials
+	Backup  *testCredentials
+	Values  map[string]string `secret:"true"`
+	Tokens  []string          `secret:"true"`
+	Started time.Time
+	hidden  string
+}
+
+func TestString(t *testing.T) {
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cfg := &testConfig{
+		Name:    "signer",
+		Creds:   testCredentials{User: "axal", Password: "hunter2-password"},
+		Backup:  &testCredentials{User: "backup", Password: "backup-password"},
+		Values:  map[string]string{"dev/privy": "privy-secret"},
+		Tokens:  []string{"token-one"},
+		Started: started,
+		hidden:  "hidden-value",
+	}
+
+	got := String(cfg)
+	want := "&{Name:signer Creds:{User:axal Password:[REDACTED] Empty:} Backup:{User:backup Password:[REDACTED] Empty:} " +
+		"Values:[REDACTED] Tokens:[REDACTED] Started:" + started.String() + "}"
+	if got != want {
+		t.Errorf("String() = %s\nwant %s", got, want)
+	}
+
+	if Value(cfg).String() != want {
+		t.Errorf("Value().String() = %s, want %s", Value(cfg), want)
+	}
+
+	if got := String(map[string]int{"b": 2, "a": 1}); got != "map[a:1 b:2]" {
+		t.Errorf("String(map) = %s", got)
+	}
+	if got := String(nil); got != "<nil>" {
+		t.Errorf("String(nil) = %s", got)
+	}
+	if got := String([]byte("raw-secret")); got != "<10 bytes>" {
+		t.Errorf("String([]byte) = %s", got)
+	}
+}
+
+func TestRegisterAndScrub(t *testing.T) {
+	Register("scrub-me-please", "short", "multi\nline-secret")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "value scrub-me-please here", want: "value [REDACTED] here"},
+		{in: "too short to register: short", want: "too short to register: short"},
+		{in: `quoted "multi\nline-secret"`, want: `quoted "[REDACTED]"`},
+		{in: "raw multi\nline-secret", want: "raw [REDACTED]"},
+	}
+
+	for _, tt := range tests {
+		if got := Scrub(tt.in); got != tt.want {
+			t.Errorf("Scrub(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterTagged(t *testing.T) {
+	RegisterTagged(&testConfig{
+		Creds:  testCredentials{User: "tagged-user", Password: "tagged-password"},
+		Values: map[string]string{"a": "tagged-map-value"},
+		Tokens: []string{"tagged-token"},
+	})
+
+	got := Scrub("tagged-user tagged-password tagged-map-value tagged-token")
+	if got != "tagged-user [REDACTED] [REDACTED] [REDACTED]" {
+		t.Errorf("Scrub() = %q, want the tagged values redacted", got)
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	for _, inner := range []logrus.Formatter{&logrus.TextFormatter{DisableColors: true}, &logrus.JSONFormatter{}} {
+		var buf bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&buf)
+		logger.SetFormatter(inner)
+		Install(logger)
+		Install(logger)
+
+		if _, ok := logger.Formatter.(*Formatter).Formatter.(*Formatter); ok {
+			t.Fatal("Install() wrapped the formatter twice")
+		}
+
+		Register("registered-formatter-secret")
+		creds := testCredentials{User: "axal", Password: "field-password"}
+
+		logger.Infof("message with registered-formatter-secret and %v", Value(creds))
+		logger.WithField("creds", creds).WithField("ptr", &creds).Info("fields")
+		logger.WithError(errors.New("failed with registered-formatter-secret")).Error("error field")
+		logger.Info("multi\nline registered-formatter-secret")
+
+		out := buf.String()
+		for _, secret := range []string{"registered-formatter-secret", "field-password"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%T log output contains %s:\n%s", inner, secret, out)
+			}
+		}
+		if !strings.Contains(out, "axal") || strings.Count(out, Redacted) < 5 {
+			t.Errorf("%T log output = %s, want the non secret fields kept", inner, out)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package apierror is the error model of the enclave API. Every error a client sees has a stable machine readable code, a message
+// for humans, the id of the request it failed and, for invalid input, the fields that were wrong.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Code is a stable machine readable error code, clients branch on it instead of on messages
+type Code string
+
+const (
+	InvalidRequest      Code = "invalid_request"
+	InvalidHash         Code = "invalid_hash"
+	PreimageRequired    Code = "preimage_required"
+	PreimageMismatch    Code = "preimage_mismatch"
+	AuthFailed          Code = "auth_failed"
+	AxalSigningDisabled Code = "axal_signing_disabled"
+	AxalSigningPaused   Code = "axal_signing_paused"
+	PolicyDenied        Code = "policy_denied"
+	ChainStateDisputed  Code = "chain_state_disputed"
+	NotFound            Code = "not_found"
+	WalletMissing       Code = "wallet_missing"
+	RateLimited         Code = "rate_limited"
+	UpstreamError       Code = "upstream_error"
+	UpstreamUnavailable Code = "upstream_unavailable"
+	UpstreamTimeout     Code = "upstream_timeout"
+	RequestCancelled    Code = "request_cancelled"
+	Unavailable         Code = "unavailable"
+	NotImplemented      Code = "not_implemented"
+	Internal            Code = "internal"
+)
+
+// Status nginx uses for a client that went away, nobody reads it but it keeps the logs honest
+const StatusClientClosedRequest = 499
+
+var statusByCode = map[Code]int{
+	InvalidRequest:      http.StatusBadRequest,
+	InvalidHash:         http.StatusBadRequest,
+	PreimageRequired:    http.StatusBadRequest,
+	PreimageMismatch:    http.StatusBadRequest,
+	AuthFailed:          http.StatusUnauthorized,
+	AxalSigningDisabled: http.StatusForbidden,
+	AxalSigningPaused:   http.StatusServiceUnavailable,
+	PolicyDenied:        http.StatusForbidden,
+	ChainStateDisputed:  http.StatusBadGateway,
+	NotFound:            http.StatusNotFound,
+	WalletMissing:       http.StatusBadRequest,
+	RateLimited:         http.StatusTooManyRequests,
+	UpstreamError:       http.StatusBadGateway,
+	UpstreamUnavailable: http.StatusServiceUnavailable,
+	UpstreamTimeout:     http.StatusGatewayTimeout,
+	RequestCancelled:    StatusClientClosedRequest,
+	Unavailable:         http.StatusServiceUnavailable,
+	NotImplemented:      http.StatusNotImplemented,
+	Internal:            http.StatusInternalServerError,
+}
+
+// Returns the http status of a code, unknown codes are internal errors
+func (code Code) Status() int {
+	if status, ok := statusByCode[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError says what is wrong with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an API error. The cause is only logged, it never reaches the client.
+type Error struct {
+	Code    Code
+	Message string
+	Details []FieldError
+	cause   error
+}
+
+// Creates an error with code
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Creates an error with code caused by err
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, cause: err}
+}
+
+// Creates an invalid request error for the given fields, the message lists them. The code is InvalidHash if only hash fields are
+// wrong.
+func Invalid(details ...FieldError) *Error {
+	code := InvalidHash
+	problems := make([]string, len(details))
+	for i, detail := range details {
+		if detail.Field != "hash" && !strings.HasSuffix(detail.Field, ".hash") {
+			code = InvalidRequest
+		}
+		problems[i] = detail.Field + " " + detail.Message
+	}
+	if len(details) == 0 {
+		code = InvalidRequest
+	}
+	return &Error{Code: code, Message: "request is invalid: " + strings.Join(problems, ", "), Details: details}
+}
+
+// Creates an internal error caused by err
+func InternalError(err error) *Error {
+	return Wrap(Internal, "Internal Server Error", err)
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Returns the http status of the error
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// Returns the API error in err, errors that are not API errors are internal errors
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return InternalError(err)
+}
+
+// Response is the JSON body of an error response
+type Response struct {
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+// Returns the response body of the error for the request requestId
+func (e *Error) Response(requestId string) Response {
+	return Response{
+		Code:      e.Code,
+		Message:   e.Message,
+		RequestID: requestId,
+		Details:   e.Details,
+	}
+}
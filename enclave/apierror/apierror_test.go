@@ -0,0 +1,70 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCode_Status(t *testing.T) {
+	tests := []struct {
+		code Code
+		want int
+	}{
+		{code: AuthFailed, want: http.StatusUnauthorized},
+		{code: InvalidHash, want: http.StatusBadRequest},
+		{code: PolicyDenied, want: http.StatusForbidden},
+		{code: UpstreamUnavailable, want: http.StatusServiceUnavailable},
+		{code: RequestCancelled, want: StatusClientClosedRequest},
+		{code: Code("made_up"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := tt.code.Status(); got != tt.want {
+			t.Errorf("%s.Status() = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		details []FieldError
+		want    Code
+	}{
+		{name: "bad hash", details: []FieldError{{Field: "params.hash", Message: "must be 32 bytes"}}, want: InvalidHash},
+		{name: "bad method", details: []FieldError{{Field: "method", Message: "must be secp256k1_sign"}}, want: InvalidRequest},
+		{
+			name:    "bad hash and more",
+			details: []FieldError{{Field: "params.hash", Message: "must be 32 bytes"}, {Field: "privy_id", Message: "is required"}},
+			want:    InvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Invalid(tt.details...)
+			if err.Code != tt.want || len(err.Details) != len(tt.details) {
+				t.Errorf("Invalid() = %+v, want code %s with %d details", err, tt.want, len(tt.details))
+			}
+		})
+	}
+}
+
+func TestFrom(t *testing.T) {
+	cause := errors.New("disk on fire")
+
+	wrapped := fmt.Errorf("context: %w", New(PolicyDenied, "denied"))
+	if got := From(wrapped); got.Code != PolicyDenied {
+		t.Errorf("From() code = %s, want the wrapped policy_denied", got.Code)
+	}
+
+	got := From(cause)
+	if got.Code != Internal || !errors.Is(got, cause) {
+		t.Errorf("From() = %v, want an internal error caused by the plain error", got)
+	}
+	if resp := got.Response("req-1"); resp.Message != "Internal Server Error" || resp.RequestID != "req-1" {
+		t.Errorf("Response() = %+v, want the cause kept out of the response", resp)
+	}
+}
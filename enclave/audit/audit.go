@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/state"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	headKey        = "audit/head"
+	entryKeyPrefix = "audit/entry/"
+)
+
+var AuditLog *Recorder
+
+// Entry is a single record in the audit trail. Every entry commits to the hash of the one before it, so removing or
+// rewriting an entry breaks the chain.
+type Entry struct {
+	Index     uint64            `json:"index"`
+	Timestamp int64             `json:"timestamp"`
+	Event     string            `json:"event"`
+	Actors    []string          `json:"actors,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash"`
+}
+
+// head points at the latest entry in the chain
+type head struct {
+	Index uint64 `json:"index"`
+	Hash  string `json:"hash"`
+}
+
+// Recorder appends entries to a hash chained audit trail kept in the enclave state store
+type Recorder struct {
+	store state.Store
+	now   func() time.Time
+	mu    sync.Mutex
+}
+
+// Inits a new Recorder backed by the given store and initiates it to audit.AuditLog
+func InitAuditLog(store state.Store) {
+	AuditLog = NewRecorder(store)
+}
+
+// Creates a new Recorder backed by the given store
+func NewRecorder(store state.Store) *Recorder {
+	return &Recorder{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Appends a new entry to the audit trail and returns it
+func (r *Recorder) Record(event string, actors []string, details map[string]string) (*Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.head()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Index:     current.Index + 1,
+		Timestamp: r.now().Unix(),
+		Event:     event,
+		Actors:    actors,
+		Details:   details,
+		PrevHash:  current.Hash,
+	}
+
+	entry.Hash, err = entry.computeHash()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	if err := r.store.Put(entryKey(entry.Index), raw); err != nil {
+		return nil, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	rawHead, err := json.Marshal(head{Index: entry.Index, Hash: entry.Hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit head: %w", err)
+	}
+
+	if err := r.store.Put(headKey, rawHead); err != nil {
+		return nil, fmt.Errorf("failed to write audit head: %w", err)
+	}
+
+	log.Infof("Audit entry %d recorded for event %s by %v", entry.Index, entry.Event, entry.Actors)
+	return entry, nil
+}
+
+// Gets the audit entry at index, entries start at 1
+func (r *Recorder) Get(index uint64) (*Entry, error) {
+	raw, err := r.store.Get(entryKey(index))
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse audit entry %d: %w", index, err)
+	}
+
+	return &entry, nil
+}
+
+// Walks the whole chain and checks that every entry hashes correctly and links to the one before it
+func (r *Recorder) Verify() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.head()
+	if err != nil {
+		return err
+	}
+
+	prevHash := ""
+	for i := uint64(1); i <= current.Index; i++ {
+		entry, err := r.Get(i)
+		if err != nil {
+			return fmt.Errorf("audit entry %d is missing: %w", i, err)
+		}
+
+		if entry.PrevHash != prevHash {
+			return fmt.Errorf("audit entry %d does not link to the previous entry", i)
+		}
+
+		hash, err := entry.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("audit entry %d has been modified", i)
+		}
+
+		prevHash = entry.Hash
+	}
+
+	if prevHash != current.Hash {
+		return fmt.Errorf("audit head does not match the last entry")
+	}
+
+	return nil
+}
+
+// Reads the head of the chain, an empty trail has index 0 and no hash
+func (r *Recorder) head() (*head, error) {
+	raw, err := r.store.Get(headKey)
+	if errors.Is(err, state.ErrNotFound) {
+		return &head{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit head: %w", err)
+	}
+
+	var h head
+	if err := json.Unmarshal(raw, &h); err != nil {
+		return nil, fmt.Errorf("failed to parse audit head: %w", err)
+	}
+
+	return &h, nil
+}
+
+// The hash covers every field of the entry except the hash itself
+func (e *Entry) computeHash() (string, error) {
+	unhashed := *e
+	unhashed.Hash = ""
+
+	raw, err := json.Marshal(unhashed)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func entryKey(index uint64) string {
+	return fmt.Sprintf("%s%020d", entryKeyPrefix, index)
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/state"
+)
+
+func TestRecorder_RecordAndVerify(t *testing.T) {
+	store := state.NewMemoryStore()
+	recorder := NewRecorder(store)
+
+	first, err := recorder.Record("pause", []string{"op1", "op2"}, map[string]string{"sequence": "1"})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if first.Index != 1 || first.PrevHash != "" {
+		t.Errorf("Record() first entry index = %d prev = %q, want 1 and empty", first.Index, first.PrevHash)
+	}
+
+	second, err := recorder.Record("unpause", []string{"op1", "op3"}, map[string]string{"sequence": "2"})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if second.Index != 2 || second.PrevHash != first.Hash {
+		t.Errorf("Record() second entry index = %d prev = %q, want 2 and %q", second.Index, second.PrevHash, first.Hash)
+	}
+
+	if err := recorder.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// A new recorder on the same store continues the chain
+	restarted := NewRecorder(store)
+	third, err := restarted.Record("pause", []string{"op2", "op3"}, nil)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if third.Index != 3 || third.PrevHash != second.Hash {
+		t.Errorf("Record() after restart index = %d prev = %q, want 3 and %q", third.Index, third.PrevHash, second.Hash)
+	}
+}
+
+func TestRecorder_VerifyDetectsTampering(t *testing.T) {
+	store := state.NewMemoryStore()
+	recorder := NewRecorder(store)
+
+	for _, event := range []string{"pause", "unpause", "pause"} {
+		if _, err := recorder.Record(event, []string{"op1"}, nil); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	entry, err := recorder.Get(2)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	entry.Event = "pause"
+	raw, _ := json.Marshal(entry)
+	if err := store.Put(entryKey(2), raw); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	if err := recorder.Verify(); err == nil {
+		t.Error("Verify() expected error for a modified entry")
+	}
+}
+
+func TestRecorder_VerifyDetectsMissingEntry(t *testing.T) {
+	store := state.NewMemoryStore()
+	recorder := NewRecorder(store)
+
+	for _, event := range []string{"pause", "unpause"} {
+		if _, err := recorder.Record(event, []string{"op1"}, nil); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	if err := store.Delete(entryKey(1)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := recorder.Verify(); err == nil {
+		t.Error("Verify() expected error for a missing entry")
+	}
+}
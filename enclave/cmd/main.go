@@ -1,7 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"flag"
+	"fmt"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/blobstore"
+	"github.com/getaxal/verified-signer/common/redact"
+	"github.com/getaxal/verified-signer/enclave/audit"
+	"github.com/getaxal/verified-signer/enclave/controls"
+	"github.com/getaxal/verified-signer/enclave/rpc"
+	"github.com/getaxal/verified-signer/enclave/signer"
+	"github.com/getaxal/verified-signer/enclave/state"
+	"github.com/getaxal/verified-signer/enclave/verifier"
 
 	privysigner "github.com/getaxal/verified-signer/enclave/privy-signer"
 
@@ -15,6 +28,9 @@ import (
 var TeeCfg *enclave.TEEConfig
 
 func main() {
+	// Every log line goes through the redacting formatter before it leaves the enclave
+	redact.Install(log.StandardLogger())
+
 	log.Info("Initiating enclave for Axal Verified Signer")
 
 	// Define command line flag for config path
@@ -30,11 +46,119 @@ func main() {
 
 	TeeCfg = teeCfg
 
-	err = privysigner.InitNewPrivyClient(*configPath, teeCfg)
+	appSigner, err := initSigner(*configPath, teeCfg)
+
+	if err != nil {
+		log.Fatalf("Error creating signer: %v", err)
+	}
+
+	// Refresh secrets in the background so rotations are picked up without a restart
+	if secretCache := teeCfg.SecretCache(); secretCache != nil {
+		secretCache.Start(context.Background())
+	}
+
+	// Kill switch, pause and audit state lives in the enclave state store
+	stateStore, err := initStateStore(teeCfg)
+	if err != nil {
+		log.Fatalf("Error opening state store: %v", err)
+	}
+
+	audit.InitAuditLog(stateStore)
+	controls.InitKillSwitch(stateStore, time.Duration(teeCfg.KillSwitch.ReenableCooldownSeconds)*time.Second)
+
+	operators := make([]controls.OperatorKey, 0, len(teeCfg.Pause.Operators))
+	for _, op := range teeCfg.Pause.Operators {
+		operatorKey, err := controls.ParseOperatorKey(op.KeyID, op.PublicKey)
+		if err != nil {
+			log.Fatalf("Invalid emergency pause operator key: %v", err)
+		}
+		operators = append(operators, *operatorKey)
+	}
+
+	err = controls.InitEmergencyPause(stateStore, audit.AuditLog, teeCfg.GetEnv(), teeCfg.Pause.Threshold, operators)
+	if err != nil {
+		log.Fatalf("Error creating emergency pause: %v", err)
+	}
+
+	// Live chain state is read through the rpc providers of each chain
+	if err := rpc.InitClients(teeCfg); err != nil {
+		log.Fatalf("Error creating rpc clients: %v", err)
+	}
+
+	verifier.InitVerifier(teeCfg.AxalSigning.RequirePreimage, axalPolicies(teeCfg)...)
+
+	router.InitRouter(TeeCfg.Ports.RouterVsockPort, appSigner)
+}
+
+// Gets the policies the verifier runs on the preimages of Axal signing requests
+func axalPolicies(cfg *enclave.TEEConfig) []verifier.Policy {
+	var policies []verifier.Policy
+	if len(cfg.AxalSigning.AllowedChainIDs) > 0 {
+		policies = append(policies, verifier.NewChainPolicy(cfg.AxalSigning.AllowedChainIDs))
+	}
+	if !cfg.AxalSigning.RequirePreimage {
+		log.Warn("Axal signing requests without a preimage are accepted, policies only run on requests that carry one")
+	}
+	return policies
+}
+
+// Creates the signer set in config. Privy holds the wallets outside of dev, the local signer keeps keys in the enclave.
+func initSigner(configPath string, cfg *enclave.TEEConfig) (signer.Signer, error) {
+	if cfg.Signer.Backend == "local" {
+		log.Warn("Using the local signer, wallets only exist in this enclave")
+		return signer.NewLocalSigner(&signer.RequestAuth{
+			AppID:               cfg.Privy.AppID,
+			Environment:         cfg.Environment,
+			JWTVerificationKeys: []string{cfg.Privy.JWTVerificationKey},
+			Keys:                cfg.KeyStore(),
+		}, cfg.KeyStore())
+	}
+
+	if err := privysigner.InitNewPrivyClient(configPath, cfg); err != nil {
+		return nil, err
+	}
+	return privysigner.PrivyCli, nil
+}
+
+// Opens the state store set in config. The sealed store keeps its blobs on the host through the state blob vsock port.
+func initStateStore(cfg *enclave.TEEConfig) (state.Store, error) {
+	switch cfg.State.Store {
+	case "sealed":
+		keyProvider, err := initDataKeyProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Ports.StateBlobVsockPort == 0 {
+			return nil, fmt.Errorf("sealed state store needs state_blob_vsock_port")
+		}
+
+		// Outside local the host could wipe the state to clear kill switches and the pause, so starting empty has to be asked for
+		initialize := cfg.GetEnv() == "local" || cfg.State.Initialize
+		return state.OpenSealedStore(blobstore.NewVsockClient(cfg.Ports.StateBlobVsockPort), keyProvider, initialize)
+	case "memory":
+		log.Warn("Using in-memory state store, kill switch and pause state will be lost on restart")
+		return state.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("invalid state store: %s", cfg.State.Store)
+	}
+}
+
+// Gets the provider that unwraps the sealed store data key. Outside of local the data key is wrapped by KMS and can only be
+// unwrapped by the attested enclave.
+func initDataKeyProvider(cfg *enclave.TEEConfig) (state.DataKeyProvider, error) {
+	if cfg.GetEnv() != "local" {
+		if cfg.KMSClient() == nil {
+			return nil, fmt.Errorf("sealed state store has no kms client")
+		}
+
+		return state.NewKMSKeyProvider(cfg.KMSClient(), cfg.KMS.KeyID)
+	}
 
+	localKey, err := hex.DecodeString(cfg.State.LocalSealingKey)
 	if err != nil {
-		log.Fatalf("Error creating privy cli: %v", err)
+		return nil, fmt.Errorf("invalid local sealing key: %w", err)
 	}
 
-	router.InitRouter(TeeCfg.Ports.RouterVsockPort)
+	return state.NewLocalKeyProvider(localKey)
 }
@@ -2,23 +2,43 @@ package enclave
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"strconv"
-	"strings"
+	"os"
+	"time"
 
 	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+	"github.com/getaxal/verified-signer/common/aws/imds"
+	"github.com/getaxal/verified-signer/common/aws/kms"
 	secretmanager "github.com/getaxal/verified-signer/common/aws/secret_manager"
+	"github.com/getaxal/verified-signer/common/redact"
+	"github.com/getaxal/verified-signer/enclave/keystore"
 	"github.com/jinzhu/configor"
 	log "github.com/sirupsen/logrus"
 )
 
+// Config of the enclave. Everything but the secrets is only ever read from the config file baked into the image, so settings that
+// bound what the enclave signs, like the pause operators, policies and verifier settings, are covered by the enclave PCRs.
 type TEEConfig struct {
-	Environment string      `yaml:"environment"`
-	Ports       PortConfig  `yaml:"ports"`
-	Axal        AxalConfig  `yaml:"axal"`
-	Region      string      `yaml:"region"`
-	Privy       PrivyConfig `yaml:"privy"`
+	Environment string            `yaml:"environment"`
+	Ports       PortConfig        `yaml:"ports"`
+	Axal        AxalConfig        `yaml:"axal"`
+	Region      string            `yaml:"region"`
+	Privy       PrivyConfig       `yaml:"privy"`
+	KillSwitch  KillSwitchConfig  `yaml:"kill_switch"`
+	Pause       PauseConfig       `yaml:"emergency_pause"`
+	Policies    PolicyConfig      `yaml:"policies"`
+	AxalSigning AxalSigningConfig `yaml:"axal_signing"`
+	RPC         RPCConfig         `yaml:"rpc"`
+	State       StateConfig       `yaml:"state"`
+	KMS         KMSConfig         `yaml:"kms"`
+	Secrets     SecretsConfig     `yaml:"secrets"`
+	Signer      SignerConfig      `yaml:"signer"`
+
+	secretCache *secretmanager.SecretCache
+	awsCreds    credentials.Provider
+	kmsClient   *kms.KMSClient
+	keys        *keystore.KeyStore
 }
 
 type PortConfig struct {
@@ -26,79 +46,144 @@ type PortConfig struct {
 	PrivyAPIVsockPort         uint32 `yaml:"privy_api_vsock_port"`
 	RouterVsockPort           uint32 `yaml:"router_vsock_port"`
 	Ec2CredsVsockPort         uint32 `yaml:"ec2_creds_vsock_port"`
+	StateBlobVsockPort        uint32 `yaml:"state_blob_vsock_port"`
+	KMSVsockPort              uint32 `yaml:"kms_vsock_port"`
 }
 
 type AxalConfig struct {
-	AxalRequestSecretKey string `yaml:"axal_request_secret_key" json:"axal_request_secret_key"`
+	AxalRequestSecretKey string `yaml:"axal_request_secret_key" json:"axal_request_secret_key" validate:"required" secret:"true"`
 }
 
-// Config for privy access
-type PrivyConfig struct {
-	AppID                 string `json:"app_id" yaml:"app_id"`
-	DelegatedActionsKey   string `json:"delegated_actions_key" yaml:"delegated_actions_key"`
-	AppSecret             string `json:"app_secret" yaml:"app_secret"`
-	JWTVerificationKey    string `json:"jwt_verification_key" yaml:"jwt_verification_key"`
-	DelegatedActionsKeyId string `json:"key_id" yaml:"key_id"`
+// Config for the enclave state store
+type StateConfig struct {
+	Store           string `yaml:"store"`                           // "sealed" (default) keeps state on the host as sealed blobs, "memory" loses it on restart and is only allowed in local
+	LocalSealingKey string `yaml:"local_sealing_key" secret:"true"` // Hex encoded 32 byte key to wrap the data key with, only used in local
+	Initialize      bool   `yaml:"initialize"`                      // Lets the sealed store start empty when the host has no state, set for the first deployment only
 }
 
-// Init Privy config by fetching details from AWS SecretsManager using a Vsock HTTPS client. We need to provide a Vsock port for AWS communication for
-// this client.
-func InitPrivyConfig(configPath string, teeConfig TEEConfig) (*PrivyConfig, error) {
-	log.Infof("Loaded secret manager config")
+// Config for KMS. The key policy should only allow Decrypt and GenerateDataKey with a recipient attestation document carrying
+// the enclave PCRs, so only the measured enclave can read anything encrypted under it.
+type KMSConfig struct {
+	KeyID            string `yaml:"key_id"`            // KMS key the sealed state data key and encrypted secrets are under
+	EncryptedSecrets bool   `yaml:"encrypted_secrets"` // The privy and axal secrets in Secrets Manager are base64 KMS ciphertexts
+}
 
-	sm, err := secretmanager.NewSecretManager(configPath, teeConfig.Environment, teeConfig.Ports.AWSSecretManagerVsockPort, teeConfig.Ports.Ec2CredsVsockPort)
-	if err != nil {
-		return nil, err
-	}
+// Config for the per user kill switch on Axal initiated signing
+type KillSwitchConfig struct {
+	ReenableCooldownSeconds int64 `yaml:"reenable_cooldown_seconds"` // Minimum time between a user asking to re-enable Axal signing and it being re-enabled
+}
 
-	log.Info("Fetching Privy config from Secret Manager")
+// Config for the global emergency pause on Axal initiated signing, its operator keys must never be loaded from Secrets Manager
+type PauseConfig struct {
+	Threshold int                   `yaml:"threshold"` // Number of operator signatures needed for a pause or unpause command
+	Operators []PauseOperatorConfig `yaml:"operators"`
+}
 
-	var secretResponse *secretmanager.GetSecretValueResponse
+// A single pause operator and their PEM encoded ECDSA P-256 public key
+type PauseOperatorConfig struct {
+	KeyID     string `yaml:"key_id"`
+	PublicKey string `yaml:"public_key"`
+}
 
-	switch teeConfig.Environment {
-	case "prod":
-		secretResponse, err = sm.GetSecret(context.Background(), "prod/privy")
-	case "dev", "local":
-		secretResponse, err = sm.GetSecret(context.Background(), "dev/privy")
-	case "staging":
-		secretResponse, err = sm.GetSecret(context.Background(), "staging/privy")
-	default:
-		return nil, fmt.Errorf("invalid environment, no such env: %s", teeConfig.Environment)
-	}
+// Config for the custody backend the enclave signs with
+type SignerConfig struct {
+	Backend   string `yaml:"backend"`                  // "privy" (default) or "local", local keys are only allowed in local and dev
+	LocalSeed string `yaml:"local_seed" secret:"true"` // Hex encoded seed of at least 32 bytes the local signer derives user keys from
+}
 
-	if err != nil {
-		return nil, err
-	}
+// Config for the Privy policies attached to new wallets
+type PolicyConfig struct {
+	IDs  []string `yaml:"ids"`  // Privy policies new wallets get for the enclave signer
+	Hash string   `yaml:"hash"` // Hex sha256 of the policies, see data.HashPolicies, checked against Privy on startup
+}
+
+// Config for what Axal may ask the enclave to sign
+type AxalSigningConfig struct {
+	RequirePreimage bool     `yaml:"require_preimage"`  // Rejects Axal requests that only carry a hash, turn on once the backend sends preimages
+	AllowedChainIDs []uint64 `yaml:"allowed_chain_ids"` // Chains Axal may sign transactions and typed data for, empty allows any
+}
+
+// Config for the Ethereum JSON-RPC providers the enclave reads live chain state from
+type RPCConfig struct {
+	Chains []RPCChainConfig `yaml:"chains"`
+}
+
+// Fewest providers that must agree on chain state outside local
+const MinRPCQuorum = 2
+
+// The providers of one chain. Without a quorum calls fail over between them in order, which is only allowed in local.
+type RPCChainConfig struct {
+	ChainID    uint64              `yaml:"chain_id"`
+	Quorum     int                 `yaml:"quorum"` // Providers that must agree on a read at the same block, more than half of them
+	Providers  []RPCProviderConfig `yaml:"providers"`
+	CrossCheck bool                `yaml:"cross_check"` // Replaced by quorum, only read to refuse configs that still set it
+}
+
+// A JSON-RPC provider, reached through the host proxy on its vsock port
+type RPCProviderConfig struct {
+	Name          string `yaml:"name"`
+	URL           string `yaml:"url" secret:"true"` // https url of the provider, its path may carry an API key
+	TLSServerName string `yaml:"tls_server_name"`   // Name the provider certificate must be valid for, the host of URL
+	VsockPort     uint32 `yaml:"vsock_port"`
+}
 
-	if secretResponse.SecretString == "" {
-		return nil, fmt.Errorf("secret does not contain string data")
+// Config for privy access
+type PrivyConfig struct {
+	AppID                 string `json:"app_id" yaml:"app_id" validate:"required"`
+	DelegatedActionsKey   string `json:"delegated_actions_key" yaml:"delegated_actions_key" validate:"required" secret:"true"`
+	AppSecret             string `json:"app_secret" yaml:"app_secret" validate:"required" secret:"true"`
+	JWTVerificationKey    string `json:"jwt_verification_key" yaml:"jwt_verification_key" validate:"required"`
+	DelegatedActionsKeyId string `json:"key_id" yaml:"key_id"`
+
+	// Key quorum support. Every request is signed with the delegated actions key and each of the authorization keys, and new
+	// wallets get the key quorum as their additional signer instead of the key_id signer.
+	AuthorizationKeys []string `json:"authorization_keys" yaml:"authorization_keys" secret:"true"`
+	KeyQuorumId       string   `json:"key_quorum_id" yaml:"key_quorum_id"`
+}
+
+// Returns the signer added to new wallets, the key quorum if there is one
+func (cfg PrivyConfig) AdditionalSignerId() string {
+	if cfg.KeyQuorumId != "" {
+		return cfg.KeyQuorumId
 	}
+	return cfg.DelegatedActionsKeyId
+}
 
-	var config PrivyConfig
-	err = json.Unmarshal([]byte(secretResponse.SecretString), &config)
+// Init Privy config by fetching details through the secret cache. With the aws secret provider the cache reads from AWS
+// SecretsManager with a Vsock HTTPS client.
+func InitPrivyConfig(cache *secretmanager.SecretCache, teeConfig *TEEConfig) (*PrivyConfig, error) {
+	log.Info("Fetching Privy config")
+
+	ref, err := teeConfig.PrivySecretRef()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse secret as PrivyConfig: %w", err)
+		return nil, err
 	}
 
-	// Validate required fields
-	if config.AppID == "" || config.AppSecret == "" || config.DelegatedActionsKey == "" || config.JWTVerificationKey == "" {
-		return nil, fmt.Errorf("secret missing required fields")
+	secret, err := cache.Get(context.Background(), ref)
+	if err != nil {
+		return nil, err
 	}
 
-	return &config, nil
+	return teeConfig.DecodePrivySecret(secret)
 }
 
 // Loads Ports config from a config path
 func LoadTEEConfig(configPath string) (*TEEConfig, error) {
 	log.Info("Loading port config for networking ports")
 
+	// configor loads an empty config for a missing file
+	if _, err := os.Stat(configPath); err != nil {
+		return nil, fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+
 	var config TEEConfig
 	if err := configor.Load(&config, configPath); err != nil {
 		return nil, fmt.Errorf("failed to load config from %s: %w", configPath, err)
 	}
+	redact.RegisterTagged(&config)
 
-	// Incorrect cfg path triggers here for configor, it will just load empty config
-	if config.Ports.AWSSecretManagerVsockPort == 0 || config.Ports.PrivyAPIVsockPort == 0 || config.Ports.RouterVsockPort == 0 {
+	awsSecrets := config.Secrets.Provider == "" || config.Secrets.Provider == "aws"
+	if (awsSecrets && config.Ports.AWSSecretManagerVsockPort == 0) || config.Ports.PrivyAPIVsockPort == 0 || config.Ports.RouterVsockPort == 0 {
 		return nil, fmt.Errorf("no port loaded from: %s", configPath)
 	}
 
@@ -106,31 +191,74 @@ func LoadTEEConfig(configPath string) (*TEEConfig, error) {
 		return nil, fmt.Errorf("no env loaded from: %s", configPath)
 	}
 
-	if config.Environment != "local" {
+	// GetEnv treats unknown environments as local, which would pick local keys for a deployment with a typo in its env
+	if config.GetEnv() != config.Environment {
+		return nil, fmt.Errorf("unknown env %s loaded from %s, must be prod, staging, dev or local", config.Environment, configPath)
+	}
+
+	if err := validateSignerConfig(&config); err != nil {
+		return nil, fmt.Errorf("invalid signer config in %s: %w", configPath, err)
+	}
+
+	if err := validateStateConfig(&config); err != nil {
+		return nil, fmt.Errorf("invalid state config in %s: %w", configPath, err)
+	}
+
+	if err := validateRPCConfig(&config); err != nil {
+		return nil, fmt.Errorf("invalid rpc config in %s: %w", configPath, err)
+	}
+
+	if len(config.Policies.IDs) > 0 && config.Policies.Hash == "" {
+		return nil, fmt.Errorf("policies without a policy hash loaded from: %s", configPath)
+	}
+
+	region, err := resolveRegion(&config)
+	if err != nil {
+		return nil, err
+	}
+	config.Region = region.String()
+
+	provider, err := NewSecretProvider(configPath, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
 
-		log.Info("loading axal wallets config from sm")
-		if config.Region == "" {
-			config.Region = aws.DEFAULT_AWS_REGION
+	config.secretCache = secretmanager.NewSecretCache(provider,
+		time.Duration(config.Secrets.RefreshIntervalSeconds)*time.Second,
+		time.Duration(config.Secrets.GraceWindowSeconds)*time.Second)
+
+	if config.KMS.EncryptedSecrets && config.Environment == "local" {
+		return nil, fmt.Errorf("kms encrypted secrets need an enclave, they can not be used in local")
+	}
+
+	// One client for the encrypted secrets and the data key of the sealed store, which outside local is wrapped by KMS
+	if config.KMS.EncryptedSecrets || (config.State.Store == "sealed" && config.Environment != "local") {
+		config.kmsClient, err = NewKMSClient(configPath, &config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kms client: %w", err)
 		}
+	}
+
+	if config.Environment != "local" {
 
-		client, err := secretmanager.NewSecretManager(configPath, config.Environment, config.Ports.AWSSecretManagerVsockPort, config.Ports.Ec2CredsVsockPort)
+		log.Info("loading axal wallets config")
 
+		secret, err := config.secretCache.Get(context.Background(), config.AxalSecretRef())
 		if err != nil {
-			return nil, fmt.Errorf("failed to load config from %s: %w", configPath, err)
+			return nil, fmt.Errorf("failed to load axal wallets config from %s: %w", configPath, err)
 		}
 
-		var axalCfg *AxalConfig
-		axalCfg, err = LoadCfgFromSM[AxalConfig](&config, *client, "axal")
+		axalCfg, err := config.DecodeAxalSecret(secret)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load axal wallets config from %s: %w", configPath, err)
 		}
 		config.Axal = *axalCfg
 
-		log.Info("loaded axal wallets config from sm")
+		log.Info("loaded axal wallets config")
 	}
 
 	log.Info("loading privy config")
-	privyConfig, err := InitPrivyConfig(configPath, config)
+	privyConfig, err := InitPrivyConfig(config.secretCache, &config)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to load privy config from %s: %w", configPath, err)
@@ -139,11 +267,106 @@ func LoadTEEConfig(configPath string) (*TEEConfig, error) {
 	config.Privy = *privyConfig
 	log.Info("loaded privy config")
 
-	log.Infof("loaded tee config: %v", config)
+	// The secrets only live in the key store from here on
+	if err := config.SealSecrets(); err != nil {
+		return nil, fmt.Errorf("invalid signer config in %s: %w", configPath, err)
+	}
+
+	log.Infof("loaded tee config: %v", redact.Value(config))
 
 	return &config, nil
 }
 
+// Checks the signer backend exists and that local keys are not used where real funds are
+func validateSignerConfig(cfg *TEEConfig) error {
+	switch cfg.Signer.Backend {
+	case "", "privy":
+		return nil
+	case "local":
+		if cfg.Environment != "local" && cfg.Environment != "dev" {
+			return fmt.Errorf("the local signer can not be used in %s", cfg.Environment)
+		}
+		if cfg.Signer.LocalSeed == "" {
+			return fmt.Errorf("the local signer needs signer.local_seed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown signer backend %s", cfg.Signer.Backend)
+	}
+}
+
+// Defaults the state store and checks kill switch and pause state can only be lost on restart in local. Outside local a missing
+// store is the sealed one.
+func validateStateConfig(cfg *TEEConfig) error {
+	switch cfg.State.Store {
+	case "":
+		if cfg.Environment == "local" {
+			cfg.State.Store = "memory"
+		} else {
+			cfg.State.Store = "sealed"
+		}
+		return nil
+	case "sealed":
+		return nil
+	case "memory":
+		if cfg.Environment != "local" {
+			return fmt.Errorf("the memory state store can not be used in %s, kill switch and pause state would be lost on restart", cfg.Environment)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown state store %s", cfg.State.Store)
+	}
+}
+
+// Checks every chain outside local reads from a quorum of at least MinRPCQuorum distinct providers. With failover, or a quorum
+// of one, a single provider could forge chain state.
+func validateRPCConfig(cfg *TEEConfig) error {
+	for _, chain := range cfg.RPC.Chains {
+		if chain.CrossCheck {
+			return fmt.Errorf("rpc chain %d sets cross_check, which was replaced by quorum", chain.ChainID)
+		}
+		if cfg.Environment == "local" {
+			continue
+		}
+
+		if chain.Quorum < MinRPCQuorum {
+			return fmt.Errorf("rpc chain %d needs a quorum of at least %d in %s", chain.ChainID, MinRPCQuorum, cfg.Environment)
+		}
+		serverNames := make(map[string]bool, len(chain.Providers))
+		for _, provider := range chain.Providers {
+			serverNames[provider.TLSServerName] = true
+		}
+		if len(serverNames) < MinRPCQuorum {
+			return fmt.Errorf("rpc chain %d needs at least %d providers with different tls_server_names in %s", chain.ChainID, MinRPCQuorum, cfg.Environment)
+		}
+	}
+	return nil
+}
+
+// Resolves the AWS region from config, or from IMDS placement/region when deployed. Local runs without a configured region leave
+// it empty so the region of the local credentials is used.
+func resolveRegion(cfg *TEEConfig) (aws.AWSRegion, error) {
+	if cfg.Region == "" && cfg.Environment == "local" {
+		return "", nil
+	}
+
+	var imdsClient *imds.Client
+	if cfg.Environment != "local" && cfg.Ports.Ec2CredsVsockPort != 0 {
+		imdsClient = imds.NewVsockClient(cfg.Ports.Ec2CredsVsockPort)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	region, err := imds.ResolveRegion(ctx, cfg.Region, imdsClient)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve aws region: %w", err)
+	}
+
+	log.Infof("Using aws region %s", region)
+	return region, nil
+}
+
 func (cfg *TEEConfig) GetEnv() string {
 	if cfg.Environment == "prod" || cfg.Environment == "dev" || cfg.Environment == "local" || cfg.Environment == "staging" {
 		return cfg.Environment
@@ -152,57 +375,15 @@ func (cfg *TEEConfig) GetEnv() string {
 	}
 }
 
-// Generic function to load any configuration type from Secrets Manager
-func LoadCfgFromSM[T any](cfg *TEEConfig, client secretmanager.SecretManager, secretType string) (*T, error) {
-	var configData T
-
-	secretName := fmt.Sprintf("%s/%s", cfg.Environment, secretType)
-	secret, err := client.GetSecret(context.Background(), secretName)
+// Generic function to load any configuration type from the JSON value at path in a secret, through the secret cache
+func LoadCfgFromSM[T any](cache *secretmanager.SecretCache, ref secretmanager.SecretRef, path string) (*T, error) {
+	secret, err := cache.Get(context.Background(), ref)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to load %s cfg from secrets manager with err: %w", secretType, err)
-	}
-
-	log.Infof("Fetched Secret from secrets manager with secret name : %s", secretName)
-
-	// Try normal unmarshal first
-	if err := json.Unmarshal([]byte(secret.SecretString), &configData); err != nil {
-		// If it's a string-to-int conversion error, try to fix it
-		if strings.Contains(err.Error(), "cannot unmarshal string into Go struct field") &&
-			strings.Contains(err.Error(), "of type int") {
-
-			// Unmarshal into a map first to manipulate the data
-			var rawData map[string]interface{}
-			if err := json.Unmarshal([]byte(secret.SecretString), &rawData); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal %s config from secrets manager: %w", secretType, err)
-			}
-
-			// Convert string port values to integers
-			for key, value := range rawData {
-				if strings.Contains(key, "port") {
-					if strVal, ok := value.(string); ok {
-						if intVal, err := strconv.Atoi(strVal); err == nil {
-							rawData[key] = intVal
-						}
-					}
-				}
-			}
-
-			// Marshal back to JSON and unmarshal into target struct
-			fixedJSON, err := json.Marshal(rawData)
-			if err != nil {
-				return nil, fmt.Errorf("failed to marshal fixed %s config: %w", secretType, err)
-			}
-
-			if err := json.Unmarshal(fixedJSON, &configData); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal fixed %s config from secrets manager: %w", secretType, err)
-			}
-		} else {
-			return nil, fmt.Errorf("failed to unmarshal %s config from secrets manager: %w", secretType, err)
-		}
+		return nil, fmt.Errorf("failed to load %s cfg from secrets manager with err: %w", ref, err)
 	}
 
-	log.Infof("Loaded config from secrets manager with secret type: %s and config data: %v", secretType, configData)
+	log.Infof("Fetched Secret from secrets manager with secret name : %s", ref)
 
-	return &configData, nil
+	return secretmanager.DecodeSecret[T](secret, path)
 }
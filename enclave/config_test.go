@@ -1,11 +1,33 @@
 package enclave
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	secretmanager "github.com/getaxal/verified-signer/common/aws/secret_manager"
+	"github.com/getaxal/verified-signer/enclave/keystore"
 )
 
+const testPrivySecret = `{"app_id": "app-id", "app_secret": "app-secret", "delegated_actions_key": "delegated-key", "jwt_verification_key": "jwt-key", "key_id": "key-id"}`
+
+// Returns the secret sealed under name in the config key store, empty if there is none
+func sealedSecret(t *testing.T, cfg *TEEConfig, name string) string {
+	t.Helper()
+
+	var value string
+	err := cfg.KeyStore().WithSecret(name, func(secret []byte) error {
+		value = string(secret)
+		return nil
+	})
+	if err != nil && !errors.Is(err, keystore.ErrNotFound) {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	return value
+}
+
 func TestLoadTEEConfig(t *testing.T) {
 	// Create temporary directory for test files
 	tmpDir := t.TempDir()
@@ -22,11 +44,18 @@ func TestLoadTEEConfig(t *testing.T) {
 			name: "valid config",
 			configYAML: `
 environment: "prod"
+region: "us-east-2"
 ports:
   aws_secret_manager_vsock_port: 8001
   privy_api_vsock_port: 8002
   router_vsock_port: 8003
+  kms_vsock_port: 8005
   ec2_creds_vsock_port: 8004
+secrets:
+  provider: "memory"
+  values:
+    prod/privy: '` + testPrivySecret + `'
+    prod/axal: '{"axal_request_secret_key": "axal-key"}'
 `,
 			filename: "valid_config.yaml",
 			wantErr:  false,
@@ -38,17 +67,44 @@ ports:
 					RouterVsockPort:           8003,
 					Ec2CredsVsockPort:         8004,
 				},
+				Privy: PrivyConfig{AppID: "app-id"},
+				Axal:  AxalConfig{AxalRequestSecretKey: "axal-key"},
 			},
 		},
+		{
+			name: "policies without a hash",
+			configYAML: `
+environment: "prod"
+region: "us-east-2"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+policies:
+  ids: ["policy-1"]
+secrets:
+  provider: "memory"
+`,
+			filename:    "policies_without_hash.yaml",
+			wantErr:     true,
+			errContains: "policies without a policy hash",
+		},
 		{
 			name: "valid config with dev environment",
 			configYAML: `
 environment: "dev"
+region: "us-east-2"
 ports:
   aws_secret_manager_vsock_port: 9001
   privy_api_vsock_port: 9002
   router_vsock_port: 9003
+  kms_vsock_port: 9005
   ec2_creds_vsock_port: 9004
+secrets:
+  provider: "memory"
+  values:
+    dev/privy: '` + testPrivySecret + `'
+    dev/axal: '{"axal_request_secret_key": "axal-key"}'
 `,
 			filename: "dev_config.yaml",
 			wantErr:  false,
@@ -60,6 +116,8 @@ ports:
 					RouterVsockPort:           9003,
 					Ec2CredsVsockPort:         9004,
 				},
+				Privy: PrivyConfig{AppID: "app-id"},
+				Axal:  AxalConfig{AxalRequestSecretKey: "axal-key"},
 			},
 		},
 		{
@@ -69,6 +127,7 @@ ports:
   aws_secret_manager_vsock_port: 8001
   privy_api_vsock_port: 8002
   router_vsock_port: 8003
+  kms_vsock_port: 8005
   ec2_creds_vsock_port: 8004
 `,
 			filename:    "no_env_config.yaml",
@@ -82,6 +141,7 @@ environment: "prod"
 ports:
   privy_api_vsock_port: 8002
   router_vsock_port: 8003
+  kms_vsock_port: 8005
   ec2_creds_vsock_port: 8004
 `,
 			filename:    "no_aws_port_config.yaml",
@@ -95,6 +155,7 @@ environment: "prod"
 ports:
   aws_secret_manager_vsock_port: 8001
   router_vsock_port: 8003
+  kms_vsock_port: 8005
   ec2_creds_vsock_port: 8004
 `,
 			filename:    "no_privy_port_config.yaml",
@@ -122,6 +183,7 @@ ports:
   aws_secret_manager_vsock_port: 0
   privy_api_vsock_port: 8002
   router_vsock_port: 8003
+  kms_vsock_port: 8005
   ec2_creds_vsock_port: 8004
 `,
 			filename:    "zero_aws_port_config.yaml",
@@ -136,12 +198,82 @@ ports:
   aws_secret_manager_vsock_port: 8001
   privy_api_vsock_port: 8002
   router_vsock_port: 8003
+  kms_vsock_port: 8005
   ec2_creds_vsock_port: 8004
 `,
 			filename:    "empty_env_config.yaml",
 			wantErr:     true,
 			errContains: "no env loaded from",
 		},
+		{
+			name: "unknown environment",
+			configYAML: `
+environment: "production"
+ports:
+  aws_secret_manager_vsock_port: 8001
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+  ec2_creds_vsock_port: 8004
+`,
+			filename:    "unknown_env_config.yaml",
+			wantErr:     true,
+			errContains: "unknown env production",
+		},
+		{
+			name: "AWS port not needed without the aws secret provider",
+			configYAML: `
+environment: "local"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "memory"
+  values:
+    dev/privy: '` + testPrivySecret + `'
+`,
+			filename: "memory_no_aws_port_config.yaml",
+			wantErr:  false,
+			want: &TEEConfig{
+				Environment: "local",
+				Ports: PortConfig{
+					PrivyAPIVsockPort: 8002,
+					RouterVsockPort:   8003,
+				},
+				Privy: PrivyConfig{AppID: "app-id"},
+			},
+		},
+		{
+			name: "unknown secret provider",
+			configYAML: `
+environment: "local"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "vault"
+`,
+			filename:    "unknown_provider_config.yaml",
+			wantErr:     true,
+			errContains: "unknown secret provider",
+		},
+		{
+			name: "missing privy secret",
+			configYAML: `
+environment: "local"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "memory"
+`,
+			filename:    "missing_privy_secret_config.yaml",
+			wantErr:     true,
+			errContains: "failed to load privy config from",
+		},
 		{
 			name: "EC2 creds port can be zero (not validated)",
 			configYAML: `
@@ -150,7 +282,12 @@ ports:
   aws_secret_manager_vsock_port: 8001
   privy_api_vsock_port: 8002
   router_vsock_port: 8003
+  kms_vsock_port: 8005
   ec2_creds_vsock_port: 0
+secrets:
+  provider: "memory"
+  values:
+    dev/privy: '` + testPrivySecret + `'
 `,
 			filename: "zero_ec2_port_config.yaml",
 			wantErr:  false,
@@ -162,6 +299,7 @@ ports:
 					RouterVsockPort:           8003,
 					Ec2CredsVsockPort:         0,
 				},
+				Privy: PrivyConfig{AppID: "app-id"},
 			},
 		},
 	}
@@ -214,10 +352,138 @@ ports:
 				t.Errorf("LoadTEEConfig() Ec2CredsVsockPort = %v, want %v",
 					got.Ports.Ec2CredsVsockPort, tt.want.Ports.Ec2CredsVsockPort)
 			}
+			if got.Privy.AppID != tt.want.Privy.AppID {
+				t.Errorf("LoadTEEConfig() Privy.AppID = %v, want %v", got.Privy.AppID, tt.want.Privy.AppID)
+			}
+			if key := sealedSecret(t, got, keystore.AxalRequestSecretKey); key != tt.want.Axal.AxalRequestSecretKey {
+				t.Errorf("LoadTEEConfig() sealed AxalRequestSecretKey = %v, want %v", key, tt.want.Axal.AxalRequestSecretKey)
+			}
+			if got.Axal.AxalRequestSecretKey != "" || got.Privy.AppSecret != "" || got.Privy.DelegatedActionsKey != "" {
+				t.Errorf("LoadTEEConfig() left secrets in the config: Axal = %+v, Privy = %+v", got.Axal, got.Privy)
+			}
 		})
 	}
 }
 
+func TestLoadTEEConfig_FileProviderWithSecretNames(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	secretsPath := filepath.Join(tmpDir, "secrets.json")
+	secrets := `{"laptop/privy": ` + testPrivySecret + `, "laptop/axal": {"axal_request_secret_key": "axal-key"}}`
+	if err := os.WriteFile(secretsPath, []byte(secrets), 0600); err != nil {
+		t.Fatalf("Failed to create secrets file: %v", err)
+	}
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configYAML := `
+environment: "dev"
+region: "eu-west-1"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "file"
+  file: "` + secretsPath + `"
+  privy_secret_name: "laptop/privy"
+  axal_secret_name: "laptop/axal"
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	got, err := LoadTEEConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+	}
+
+	if sealedSecret(t, got, keystore.PrivyAppSecret) != "app-secret" || got.Privy.DelegatedActionsKeyId != "key-id" {
+		t.Errorf("LoadTEEConfig() Privy = %+v, want the laptop/privy secret", got.Privy)
+	}
+	if sealedSecret(t, got, keystore.PrivyDelegatedActionsKey) != "delegated-key" {
+		t.Error("LoadTEEConfig() did not seal the laptop/privy delegated actions key")
+	}
+	if key := sealedSecret(t, got, keystore.AxalRequestSecretKey); key != "axal-key" {
+		t.Errorf("LoadTEEConfig() sealed AxalRequestSecretKey = %v, want axal-key", key)
+	}
+	if got.Region != "eu-west-1" {
+		t.Errorf("LoadTEEConfig() Region = %v, want eu-west-1", got.Region)
+	}
+	if got.SecretCache() == nil {
+		t.Error("LoadTEEConfig() should keep the secret cache for rotations")
+	}
+}
+
+func TestLoadTEEConfig_EnvProvider(t *testing.T) {
+	t.Setenv("AXAL_SECRET_DEV_PRIVY", testPrivySecret)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := `
+environment: "local"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "env"
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	got, err := LoadTEEConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+	}
+	if got.Privy.AppID != "app-id" {
+		t.Errorf("LoadTEEConfig() Privy.AppID = %v, want app-id", got.Privy.AppID)
+	}
+}
+
+func TestLoadTEEConfig_SecretPaths(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := `
+environment: "dev"
+region: "us-east-2"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "memory"
+  privy_secret_name: "dev/signer"
+  privy_secret_path: "privy"
+  axal_secret_name: "dev/signer"
+  axal_secret_path: "axal"
+  values:
+    dev/signer: '{"privy": ` + testPrivySecret + `, "axal": {"axal_request_secret_key": "axal-key"}}'
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	got, err := LoadTEEConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+	}
+	if key := sealedSecret(t, got, keystore.AxalRequestSecretKey); got.Privy.AppID != "app-id" || key != "axal-key" {
+		t.Errorf("LoadTEEConfig() Privy.AppID = %v, sealed AxalRequestSecretKey = %v", got.Privy.AppID, key)
+	}
+}
+
+func TestDecodePrivySecret_NamesMissingField(t *testing.T) {
+	cfg := &TEEConfig{Environment: "local"}
+	secret := secretmanager.Secret{
+		Name:  "dev/privy",
+		Value: `{"app_id": "app-id", "app_secret": "app-secret", "delegated_actions_key": "delegated-key"}`,
+	}
+
+	_, err := cfg.DecodePrivySecret(secret)
+	if err == nil || !containsString(err.Error(), "jwt_verification_key") || !errors.Is(err, secretmanager.ErrMissingField) {
+		t.Errorf("DecodePrivySecret() error = %v, want the missing jwt_verification_key", err)
+	}
+}
+
 func TestLoadTEEConfig_FileNotFound(t *testing.T) {
 	nonExistentPath := "/path/that/does/not/exist/config.yaml"
 
@@ -391,3 +657,268 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestPrivyConfig_AdditionalSignerId(t *testing.T) {
+	cfg := PrivyConfig{DelegatedActionsKeyId: "key-id"}
+	if got := cfg.AdditionalSignerId(); got != "key-id" {
+		t.Errorf("AdditionalSignerId() = %v, want key-id", got)
+	}
+
+	cfg.KeyQuorumId = "quorum-id"
+	if got := cfg.AdditionalSignerId(); got != "quorum-id" {
+		t.Errorf("AdditionalSignerId() = %v, want the key quorum", got)
+	}
+}
+
+func TestLoadTEEConfig_KeyQuorum(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := `
+environment: "dev"
+region: "us-east-2"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "memory"
+  values:
+    dev/privy: '{"app_id": "app-id", "app_secret": "app-secret", "delegated_actions_key": "delegated-key", "jwt_verification_key": "jwt-key", "authorization_keys": ["quorum-key-1", "quorum-key-2"], "key_quorum_id": "quorum-id"}'
+    dev/axal: '{"axal_request_secret_key": "axal-key"}'
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	got, err := LoadTEEConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+	}
+
+	if got.Privy.AuthorizationKeys != nil || got.Privy.KeyQuorumId != "quorum-id" {
+		t.Errorf("LoadTEEConfig() Privy = %+v, want the key quorum with its keys sealed", got.Privy)
+	}
+	for i, want := range []string{"quorum-key-1", "quorum-key-2"} {
+		if key := sealedSecret(t, got, keystore.PrivyQuorumKey(i)); key != want {
+			t.Errorf("LoadTEEConfig() sealed quorum key %d = %v, want %v", i, key, want)
+		}
+	}
+
+	// Sealing a smaller quorum drops the rest
+	SealPrivySecrets(got.KeyStore(), &PrivyConfig{AuthorizationKeys: []string{"quorum-key-3"}})
+	if sealedSecret(t, got, keystore.PrivyQuorumKey(0)) != "quorum-key-3" || got.KeyStore().Has(keystore.PrivyQuorumKey(1)) {
+		t.Error("SealPrivySecrets() kept a quorum key that was dropped")
+	}
+}
+
+func TestLoadTEEConfig_Signer(t *testing.T) {
+	seed := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name        string
+		environment string
+		signer      string
+		errContains string
+	}{
+		{name: "privy by default", environment: "prod"},
+		{name: "local signer in dev", environment: "dev", signer: "backend: \"local\"\n  local_seed: \"" + seed + "\""},
+		{name: "local signer in prod", environment: "prod", signer: "backend: \"local\"\n  local_seed: \"" + seed + "\"", errContains: "can not be used in prod"},
+		{name: "local signer without a seed", environment: "dev", signer: "backend: \"local\"", errContains: "needs signer.local_seed"},
+		{name: "local seed not hex", environment: "dev", signer: "backend: \"local\"\n  local_seed: \"zz\"", errContains: "not hex"},
+		{name: "unknown backend", environment: "dev", signer: "backend: \"vault\"", errContains: "unknown signer backend"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configYAML := `
+environment: "` + tt.environment + `"
+region: "us-east-2"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "memory"
+  values:
+    ` + tt.environment + `/privy: '` + testPrivySecret + `'
+    ` + tt.environment + `/axal: '{"axal_request_secret_key": "axal-key"}'
+`
+			if tt.signer != "" {
+				configYAML += "signer:\n  " + tt.signer + "\n"
+			}
+
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+				t.Fatalf("Failed to create test config file: %v", err)
+			}
+
+			got, err := LoadTEEConfig(configPath)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("LoadTEEConfig() error = %v, want error containing %v", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+			}
+
+			if got.Signer.LocalSeed != "" {
+				t.Error("LoadTEEConfig() left the local signer seed in the config")
+			}
+			if tt.signer != "" && sealedSecret(t, got, keystore.LocalSignerSeed) != strings.Repeat("\xab", 32) {
+				t.Error("LoadTEEConfig() did not seal the local signer seed")
+			}
+		})
+	}
+}
+
+func TestLoadTEEConfig_StateStore(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		store       string
+		want        string
+		errContains string
+	}{
+		{name: "sealed by default", environment: "prod", want: "sealed"},
+		{name: "sealed by default in staging", environment: "staging", want: "sealed"},
+		{name: "memory by default in local", environment: "local", want: "memory"},
+		{name: "memory in local", environment: "local", store: "memory", want: "memory"},
+		{name: "sealed in dev", environment: "dev", store: "sealed", want: "sealed"},
+		{name: "memory in prod", environment: "prod", store: "memory", errContains: "can not be used in prod"},
+		{name: "memory in dev", environment: "dev", store: "memory", errContains: "can not be used in dev"},
+		{name: "unknown store", environment: "dev", store: "redis", errContains: "unknown state store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// local reads the dev secrets
+			secretEnv := tt.environment
+			if secretEnv == "local" {
+				secretEnv = "dev"
+			}
+			configYAML := `
+environment: "` + tt.environment + `"
+region: "us-east-2"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "memory"
+  values:
+    ` + secretEnv + `/privy: '` + testPrivySecret + `'
+    ` + secretEnv + `/axal: '{"axal_request_secret_key": "axal-key"}'
+`
+			if tt.store != "" {
+				configYAML += "state:\n  store: \"" + tt.store + "\"\n"
+			}
+
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+				t.Fatalf("Failed to create test config file: %v", err)
+			}
+
+			got, err := LoadTEEConfig(configPath)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("LoadTEEConfig() error = %v, want error containing %v", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+			}
+			if got.State.Store != tt.want {
+				t.Errorf("LoadTEEConfig() State.Store = %s, want %s", got.State.Store, tt.want)
+			}
+			// The data key of the sealed store is wrapped by KMS outside local
+			if wantKMS := tt.want == "sealed" && tt.environment != "local"; (got.KMSClient() != nil) != wantKMS {
+				t.Errorf("LoadTEEConfig() KMSClient() = %v, want a client %v", got.KMSClient(), wantKMS)
+			}
+		})
+	}
+}
+
+func TestLoadTEEConfig_RPC(t *testing.T) {
+	alchemy := `
+        - name: "alchemy"
+          url: "https://eth-mainnet.g.alchemy.com/v2/key"
+          tls_server_name: "eth-mainnet.g.alchemy.com"
+          vsock_port: 8010`
+	infura := `
+        - name: "infura"
+          url: "https://mainnet.infura.io/v3/key"
+          tls_server_name: "mainnet.infura.io"
+          vsock_port: 8011`
+	alchemyAgain := `
+        - name: "alchemy-backup"
+          url: "https://eth-mainnet.g.alchemy.com/v2/other-key"
+          tls_server_name: "eth-mainnet.g.alchemy.com"
+          vsock_port: 8012`
+
+	tests := []struct {
+		name        string
+		environment string
+		chain       string
+		providers   string
+		errContains string
+	}{
+		{name: "quorum in prod", environment: "prod", chain: "quorum: 2", providers: alchemy + infura},
+		{name: "failover in local", environment: "local", providers: alchemy},
+		{name: "one of one in local", environment: "local", chain: "quorum: 1", providers: alchemy},
+		{name: "failover in prod", environment: "prod", providers: alchemy + infura, errContains: "rpc chain 1 needs a quorum of at least 2 in prod"},
+		{name: "failover in dev", environment: "dev", providers: alchemy + infura, errContains: "rpc chain 1 needs a quorum of at least 2 in dev"},
+		{name: "one of one in prod", environment: "prod", chain: "quorum: 1", providers: alchemy, errContains: "rpc chain 1 needs a quorum of at least 2 in prod"},
+		{name: "one provider twice in prod", environment: "prod", chain: "quorum: 2", providers: alchemy + alchemyAgain, errContains: "at least 2 providers with different tls_server_names"},
+		{name: "cross check", environment: "local", chain: "cross_check: true", providers: alchemy, errContains: "replaced by quorum"},
+		{name: "cross check with a quorum", environment: "prod", chain: "quorum: 2\n      cross_check: true", providers: alchemy + infura, errContains: "replaced by quorum"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// local reads the dev secrets
+			secretEnv := tt.environment
+			if secretEnv == "local" {
+				secretEnv = "dev"
+			}
+			configYAML := `
+environment: "` + tt.environment + `"
+region: "us-east-2"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+secrets:
+  provider: "memory"
+  values:
+    ` + secretEnv + `/privy: '` + testPrivySecret + `'
+    ` + secretEnv + `/axal: '{"axal_request_secret_key": "axal-key"}'
+rpc:
+  chains:
+    - chain_id: 1
+      ` + tt.chain + `
+      providers:` + tt.providers + `
+`
+
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+				t.Fatalf("Failed to create test config file: %v", err)
+			}
+
+			got, err := LoadTEEConfig(configPath)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("LoadTEEConfig() error = %v, want error containing %v", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+			}
+			if len(got.RPC.Chains) != 1 || len(got.RPC.Chains[0].Providers) != strings.Count(tt.providers, "- name:") {
+				t.Errorf("LoadTEEConfig() RPC = %+v, want one chain with the configured providers", got.RPC)
+			}
+		})
+	}
+}
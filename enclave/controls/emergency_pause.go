@@ -0,0 +1,282 @@
+package controls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+	"github.com/getaxal/verified-signer/enclave/audit"
+	authorizationsignature "github.com/getaxal/verified-signer/enclave/privy-signer/authorization_signature"
+	"github.com/getaxal/verified-signer/enclave/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrAxalSigningPaused is returned for all Axal initiated signing while the operators have paused it
+var ErrAxalSigningPaused = errors.New("axal initiated signing is paused")
+
+// ErrPauseUnavailable is returned when the pause state could not be read or written. Callers must treat this as paused.
+var ErrPauseUnavailable = errors.New("pause state is unavailable")
+
+// ErrPauseNotConfigured is returned when pause commands are sent to an enclave with no operator quorum
+var ErrPauseNotConfigured = errors.New("emergency pause has no operator quorum configured")
+
+const pauseStateKey = "pause/state"
+
+var GlobalPause *EmergencyPause
+
+// OperatorKey is an operator public key that can sign pause commands
+type OperatorKey struct {
+	KeyID     string
+	PublicKey *ecdsa.PublicKey
+}
+
+// EmergencyPause freezes Axal initiated signing for every user. It only accepts commands signed by a threshold of the operator
+// keys baked into the image, so no single host, backend or operator can pause or unpause on their own.
+type EmergencyPause struct {
+	store       state.Store
+	auditLog    *audit.Recorder
+	environment string
+	threshold   int
+	operators   map[string]*ecdsa.PublicKey
+	now         func() time.Time
+	mu          sync.Mutex
+}
+
+// Inits a new EmergencyPause and initiates it to controls.GlobalPause
+func InitEmergencyPause(store state.Store, auditLog *audit.Recorder, environment string, threshold int, operators []OperatorKey) error {
+	pause, err := NewEmergencyPause(store, auditLog, environment, threshold, operators)
+	if err != nil {
+		return err
+	}
+
+	GlobalPause = pause
+	return nil
+}
+
+// Creates a new EmergencyPause. A threshold of 0 with no operators is allowed, in which case every pause command is rejected.
+func NewEmergencyPause(store state.Store, auditLog *audit.Recorder, environment string, threshold int, operators []OperatorKey) (*EmergencyPause, error) {
+	operatorMap := make(map[string]*ecdsa.PublicKey, len(operators))
+	for _, op := range operators {
+		if op.KeyID == "" || op.PublicKey == nil {
+			return nil, fmt.Errorf("operator key is missing its key id or public key")
+		}
+		if _, ok := operatorMap[op.KeyID]; ok {
+			return nil, fmt.Errorf("duplicate operator key id: %s", op.KeyID)
+		}
+		operatorMap[op.KeyID] = op.PublicKey
+	}
+
+	if threshold < 0 || threshold > len(operatorMap) {
+		return nil, fmt.Errorf("pause threshold %d is invalid for %d operators", threshold, len(operatorMap))
+	}
+	if len(operatorMap) > 0 && threshold == 0 {
+		return nil, fmt.Errorf("pause threshold must be at least 1 when operators are configured")
+	}
+
+	if threshold == 0 {
+		log.Warn("Emergency pause has no operator quorum configured, pause commands will be rejected")
+	}
+
+	return &EmergencyPause{
+		store:       store,
+		auditLog:    auditLog,
+		environment: environment,
+		threshold:   threshold,
+		operators:   operatorMap,
+		now:         time.Now,
+	}, nil
+}
+
+// Parses a PEM encoded ECDSA P-256 operator public key
+func ParseOperatorKey(keyId string, publicKeyPEM string) (*OperatorKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("operator key %s is not PEM encoded", keyId)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse operator key %s: %w", keyId, err)
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("operator key %s is not an ECDSA public key", keyId)
+	}
+	if ecdsaKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("operator key %s is on %s, operator keys must be P-256", keyId, ecdsaKey.Curve.Params().Name)
+	}
+
+	return &OperatorKey{KeyID: keyId, PublicKey: ecdsaKey}, nil
+}
+
+// Gets the current pause state
+func (p *EmergencyPause) Status() (*PauseState, error) {
+	raw, err := p.store.Get(pauseStateKey)
+	if errors.Is(err, state.ErrNotFound) {
+		return &PauseState{}, nil
+	}
+	if err != nil {
+		log.Errorf("Unable to read pause state with err: %v", err)
+		return nil, ErrPauseUnavailable
+	}
+
+	var pauseState PauseState
+	if err := json.Unmarshal(raw, &pauseState); err != nil {
+		log.Errorf("Unable to parse pause state with err: %v", err)
+		return nil, ErrPauseUnavailable
+	}
+
+	return &pauseState, nil
+}
+
+// Checks that Axal signing is not paused. Fails closed if the pause state could not be read.
+func (p *EmergencyPause) CheckAxalSigningAllowed() error {
+	pauseState, err := p.Status()
+	if err != nil {
+		return err
+	}
+
+	if pauseState.Paused {
+		return ErrAxalSigningPaused
+	}
+
+	return nil
+}
+
+// Applies a signed pause or unpause command. The command must be for this action and environment, must not have expired,
+// must carry a sequence number higher than every command applied before and must be signed by a threshold of operators.
+func (p *EmergencyPause) Apply(action PauseAction, signed *SignedPauseCommand) (*PauseState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.threshold == 0 {
+		return nil, ErrPauseNotConfigured
+	}
+
+	cmd := signed.Command
+	if cmd.Action != action {
+		return nil, fmt.Errorf("%w: command is for %s, not %s", ErrInvalidPauseCommand, cmd.Action, action)
+	}
+	if cmd.Environment != p.environment {
+		return nil, fmt.Errorf("%w: command is for environment %s", ErrInvalidPauseCommand, cmd.Environment)
+	}
+	if p.now().Unix() >= cmd.ExpiresAt {
+		return nil, fmt.Errorf("%w: command has expired", ErrInvalidPauseCommand)
+	}
+
+	signers, err := p.verifySignatures(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := p.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Sequence <= current.Sequence {
+		return nil, fmt.Errorf("%w: sequence %d has already been used, next sequence must be above %d", ErrInvalidPauseCommand, cmd.Sequence, current.Sequence)
+	}
+
+	next := &PauseState{
+		Paused:    action == PauseActionPause,
+		Sequence:  cmd.Sequence,
+		Reason:    cmd.Reason,
+		UpdatedAt: p.now().Unix(),
+		Signers:   signers,
+	}
+
+	// The audit entry goes first, a command that can not be audited is not applied
+	if p.auditLog == nil {
+		return nil, fmt.Errorf("emergency pause has no audit log")
+	}
+	details := map[string]string{
+		"sequence":    strconv.FormatUint(cmd.Sequence, 10),
+		"environment": cmd.Environment,
+		"reason":      cmd.Reason,
+	}
+	_, err = p.auditLog.Record("emergency_"+string(action), signers, details)
+	if err != nil {
+		log.Errorf("Unable to record pause command in audit log with err: %v", err)
+		return nil, ErrPauseUnavailable
+	}
+
+	raw, err := json.Marshal(next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pause state: %w", err)
+	}
+
+	if err := p.store.Put(pauseStateKey, raw); err != nil {
+		log.Errorf("Unable to write pause state with err: %v", err)
+		// The trail already has the command, record that it was not applied so a retry does not read as a second one
+		if _, err := p.auditLog.Record("emergency_"+string(action)+"_failed", signers, details); err != nil {
+			log.Errorf("Unable to record failed pause command in audit log with err: %v", err)
+		}
+		return nil, ErrPauseUnavailable
+	}
+
+	log.Warnf("Emergency %s applied with sequence %d by operators %v", action, cmd.Sequence, signers)
+	return next, nil
+}
+
+// Verifies the operator signatures over the canonical command and returns the sorted ids of the operators that signed.
+// Unknown keys and repeated signatures from the same operator do not count towards the threshold.
+func (p *EmergencyPause) verifySignatures(signed *SignedPauseCommand) ([]string, error) {
+	payload, err := signed.Command.CanonicalPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	signers := make(map[string]bool)
+	for _, sig := range signed.Signatures {
+		publicKey, ok := p.operators[sig.KeyID]
+		if !ok || signers[sig.KeyID] {
+			continue
+		}
+
+		valid, err := authorizationsignature.VerifySignature(publicKey, payload, []byte(sig.Signature))
+		if err != nil || !valid {
+			log.Warnf("Invalid pause command signature from operator %s", sig.KeyID)
+			continue
+		}
+
+		signers[sig.KeyID] = true
+	}
+
+	if len(signers) < p.threshold {
+		return nil, fmt.Errorf("%w: %d valid operator signatures, %d required", ErrInvalidPauseCommand, len(signers), p.threshold)
+	}
+
+	signerIds := make([]string, 0, len(signers))
+	for keyId := range signers {
+		signerIds = append(signerIds, keyId)
+	}
+	sort.Strings(signerIds)
+
+	return signerIds, nil
+}
+
+// Gets the canonical JSON (RFC 8785) of the command, this is what operators sign
+func (cmd *PauseCommand) CanonicalPayload() ([]byte, error) {
+	raw, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pause command: %w", err)
+	}
+
+	canonical, err := jsoncanonicalizer.Transform(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize pause command: %w", err)
+	}
+
+	return canonical, nil
+}
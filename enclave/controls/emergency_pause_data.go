@@ -0,0 +1,66 @@
+package controls
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPauseCommand is returned when a pause command is rejected
+var ErrInvalidPauseCommand = errors.New("invalid pause command")
+
+type PauseAction string
+
+const (
+	PauseActionPause   PauseAction = "pause"
+	PauseActionUnpause PauseAction = "unpause"
+)
+
+// PauseState is the persisted global pause record
+type PauseState struct {
+	Paused    bool     `json:"paused"`
+	Sequence  uint64   `json:"sequence"` // Sequence of the last applied command, the next command must be higher
+	Reason    string   `json:"reason,omitempty"`
+	UpdatedAt int64    `json:"updated_at,omitempty"`
+	Signers   []string `json:"signers,omitempty"`
+}
+
+// PauseCommand is the payload the operators sign
+type PauseCommand struct {
+	Action      PauseAction `json:"action"`
+	Environment string      `json:"environment"`
+	Sequence    uint64      `json:"sequence"`
+	ExpiresAt   int64       `json:"expires_at"`
+	Reason      string      `json:"reason"`
+}
+
+// OperatorSignature is an operator's base64 encoded ECDSA P-256 signature over the canonical command
+type OperatorSignature struct {
+	KeyID     string `json:"key_id"`
+	Signature string `json:"signature"`
+}
+
+// SignedPauseCommand is the request body for the admin pause routes
+type SignedPauseCommand struct {
+	Command    PauseCommand        `json:"command"`
+	Signatures []OperatorSignature `json:"signatures"`
+}
+
+// Validates the shape of a signed pause command, signatures are checked when it is applied
+func (req *SignedPauseCommand) Validate() error {
+	if req.Command.Action != PauseActionPause && req.Command.Action != PauseActionUnpause {
+		return fmt.Errorf("action must be 'pause' or 'unpause'")
+	}
+	if req.Command.Environment == "" {
+		return fmt.Errorf("environment is required")
+	}
+	if req.Command.Sequence == 0 {
+		return fmt.Errorf("sequence must be above 0")
+	}
+	if req.Command.ExpiresAt == 0 {
+		return fmt.Errorf("expires_at is required")
+	}
+	if len(req.Signatures) == 0 {
+		return fmt.Errorf("signatures are required")
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package controls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/audit"
+	authorizationsignature "github.com/getaxal/verified-signer/enclave/privy-signer/authorization_signature"
+	"github.com/getaxal/verified-signer/enclave/state"
+)
+
+type testOperator struct {
+	keyId      string
+	privateKey *ecdsa.PrivateKey
+}
+
+func newTestOperators(t *testing.T, ids ...string) []testOperator {
+	t.Helper()
+
+	operators := make([]testOperator, 0, len(ids))
+	for _, id := range ids {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate operator key: %v", err)
+		}
+		operators = append(operators, testOperator{keyId: id, privateKey: key})
+	}
+
+	return operators
+}
+
+func (op testOperator) sign(t *testing.T, cmd PauseCommand) OperatorSignature {
+	t.Helper()
+
+	payload, err := cmd.CanonicalPayload()
+	if err != nil {
+		t.Fatalf("CanonicalPayload() error = %v", err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(op.privateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal operator key: %v", err)
+	}
+
+	sig, err := authorizationsignature.SignPayload([]byte(base64.StdEncoding.EncodeToString(pkcs8)), payload)
+	if err != nil {
+		t.Fatalf("SignPayload() error = %v", err)
+	}
+
+	return OperatorSignature{KeyID: op.keyId, Signature: string(sig)}
+}
+
+func newTestPause(t *testing.T, store state.Store, threshold int, operators []testOperator) (*EmergencyPause, *audit.Recorder) {
+	t.Helper()
+
+	keys := make([]OperatorKey, 0, len(operators))
+	for _, op := range operators {
+		keys = append(keys, OperatorKey{KeyID: op.keyId, PublicKey: &op.privateKey.PublicKey})
+	}
+
+	recorder := audit.NewRecorder(store)
+	pause, err := NewEmergencyPause(store, recorder, "prod", threshold, keys)
+	if err != nil {
+		t.Fatalf("NewEmergencyPause() error = %v", err)
+	}
+	pause.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+
+	return pause, recorder
+}
+
+func newTestCommand(action PauseAction, sequence uint64) PauseCommand {
+	return PauseCommand{
+		Action:      action,
+		Environment: "prod",
+		Sequence:    sequence,
+		ExpiresAt:   1_700_000_600,
+		Reason:      "incident",
+	}
+}
+
+func TestEmergencyPause_PauseAndUnpause(t *testing.T) {
+	operators := newTestOperators(t, "op1", "op2", "op3")
+	store := state.NewMemoryStore()
+	pause, recorder := newTestPause(t, store, 2, operators)
+
+	if err := pause.CheckAxalSigningAllowed(); err != nil {
+		t.Fatalf("CheckAxalSigningAllowed() before pause error = %v, want nil", err)
+	}
+
+	cmd := newTestCommand(PauseActionPause, 1)
+	pauseState, err := pause.Apply(PauseActionPause, &SignedPauseCommand{
+		Command:    cmd,
+		Signatures: []OperatorSignature{operators[0].sign(t, cmd), operators[2].sign(t, cmd)},
+	})
+	if err != nil {
+		t.Fatalf("Apply() pause error = %v", err)
+	}
+	if !pauseState.Paused || pauseState.Sequence != 1 {
+		t.Errorf("Apply() state = %+v, want paused with sequence 1", pauseState)
+	}
+
+	if err := pause.CheckAxalSigningAllowed(); !errors.Is(err, ErrAxalSigningPaused) {
+		t.Errorf("CheckAxalSigningAllowed() after pause error = %v, want %v", err, ErrAxalSigningPaused)
+	}
+
+	// The pause survives a restart on the same store
+	restarted, _ := newTestPause(t, store, 2, operators)
+	if err := restarted.CheckAxalSigningAllowed(); !errors.Is(err, ErrAxalSigningPaused) {
+		t.Errorf("CheckAxalSigningAllowed() after restart error = %v, want %v", err, ErrAxalSigningPaused)
+	}
+
+	cmd = newTestCommand(PauseActionUnpause, 2)
+	if _, err := pause.Apply(PauseActionUnpause, &SignedPauseCommand{
+		Command:    cmd,
+		Signatures: []OperatorSignature{operators[1].sign(t, cmd), operators[2].sign(t, cmd)},
+	}); err != nil {
+		t.Fatalf("Apply() unpause error = %v", err)
+	}
+
+	if err := pause.CheckAxalSigningAllowed(); err != nil {
+		t.Errorf("CheckAxalSigningAllowed() after unpause error = %v, want nil", err)
+	}
+
+	// Both commands are in the audit trail
+	for index, event := range map[uint64]string{1: "emergency_pause", 2: "emergency_unpause"} {
+		entry, err := recorder.Get(index)
+		if err != nil {
+			t.Fatalf("audit Get(%d) error = %v", index, err)
+		}
+		if entry.Event != event {
+			t.Errorf("audit entry %d event = %s, want %s", index, entry.Event, event)
+		}
+	}
+	if err := recorder.Verify(); err != nil {
+		t.Errorf("audit Verify() error = %v", err)
+	}
+}
+
+// failingPutStore is a state store that fails writes of one key while fail is set
+type failingPutStore struct {
+	state.Store
+	failKey string
+	fail    bool
+}
+
+func (s *failingPutStore) Put(key string, value []byte) error {
+	if s.fail && key == s.failKey {
+		return errors.New("host is gone")
+	}
+	return s.Store.Put(key, value)
+}
+
+func TestEmergencyPause_FailedStateWrite(t *testing.T) {
+	operators := newTestOperators(t, "op1", "op2", "op3")
+	store := &failingPutStore{Store: state.NewMemoryStore(), failKey: pauseStateKey, fail: true}
+	pause, recorder := newTestPause(t, store, 2, operators)
+
+	cmd := newTestCommand(PauseActionPause, 1)
+	signed := &SignedPauseCommand{
+		Command:    cmd,
+		Signatures: []OperatorSignature{operators[0].sign(t, cmd), operators[1].sign(t, cmd)},
+	}
+	if _, err := pause.Apply(PauseActionPause, signed); !errors.Is(err, ErrPauseUnavailable) {
+		t.Fatalf("Apply() error = %v, want %v", err, ErrPauseUnavailable)
+	}
+	if err := pause.CheckAxalSigningAllowed(); err != nil {
+		t.Errorf("CheckAxalSigningAllowed() after a failed pause error = %v, want nil", err)
+	}
+
+	// Retrying the same command works once the store does
+	store.fail = false
+	if _, err := pause.Apply(PauseActionPause, signed); err != nil {
+		t.Fatalf("Apply() retry error = %v", err)
+	}
+
+	// The trail shows the command failed before it was applied
+	for index, event := range map[uint64]string{1: "emergency_pause", 2: "emergency_pause_failed", 3: "emergency_pause"} {
+		entry, err := recorder.Get(index)
+		if err != nil {
+			t.Fatalf("audit Get(%d) error = %v", index, err)
+		}
+		if entry.Event != event || entry.Details["sequence"] != "1" {
+			t.Errorf("audit entry %d = %s for sequence %s, want %s for sequence 1", index, entry.Event, entry.Details["sequence"], event)
+		}
+	}
+	if err := recorder.Verify(); err != nil {
+		t.Errorf("audit Verify() error = %v", err)
+	}
+}
+
+func TestEmergencyPause_RejectsInvalidCommands(t *testing.T) {
+	operators := newTestOperators(t, "op1", "op2", "op3")
+	outsider := newTestOperators(t, "op4")[0]
+
+	tests := []struct {
+		name      string
+		action    PauseAction
+		buildCmd  func() *SignedPauseCommand
+		wantError error
+	}{
+		{
+			name:   "below threshold",
+			action: PauseActionPause,
+			buildCmd: func() *SignedPauseCommand {
+				cmd := newTestCommand(PauseActionPause, 1)
+				return &SignedPauseCommand{Command: cmd, Signatures: []OperatorSignature{operators[0].sign(t, cmd)}}
+			},
+			wantError: ErrInvalidPauseCommand,
+		},
+		{
+			name:   "same operator signing twice",
+			action: PauseActionPause,
+			buildCmd: func() *SignedPauseCommand {
+				cmd := newTestCommand(PauseActionPause, 1)
+				return &SignedPauseCommand{Command: cmd, Signatures: []OperatorSignature{operators[0].sign(t, cmd), operators[0].sign(t, cmd)}}
+			},
+			wantError: ErrInvalidPauseCommand,
+		},
+		{
+			name:   "unknown operator",
+			action: PauseActionPause,
+			buildCmd: func() *SignedPauseCommand {
+				cmd := newTestCommand(PauseActionPause, 1)
+				sig := outsider.sign(t, cmd)
+				sig.KeyID = "op2"
+				return &SignedPauseCommand{Command: cmd, Signatures: []OperatorSignature{operators[0].sign(t, cmd), sig}}
+			},
+			wantError: ErrInvalidPauseCommand,
+		},
+		{
+			name:   "signature over a different command",
+			action: PauseActionPause,
+			buildCmd: func() *SignedPauseCommand {
+				cmd := newTestCommand(PauseActionPause, 1)
+				other := newTestCommand(PauseActionPause, 2)
+				return &SignedPauseCommand{Command: cmd, Signatures: []OperatorSignature{operators[0].sign(t, cmd), operators[1].sign(t, other)}}
+			},
+			wantError: ErrInvalidPauseCommand,
+		},
+		{
+			name:   "action does not match route",
+			action: PauseActionUnpause,
+			buildCmd: func() *SignedPauseCommand {
+				cmd := newTestCommand(PauseActionPause, 1)
+				return &SignedPauseCommand{Command: cmd, Signatures: []OperatorSignature{operators[0].sign(t, cmd), operators[1].sign(t, cmd)}}
+			},
+			wantError: ErrInvalidPauseCommand,
+		},
+		{
+			name:   "wrong environment",
+			action: PauseActionPause,
+			buildCmd: func() *SignedPauseCommand {
+				cmd := newTestCommand(PauseActionPause, 1)
+				cmd.Environment = "dev"
+				return &SignedPauseCommand{Command: cmd, Signatures: []OperatorSignature{operators[0].sign(t, cmd), operators[1].sign(t, cmd)}}
+			},
+			wantError: ErrInvalidPauseCommand,
+		},
+		{
+			name:   "expired command",
+			action: PauseActionPause,
+			buildCmd: func() *SignedPauseCommand {
+				cmd := newTestCommand(PauseActionPause, 1)
+				cmd.ExpiresAt = 1_699_999_999
+				return &SignedPauseCommand{Command: cmd, Signatures: []OperatorSignature{operators[0].sign(t, cmd), operators[1].sign(t, cmd)}}
+			},
+			wantError: ErrInvalidPauseCommand,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pause, _ := newTestPause(t, state.NewMemoryStore(), 2, operators)
+
+			_, err := pause.Apply(tt.action, tt.buildCmd())
+			if !errors.Is(err, tt.wantError) {
+				t.Errorf("Apply() error = %v, want %v", err, tt.wantError)
+			}
+
+			if err := pause.CheckAxalSigningAllowed(); err != nil {
+				t.Errorf("CheckAxalSigningAllowed() after rejected command error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestEmergencyPause_RejectsReplayedSequence(t *testing.T) {
+	operators := newTestOperators(t, "op1", "op2")
+	pause, _ := newTestPause(t, state.NewMemoryStore(), 2, operators)
+
+	pauseCmd := newTestCommand(PauseActionPause, 5)
+	signedPause := &SignedPauseCommand{
+		Command:    pauseCmd,
+		Signatures: []OperatorSignature{operators[0].sign(t, pauseCmd), operators[1].sign(t, pauseCmd)},
+	}
+	if _, err := pause.Apply(PauseActionPause, signedPause); err != nil {
+		t.Fatalf("Apply() pause error = %v", err)
+	}
+
+	unpauseCmd := newTestCommand(PauseActionUnpause, 6)
+	if _, err := pause.Apply(PauseActionUnpause, &SignedPauseCommand{
+		Command:    unpauseCmd,
+		Signatures: []OperatorSignature{operators[0].sign(t, unpauseCmd), operators[1].sign(t, unpauseCmd)},
+	}); err != nil {
+		t.Fatalf("Apply() unpause error = %v", err)
+	}
+
+	// Replaying the old pause command must fail
+	if _, err := pause.Apply(PauseActionPause, signedPause); !errors.Is(err, ErrInvalidPauseCommand) {
+		t.Errorf("Apply() replay error = %v, want %v", err, ErrInvalidPauseCommand)
+	}
+
+	if err := pause.CheckAxalSigningAllowed(); err != nil {
+		t.Errorf("CheckAxalSigningAllowed() after replay error = %v, want nil", err)
+	}
+}
+
+func TestEmergencyPause_NotConfigured(t *testing.T) {
+	pause, err := NewEmergencyPause(state.NewMemoryStore(), nil, "prod", 0, nil)
+	if err != nil {
+		t.Fatalf("NewEmergencyPause() error = %v", err)
+	}
+
+	cmd := newTestCommand(PauseActionPause, 1)
+	if _, err := pause.Apply(PauseActionPause, &SignedPauseCommand{Command: cmd}); !errors.Is(err, ErrPauseNotConfigured) {
+		t.Errorf("Apply() error = %v, want %v", err, ErrPauseNotConfigured)
+	}
+}
+
+func TestNewEmergencyPause_InvalidQuorum(t *testing.T) {
+	operators := newTestOperators(t, "op1", "op2")
+	keys := []OperatorKey{
+		{KeyID: operators[0].keyId, PublicKey: &operators[0].privateKey.PublicKey},
+		{KeyID: operators[1].keyId, PublicKey: &operators[1].privateKey.PublicKey},
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		keys      []OperatorKey
+	}{
+		{name: "threshold above operator count", threshold: 3, keys: keys},
+		{name: "zero threshold with operators", threshold: 0, keys: keys},
+		{name: "negative threshold", threshold: -1, keys: keys},
+		{name: "duplicate key ids", threshold: 1, keys: []OperatorKey{keys[0], keys[0]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewEmergencyPause(state.NewMemoryStore(), nil, "prod", tt.threshold, tt.keys); err == nil {
+				t.Error("NewEmergencyPause() expected error but got none")
+			}
+		})
+	}
+}
+
+func TestParseOperatorKey(t *testing.T) {
+	operator := newTestOperators(t, "op1")[0]
+	der, err := x509.MarshalPKIXPublicKey(&operator.privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	publicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	key, err := ParseOperatorKey("op1", publicKeyPEM)
+	if err != nil {
+		t.Fatalf("ParseOperatorKey() error = %v", err)
+	}
+	if !key.PublicKey.Equal(&operator.privateKey.PublicKey) {
+		t.Error("ParseOperatorKey() returned a different key")
+	}
+
+	if _, err := ParseOperatorKey("op1", "not a pem"); err == nil {
+		t.Error("ParseOperatorKey() expected error for invalid PEM")
+	}
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err = x509.MarshalPKIXPublicKey(&p384Key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	if _, err := ParseOperatorKey("op1", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))); err == nil {
+		t.Error("ParseOperatorKey() accepted a P-384 key")
+	}
+}
@@ -0,0 +1,192 @@
+package controls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrAxalSigningDisabled is returned when a user has engaged their kill switch and Axal tries to sign for them
+var ErrAxalSigningDisabled = errors.New("axal initiated signing is disabled by the user")
+
+// ErrKillSwitchUnavailable is returned when the kill switch state could not be read. Callers must treat this as disabled.
+var ErrKillSwitchUnavailable = errors.New("kill switch state is unavailable")
+
+const killSwitchKeyPrefix = "killswitch/"
+
+var UserKillSwitch *KillSwitch
+
+// KillSwitch lets a user opt out of all Axal initiated signing for their privy id. User initiated signing is never affected.
+// Engaging the switch is immediate, releasing it only takes effect once the re-enable cooldown has passed.
+type KillSwitch struct {
+	store            state.Store
+	reenableCooldown time.Duration
+	now              func() time.Time
+	mu               sync.Mutex
+}
+
+// Inits a new KillSwitch backed by the given store and initiates it to controls.UserKillSwitch. reenableCooldown is the minimum
+// time between a user asking to re-enable Axal signing and it actually being re-enabled, 0 re-enables immediately.
+func InitKillSwitch(store state.Store, reenableCooldown time.Duration) {
+	UserKillSwitch = NewKillSwitch(store, reenableCooldown)
+}
+
+// Creates a new KillSwitch backed by the given store
+func NewKillSwitch(store state.Store, reenableCooldown time.Duration) *KillSwitch {
+	return &KillSwitch{
+		store:            store,
+		reenableCooldown: reenableCooldown,
+		now:              time.Now,
+	}
+}
+
+// Engages the kill switch for a user, disabling Axal initiated signing straight away. The user can ask for a cooldown longer
+// than the configured one, it can never be shorter. Engaging again cancels any pending release.
+func (ks *KillSwitch) Engage(privyId string, cooldown time.Duration) (*KillSwitchState, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if cooldown < ks.reenableCooldown {
+		cooldown = ks.reenableCooldown
+	}
+
+	ksState := &KillSwitchState{
+		PrivyID:                 privyId,
+		Engaged:                 true,
+		EngagedAt:               ks.now().Unix(),
+		ReenableCooldownSeconds: int64(cooldown / time.Second),
+	}
+
+	if err := ks.write(ksState); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Kill switch engaged for user %s", privyId)
+	return ksState, nil
+}
+
+// Asks for Axal initiated signing to be re-enabled for a user. If there is no cooldown the switch is released immediately,
+// otherwise Axal signing stays disabled until the cooldown has passed.
+func (ks *KillSwitch) Release(privyId string) (*KillSwitchState, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ksState, err := ks.read(privyId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := ks.now()
+	ksState.Engaged = ksState.isEngagedAt(now)
+	if !ksState.Engaged {
+		return ksState, nil
+	}
+
+	// A release that is already pending keeps its original time, asking again should not push it back
+	if ksState.ReenableAt != 0 {
+		return ksState, nil
+	}
+
+	if ksState.ReenableCooldownSeconds == 0 {
+		if err := ks.store.Delete(killSwitchKeyPrefix + privyId); err != nil {
+			log.Errorf("Unable to release kill switch for user %s with err: %v", privyId, err)
+			return nil, ErrKillSwitchUnavailable
+		}
+
+		log.Infof("Kill switch released for user %s", privyId)
+		return &KillSwitchState{PrivyID: privyId}, nil
+	}
+
+	ksState.ReenableAt = now.Add(time.Duration(ksState.ReenableCooldownSeconds) * time.Second).Unix()
+	if err := ks.write(ksState); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Kill switch release requested for user %s, axal signing re-enabled at %d", privyId, ksState.ReenableAt)
+	return ksState, nil
+}
+
+// Gets the current kill switch state for a user
+func (ks *KillSwitch) Status(privyId string) (*KillSwitchState, error) {
+	ksState, err := ks.read(privyId)
+	if err != nil {
+		return nil, err
+	}
+
+	ksState.Engaged = ksState.isEngagedAt(ks.now())
+	return ksState, nil
+}
+
+// Checks if Axal is allowed to sign for this user. Returns ErrAxalSigningDisabled if the user has engaged their kill switch and
+// ErrKillSwitchUnavailable if the state could not be read, in which case signing must not go ahead.
+func (ks *KillSwitch) CheckAxalSigningAllowed(privyId string) error {
+	ksState, err := ks.read(privyId)
+	if err != nil {
+		return err
+	}
+
+	if ksState.isEngagedAt(ks.now()) {
+		return ErrAxalSigningDisabled
+	}
+
+	return nil
+}
+
+// Reads the state of a user, a user with no record has never engaged the switch
+func (ks *KillSwitch) read(privyId string) (*KillSwitchState, error) {
+	raw, err := ks.store.Get(killSwitchKeyPrefix + privyId)
+	if errors.Is(err, state.ErrNotFound) {
+		return &KillSwitchState{PrivyID: privyId}, nil
+	}
+	if err != nil {
+		log.Errorf("Unable to read kill switch for user %s with err: %v", privyId, err)
+		return nil, ErrKillSwitchUnavailable
+	}
+
+	var ksState KillSwitchState
+	if err := json.Unmarshal(raw, &ksState); err != nil {
+		log.Errorf("Unable to parse kill switch for user %s with err: %v", privyId, err)
+		return nil, ErrKillSwitchUnavailable
+	}
+
+	return &ksState, nil
+}
+
+func (ks *KillSwitch) write(ksState *KillSwitchState) error {
+	raw, err := json.Marshal(ksState)
+	if err != nil {
+		return fmt.Errorf("failed to marshal kill switch state: %w", err)
+	}
+
+	if err := ks.store.Put(killSwitchKeyPrefix+ksState.PrivyID, raw); err != nil {
+		log.Errorf("Unable to write kill switch for user %s with err: %v", ksState.PrivyID, err)
+		return ErrKillSwitchUnavailable
+	}
+
+	return nil
+}
+
+// Checks if Axal is allowed to sign for this user, it must not be globally paused and the user must not have engaged their
+// kill switch. Fails closed if either control was never initiated.
+func CheckAxalSigningAllowed(privyId string) error {
+	if GlobalPause == nil {
+		log.Error("Emergency pause has not been initiated")
+		return ErrPauseUnavailable
+	}
+
+	if err := GlobalPause.CheckAxalSigningAllowed(); err != nil {
+		return err
+	}
+
+	if UserKillSwitch == nil {
+		log.Error("Kill switch has not been initiated")
+		return ErrKillSwitchUnavailable
+	}
+
+	return UserKillSwitch.CheckAxalSigningAllowed(privyId)
+}
@@ -0,0 +1,43 @@
+package controls
+
+import (
+	"fmt"
+	"time"
+)
+
+// The longest cooldown a user can ask for when engaging the kill switch
+const MaxReenableCooldown = 30 * 24 * time.Hour
+
+// KillSwitchState is the persisted kill switch record for a user
+type KillSwitchState struct {
+	PrivyID                 string `json:"privy_id"`
+	Engaged                 bool   `json:"engaged"`
+	EngagedAt               int64  `json:"engaged_at,omitempty"`
+	ReenableCooldownSeconds int64  `json:"reenable_cooldown_seconds,omitempty"`
+	ReenableAt              int64  `json:"reenable_at,omitempty"` // Set once the user asks to re-enable, 0 means no release pending
+}
+
+// A switch stays engaged until its pending release time has passed
+func (s *KillSwitchState) isEngagedAt(now time.Time) bool {
+	if !s.Engaged {
+		return false
+	}
+
+	return s.ReenableAt == 0 || now.Unix() < s.ReenableAt
+}
+
+// Request body for engaging the kill switch, the cooldown is optional
+type EngageKillSwitchRequest struct {
+	ReenableCooldownSeconds int64 `json:"reenable_cooldown_seconds"`
+}
+
+// Validates the engage request
+func (req *EngageKillSwitchRequest) Validate() error {
+	if req.ReenableCooldownSeconds < 0 {
+		return fmt.Errorf("reenable_cooldown_seconds cannot be negative")
+	}
+	if req.ReenableCooldownSeconds > int64(MaxReenableCooldown/time.Second) {
+		return fmt.Errorf("reenable_cooldown_seconds cannot be more than %d", int64(MaxReenableCooldown/time.Second))
+	}
+	return nil
+}
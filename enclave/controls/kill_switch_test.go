@@ -0,0 +1,267 @@
+package controls
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/state"
+)
+
+// Helper that creates a kill switch with a controllable clock
+func newTestKillSwitch(store state.Store, cooldown time.Duration) (*KillSwitch, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	ks := NewKillSwitch(store, cooldown)
+	ks.now = func() time.Time { return now }
+	return ks, &now
+}
+
+func TestKillSwitch_EngageDisablesAxalSigning(t *testing.T) {
+	ks, _ := newTestKillSwitch(state.NewMemoryStore(), 0)
+	privyId := "did:privy:user1"
+
+	if err := ks.CheckAxalSigningAllowed(privyId); err != nil {
+		t.Fatalf("CheckAxalSigningAllowed() before engage error = %v, want nil", err)
+	}
+
+	if _, err := ks.Engage(privyId, 0); err != nil {
+		t.Fatalf("Engage() error = %v", err)
+	}
+
+	if err := ks.CheckAxalSigningAllowed(privyId); !errors.Is(err, ErrAxalSigningDisabled) {
+		t.Errorf("CheckAxalSigningAllowed() after engage error = %v, want %v", err, ErrAxalSigningDisabled)
+	}
+
+	// Other users are not affected
+	if err := ks.CheckAxalSigningAllowed("did:privy:user2"); err != nil {
+		t.Errorf("CheckAxalSigningAllowed() for other user error = %v, want nil", err)
+	}
+}
+
+func TestKillSwitch_ReleaseWithoutCooldown(t *testing.T) {
+	ks, _ := newTestKillSwitch(state.NewMemoryStore(), 0)
+	privyId := "did:privy:user1"
+
+	if _, err := ks.Engage(privyId, 0); err != nil {
+		t.Fatalf("Engage() error = %v", err)
+	}
+
+	ksState, err := ks.Release(privyId)
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if ksState.Engaged {
+		t.Errorf("Release() state engaged = true, want false")
+	}
+
+	if err := ks.CheckAxalSigningAllowed(privyId); err != nil {
+		t.Errorf("CheckAxalSigningAllowed() after release error = %v, want nil", err)
+	}
+}
+
+func TestKillSwitch_ReleaseWithCooldown(t *testing.T) {
+	tests := []struct {
+		name             string
+		configCooldown   time.Duration
+		requestCooldown  time.Duration
+		expectedCooldown time.Duration
+	}{
+		{
+			name:             "configured cooldown",
+			configCooldown:   time.Hour,
+			requestCooldown:  0,
+			expectedCooldown: time.Hour,
+		},
+		{
+			name:             "user asks for a longer cooldown",
+			configCooldown:   time.Hour,
+			requestCooldown:  24 * time.Hour,
+			expectedCooldown: 24 * time.Hour,
+		},
+		{
+			name:             "user cannot shorten the configured cooldown",
+			configCooldown:   time.Hour,
+			requestCooldown:  time.Minute,
+			expectedCooldown: time.Hour,
+		},
+		{
+			name:             "user cooldown with no configured cooldown",
+			configCooldown:   0,
+			requestCooldown:  10 * time.Minute,
+			expectedCooldown: 10 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, now := newTestKillSwitch(state.NewMemoryStore(), tt.configCooldown)
+			privyId := "did:privy:user1"
+
+			if _, err := ks.Engage(privyId, tt.requestCooldown); err != nil {
+				t.Fatalf("Engage() error = %v", err)
+			}
+
+			ksState, err := ks.Release(privyId)
+			if err != nil {
+				t.Fatalf("Release() error = %v", err)
+			}
+
+			expectedReenableAt := now.Add(tt.expectedCooldown).Unix()
+			if ksState.ReenableAt != expectedReenableAt {
+				t.Errorf("Release() ReenableAt = %d, want %d", ksState.ReenableAt, expectedReenableAt)
+			}
+
+			// Still disabled right before the cooldown ends
+			*now = now.Add(tt.expectedCooldown - time.Second)
+			if err := ks.CheckAxalSigningAllowed(privyId); !errors.Is(err, ErrAxalSigningDisabled) {
+				t.Errorf("CheckAxalSigningAllowed() during cooldown error = %v, want %v", err, ErrAxalSigningDisabled)
+			}
+
+			// Re-enabled once the cooldown has passed
+			*now = now.Add(time.Second)
+			if err := ks.CheckAxalSigningAllowed(privyId); err != nil {
+				t.Errorf("CheckAxalSigningAllowed() after cooldown error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestKillSwitch_ReleaseDoesNotExtendPendingRelease(t *testing.T) {
+	ks, now := newTestKillSwitch(state.NewMemoryStore(), time.Hour)
+	privyId := "did:privy:user1"
+
+	if _, err := ks.Engage(privyId, 0); err != nil {
+		t.Fatalf("Engage() error = %v", err)
+	}
+
+	first, err := ks.Release(privyId)
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	*now = now.Add(30 * time.Minute)
+	second, err := ks.Release(privyId)
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	if first.ReenableAt != second.ReenableAt {
+		t.Errorf("Release() ReenableAt moved from %d to %d", first.ReenableAt, second.ReenableAt)
+	}
+}
+
+func TestKillSwitch_ReleaseAfterCooldownIsNotEngaged(t *testing.T) {
+	ks, now := newTestKillSwitch(state.NewMemoryStore(), time.Hour)
+	privyId := "did:privy:user1"
+
+	if _, err := ks.Engage(privyId, 0); err != nil {
+		t.Fatalf("Engage() error = %v", err)
+	}
+	if _, err := ks.Release(privyId); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	ksState, err := ks.Release(privyId)
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if ksState.Engaged {
+		t.Errorf("Release() after the cooldown state engaged = true, want false")
+	}
+}
+
+func TestKillSwitch_EngageCancelsPendingRelease(t *testing.T) {
+	ks, now := newTestKillSwitch(state.NewMemoryStore(), time.Hour)
+	privyId := "did:privy:user1"
+
+	if _, err := ks.Engage(privyId, 0); err != nil {
+		t.Fatalf("Engage() error = %v", err)
+	}
+	if _, err := ks.Release(privyId); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := ks.Engage(privyId, 0); err != nil {
+		t.Fatalf("Engage() error = %v", err)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	if err := ks.CheckAxalSigningAllowed(privyId); !errors.Is(err, ErrAxalSigningDisabled) {
+		t.Errorf("CheckAxalSigningAllowed() error = %v, want %v", err, ErrAxalSigningDisabled)
+	}
+}
+
+func TestKillSwitch_StatePersistsInStore(t *testing.T) {
+	store := state.NewMemoryStore()
+	privyId := "did:privy:user1"
+
+	ks, _ := newTestKillSwitch(store, 0)
+	if _, err := ks.Engage(privyId, 0); err != nil {
+		t.Fatalf("Engage() error = %v", err)
+	}
+
+	// A new kill switch on the same store, as after an enclave restart
+	restarted, _ := newTestKillSwitch(store, 0)
+	ksState, err := restarted.Status(privyId)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !ksState.Engaged {
+		t.Errorf("Status() engaged = false after restart, want true")
+	}
+}
+
+// failingStore is a state store that always fails
+type failingStore struct{}
+
+func (failingStore) Get(key string) ([]byte, error)     { return nil, errors.New("store is down") }
+func (failingStore) Put(key string, value []byte) error { return errors.New("store is down") }
+func (failingStore) Delete(key string) error            { return errors.New("store is down") }
+
+func TestKillSwitch_FailsClosed(t *testing.T) {
+	ks, _ := newTestKillSwitch(failingStore{}, 0)
+
+	if err := ks.CheckAxalSigningAllowed("did:privy:user1"); !errors.Is(err, ErrKillSwitchUnavailable) {
+		t.Errorf("CheckAxalSigningAllowed() error = %v, want %v", err, ErrKillSwitchUnavailable)
+	}
+}
+
+func TestCheckAxalSigningAllowed_NotInitiated(t *testing.T) {
+	GlobalPause = nil
+	UserKillSwitch = nil
+
+	if err := CheckAxalSigningAllowed("did:privy:user1"); !errors.Is(err, ErrPauseUnavailable) {
+		t.Errorf("CheckAxalSigningAllowed() error = %v, want %v", err, ErrPauseUnavailable)
+	}
+
+	if err := InitEmergencyPause(state.NewMemoryStore(), nil, "prod", 0, nil); err != nil {
+		t.Fatalf("InitEmergencyPause() error = %v", err)
+	}
+	defer func() { GlobalPause = nil }()
+
+	if err := CheckAxalSigningAllowed("did:privy:user1"); !errors.Is(err, ErrKillSwitchUnavailable) {
+		t.Errorf("CheckAxalSigningAllowed() error = %v, want %v", err, ErrKillSwitchUnavailable)
+	}
+}
+
+func TestEngageKillSwitchRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     EngageKillSwitchRequest
+		wantErr bool
+	}{
+		{name: "no cooldown", req: EngageKillSwitchRequest{}, wantErr: false},
+		{name: "valid cooldown", req: EngageKillSwitchRequest{ReenableCooldownSeconds: 3600}, wantErr: false},
+		{name: "negative cooldown", req: EngageKillSwitchRequest{ReenableCooldownSeconds: -1}, wantErr: true},
+		{name: "cooldown too long", req: EngageKillSwitchRequest{ReenableCooldownSeconds: 31 * 24 * 3600}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+// Names of the secrets the enclave keeps in its key store
+const (
+	PrivyAppSecret           = "privy/app_secret"
+	PrivyDelegatedActionsKey = "privy/delegated_actions_key"
+	PrivyBasicAuthorization  = "privy/basic_authorization"
+	PrivyAuthorizationKey    = "privy/authorization_key" // Parsed ECDSA key of the delegated actions key
+	AxalRequestSecretKey     = "axal/request_secret_key"
+	LocalSignerSeed          = "local/signer_seed" // Seed the local signer derives user keys from
+)
+
+var ErrNotFound = errors.New("secret not found in key store")
+
+// Name of the n-th additional authorization key of the privy key quorum, the delegated actions key being the first member
+func PrivyQuorumKey(n int) string {
+	return fmt.Sprintf("privy/quorum_key/%d", n)
+}
+
+// Name of the parsed ECDSA key of PrivyQuorumKey(n)
+func PrivyQuorumAuthorizationKey(n int) string {
+	return fmt.Sprintf("privy/quorum_authorization_key/%d", n)
+}
+
+type entry struct {
+	current       *memguard.Enclave
+	previous      *memguard.Enclave
+	previousUntil time.Time
+}
+
+type ecdsaKey struct {
+	public ecdsa.PublicKey
+	scalar *memguard.Enclave
+}
+
+// KeyStore keeps long lived secrets in memguard enclaves, encrypted in memory for the lifetime of the process. Secrets are only
+// ever decrypted into locked buffers for the duration of a WithSecret call and wiped afterwards.
+type KeyStore struct {
+	mu        sync.RWMutex
+	secrets   map[string]*entry
+	ecdsaKeys map[string]*ecdsaKey
+	now       func() time.Time
+}
+
+// Creates a new empty KeyStore
+func New() *KeyStore {
+	return &KeyStore{
+		secrets:   make(map[string]*entry),
+		ecdsaKeys: make(map[string]*ecdsaKey),
+		now:       time.Now,
+	}
+}
+
+// Put seals secret under name, replacing any previous value. secret is wiped.
+func (ks *KeyStore) Put(name string, secret []byte) {
+	ks.Rotate(name, secret, time.Time{})
+}
+
+// PutString seals a secret held in a string. The string itself can not be wiped, callers should drop it afterwards.
+func (ks *KeyStore) PutString(name string, secret string) {
+	ks.Put(name, []byte(secret))
+}
+
+// Rotate seals secret as the current value of name and keeps the value it replaces valid until previousUntil, see
+// WithValidSecrets. A zero previousUntil drops the replaced value. secret is wiped.
+func (ks *KeyStore) Rotate(name string, secret []byte, previousUntil time.Time) {
+	sealed := memguard.NewEnclave(secret)
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	e, ok := ks.secrets[name]
+	if !ok {
+		ks.secrets[name] = &entry{current: sealed}
+		return
+	}
+
+	if previousUntil.IsZero() {
+		e.previous = nil
+	} else {
+		e.previous = e.current
+	}
+	e.previousUntil = previousUntil
+	e.current = sealed
+}
+
+// Has reports whether a non-empty secret or an ECDSA key is stored under name
+func (ks *KeyStore) Has(name string) bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if _, ok := ks.ecdsaKeys[name]; ok {
+		return true
+	}
+	e, ok := ks.secrets[name]
+	return ok && e.current != nil
+}
+
+// Delete drops the secret stored under name
+func (ks *KeyStore) Delete(name string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	delete(ks.secrets, name)
+	delete(ks.ecdsaKeys, name)
+}
+
+// WithSecret decrypts the current value of name into a locked buffer and calls fn with it. The buffer is wiped when fn returns,
+// fn must not keep the slice.
+func (ks *KeyStore) WithSecret(name string, fn func(secret []byte) error) error {
+	ks.mu.RLock()
+	e, ok := ks.secrets[name]
+	var sealed *memguard.Enclave
+	if ok {
+		sealed = e.current
+	}
+	ks.mu.RUnlock()
+
+	if sealed == nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return withOpened(sealed, fn)
+}
+
+// WithSecrets calls fn with the current values of names, in order, each decrypted into a locked buffer like WithSecret
+func (ks *KeyStore) WithSecrets(names []string, fn func(secrets [][]byte) error) error {
+	secrets := make([][]byte, 0, len(names))
+
+	var open func(i int) error
+	open = func(i int) error {
+		if i == len(names) {
+			return fn(secrets)
+		}
+		return ks.WithSecret(names[i], func(secret []byte) error {
+			secrets = append(secrets, secret)
+			return open(i + 1)
+		})
+	}
+	return open(0)
+}
+
+// WithValidSecrets calls fn with the current value of name and, during its grace window, the value it replaced, until fn
+// returns true. It reports whether fn returned true for any of them.
+func (ks *KeyStore) WithValidSecrets(name string, fn func(secret []byte) bool) bool {
+	ks.mu.RLock()
+	var sealed []*memguard.Enclave
+	if e, ok := ks.secrets[name]; ok {
+		if e.current != nil {
+			sealed = append(sealed, e.current)
+		}
+		if e.previous != nil && ks.now().Before(e.previousUntil) {
+			sealed = append(sealed, e.previous)
+		}
+	}
+	ks.mu.RUnlock()
+
+	for _, s := range sealed {
+		matched := false
+		err := withOpened(s, func(secret []byte) error {
+			matched = fn(secret)
+			return nil
+		})
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// PutECDSAKey keeps the private scalar of key sealed under name so it is parsed once and not on every signature. The scalar in
+// key is wiped, key can not be used afterwards.
+func (ks *KeyStore) PutECDSAKey(name string, key *ecdsa.PrivateKey) {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	scalar := make([]byte, size)
+	key.D.FillBytes(scalar)
+	wipeInt(key.D)
+
+	sealed := &ecdsaKey{
+		public: key.PublicKey,
+		scalar: memguard.NewEnclave(scalar),
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.ecdsaKeys[name] = sealed
+}
+
+// ECDSAPublicKey returns the public key of the ECDSA key stored under name
+func (ks *KeyStore) ECDSAPublicKey(name string) (*ecdsa.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.ecdsaKeys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	public := key.public
+	return &public, nil
+}
+
+// SignECDSA signs digest with the ECDSA key stored under name and returns the ASN.1 signature. The private key only exists for
+// the duration of the call.
+func (ks *KeyStore) SignECDSA(name string, digest []byte) ([]byte, error) {
+	ks.mu.RLock()
+	key, ok := ks.ecdsaKeys[name]
+	ks.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	var signature []byte
+	err := withOpened(key.scalar, func(scalar []byte) error {
+		private := &ecdsa.PrivateKey{PublicKey: key.public, D: new(big.Int).SetBytes(scalar)}
+		defer wipeInt(private.D)
+
+		var err error
+		signature, err = ecdsa.SignASN1(rand.Reader, private, digest)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with %s: %w", name, err)
+	}
+	return signature, nil
+}
+
+func withOpened(sealed *memguard.Enclave, fn func(secret []byte) error) error {
+	buf, err := sealed.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open sealed secret: %w", err)
+	}
+	defer buf.Destroy()
+
+	return fn(buf.Bytes())
+}
+
+// Zeroes the words backing a big.Int
+func wipeInt(n *big.Int) {
+	words := n.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	n.SetInt64(0)
+}
@@ -0,0 +1,166 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+func secretOf(t *testing.T, ks *KeyStore, name string) string {
+	t.Helper()
+
+	var value string
+	if err := ks.WithSecret(name, func(secret []byte) error {
+		value = string(secret)
+		return nil
+	}); err != nil {
+		t.Fatalf("WithSecret(%s) error = %v", name, err)
+	}
+	return value
+}
+
+func TestKeyStore_PutAndWithSecret(t *testing.T) {
+	ks := New()
+
+	secret := []byte("app-secret")
+	ks.Put(PrivyAppSecret, secret)
+	for _, b := range secret {
+		if b != 0 {
+			t.Fatal("Put() did not wipe the source secret")
+		}
+	}
+
+	if !ks.Has(PrivyAppSecret) {
+		t.Error("Has() = false after Put()")
+	}
+	if got := secretOf(t, ks, PrivyAppSecret); got != "app-secret" {
+		t.Errorf("WithSecret() = %s, want app-secret", got)
+	}
+
+	ks.PutString(PrivyAppSecret, "rotated-secret")
+	if got := secretOf(t, ks, PrivyAppSecret); got != "rotated-secret" {
+		t.Errorf("WithSecret() = %s, want rotated-secret", got)
+	}
+
+	wantErr := errors.New("callback failed")
+	if err := ks.WithSecret(PrivyAppSecret, func([]byte) error { return wantErr }); !errors.Is(err, wantErr) {
+		t.Errorf("WithSecret() error = %v, want the callback error", err)
+	}
+
+	ks.Delete(PrivyAppSecret)
+	if ks.Has(PrivyAppSecret) {
+		t.Error("Has() = true after Delete()")
+	}
+	if err := ks.WithSecret(PrivyAppSecret, func([]byte) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("WithSecret() error = %v, want ErrNotFound", err)
+	}
+
+	// Empty secrets are not stored
+	ks.Put(AxalRequestSecretKey, nil)
+	if ks.Has(AxalRequestSecretKey) {
+		t.Error("Has() = true for an empty secret")
+	}
+}
+
+func TestKeyStore_WithSecrets(t *testing.T) {
+	ks := New()
+	ks.PutString(PrivyAppSecret, "app-secret")
+	ks.PutString(PrivyQuorumKey(0), "quorum-key")
+
+	var got []string
+	err := ks.WithSecrets([]string{PrivyAppSecret, PrivyQuorumKey(0)}, func(secrets [][]byte) error {
+		for _, secret := range secrets {
+			got = append(got, string(secret))
+		}
+		return nil
+	})
+	if err != nil || len(got) != 2 || got[0] != "app-secret" || got[1] != "quorum-key" {
+		t.Errorf("WithSecrets() = %v, %v, want both secrets in order", got, err)
+	}
+
+	called := false
+	err = ks.WithSecrets([]string{PrivyAppSecret, PrivyQuorumKey(1)}, func([][]byte) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrNotFound) || called {
+		t.Errorf("WithSecrets() error = %v, called = %v, want ErrNotFound without calling fn", err, called)
+	}
+}
+
+func TestKeyStore_WithValidSecrets(t *testing.T) {
+	now := time.Now()
+	ks := New()
+	ks.now = func() time.Time { return now }
+
+	valid := func() []string {
+		var secrets []string
+		ks.WithValidSecrets(AxalRequestSecretKey, func(secret []byte) bool {
+			secrets = append(secrets, string(secret))
+			return false
+		})
+		return secrets
+	}
+
+	ks.PutString(AxalRequestSecretKey, "old-key")
+	ks.Rotate(AxalRequestSecretKey, []byte("new-key"), now.Add(time.Hour))
+	if got := valid(); len(got) != 2 || got[0] != "new-key" || got[1] != "old-key" {
+		t.Errorf("valid secrets = %v, want the new key and the old one in its grace window", got)
+	}
+
+	matched := ks.WithValidSecrets(AxalRequestSecretKey, func(secret []byte) bool {
+		return string(secret) == "old-key"
+	})
+	if !matched {
+		t.Error("WithValidSecrets() = false, want the old key matched inside the grace window")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if got := valid(); len(got) != 1 || got[0] != "new-key" {
+		t.Errorf("valid secrets = %v, want only the new key after the grace window", got)
+	}
+
+	// A zero grace window drops the replaced secret straight away
+	ks.Rotate(AxalRequestSecretKey, []byte("newer-key"), time.Time{})
+	if got := valid(); len(got) != 1 || got[0] != "newer-key" {
+		t.Errorf("valid secrets = %v, want only the newer key", got)
+	}
+}
+
+func TestKeyStore_SignECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	public := key.PublicKey
+
+	ks := New()
+	ks.PutECDSAKey(PrivyAuthorizationKey, key)
+	if key.D.Sign() != 0 {
+		t.Error("PutECDSAKey() did not wipe the private scalar")
+	}
+
+	got, err := ks.ECDSAPublicKey(PrivyAuthorizationKey)
+	if err != nil || !got.Equal(&public) {
+		t.Fatalf("ECDSAPublicKey() = %v, %v, want the stored public key", got, err)
+	}
+
+	digest := sha256.Sum256([]byte("payload"))
+	for i := 0; i < 2; i++ {
+		signature, err := ks.SignECDSA(PrivyAuthorizationKey, digest[:])
+		if err != nil {
+			t.Fatalf("SignECDSA() error = %v", err)
+		}
+		if !ecdsa.VerifyASN1(&public, digest[:], signature) {
+			t.Errorf("SignECDSA() signature %d does not verify", i)
+		}
+	}
+
+	if _, err := ks.SignECDSA("missing", digest[:]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SignECDSA() error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,83 @@
+package enclave
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+	"github.com/getaxal/verified-signer/common/aws/kms"
+	secretmanager "github.com/getaxal/verified-signer/common/aws/secret_manager"
+	"github.com/getaxal/verified-signer/common/redact"
+	"github.com/getaxal/verified-signer/enclave/attestation"
+	log "github.com/sirupsen/logrus"
+)
+
+// KMSDecrypter decrypts KMS ciphertexts inside the enclave
+type KMSDecrypter interface {
+	Decrypt(ctx context.Context, ciphertextBlob []byte, keyId string) ([]byte, error)
+}
+
+// Creates a KMS client that reaches KMS through the KMS vsock port and signs with the credentials of the config. Every call
+// attests to a fresh recipient key with the NSM, so this only works inside a Nitro enclave.
+func NewKMSClient(configPath string, cfg *TEEConfig) (*kms.KMSClient, error) {
+	if cfg.Ports.KMSVsockPort == 0 {
+		return nil, fmt.Errorf("kms needs kms_vsock_port")
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("kms needs a region")
+	}
+
+	creds, err := cfg.awsCredentials(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return kms.NewKMSClient(creds, aws.AWSRegion(cfg.Region), cfg.Ports.KMSVsockPort, attestRecipientKey), nil
+}
+
+// Returns the AWS credentials of the instance role, shared by every AWS client of the config so there is one provider to refresh
+func (cfg *TEEConfig) awsCredentials(configPath string) (credentials.Provider, error) {
+	if cfg.awsCreds == nil {
+		provider, err := secretmanager.NewCredentialsProvider(configPath, cfg.Environment, cfg.Ports.Ec2CredsVsockPort)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create aws credentials provider: %w", err)
+		}
+		cfg.awsCreds = provider
+	}
+	return cfg.awsCreds, nil
+}
+
+// Returns the KMS client built by LoadTEEConfig, nil when nothing in the config needs KMS
+func (cfg *TEEConfig) KMSClient() *kms.KMSClient {
+	return cfg.kmsClient
+}
+
+func attestRecipientKey(publicKey []byte) ([]byte, error) {
+	return attestation.Attest(nil, nil, publicKey)
+}
+
+// Replaces each base64 KMS ciphertext in secrets with its plaintext. The plaintext only ever exists inside the enclave.
+func decryptKMSSecrets(client KMSDecrypter, keyId string, secrets map[string]*string) error {
+	for name, secret := range secrets {
+		ciphertext, err := base64.StdEncoding.DecodeString(*secret)
+		if err != nil {
+			return fmt.Errorf("secret %s is not a base64 kms ciphertext: %w", name, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		plaintext, err := client.Decrypt(ctx, ciphertext, keyId)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret %s: %w", name, err)
+		}
+
+		*secret = string(plaintext)
+		redact.Register(*secret)
+		log.Infof("Decrypted secret %s with kms", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package enclave
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+	"github.com/getaxal/verified-signer/common/aws/kms"
+	"github.com/getaxal/verified-signer/common/aws/kms/kmstest"
+)
+
+func TestDecryptKMSSecrets(t *testing.T) {
+	server := kmstest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddKey("secrets-key")
+
+	client := &kms.KMSClient{
+		KmsClient: server.Client(),
+		Config:    &kms.KMSConfig{Credentials: credentials.NewStaticProvider(aws.AWSCredentials{AccessKey: "AKIDEXAMPLE", AccessSecret: "secret"}), Region: aws.USEast2},
+		Attester:  kmstest.FakeAttester,
+	}
+
+	encrypt := func(plaintext string) string {
+		blob, err := server.Encrypt("secrets-key", []byte(plaintext))
+		if err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+		return base64.StdEncoding.EncodeToString(blob)
+	}
+
+	privy := PrivyConfig{AppSecret: encrypt("app-secret"), DelegatedActionsKey: encrypt("wallet-auth:key")}
+	axal := AxalConfig{AxalRequestSecretKey: encrypt("hmac-key")}
+
+	err := decryptKMSSecrets(client, "secrets-key", map[string]*string{
+		"app_secret":              &privy.AppSecret,
+		"delegated_actions_key":   &privy.DelegatedActionsKey,
+		"axal_request_secret_key": &axal.AxalRequestSecretKey,
+	})
+	if err != nil {
+		t.Fatalf("decryptKMSSecrets() error = %v", err)
+	}
+
+	if privy.AppSecret != "app-secret" || privy.DelegatedActionsKey != "wallet-auth:key" || axal.AxalRequestSecretKey != "hmac-key" {
+		t.Errorf("decryptKMSSecrets() did not replace the secrets with their plaintext")
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{"not base64", "not-base64!"},
+		{"plaintext secret", base64.StdEncoding.EncodeToString([]byte("plaintext"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := tt.secret
+			if err := decryptKMSSecrets(client, "secrets-key", map[string]*string{"secret": &secret}); err == nil {
+				t.Error("decryptKMSSecrets() should fail")
+			}
+			if secret != tt.secret {
+				t.Error("decryptKMSSecrets() should leave the secret untouched on failure")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package enclave
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getaxal/verified-signer/common/redact"
+	log "github.com/sirupsen/logrus"
+)
+
+// Sends the standard logger through the redacting formatter into a buffer for the rest of the test
+func captureRedactedLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	logger := log.StandardLogger()
+	out, formatter, level := logger.Out, logger.Formatter, logger.Level
+
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	logger.SetLevel(log.DebugLevel)
+	redact.Install(logger)
+
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.SetLevel(level)
+	})
+	return &buf
+}
+
+func TestLoadTEEConfig_DoesNotLogSecrets(t *testing.T) {
+	buf := captureRedactedLogs(t)
+
+	knownSecrets := []string{
+		"privy-app-secret-value",
+		"privy-delegated-actions-key-value",
+		"axal-hmac-key-value",
+		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := `
+environment: "dev"
+region: "us-east-2"
+ports:
+  privy_api_vsock_port: 8002
+  router_vsock_port: 8003
+  kms_vsock_port: 8005
+state:
+  local_sealing_key: "` + knownSecrets[3] + `"
+secrets:
+  provider: "memory"
+  values:
+    dev/privy: '{"app_id": "app-id", "app_secret": "` + knownSecrets[0] + `", "delegated_actions_key": "` + knownSecrets[1] + `", "jwt_verification_key": "jwt-key"}'
+    dev/axal: '{"axal_request_secret_key": "` + knownSecrets[2] + `"}'
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadTEEConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadTEEConfig() unexpected error = %v", err)
+	}
+
+	// Secrets logged without going through redact.Value must be caught by the formatter too
+	log.Infof("raw config: %+v", cfg)
+	log.WithField("privy", cfg.Privy).Info("privy config")
+	log.Errorf("failed with key %s", cfg.Axal.AxalRequestSecretKey)
+
+	logs := buf.String()
+	if !strings.Contains(logs, "loaded tee config") || !strings.Contains(logs, "app-id") {
+		t.Fatalf("expected the loaded config to be logged, got:\n%s", logs)
+	}
+	for _, secret := range knownSecrets {
+		if strings.Contains(logs, secret) {
+			t.Errorf("secret %s reached the log sink:\n%s", secret, logs)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+
+	"github.com/getaxal/verified-signer/enclave/keystore"
 )
 
 func signRequest(message string, secretKey []byte) string {
@@ -18,3 +20,12 @@ func VerifyAxalSignature(payload string, signature string, secretKey string) boo
 	expectedSignature := signRequest(payload, []byte(secretKey))
 	return hmac.Equal([]byte(expectedSignature), []byte(signature))
 }
+
+// VerifyAxalSignatureWithKeyStore accepts a signature made with the axal request secret key in the key store, or with the key it
+// replaced during its grace window
+func VerifyAxalSignatureWithKeyStore(payload string, signature string, keys *keystore.KeyStore) bool {
+	return keys.WithValidSecrets(keystore.AxalRequestSecretKey, func(secretKey []byte) bool {
+		expectedSignature := signRequest(payload, secretKey)
+		return hmac.Equal([]byte(expectedSignature), []byte(signature))
+	})
+}
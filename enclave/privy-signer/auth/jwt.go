@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"strings"
@@ -56,20 +55,82 @@ func validatePrivyClaims(claims *PrivyClaims, appID, env string) error {
 
 // validateJWTAndExtractUserID validates a Privy JWT token using ES256 and extracts the users privy ID
 func ValidateJWTAndExtractPrivyID(tokenString string, teeCfg *enclave.TEEConfig) (string, error) {
+	return ValidateJWTWithKeys(tokenString, []string{teeCfg.Privy.JWTVerificationKey}, teeCfg.Privy.AppID, teeCfg.Environment)
+}
+
+// ValidateJWTWithKeys validates a Privy JWT token against any of the PEM verification keys and extracts the users privy ID.
+// More than one key is only valid while a rotated key is in its grace window.
+func ValidateJWTWithKeys(tokenString string, verificationKeys []string, appID string, env string) (string, error) {
 	if tokenString == "" {
 		return "", fmt.Errorf("token cannot be empty")
 	}
 
-	if teeCfg.Privy.JWTVerificationKey == "" {
+	if len(verificationKeys) == 0 || verificationKeys[0] == "" {
 		return "", fmt.Errorf("verification key is not configured")
 	}
 
-	if teeCfg.Privy.AppID == "" {
+	if appID == "" {
 		return "", fmt.Errorf("app ID is not configured")
 	}
 
-	// Format the PEM key properly - handle both escaped newlines and space-separated format
-	formattedVerificationKey := teeCfg.Privy.JWTVerificationKey
+	// keyFunc validates the signing method and returns the verification keys
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != "ES256" {
+			log.Infof("Unexpected JWT signing method: %s", token.Method.Alg())
+			return nil, fmt.Errorf("unexpected JWT signing method=%v", token.Header["alg"])
+		}
+
+		var keySet jwt.VerificationKeySet
+		for _, verificationKey := range verificationKeys {
+			if verificationKey == "" {
+				continue
+			}
+
+			// Parse the ECDSA public key from PEM format using the formatted key
+			key, err := jwt.ParseECPublicKeyFromPEM([]byte(formatVerificationKey(verificationKey)))
+			if err != nil {
+				log.Errorf("Failed to parse ECDSA public key: %v", err)
+				return nil, fmt.Errorf("failed to parse verification key: %w", err)
+			}
+			keySet.Keys = append(keySet.Keys, key)
+		}
+
+		log.Info("Successfully parsed ECDSA public key")
+		if len(keySet.Keys) == 1 {
+			return keySet.Keys[0], nil
+		}
+		return keySet, nil
+	}
+
+	// Parse and validate the JWT token
+	log.Info("Parsing JWT token")
+	token, err := jwt.ParseWithClaims(tokenString, &PrivyClaims{}, keyFunc)
+	if err != nil {
+		log.Errorf("JWT parsing failed: %v", err)
+		return "", fmt.Errorf("JWT signature is invalid: %w", err)
+	}
+
+	if token.Valid {
+		log.Info("Successfuly parsed token")
+	}
+
+	// Parse the JWT claims into our custom struct
+	privyClaim, ok := token.Claims.(*PrivyClaims)
+	if !ok {
+		return "", fmt.Errorf("JWT does not have all the necessary claims")
+	}
+
+	// Validate Privy-specific claims
+	if err := validatePrivyClaims(privyClaim, appID, env); err != nil {
+		return "", fmt.Errorf("JWT claims are invalid: %w", err)
+	}
+
+	return privyClaim.PrivyId, nil
+}
+
+// Formats the PEM key properly - handles both escaped newlines and space-separated format
+func formatVerificationKey(verificationKey string) string {
+	formattedVerificationKey := verificationKey
 
 	// First try to handle escaped newlines (for JSON strings)
 	formattedVerificationKey = strings.ReplaceAll(formattedVerificationKey, "\\n", "\n")
@@ -105,62 +166,5 @@ func ValidateJWTAndExtractPrivyID(tokenString string, teeCfg *enclave.TEEConfig)
 		}
 	}
 
-	// Let's also try to decode the JWT header and payload to see what's inside
-	parts := strings.Split(tokenString, ".")
-	if len(parts) == 3 {
-		// Decode header
-		headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
-		if err == nil {
-			log.Infof("JWT Header: %s", string(headerBytes))
-		}
-
-		// Decode payload
-		payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
-		if err == nil {
-			log.Infof("JWT payload: %s", string(payloadBytes))
-		}
-	}
-
-	// keyFunc validates the signing method and returns the verification key
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		if token.Method.Alg() != "ES256" {
-			log.Infof("Unexpected JWT signing method: %s", token.Method.Alg())
-			return nil, fmt.Errorf("unexpected JWT signing method=%v", token.Header["alg"])
-		}
-
-		// Parse the ECDSA public key from PEM format using the formatted key
-		key, err := jwt.ParseECPublicKeyFromPEM([]byte(formattedVerificationKey))
-		if err != nil {
-			log.Errorf("Failed to parse ECDSA public key: %v", err)
-			return nil, fmt.Errorf("failed to parse verification key: %w", err)
-		}
-
-		log.Info("Successfully parsed ECDSA public key")
-		return key, nil
-	}
-
-	// Parse and validate the JWT token
-	log.Info("Parsing JWT token")
-	token, err := jwt.ParseWithClaims(tokenString, &PrivyClaims{}, keyFunc)
-	if err != nil {
-		log.Errorf("JWT parsing failed: %v", err)
-		return "", fmt.Errorf("JWT signature is invalid: %w", err)
-	}
-
-	if token.Valid {
-		log.Info("Successfuly parsed token")
-	}
-
-	// Parse the JWT claims into our custom struct
-	privyClaim, ok := token.Claims.(*PrivyClaims)
-	if !ok {
-		return "", fmt.Errorf("JWT does not have all the necessary claims")
-	}
-
-	// Validate Privy-specific claims
-	if err := validatePrivyClaims(privyClaim, teeCfg.Privy.AppID, teeCfg.Environment); err != nil {
-		return "", fmt.Errorf("JWT claims are invalid: %w", err)
-	}
-
-	return privyClaim.PrivyId, nil
+	return formattedVerificationKey
 }
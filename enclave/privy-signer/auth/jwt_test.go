@@ -1,16 +1,20 @@
 package auth
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/pem"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/getaxal/verified-signer/common/redact"
 	"github.com/getaxal/verified-signer/enclave"
 	"github.com/golang-jwt/jwt/v5"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -336,3 +340,34 @@ func TestValidateJWTAndExtractUserID_WrongSigningMethod(t *testing.T) {
 	assert.Empty(t, userID)
 	assert.Contains(t, err.Error(), "unexpected JWT signing method")
 }
+
+func TestValidateJWTWithKeys_DoesNotLogToken(t *testing.T) {
+	logger := log.StandardLogger()
+	out, formatter := logger.Out, logger.Formatter
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	redact.Install(logger)
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+	})
+
+	claims := &PrivyClaims{
+		PrivyId:    "did:privy:test123456789",
+		Issuer:     "privy.io",
+		AppId:      "other-app-id",
+		Expiration: time.Now().Add(1 * time.Hour).Unix(),
+		IssuedAt:   time.Now().Unix(),
+	}
+	token, err := createTestJWT(claims)
+	require.NoError(t, err)
+
+	_, err = ValidateJWTWithKeys(token, []string{testPublicKeyPEM}, "test-app-id", "local")
+	assert.Error(t, err)
+
+	parts := strings.Split(token, ".")
+	for _, part := range parts {
+		assert.NotContains(t, buf.String(), part)
+	}
+	assert.NotContains(t, buf.String(), "did:privy:test123456789")
+}
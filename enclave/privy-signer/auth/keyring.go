@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Keyring holds the current value of a rotating key and, until the end of the rotation grace window, the key it replaced.
+// Verifiers accept either so requests signed just before a rotation still pass.
+type Keyring struct {
+	mu            sync.RWMutex
+	current       string
+	previous      string
+	previousUntil time.Time
+	now           func() time.Time
+}
+
+// Creates a new Keyring with a single key
+func NewKeyring(current string) *Keyring {
+	return &Keyring{
+		current: current,
+		now:     time.Now,
+	}
+}
+
+// Rotate makes next the current key and keeps the old current key valid until previousUntil
+func (k *Keyring) Rotate(next string, previousUntil time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if next == k.current {
+		return
+	}
+
+	k.previous = k.current
+	k.previousUntil = previousUntil
+	k.current = next
+}
+
+// Current returns the current key
+func (k *Keyring) Current() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current
+}
+
+// Keys returns the keys that are valid right now, current first
+func (k *Keyring) Keys() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := []string{k.current}
+	if k.previous != "" && k.now().Before(k.previousUntil) {
+		keys = append(keys, k.previous)
+	}
+	return keys
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/keystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_Rotate(t *testing.T) {
+	now := time.Now()
+	keyring := NewKeyring("old-key")
+	keyring.now = func() time.Time { return now }
+
+	assert.Equal(t, []string{"old-key"}, keyring.Keys())
+
+	keyring.Rotate("new-key", now.Add(time.Hour))
+	assert.Equal(t, "new-key", keyring.Current())
+	assert.Equal(t, []string{"new-key", "old-key"}, keyring.Keys())
+
+	// Rotating to the same key keeps the grace window of the previous key
+	keyring.Rotate("new-key", now.Add(time.Minute))
+	assert.Equal(t, []string{"new-key", "old-key"}, keyring.Keys())
+
+	now = now.Add(2 * time.Hour)
+	assert.Equal(t, []string{"new-key"}, keyring.Keys())
+}
+
+func TestVerifyAxalSignatureWithKeyStore(t *testing.T) {
+	keys := keystore.New()
+	keys.PutString(keystore.AxalRequestSecretKey, "old-key")
+
+	oldSig := signRequest("payload", []byte("old-key"))
+	assert.True(t, VerifyAxalSignatureWithKeyStore("payload", oldSig, keys))
+
+	keys.Rotate(keystore.AxalRequestSecretKey, []byte("new-key"), time.Now().Add(time.Hour))
+	newSig := signRequest("payload", []byte("new-key"))
+	assert.True(t, VerifyAxalSignatureWithKeyStore("payload", newSig, keys))
+	assert.True(t, VerifyAxalSignatureWithKeyStore("payload", oldSig, keys), "old key must pass inside the grace window")
+
+	keys.Rotate(keystore.AxalRequestSecretKey, []byte("newer-key"), time.Now().Add(-time.Second))
+	assert.False(t, VerifyAxalSignatureWithKeyStore("payload", newSig, keys), "replaced key must fail after the grace window")
+	assert.False(t, VerifyAxalSignatureWithKeyStore("payload", oldSig, keys))
+	assert.True(t, VerifyAxalSignatureWithKeyStore("payload", signRequest("payload", []byte("newer-key")), keys))
+
+	assert.False(t, VerifyAxalSignatureWithKeyStore("payload", oldSig, keystore.New()), "an empty key store accepts nothing")
+}
+
+func TestValidateJWTWithKeys_Rotation(t *testing.T) {
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKeyBytes, err := x509.MarshalPKIXPublicKey(&newKey.PublicKey)
+	require.NoError(t, err)
+	newKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: newKeyBytes}))
+
+	appID := "test-app-id"
+	claims := &PrivyClaims{
+		PrivyId:    "did:privy:test123456789",
+		Issuer:     "privy.io",
+		AppId:      appID,
+		Expiration: time.Now().Add(time.Hour).Unix(),
+		IssuedAt:   time.Now().Unix(),
+	}
+
+	// Token signed with the key that is being rotated out
+	token, err := createTestJWT(claims)
+	require.NoError(t, err)
+
+	privyId, err := ValidateJWTWithKeys(token, []string{newKeyPEM, testPublicKeyPEM}, appID, "local")
+	assert.NoError(t, err)
+	assert.Equal(t, claims.PrivyId, privyId)
+
+	_, err = ValidateJWTWithKeys(token, []string{newKeyPEM}, appID, "local")
+	assert.Error(t, err)
+
+	_, err = ValidateJWTWithKeys(token, nil, appID, "local")
+	assert.Error(t, err)
+}
@@ -2,8 +2,11 @@ package authorizationsignature
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/keystore"
 
 	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
 	log "github.com/sirupsen/logrus"
@@ -24,7 +27,13 @@ import (
 //
 // The headers are the headers of the http request we will be signing, the bpdy is the body of the http we are signing. We assume that there is only one header value per key (for privy headers this is the case)
 // We then use the authorization key (also known as the delegated signing key) to sign the hash of the payload. It is a ECDSA-P256 signature that is then base64 encoded.
-func GetAuthorizationSignature(body interface{}, methodType string, privyAuthorizationKey string, url string, privyAppId string) (string, error) {
+// Each of keyNames is a parsed authorization key kept in keys, for a wallet owned by a key quorum every member key signs the same
+// payload and the signatures are joined with commas.
+func GetAuthorizationSignature(body interface{}, methodType string, keys *keystore.KeyStore, keyNames []string, url string, privyAppId string) (string, error) {
+	if len(keyNames) == 0 {
+		return "", fmt.Errorf("no authorization keys to sign with")
+	}
+
 	headermap := map[string]string{
 		"privy-app-id": privyAppId,
 	}
@@ -52,12 +61,17 @@ func GetAuthorizationSignature(body interface{}, methodType string, privyAuthori
 		return "", err
 	}
 
-	signature, err := SignPayload([]byte(privyAuthorizationKey), canonical)
+	signatures := make([]string, 0, len(keyNames))
+	for _, name := range keyNames {
+		signature, err := SignPayloadWithKeyStore(keys, name, canonical)
 
-	if err != nil {
-		log.Errorf("Error: %v", err)
-		return "", err
+		if err != nil {
+			log.Errorf("Error: %v", err)
+			return "", err
+		}
+
+		signatures = append(signatures, string(signature))
 	}
 
-	return string(signature), nil
+	return strings.Join(signatures, ","), nil
 }
@@ -0,0 +1,64 @@
+package authorizationsignature
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+	"github.com/getaxal/verified-signer/enclave/keystore"
+)
+
+func TestGetAuthorizationSignature_KeyQuorum(t *testing.T) {
+	keys := keystore.New()
+	names := []string{keystore.PrivyAuthorizationKey, keystore.PrivyQuorumAuthorizationKey(0)}
+	publicKeys := make([]ecdsa.PublicKey, len(names))
+
+	for i, name := range names {
+		privateKey, authKeyBytes, err := generateTestECDSAKeyBytes()
+		if err != nil {
+			t.Fatalf("Failed to generate test key: %v", err)
+		}
+		parsed, err := ParseAuthorizationKey(authKeyBytes)
+		if err != nil {
+			t.Fatalf("ParseAuthorizationKey() error = %v", err)
+		}
+		keys.PutECDSAKey(name, parsed)
+		publicKeys[i] = privateKey.PublicKey
+	}
+
+	body := map[string]interface{}{"method": "eth_signTransaction"}
+	url := "https://api.privy.io/v1/wallets/wallet-id/rpc"
+	header, err := GetAuthorizationSignature(&body, "POST", keys, names, url, "app-id")
+	if err != nil {
+		t.Fatalf("GetAuthorizationSignature() error = %v", err)
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"body":    body,
+		"headers": map[string]string{"privy-app-id": "app-id"},
+		"method":  "POST",
+		"url":     url,
+		"version": 1,
+	})
+	canonical, err := jsoncanonicalizer.Transform(payload)
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+
+	signatures := strings.Split(header, ",")
+	if len(signatures) != len(names) {
+		t.Fatalf("GetAuthorizationSignature() = %s, want %d comma separated signatures", header, len(names))
+	}
+	for i, signature := range signatures {
+		valid, err := VerifySignature(&publicKeys[i], canonical, []byte(signature))
+		if err != nil || !valid {
+			t.Errorf("signature %d does not verify with its quorum key: %v", i, err)
+		}
+	}
+
+	if _, err := GetAuthorizationSignature(body, "POST", keys, nil, url, "app-id"); err == nil {
+		t.Error("GetAuthorizationSignature() expected an error without authorization keys")
+	}
+}
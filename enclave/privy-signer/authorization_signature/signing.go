@@ -11,6 +11,7 @@ import (
 	"unsafe"
 
 	"github.com/awnumar/memguard"
+	"github.com/getaxal/verified-signer/enclave/keystore"
 )
 
 func init() {
@@ -112,6 +113,33 @@ func parsePrivateKeyFromAuthorizationKeyBytes(authKeyBytes []byte) (*ecdsa.Priva
 	return ecdsaKey, nil
 }
 
+// ParseAuthorizationKey parses the ecdsa key from a privy authorization key, with or without its wallet-auth: prefix
+func ParseAuthorizationKey(authKeyBytes []byte) (*ecdsa.PrivateKey, error) {
+	return parsePrivateKeyFromAuthorizationKeyBytes(authKeyBytes)
+}
+
+// SignPayloadWithKeyStore signs the canonicalized JSON payload using ECDSA (P-256 + SHA-256) with the parsed key stored under
+// name in keys, without parsing the authorization key again
+func SignPayloadWithKeyStore(keys *keystore.KeyStore, name string, payloadBytes []byte) ([]byte, error) {
+	hashMem := newSecureMemory(sha256.Size)
+	defer hashMem.destroy()
+
+	hash := sha256.Sum256(payloadBytes)
+	copy(hashMem.bytes(), hash[:])
+	secureZero(hash[:])
+
+	signature, err := keys.SignECDSA(name, hashMem.bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign payload: %w", err)
+	}
+	defer secureZero(signature)
+
+	result := make([]byte, base64.StdEncoding.EncodedLen(len(signature)))
+	base64.StdEncoding.Encode(result, signature)
+
+	return result, nil
+}
+
 // SignPayload signs the canonicalized JSON payload using ECDSA (P-256 + SHA-256)
 func SignPayload(privyAuthorizationKeyBytes, payloadBytes []byte) ([]byte, error) {
 	// Parse private key first
@@ -9,6 +9,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/keystore"
 )
 
 // Test helper functions
@@ -587,3 +589,33 @@ func TestSecureCompare(t *testing.T) {
 		})
 	}
 }
+
+func TestSignPayloadWithKeyStore(t *testing.T) {
+	privateKey, authKeyBytes, err := generateTestECDSAKeyBytes()
+	if err != nil {
+		t.Fatalf("Failed to generate test key: %v", err)
+	}
+
+	parsed, err := ParseAuthorizationKey(authKeyBytes)
+	if err != nil {
+		t.Fatalf("ParseAuthorizationKey() error = %v", err)
+	}
+
+	keys := keystore.New()
+	keys.PutECDSAKey(keystore.PrivyAuthorizationKey, parsed)
+
+	payload := []byte(`{"body":{},"version":1}`)
+	signature, err := SignPayloadWithKeyStore(keys, keystore.PrivyAuthorizationKey, payload)
+	if err != nil {
+		t.Fatalf("SignPayloadWithKeyStore() error = %v", err)
+	}
+
+	valid, err := VerifySignature(&privateKey.PublicKey, payload, signature)
+	if err != nil || !valid {
+		t.Errorf("VerifySignature() = %v, %v, want the signature to verify with the original key", valid, err)
+	}
+
+	if _, err := SignPayloadWithKeyStore(keys, "missing", payload); err == nil {
+		t.Error("SignPayloadWithKeyStore() expected an error for a missing key")
+	}
+}
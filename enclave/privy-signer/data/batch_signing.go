@@ -1,8 +1,10 @@
 package data
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+
+	"github.com/getaxal/verified-signer/enclave/validate"
 )
 
 // BatchSignRequest represents a batch of signing requests from Axal backend
@@ -34,32 +36,33 @@ type SignatureResult struct {
 	Error     string `json:"error,omitempty"`
 }
 
-// ValidateBatchRequest validates the entire batch request
+// Most signing requests a batch may hold
+const maxBatchSize = 10000
+
+// ValidateBatchRequest validates the entire batch request, the error lists every invalid field
 func (bsr *BatchSignRequest) ValidateBatchRequest() error {
+	var fields validate.Fields
 	if len(bsr.SigningRequests) == 0 {
-		return fmt.Errorf("batch request cannot be empty")
+		fields.Check("signing_requests", errors.New("cannot be empty"))
 	}
-
-	if len(bsr.SigningRequests) > 10000 {
-		return fmt.Errorf("batch request too large: max 10000 requests, got %d", len(bsr.SigningRequests))
+	if len(bsr.SigningRequests) > maxBatchSize {
+		fields.Check("signing_requests", fmt.Errorf("cannot hold more than %d requests, got %d", maxBatchSize, len(bsr.SigningRequests)))
+		return fields.Err()
 	}
 
 	for i, req := range bsr.SigningRequests {
-		if req.Hash == "" {
-			return fmt.Errorf("hash is required for request %d", i)
-		}
-		if !strings.HasPrefix(req.Hash, "0x") {
-			return fmt.Errorf("hash must start with 0x for request %d", i)
-		}
-		if req.PrivyID == "" {
-			return fmt.Errorf("privy_id is required for request %d", i)
-		}
-		if req.SigningType != "axal" && req.SigningType != "user" {
-			return fmt.Errorf("invalid signing_type for request %d: must be 'axal' or 'user'", i)
-		}
+		req.validate(&fields, fmt.Sprintf("signing_requests[%d].", i))
 	}
+	return fields.Err()
+}
 
-	return nil
+// Records the invalid fields of a single request, prefix locates it in the batch
+func (ssr *SingleSignRequest) validate(fields *validate.Fields, prefix string) {
+	fields.Check(prefix+"hash", validate.Hash32(ssr.Hash))
+	fields.Check(prefix+"privy_id", validate.PrivyDID(ssr.PrivyID))
+	if ssr.SigningType != UserInitiatedSigning.String() && ssr.SigningType != AxalInitiatedSigning.String() {
+		fields.Check(prefix+"signing_type", errors.New("must be 'axal' or 'user'"))
+	}
 }
 
 // Helper function to create a new batch sign request
@@ -2,28 +2,29 @@ package data
 
 import (
 	"encoding/json"
+	"slices"
 	"testing"
 )
 
 func TestBatchSignRequest_ValidateBatchRequest(t *testing.T) {
 	tests := []struct {
-		name    string
-		req     *BatchSignRequest
-		wantErr bool
-		errMsg  string
+		name       string
+		req        *BatchSignRequest
+		wantErr    bool
+		wantFields []string
 	}{
 		{
 			name: "valid batch request",
 			req: &BatchSignRequest{
 				SigningRequests: []SingleSignRequest{
 					{
-						Hash:        "0x1234567890abcdef",
+						Hash:        "0x1212121212121212121212121212121212121212121212121212121212121212",
 						PrivyID:     "did:privy:test123",
 						SigningType: "axal",
 						Index:       0,
 					},
 					{
-						Hash:        "0xfedcba0987654321",
+						Hash:        "0xfefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefe",
 						PrivyID:     "did:privy:test456",
 						SigningType: "user",
 						Index:       1,
@@ -37,8 +38,8 @@ func TestBatchSignRequest_ValidateBatchRequest(t *testing.T) {
 			req: &BatchSignRequest{
 				SigningRequests: []SingleSignRequest{},
 			},
-			wantErr: true,
-			errMsg:  "batch request cannot be empty",
+			wantErr:    true,
+			wantFields: []string{"signing_requests"},
 		},
 		{
 			name: "missing hash",
@@ -52,53 +53,89 @@ func TestBatchSignRequest_ValidateBatchRequest(t *testing.T) {
 					},
 				},
 			},
-			wantErr: true,
-			errMsg:  "hash is required for request 0",
+			wantErr:    true,
+			wantFields: []string{"signing_requests[0].hash"},
 		},
 		{
 			name: "invalid hash format",
 			req: &BatchSignRequest{
 				SigningRequests: []SingleSignRequest{
 					{
-						Hash:        "1234567890abcdef", // Missing 0x prefix
+						Hash:        "1212121212121212121212121212121212121212121212121212121212121212", // Missing 0x prefix
 						PrivyID:     "did:privy:test123",
 						SigningType: "axal",
 						Index:       0,
 					},
 				},
 			},
-			wantErr: true,
-			errMsg:  "hash must start with 0x for request 0",
+			wantErr:    true,
+			wantFields: []string{"signing_requests[0].hash"},
 		},
 		{
 			name: "missing privy_id",
 			req: &BatchSignRequest{
 				SigningRequests: []SingleSignRequest{
 					{
-						Hash:        "0x1234567890abcdef",
+						Hash:        "0x1212121212121212121212121212121212121212121212121212121212121212",
 						PrivyID:     "",
 						SigningType: "axal",
 						Index:       0,
 					},
 				},
 			},
-			wantErr: true,
-			errMsg:  "privy_id is required for request 0",
+			wantErr:    true,
+			wantFields: []string{"signing_requests[0].privy_id"},
 		},
 		{
-			name: "invalid signing type",
+			name: "short hash",
 			req: &BatchSignRequest{
 				SigningRequests: []SingleSignRequest{
 					{
 						Hash:        "0x1234567890abcdef",
 						PrivyID:     "did:privy:test123",
+						SigningType: "axal",
+						Index:       0,
+					},
+				},
+			},
+			wantErr:    true,
+			wantFields: []string{"signing_requests[0].hash"},
+		},
+		{
+			name: "every invalid field is reported",
+			req: &BatchSignRequest{
+				SigningRequests: []SingleSignRequest{
+					{
+						Hash:        "0x1212121212121212121212121212121212121212121212121212121212121212",
+						PrivyID:     "did:privy:test123",
+						SigningType: "axal",
+						Index:       0,
+					},
+					{
+						Hash:        "0x12",
+						PrivyID:     "alice",
+						SigningType: "axal",
+						Index:       1,
+					},
+				},
+			},
+			wantErr:    true,
+			wantFields: []string{"signing_requests[1].hash", "signing_requests[1].privy_id"},
+		},
+		{
+			name: "invalid signing type",
+			req: &BatchSignRequest{
+				SigningRequests: []SingleSignRequest{
+					{
+						Hash:        "0x1212121212121212121212121212121212121212121212121212121212121212",
+						PrivyID:     "did:privy:test123",
 						SigningType: "invalid",
 						Index:       0,
 					},
 				},
 			},
-			wantErr: true,
-			errMsg:  "invalid signing_type for request 0: must be 'axal' or 'user'",
+			wantErr:    true,
+			wantFields: []string{"signing_requests[0].signing_type"},
 		},
 	}
 
@@ -108,8 +145,8 @@ func TestBatchSignRequest_ValidateBatchRequest(t *testing.T) {
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("BatchSignRequest.ValidateBatchRequest() expected error but got none")
-				} else if err.Error() != tt.errMsg {
-					t.Errorf("BatchSignRequest.ValidateBatchRequest() error = %v, want %v", err.Error(), tt.errMsg)
+				} else if fields := errorFields(t, err); !slices.Equal(fields, tt.wantFields) {
+					t.Errorf("BatchSignRequest.ValidateBatchRequest() fields = %v, want %v", fields, tt.wantFields)
 				}
 			} else {
 				if err != nil {
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+
+	"github.com/getaxal/verified-signer/enclave/validate"
 )
 
-// BigInt wraps big.Int with proper JSON marshaling/unmarshaling
+// BigInt wraps big.Int with proper JSON marshaling/unmarshaling. It unmarshals from decimal strings and numbers, and from
+// JSON-RPC hex quantities like "0x1a". It always marshals to a decimal string, Hex returns the quantity form.
 // @Description Large integer that preserves precision by using string representation in JSON
 // @Example "123456789012345678901234567890"
 type BigInt struct {
@@ -22,8 +25,8 @@ func NewBigInt(value interface{}) (*BigInt, error) {
 	case int64:
 		b.Int.SetInt64(v)
 	case string:
-		if _, ok := b.Int.SetString(v, 10); !ok {
-			return nil, fmt.Errorf("invalid big integer string: %s", v)
+		if err := setBigIntString(b.Int, v); err != nil {
+			return nil, err
 		}
 	case *big.Int:
 		if v != nil {
@@ -40,7 +43,22 @@ func NewBigInt(value interface{}) (*BigInt, error) {
 	return b, nil
 }
 
-// NewBigIntFromString creates a BigInt from a string
+// Sets n to a decimal string or a 0x prefixed hex quantity
+func setBigIntString(n *big.Int, s string) error {
+	if strings.HasPrefix(s, "0x") {
+		if err := validate.Quantity(s); err != nil {
+			return fmt.Errorf("invalid hex quantity %s: %w", s, err)
+		}
+		n.SetString(s[2:], 16)
+		return nil
+	}
+	if _, ok := n.SetString(s, 10); !ok {
+		return fmt.Errorf("invalid big integer string: %s", s)
+	}
+	return nil
+}
+
+// NewBigIntFromString creates a BigInt from a decimal string or a hex quantity
 func NewBigIntFromString(s string) (*BigInt, error) {
 	return NewBigInt(s)
 }
@@ -75,10 +93,7 @@ func (b *BigInt) UnmarshalJSON(data []byte) error {
 	// Try to unmarshal as string first (preferred format)
 	var s string
 	if err := json.Unmarshal(data, &s); err == nil {
-		if _, ok := b.Int.SetString(s, 10); !ok {
-			return fmt.Errorf("invalid big integer string: %s", s)
-		}
-		return nil
+		return setBigIntString(b.Int, s)
 	}
 
 	// Try to unmarshal as number (for compatibility)
@@ -107,6 +122,17 @@ func (b *BigInt) String() string {
 	return b.Int.String()
 }
 
+// Hex returns the JSON-RPC hex quantity, like 0x1a. Negative numbers get a leading minus sign.
+func (b *BigInt) Hex() string {
+	if b == nil || b.Int == nil {
+		return "0x0"
+	}
+	if b.Int.Sign() < 0 {
+		return "-0x" + new(big.Int).Neg(b.Int).Text(16)
+	}
+	return "0x" + b.Int.Text(16)
+}
+
 // IsZero checks if the BigInt is zero
 func (b *BigInt) IsZero() bool {
 	return b == nil || b.Int == nil || b.Int.Sign() == 0
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBigInt_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    string
+		wantErr bool
+	}{
+		{json: `"1000"`, want: "1000"},
+		{json: `1000`, want: "1000"},
+		{json: `"0x1a"`, want: "26"},
+		{json: `"0x0"`, want: "0"},
+		{json: `"0xde0b6b3a7640000"`, want: "1000000000000000000"},
+		{json: `null`, want: "0"},
+		{json: `"0x01a"`, wantErr: true},
+		{json: `"0x"`, wantErr: true},
+		{json: `"0xzz"`, wantErr: true},
+		{json: `"ten"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var b BigInt
+		err := json.Unmarshal([]byte(tt.json), &b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && b.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.json, b.String(), tt.want)
+		}
+	}
+}
+
+func TestBigInt_Hex(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "0", want: "0x0"},
+		{value: "26", want: "0x1a"},
+		{value: "0x400", want: "0x400"},
+		{value: "-26", want: "-0x1a"},
+	}
+
+	for _, tt := range tests {
+		b, err := NewBigIntFromString(tt.value)
+		if err != nil {
+			t.Fatalf("NewBigIntFromString(%q) error = %v", tt.value, err)
+		}
+		if got := b.Hex(); got != tt.want {
+			t.Errorf("Hex() of %s = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	// Hex quantities still marshal as decimal strings
+	b, _ := NewBigIntFromString("0x1a")
+	if got, _ := json.Marshal(b); string(got) != `"26"` {
+		t.Errorf("Marshal() = %s, want \"26\"", got)
+	}
+}
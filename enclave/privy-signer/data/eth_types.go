@@ -0,0 +1,128 @@
+package data
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/getaxal/verified-signer/enclave/validate"
+)
+
+// Address is a 20 byte Ethereum address. It marshals to its EIP-55 checksummed form and only unmarshals from valid addresses.
+type Address [20]byte
+
+// Parses a 0x prefixed address, mixed case addresses must carry a valid EIP-55 checksum
+func ParseAddress(s string) (Address, error) {
+	var addr Address
+	if err := validate.Address(s); err != nil {
+		return addr, fmt.Errorf("address %s %w", s, err)
+	}
+	hex.Decode(addr[:], []byte(s[2:]))
+	return addr, nil
+}
+
+// Returns the EIP-55 checksummed address
+func (a Address) String() string {
+	return validate.ChecksumAddress(a[:])
+}
+
+// Reports whether a is the zero address
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// MarshalJSON implements json.Marshaler
+func (a Address) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("address must be a string: %w", err)
+	}
+	addr, err := ParseAddress(s)
+	if err != nil {
+		return err
+	}
+	*a = addr
+	return nil
+}
+
+// Hash32 is a 32 byte hash, like a signing hash or a transaction hash
+type Hash32 [32]byte
+
+// Parses a 0x prefixed 32 byte hex hash
+func ParseHash32(s string) (Hash32, error) {
+	var hash Hash32
+	if err := validate.Hash32(s); err != nil {
+		return hash, fmt.Errorf("hash %s %w", s, err)
+	}
+	hex.Decode(hash[:], []byte(s[2:]))
+	return hash, nil
+}
+
+// Returns the 0x prefixed lower case hex hash
+func (h Hash32) String() string {
+	return "0x" + hex.EncodeToString(h[:])
+}
+
+// MarshalJSON implements json.Marshaler
+func (h Hash32) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (h *Hash32) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("hash must be a string: %w", err)
+	}
+	hash, err := ParseHash32(s)
+	if err != nil {
+		return err
+	}
+	*h = hash
+	return nil
+}
+
+// HexBytes are bytes of any length that marshal to 0x prefixed hex, "0x" is empty
+type HexBytes []byte
+
+// Parses 0x prefixed hex bytes
+func ParseHexBytes(s string) (HexBytes, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("hex bytes %s must start with 0x", s)
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, fmt.Errorf("hex bytes %s must be hex encoded bytes", s)
+	}
+	return b, nil
+}
+
+// Returns the 0x prefixed lower case hex bytes
+func (b HexBytes) String() string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// MarshalJSON implements json.Marshaler
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("hex bytes must be a string: %w", err)
+	}
+	parsed, err := ParseHexBytes(s)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAddress_JSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    string
+		wantErr bool
+	}{
+		{name: "checksummed", json: `"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"`, want: `"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"`},
+		{name: "lower case is checksummed on the way out", json: `"0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"`, want: `"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"`},
+		{name: "bad checksum", json: `"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD"`, wantErr: true},
+		{name: "short", json: `"0x5aaeb6053f3e94c9b9a09f33669435e7ef1bea"`, wantErr: true},
+		{name: "not a string", json: `1`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var addr Address
+			err := json.Unmarshal([]byte(tt.json), &addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %v", tt.json, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, err := json.Marshal(addr)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("Marshal() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddress_IsZero(t *testing.T) {
+	var zero Address
+	addr, err := ParseAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	if err != nil {
+		t.Fatalf("ParseAddress() error = %v", err)
+	}
+	if !zero.IsZero() || addr.IsZero() {
+		t.Errorf("IsZero() = %v, %v, want true, false", zero.IsZero(), addr.IsZero())
+	}
+}
+
+func TestHash32_JSON(t *testing.T) {
+	hex := "0x" + strings.Repeat("ab", 32)
+
+	var hash Hash32
+	if err := json.Unmarshal([]byte(`"`+strings.ToUpper(hex[2:])+`"`), &hash); err == nil {
+		t.Errorf("Unmarshal() accepted a hash without 0x")
+	}
+	if err := json.Unmarshal([]byte(`"0x`+strings.Repeat("AB", 32)+`"`), &hash); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got, _ := json.Marshal(hash); string(got) != `"`+hex+`"` {
+		t.Errorf("Marshal() = %s, want %q", got, hex)
+	}
+	if err := json.Unmarshal([]byte(`"0xabab"`), &hash); err == nil {
+		t.Errorf("Unmarshal() accepted a 2 byte hash")
+	}
+}
+
+func TestHexBytes_JSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    HexBytes
+		wantErr bool
+	}{
+		{json: `"0x"`, want: HexBytes{}},
+		{json: `"0x00ff"`, want: HexBytes{0x00, 0xff}},
+		{json: `"0x0"`, wantErr: true},
+		{json: `"00ff"`, wantErr: true},
+		{json: `"0xzz"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var b HexBytes
+		err := json.Unmarshal([]byte(tt.json), &b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got, _ := json.Marshal(b); string(got) != tt.json {
+			t.Errorf("Marshal() = %s, want %s", got, tt.json)
+		}
+	}
+}
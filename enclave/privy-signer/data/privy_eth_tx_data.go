@@ -1,6 +1,10 @@
 package data
 
-import "fmt"
+import (
+	"errors"
+
+	"github.com/getaxal/verified-signer/enclave/validate"
+)
 
 // Interface for all Eth transaction requests
 type EthTxRequest interface {
@@ -22,18 +26,27 @@ type AxalEthSecp256k1SignRequest struct {
 	Params struct {
 		Hash string `json:"hash"`
 	} `json:"params"`
-	PrivyID string `json:"privy_id"`
+	PrivyID  string           `json:"privy_id"`
+	Preimage *SigningPreimage `json:"preimage,omitempty"` // What params.hash is the digest of, it is checked in the enclave and never sent to Privy
+}
+
+// SigningPreimage is the content an Axal signing hash is the digest of, exactly one of its fields is set
+type SigningPreimage struct {
+	Transaction HexBytes   `json:"transaction,omitempty"` // Serialized unsigned transaction, see DecodeUnsignedTransaction
+	TypedData   *TypedData `json:"typed_data,omitempty"`  // EIP-712 typed data
 }
 
+// The only method the sign requests support
+const secp256k1SignMethod = "secp256k1_sign"
+
 // UserEthSecp256k1SignRequest methods
+
+// Validates the request, the error lists every invalid field
 func (req *UserEthSecp256k1SignRequest) ValidateTxRequest() error {
-	if req.Method != "secp256k1_sign" {
-		return fmt.Errorf("incorrect transaction request method")
-	}
-	if req.Params.Hash == "" {
-		return fmt.Errorf("hash is required")
-	}
-	return nil
+	var fields validate.Fields
+	fields.Check("method", validateMethod(req.Method))
+	fields.Check("params.hash", validate.Hash32(req.Params.Hash))
+	return fields.Err()
 }
 
 func (req *UserEthSecp256k1SignRequest) GetMethod() string {
@@ -41,15 +54,30 @@ func (req *UserEthSecp256k1SignRequest) GetMethod() string {
 }
 
 // AxalEthSecp256k1SignRequest methods
+
+// Validates the request, the error lists every invalid field
 func (req *AxalEthSecp256k1SignRequest) ValidateTxRequest() error {
-	if req.Method != "secp256k1_sign" {
-		return fmt.Errorf("incorrect transaction request method")
+	var fields validate.Fields
+	fields.Check("method", validateMethod(req.Method))
+	fields.Check("params.hash", validate.Hash32(req.Params.Hash))
+	fields.Check("privy_id", validate.PrivyDID(req.PrivyID))
+	if req.Preimage != nil {
+		fields.Check("preimage", req.Preimage.validate())
 	}
-	if req.Params.Hash == "" {
-		return fmt.Errorf("hash is required")
-	}
-	if req.PrivyID == "" {
-		return fmt.Errorf("privy_id is required for axal requests")
+	return fields.Err()
+}
+
+// Returns the request without its preimage, the body Privy is asked to sign
+func (req *AxalEthSecp256k1SignRequest) WithoutPreimage() *AxalEthSecp256k1SignRequest {
+	privyReq := *req
+	privyReq.Preimage = nil
+	return &privyReq
+}
+
+// Checks exactly one kind of preimage is given, its content is decoded and checked against the hash by the verifier
+func (p *SigningPreimage) validate() error {
+	if (len(p.Transaction) == 0) == (p.TypedData == nil) {
+		return errors.New("must hold exactly one of transaction and typed_data")
 	}
 	return nil
 }
@@ -58,10 +86,17 @@ func (req *AxalEthSecp256k1SignRequest) GetMethod() string {
 	return req.Method
 }
 
+func validateMethod(method string) error {
+	if method != secp256k1SignMethod {
+		return errors.New("must be " + secp256k1SignMethod)
+	}
+	return nil
+}
+
 // Creates a new User secp256k1_sign Request
 func NewUserEthSecp256k1SignRequest(hash string) *UserEthSecp256k1SignRequest {
 	return &UserEthSecp256k1SignRequest{
-		Method: "secp256k1_sign",
+		Method: secp256k1SignMethod,
 		Params: struct {
 			Hash string `json:"hash"`
 		}{
@@ -73,7 +108,7 @@ func NewUserEthSecp256k1SignRequest(hash string) *UserEthSecp256k1SignRequest {
 // Creates a new Axal secp256k1_sign Request
 func NewAxalEthSecp256k1SignRequest(hash, privyID string) *AxalEthSecp256k1SignRequest {
 	return &AxalEthSecp256k1SignRequest{
-		Method: "secp256k1_sign",
+		Method: secp256k1SignMethod,
 		Params: struct {
 			Hash string `json:"hash"`
 		}{
@@ -2,7 +2,12 @@ package data
 
 import (
 	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
 )
 
 func TestNewUserEthSecp256k1SignRequest(t *testing.T) {
@@ -64,10 +69,10 @@ func TestNewUserEthSecp256k1SignRequest(t *testing.T) {
 
 func TestUserEthSecp256k1SignRequest_ValidateTxRequest(t *testing.T) {
 	tests := []struct {
-		name    string
-		req     *UserEthSecp256k1SignRequest
-		wantErr bool
-		errMsg  string
+		name       string
+		req        *UserEthSecp256k1SignRequest
+		wantErr    bool
+		wantFields []string
 	}{
 		{
 			name: "valid method",
@@ -76,7 +81,7 @@ func TestUserEthSecp256k1SignRequest_ValidateTxRequest(t *testing.T) {
 				Params: struct {
 					Hash string `json:"hash"`
 				}{
-					Hash: "0x1234",
+					Hash: "0x1212121212121212121212121212121212121212121212121212121212121212",
 				},
 			},
 			wantErr: false,
@@ -88,24 +93,50 @@ func TestUserEthSecp256k1SignRequest_ValidateTxRequest(t *testing.T) {
 				Params: struct {
 					Hash string `json:"hash"`
 				}{
-					Hash: "0x1234",
+					Hash: "0x1212121212121212121212121212121212121212121212121212121212121212",
 				},
 			},
-			wantErr: true,
-			errMsg:  "incorrect transaction request method",
+			wantErr:    true,
+			wantFields: []string{"method"},
 		},
 		{
 			name: "empty method",
 			req: &UserEthSecp256k1SignRequest{
 				Method: "",
+				Params: struct {
+					Hash string `json:"hash"`
+				}{
+					Hash: "0x1212121212121212121212121212121212121212121212121212121212121212",
+				},
+			},
+			wantErr:    true,
+			wantFields: []string{"method"},
+		},
+		{
+			name: "short hash",
+			req: &UserEthSecp256k1SignRequest{
+				Method: "secp256k1_sign",
 				Params: struct {
 					Hash string `json:"hash"`
 				}{
 					Hash: "0x1234",
 				},
 			},
-			wantErr: true,
-			errMsg:  "incorrect transaction request method",
+			wantErr:    true,
+			wantFields: []string{"params.hash"},
+		},
+		{
+			name: "hash is not hex",
+			req: &UserEthSecp256k1SignRequest{
+				Method: "secp256k1_sign",
+				Params: struct {
+					Hash string `json:"hash"`
+				}{
+					Hash: "0x" + strings.Repeat("zz", 32),
+				},
+			},
+			wantErr:    true,
+			wantFields: []string{"params.hash"},
 		},
 		{
 			name: "empty hash",
@@ -117,8 +148,8 @@ func TestUserEthSecp256k1SignRequest_ValidateTxRequest(t *testing.T) {
 					Hash: "",
 				},
 			},
-			wantErr: true,
-			errMsg:  "hash is required",
+			wantErr:    true,
+			wantFields: []string{"params.hash"},
 		},
 	}
 
@@ -128,8 +159,8 @@ func TestUserEthSecp256k1SignRequest_ValidateTxRequest(t *testing.T) {
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("UserEthSecp256k1SignRequest.ValidateTxRequest() expected error but got none")
-				} else if err.Error() != tt.errMsg {
-					t.Errorf("UserEthSecp256k1SignRequest.ValidateTxRequest() error = %v, want %v", err.Error(), tt.errMsg)
+				} else if fields := errorFields(t, err); !slices.Equal(fields, tt.wantFields) {
+					t.Errorf("UserEthSecp256k1SignRequest.ValidateTxRequest() fields = %v, want %v", fields, tt.wantFields)
 				}
 			} else {
 				if err != nil {
@@ -140,6 +171,85 @@ func TestUserEthSecp256k1SignRequest_ValidateTxRequest(t *testing.T) {
 	}
 }
 
+func TestAxalEthSecp256k1SignRequest_ValidateTxRequest(t *testing.T) {
+	hash := "0x" + strings.Repeat("12", 32)
+
+	tests := []struct {
+		name       string
+		req        *AxalEthSecp256k1SignRequest
+		wantFields []string
+		wantCode   apierror.Code
+	}{
+		{name: "valid", req: NewAxalEthSecp256k1SignRequest(hash, "did:privy:cm3np4u9j001rc8b73seqmqqk")},
+		{name: "missing privy id", req: NewAxalEthSecp256k1SignRequest(hash, ""), wantFields: []string{"privy_id"}, wantCode: apierror.InvalidRequest},
+		{name: "privy id is not a DID", req: NewAxalEthSecp256k1SignRequest(hash, "cm3np4u9j001rc8b73seqmqqk"), wantFields: []string{"privy_id"}, wantCode: apierror.InvalidRequest},
+		{name: "bad hash", req: NewAxalEthSecp256k1SignRequest("0x12", "did:privy:test123"), wantFields: []string{"params.hash"}, wantCode: apierror.InvalidHash},
+		{name: "transaction preimage", req: withPreimage(NewAxalEthSecp256k1SignRequest(hash, "did:privy:test123"), &SigningPreimage{Transaction: HexBytes{0x01}})},
+		{name: "typed data preimage", req: withPreimage(NewAxalEthSecp256k1SignRequest(hash, "did:privy:test123"), &SigningPreimage{TypedData: &TypedData{}})},
+		{name: "empty preimage", req: withPreimage(NewAxalEthSecp256k1SignRequest(hash, "did:privy:test123"), &SigningPreimage{}), wantFields: []string{"preimage"}, wantCode: apierror.InvalidRequest},
+		{
+			name:       "two preimages",
+			req:        withPreimage(NewAxalEthSecp256k1SignRequest(hash, "did:privy:test123"), &SigningPreimage{Transaction: HexBytes{0x01}, TypedData: &TypedData{}}),
+			wantFields: []string{"preimage"},
+			wantCode:   apierror.InvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.ValidateTxRequest()
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("ValidateTxRequest() unexpected error = %v", err)
+				}
+				return
+			}
+
+			if fields := errorFields(t, err); !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("ValidateTxRequest() fields = %v, want %v", fields, tt.wantFields)
+			}
+			if code := apierror.From(err).Code; code != tt.wantCode {
+				t.Errorf("ValidateTxRequest() code = %s, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func withPreimage(req *AxalEthSecp256k1SignRequest, preimage *SigningPreimage) *AxalEthSecp256k1SignRequest {
+	req.Preimage = preimage
+	return req
+}
+
+func TestAxalEthSecp256k1SignRequest_WithoutPreimage(t *testing.T) {
+	req := withPreimage(NewAxalEthSecp256k1SignRequest("0x"+strings.Repeat("12", 32), "did:privy:test123"), &SigningPreimage{Transaction: HexBytes{0x01}})
+
+	body, err := json.Marshal(req.WithoutPreimage())
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if strings.Contains(string(body), "preimage") {
+		t.Errorf("WithoutPreimage() marshals to %s, want no preimage", body)
+	}
+	if req.Preimage == nil {
+		t.Errorf("WithoutPreimage() removed the preimage of the original request")
+	}
+}
+
+// Returns the fields of a validation error
+func errorFields(t *testing.T, err error) []string {
+	t.Helper()
+
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want a validation error", err)
+	}
+	fields := make([]string, len(apiErr.Details))
+	for i, detail := range apiErr.Details {
+		fields[i] = detail.Field
+	}
+	return fields
+}
+
 func TestUserEthSecp256k1SignRequest_GetMethod(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -184,7 +294,7 @@ func TestUserEthSecp256k1SignRequest_Interface(t *testing.T) {
 	// Test that UserEthSecp256k1SignRequest implements EthTxRequest interface
 	var _ EthTxRequest = (*UserEthSecp256k1SignRequest)(nil)
 
-	req := NewUserEthSecp256k1SignRequest("0x1234567890abcdef")
+	req := NewUserEthSecp256k1SignRequest("0x" + strings.Repeat("12", 32))
 
 	// Test interface methods
 	if method := req.GetMethod(); method != "secp256k1_sign" {
@@ -200,7 +310,7 @@ func TestAxalEthSecp256k1SignRequest_Interface(t *testing.T) {
 	// Test that AxalEthSecp256k1SignRequest implements EthTxRequest interface
 	var _ EthTxRequest = (*AxalEthSecp256k1SignRequest)(nil)
 
-	req := NewAxalEthSecp256k1SignRequest("0x1234567890abcdef", "did:privy:test123")
+	req := NewAxalEthSecp256k1SignRequest("0x"+strings.Repeat("12", 32), "did:privy:test123")
 
 	// Test interface methods
 	if method := req.GetMethod(); method != "secp256k1_sign" {
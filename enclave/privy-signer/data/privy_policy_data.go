@@ -0,0 +1,75 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+)
+
+// PrivyPolicy represents a Privy wallet policy, a set of rules Privy enforces on every request signed for a wallet it is attached
+// to
+type PrivyPolicy struct {
+	ID        string        `json:"id,omitempty"`
+	Version   string        `json:"version"`
+	Name      string        `json:"name"`
+	ChainType string        `json:"chain_type"`
+	Rules     []*PolicyRule `json:"rules"`
+	OwnerID   string        `json:"owner_id,omitempty"` // Key or key quorum that has to sign updates of the policy
+}
+
+// PolicyRule allows or denies an RPC method when all of its conditions hold
+type PolicyRule struct {
+	ID         string             `json:"id,omitempty"`
+	Name       string             `json:"name"`
+	Method     string             `json:"method"` // eth_signTransaction, eth_signTypedData_v4, * etc.
+	Conditions []*PolicyCondition `json:"conditions"`
+	Action     string             `json:"action"` // ALLOW or DENY
+}
+
+// PolicyCondition compares a field of the request with a value, eg. ethereum_transaction.to eq 0x...
+type PolicyCondition struct {
+	FieldSource string          `json:"field_source"`
+	Field       string          `json:"field"`
+	Operator    string          `json:"operator"`
+	Value       json.RawMessage `json:"value"`
+}
+
+// UpdatePolicyRequest represents the request to update a policy, unset fields are left as they are
+type UpdatePolicyRequest struct {
+	Name    string        `json:"name,omitempty"`
+	Rules   []*PolicyRule `json:"rules,omitempty"`
+	OwnerID string        `json:"owner_id,omitempty"`
+}
+
+// HashPolicies returns the hex sha256 of the canonical JSON of policies, in order. Ids assigned by Privy are left out so the hash
+// only covers what the policies enforce and who owns them.
+func HashPolicies(policies []*PrivyPolicy) (string, error) {
+	definitions := make([]PrivyPolicy, len(policies))
+	for i, policy := range policies {
+		definition := *policy
+		definition.ID = ""
+		definition.Rules = make([]*PolicyRule, len(policy.Rules))
+		for j, rule := range policy.Rules {
+			ruleDefinition := *rule
+			ruleDefinition.ID = ""
+			definition.Rules[j] = &ruleDefinition
+		}
+		definitions[i] = definition
+	}
+
+	raw, err := json.Marshal(definitions)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal policies: %w", err)
+	}
+
+	canonical, err := jsoncanonicalizer.Transform(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to canonicalize policies: %w", err)
+	}
+
+	hash := sha256.Sum256(canonical)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newTestPolicy(id string, to string) *PrivyPolicy {
+	return &PrivyPolicy{
+		ID:        id,
+		Version:   "1.0",
+		Name:      "Allow transfers",
+		ChainType: "ethereum",
+		Rules: []*PolicyRule{{
+			ID:     id + "-rule",
+			Name:   "allow to",
+			Method: "eth_signTransaction",
+			Action: "ALLOW",
+			Conditions: []*PolicyCondition{{
+				FieldSource: "ethereum_transaction",
+				Field:       "to",
+				Operator:    "eq",
+				Value:       json.RawMessage(`"` + to + `"`),
+			}},
+		}},
+	}
+}
+
+func TestHashPolicies(t *testing.T) {
+	policy := newTestPolicy("policy-1", testEthAddress)
+
+	hash, err := HashPolicies([]*PrivyPolicy{policy})
+	if err != nil {
+		t.Fatalf("HashPolicies() error = %v", err)
+	}
+	if len(hash) != 64 {
+		t.Errorf("HashPolicies() = %s, want a hex sha256", hash)
+	}
+
+	// Privy assigned ids are not part of the hash, and the policy is not modified
+	sameRules, _ := HashPolicies([]*PrivyPolicy{newTestPolicy("policy-2", testEthAddress)})
+	if sameRules != hash {
+		t.Errorf("HashPolicies() = %s for the same rules under other ids, want %s", sameRules, hash)
+	}
+	if policy.ID != "policy-1" || policy.Rules[0].ID != "policy-1-rule" {
+		t.Error("HashPolicies() modified the policy")
+	}
+
+	otherRules, _ := HashPolicies([]*PrivyPolicy{newTestPolicy("policy-1", "0x0000000000000000000000000000000000000000")})
+	if otherRules == hash {
+		t.Error("HashPolicies() ignored a changed condition")
+	}
+
+	policy.OwnerID = "owner"
+	if owned, _ := HashPolicies([]*PrivyPolicy{policy}); owned == hash {
+		t.Error("HashPolicies() ignored the policy owner")
+	}
+}
+
+func TestNewCreateEthWalletRequest_Policies(t *testing.T) {
+	req := NewCreateEthWalletRequest(testSignerID, "policy-1", "policy-2")
+
+	signer := req.PrivyWalletCreateRequestWallets[0].AdditionalSigners[0]
+	if signer.SignerID != testSignerID || len(signer.OverridePolicyIDs) != 2 || signer.OverridePolicyIDs[1] != "policy-2" {
+		t.Errorf("additional signer = %+v, want the signer with both policies", signer)
+	}
+
+	raw, _ := json.Marshal(NewCreateEthWalletRequest(testSignerID))
+	var decoded map[string][]map[string][]map[string]interface{}
+	json.Unmarshal(raw, &decoded)
+	if _, ok := decoded["wallets"][0]["additional_signers"][0]["override_policy_ids"]; ok {
+		t.Errorf("request without policies = %s, want override_policy_ids left out", raw)
+	}
+}
@@ -119,14 +119,17 @@ type AdditionalSigner struct {
 	OverridePolicyIDs []string `json:"override_policy_ids,omitempty"`
 }
 
-func NewCreateEthWalletRequest(delegatedSignerId string) *CreateWalletRequest {
+// Creates the request for a new ethereum wallet with delegatedSignerId as an additional signer. Privy enforces policyIds on
+// every request that signer makes.
+func NewCreateEthWalletRequest(delegatedSignerId string, policyIds ...string) *CreateWalletRequest {
 	return &CreateWalletRequest{
 		PrivyWalletCreateRequestWallets: []*CreateWalletData{
 			{
 				ChainType: "ethereum",
 				AdditionalSigners: []*AdditionalSigner{
 					{
-						SignerID: delegatedSignerId,
+						SignerID:          delegatedSignerId,
+						OverridePolicyIDs: policyIds,
 					},
 				},
 			},
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Deepest nesting of lists the decoder accepts. A transaction nests at most 4 deep (its access list storage keys), the limit
+// keeps crafted payloads of nested lists from recursing without bound.
+const maxRlpDepth = 16
+
+// rlpItem is a decoded RLP item, either a byte string or a list of items
+type rlpItem struct {
+	isList bool
+	bytes  []byte
+	list   []rlpItem
+}
+
+// Decodes exactly one RLP item from b. Decoding is strict, only the canonical encoding of an item is accepted so every payload has
+// one meaning.
+func rlpDecode(b []byte) (rlpItem, error) {
+	item, rest, err := rlpDecodeItem(b, 0)
+	if err != nil {
+		return rlpItem{}, err
+	}
+	if len(rest) != 0 {
+		return rlpItem{}, fmt.Errorf("rlp: %d trailing bytes after the item", len(rest))
+	}
+	return item, nil
+}
+
+// Decodes the first item of b, a list nested depth lists deep, and returns the bytes after it
+func rlpDecodeItem(b []byte, depth int) (rlpItem, []byte, error) {
+	if len(b) == 0 {
+		return rlpItem{}, nil, errors.New("rlp: unexpected end of input")
+	}
+
+	prefix := b[0]
+	switch {
+	case prefix < 0x80:
+		return rlpItem{bytes: b[:1]}, b[1:], nil
+
+	case prefix < 0xb8:
+		size := int(prefix - 0x80)
+		if len(b) < 1+size {
+			return rlpItem{}, nil, errors.New("rlp: string longer than the input")
+		}
+		if size == 1 && b[1] < 0x80 {
+			return rlpItem{}, nil, errors.New("rlp: single byte below 0x80 must be encoded as itself")
+		}
+		return rlpItem{bytes: b[1 : 1+size]}, b[1+size:], nil
+
+	case prefix < 0xc0:
+		size, offset, err := rlpLongSize(b, int(prefix-0xb7))
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+		return rlpItem{bytes: b[offset : offset+size]}, b[offset+size:], nil
+
+	default:
+		if depth >= maxRlpDepth {
+			return rlpItem{}, nil, fmt.Errorf("rlp: lists nested deeper than %d", maxRlpDepth)
+		}
+
+		var size, offset int
+		if prefix < 0xf8 {
+			size, offset = int(prefix-0xc0), 1
+			if len(b) < offset+size {
+				return rlpItem{}, nil, errors.New("rlp: list longer than the input")
+			}
+		} else {
+			var err error
+			if size, offset, err = rlpLongSize(b, int(prefix-0xf7)); err != nil {
+				return rlpItem{}, nil, err
+			}
+		}
+
+		content := b[offset : offset+size]
+		list := []rlpItem{}
+		for len(content) > 0 {
+			item, rest, err := rlpDecodeItem(content, depth+1)
+			if err != nil {
+				return rlpItem{}, nil, err
+			}
+			list = append(list, item)
+			content = rest
+		}
+		return rlpItem{isList: true, list: list}, b[offset+size:], nil
+	}
+}
+
+// Reads the big endian size of a long string or list, returns it and the offset of the payload
+func rlpLongSize(b []byte, sizeLen int) (int, int, error) {
+	if len(b) < 1+sizeLen {
+		return 0, 0, errors.New("rlp: size longer than the input")
+	}
+	if b[1] == 0 {
+		return 0, 0, errors.New("rlp: size has leading zeros")
+	}
+	if sizeLen > 4 {
+		return 0, 0, errors.New("rlp: item too large")
+	}
+
+	size := 0
+	for _, c := range b[1 : 1+sizeLen] {
+		size = size<<8 | int(c)
+	}
+	if size < 56 {
+		return 0, 0, errors.New("rlp: size below 56 must use the short form")
+	}
+	if len(b) < 1+sizeLen+size {
+		return 0, 0, errors.New("rlp: item longer than the input")
+	}
+	return size, 1 + sizeLen, nil
+}
+
+// Returns the byte string of the item, failing for lists
+func (item rlpItem) stringBytes(field string) ([]byte, error) {
+	if item.isList {
+		return nil, fmt.Errorf("%s must be a string, got a list", field)
+	}
+	return item.bytes, nil
+}
+
+// Returns the item as an unsigned integer of at most 256 bits
+func (item rlpItem) bigInt(field string) (*BigInt, error) {
+	b, err := item.stringBytes(field)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 32 {
+		return nil, fmt.Errorf("%s is larger than 256 bits", field)
+	}
+	if len(b) > 0 && b[0] == 0 {
+		return nil, fmt.Errorf("%s has leading zeros", field)
+	}
+	return &BigInt{new(big.Int).SetBytes(b)}, nil
+}
+
+// Returns the item as a uint64
+func (item rlpItem) uint64(field string) (uint64, error) {
+	n, err := item.bigInt(field)
+	if err != nil {
+		return 0, err
+	}
+	if !n.Int.IsUint64() {
+		return 0, fmt.Errorf("%s is larger than 64 bits", field)
+	}
+	return n.Int.Uint64(), nil
+}
+
+// Returns the list of the item, failing for strings
+func (item rlpItem) items(field string) ([]rlpItem, error) {
+	if !item.isList {
+		return nil, fmt.Errorf("%s must be a list, got a string", field)
+	}
+	return item.list, nil
+}
+
+// Encodes a byte string
+func rlpEncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+// Encodes an unsigned integer, zero is the empty string
+func rlpEncodeBigInt(n *BigInt) []byte {
+	if n.IsNil() {
+		return rlpEncodeBytes(nil)
+	}
+	return rlpEncodeBytes(n.Int.Bytes())
+}
+
+func rlpEncodeUint64(n uint64) []byte {
+	return rlpEncodeBytes(new(big.Int).SetUint64(n).Bytes())
+}
+
+// Encodes a list of already encoded items
+func rlpEncodeList(items ...[]byte) []byte {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+
+	out := rlpHeader(0xc0, size)
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// Returns the header of a string (offset 0x80) or list (offset 0xc0) of size bytes
+func rlpHeader(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	sizeBytes := new(big.Int).SetUint64(uint64(size)).Bytes()
+	return append([]byte{offset + 55 + byte(len(sizeBytes))}, sizeBytes...)
+}
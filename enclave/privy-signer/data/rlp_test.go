@@ -0,0 +1,84 @@
+package data
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Returns the hex encoding of n lists each nested in the next
+func nestedLists(n int) string {
+	b := rlpEncodeList()
+	for i := 1; i < n; i++ {
+		b = rlpEncodeList(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+func TestRlpDecode(t *testing.T) {
+	long := strings.Repeat("a", 56)
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    []byte
+		isList  bool
+		items   int
+		wantErr bool
+	}{
+		{name: "single byte", raw: "7f", want: []byte{0x7f}},
+		{name: "empty string", raw: "80", want: []byte{}},
+		{name: "short string", raw: "83646f67", want: []byte("dog")},
+		{name: "long string", raw: "b838" + strings.Repeat("61", 56), want: []byte(long)},
+		{name: "empty list", raw: "c0", isList: true},
+		{name: "nested list", raw: "c7c0c1c0c3c0c1c0", isList: true, items: 3},
+		{name: "lists nested to the limit", raw: nestedLists(maxRlpDepth), isList: true, items: 1},
+		{name: "lists nested past the limit", raw: nestedLists(maxRlpDepth + 1), wantErr: true},
+		{name: "deeply nested lists", raw: nestedLists(10_000), wantErr: true},
+		{name: "non canonical single byte", raw: "817f", wantErr: true},
+		{name: "long form for a short string", raw: "b803646f67", wantErr: true},
+		{name: "size with leading zeros", raw: "b90038" + strings.Repeat("61", 56), wantErr: true},
+		{name: "truncated string", raw: "83646f", wantErr: true},
+		{name: "truncated list", raw: "c3c0c0", wantErr: true},
+		{name: "trailing bytes", raw: "8080", wantErr: true},
+		{name: "empty input", raw: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := rlpDecode(mustHex(t, tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rlpDecode(%s) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if item.isList != tt.isList || (!tt.isList && !bytes.Equal(item.bytes, tt.want)) || len(item.list) != tt.items {
+				t.Errorf("rlpDecode(%s) = %+v", tt.raw, item)
+			}
+		})
+	}
+}
+
+func TestRlpEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{name: "zero", got: rlpEncodeUint64(0), want: "80"},
+		{name: "small int", got: rlpEncodeUint64(15), want: "0f"},
+		{name: "int", got: rlpEncodeUint64(1024), want: "820400"},
+		{name: "string", got: rlpEncodeBytes([]byte("dog")), want: "83646f67"},
+		{name: "long string", got: rlpEncodeBytes(bytes.Repeat([]byte("a"), 56)), want: "b838" + strings.Repeat("61", 56)},
+		{name: "list", got: rlpEncodeList(rlpEncodeBytes([]byte("cat")), rlpEncodeBytes([]byte("dog"))), want: "c88363617483646f67"},
+		{name: "nil big int", got: rlpEncodeBigInt(nil), want: "80"},
+	}
+
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, mustHex(t, tt.want)) {
+			t.Errorf("%s: encoded %x, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TxType is the EIP-2718 type of a transaction
+type TxType byte
+
+const (
+	LegacyTxType     TxType = 0x00
+	AccessListTxType TxType = 0x01 // EIP-2930
+	DynamicFeeTxType TxType = 0x02 // EIP-1559
+	BlobTxType       TxType = 0x03 // EIP-4844
+)
+
+// AccessTuple is an entry of an EIP-2930 access list
+type AccessTuple struct {
+	Address     Address  `json:"address"`
+	StorageKeys []Hash32 `json:"storage_keys"`
+}
+
+// UnsignedTransaction is a decoded unsigned transaction of any supported type. Fields a type does not have are nil or empty.
+type UnsignedTransaction struct {
+	Type       TxType        `json:"type"`
+	ChainID    *BigInt       `json:"chain_id,omitempty"` // Nil for a legacy transaction without EIP-155 replay protection
+	Nonce      uint64        `json:"nonce"`
+	GasPrice   *BigInt       `json:"gas_price,omitempty"`    // Legacy and access list transactions
+	GasTipCap  *BigInt       `json:"gas_tip_cap,omitempty"`  // max_priority_fee_per_gas of dynamic fee and blob transactions
+	GasFeeCap  *BigInt       `json:"gas_fee_cap,omitempty"`  // max_fee_per_gas of dynamic fee and blob transactions
+	Gas        uint64        `json:"gas"`                    // Gas limit
+	To         *Address      `json:"to,omitempty"`           // Nil for a contract creation
+	Value      *BigInt       `json:"value"`                  // In wei
+	Data       HexBytes      `json:"data"`                   // Calldata or init code
+	AccessList []AccessTuple `json:"access_list,omitempty"`  // Typed transactions
+	BlobFeeCap *BigInt       `json:"blob_fee_cap,omitempty"` // max_fee_per_blob_gas of blob transactions
+	BlobHashes []Hash32      `json:"blob_hashes,omitempty"`  // Versioned blob hashes of blob transactions
+}
+
+// Decodes a serialized unsigned transaction, the payload a wallet hashes and signs. Typed transactions are the type byte followed
+// by the RLP list of their fields without v, r and s. Legacy transactions are the RLP list of their six fields, or of nine fields
+// ending in the chain id and two empty strings for EIP-155. Signed transactions are rejected.
+func DecodeUnsignedTransaction(raw []byte) (*UnsignedTransaction, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("transaction is empty")
+	}
+
+	// A legacy transaction starts with an RLP list prefix, typed transactions with their type
+	if raw[0] >= 0xc0 {
+		return decodeLegacyTransaction(raw)
+	}
+
+	txType := TxType(raw[0])
+	item, err := rlpDecode(raw[1:])
+	if err != nil {
+		return nil, err
+	}
+	fields, err := item.items("transaction")
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &UnsignedTransaction{Type: txType}
+	switch txType {
+	case AccessListTxType:
+		err = tx.decodeFields(fields, "chain_id", "nonce", "gas_price", "gas", "to", "value", "data", "access_list")
+	case DynamicFeeTxType:
+		err = tx.decodeFields(fields, "chain_id", "nonce", "gas_tip_cap", "gas_fee_cap", "gas", "to", "value", "data", "access_list")
+	case BlobTxType:
+		err = tx.decodeFields(fields, "chain_id", "nonce", "gas_tip_cap", "gas_fee_cap", "gas", "to", "value", "data", "access_list",
+			"blob_fee_cap", "blob_hashes")
+		if err == nil && tx.To == nil {
+			err = errors.New("blob transactions can not create contracts")
+		}
+		if err == nil && len(tx.BlobHashes) == 0 {
+			err = errors.New("blob transactions must carry at least one blob hash")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type 0x%02x", raw[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func decodeLegacyTransaction(raw []byte) (*UnsignedTransaction, error) {
+	item, err := rlpDecode(raw)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := item.items("transaction")
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &UnsignedTransaction{Type: LegacyTxType}
+	names := []string{"nonce", "gas_price", "gas", "to", "value", "data"}
+	switch len(fields) {
+	case 6:
+		return tx, tx.decodeFields(fields, names...)
+	case 9:
+		// EIP-155 signing payload, the chain id followed by two empty strings where r and s go once signed
+		for _, field := range fields[7:] {
+			if b, err := field.stringBytes("signature"); err != nil || len(b) != 0 {
+				return nil, errors.New("transaction is signed, only unsigned transactions are accepted")
+			}
+		}
+		if err := tx.decodeFields(fields[:7], append(names, "chain_id")...); err != nil {
+			return nil, err
+		}
+		if tx.ChainID.IsZero() {
+			return nil, errors.New("chain_id of an EIP-155 transaction can not be zero")
+		}
+		return tx, nil
+	default:
+		return nil, fmt.Errorf("legacy transaction must have 6 or 9 fields, got %d", len(fields))
+	}
+}
+
+// Decodes the fields of a transaction in the order given by names
+func (tx *UnsignedTransaction) decodeFields(fields []rlpItem, names ...string) error {
+	if len(fields) != len(names) {
+		return fmt.Errorf("transaction type 0x%02x must have %d fields, got %d, signed transactions are not accepted", byte(tx.Type), len(names), len(fields))
+	}
+
+	for i, name := range names {
+		field := fields[i]
+		var err error
+		switch name {
+		case "chain_id":
+			tx.ChainID, err = field.bigInt(name)
+		case "nonce":
+			tx.Nonce, err = field.uint64(name)
+		case "gas_price":
+			tx.GasPrice, err = field.bigInt(name)
+		case "gas_tip_cap":
+			tx.GasTipCap, err = field.bigInt(name)
+		case "gas_fee_cap":
+			tx.GasFeeCap, err = field.bigInt(name)
+		case "gas":
+			tx.Gas, err = field.uint64(name)
+		case "to":
+			tx.To, err = decodeTo(field)
+		case "value":
+			tx.Value, err = field.bigInt(name)
+		case "data":
+			tx.Data, err = field.stringBytes(name)
+		case "access_list":
+			tx.AccessList, err = decodeAccessList(field)
+		case "blob_fee_cap":
+			tx.BlobFeeCap, err = field.bigInt(name)
+		case "blob_hashes":
+			tx.BlobHashes, err = decodeHashes(field, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decodes the recipient, the empty string is a contract creation
+func decodeTo(item rlpItem) (*Address, error) {
+	b, err := item.stringBytes("to")
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) != 20 {
+		return nil, fmt.Errorf("to must be 20 bytes, got %d", len(b))
+	}
+	var to Address
+	copy(to[:], b)
+	return &to, nil
+}
+
+func decodeAccessList(item rlpItem) ([]AccessTuple, error) {
+	tuples, err := item.items("access_list")
+	if err != nil {
+		return nil, err
+	}
+
+	accessList := make([]AccessTuple, 0, len(tuples))
+	for i, tupleItem := range tuples {
+		field := fmt.Sprintf("access_list[%d]", i)
+		tuple, err := tupleItem.items(field)
+		if err != nil {
+			return nil, err
+		}
+		if len(tuple) != 2 {
+			return nil, fmt.Errorf("%s must have an address and storage keys", field)
+		}
+
+		b, err := tuple[0].stringBytes(field + ".address")
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 20 {
+			return nil, fmt.Errorf("%s.address must be 20 bytes, got %d", field, len(b))
+		}
+		var entry AccessTuple
+		copy(entry.Address[:], b)
+
+		if entry.StorageKeys, err = decodeHashes(tuple[1], field+".storage_keys"); err != nil {
+			return nil, err
+		}
+		accessList = append(accessList, entry)
+	}
+	return accessList, nil
+}
+
+func decodeHashes(item rlpItem, field string) ([]Hash32, error) {
+	items, err := item.items(field)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]Hash32, len(items))
+	for i, hashItem := range items {
+		b, err := hashItem.stringBytes(field)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 32 {
+			return nil, fmt.Errorf("%s[%d] must be 32 bytes, got %d", field, i, len(b))
+		}
+		copy(hashes[i][:], b)
+	}
+	return hashes, nil
+}
+
+// Encodes the unsigned transaction, the inverse of DecodeUnsignedTransaction
+func (tx *UnsignedTransaction) Encode() ([]byte, error) {
+	common := func() [][]byte {
+		return [][]byte{rlpEncodeBigInt(tx.ChainID), rlpEncodeUint64(tx.Nonce)}
+	}
+	tail := func() [][]byte {
+		return [][]byte{rlpEncodeUint64(tx.Gas), encodeTo(tx.To), rlpEncodeBigInt(tx.Value), rlpEncodeBytes(tx.Data), encodeAccessList(tx.AccessList)}
+	}
+
+	switch tx.Type {
+	case LegacyTxType:
+		fields := [][]byte{
+			rlpEncodeUint64(tx.Nonce), rlpEncodeBigInt(tx.GasPrice), rlpEncodeUint64(tx.Gas), encodeTo(tx.To), rlpEncodeBigInt(tx.Value),
+			rlpEncodeBytes(tx.Data),
+		}
+		if tx.ChainID != nil {
+			fields = append(fields, rlpEncodeBigInt(tx.ChainID), rlpEncodeBytes(nil), rlpEncodeBytes(nil))
+		}
+		return rlpEncodeList(fields...), nil
+
+	case AccessListTxType:
+		fields := append(common(), rlpEncodeBigInt(tx.GasPrice))
+		return typedEncoding(tx.Type, append(fields, tail()...)), nil
+
+	case DynamicFeeTxType:
+		fields := append(common(), rlpEncodeBigInt(tx.GasTipCap), rlpEncodeBigInt(tx.GasFeeCap))
+		return typedEncoding(tx.Type, append(fields, tail()...)), nil
+
+	case BlobTxType:
+		fields := append(common(), rlpEncodeBigInt(tx.GasTipCap), rlpEncodeBigInt(tx.GasFeeCap))
+		fields = append(fields, tail()...)
+		fields = append(fields, rlpEncodeBigInt(tx.BlobFeeCap), encodeHashes(tx.BlobHashes))
+		return typedEncoding(tx.Type, fields), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported transaction type 0x%02x", byte(tx.Type))
+	}
+}
+
+// Returns the hash the sender signs, keccak256 of the unsigned encoding
+func (tx *UnsignedTransaction) SigningHash() (Hash32, error) {
+	encoded, err := tx.Encode()
+	if err != nil {
+		return Hash32{}, err
+	}
+
+	var hash Hash32
+	copy(hash[:], keccak256(encoded))
+	return hash, nil
+}
+
+func typedEncoding(txType TxType, fields [][]byte) []byte {
+	return append([]byte{byte(txType)}, rlpEncodeList(fields...)...)
+}
+
+func encodeTo(to *Address) []byte {
+	if to == nil {
+		return rlpEncodeBytes(nil)
+	}
+	return rlpEncodeBytes(to[:])
+}
+
+func encodeAccessList(accessList []AccessTuple) []byte {
+	tuples := make([][]byte, len(accessList))
+	for i, tuple := range accessList {
+		tuples[i] = rlpEncodeList(rlpEncodeBytes(tuple.Address[:]), encodeHashes(tuple.StorageKeys))
+	}
+	return rlpEncodeList(tuples...)
+}
+
+func encodeHashes(hashes []Hash32) []byte {
+	items := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		items[i] = rlpEncodeBytes(hash[:])
+	}
+	return rlpEncodeList(items...)
+}
@@ -0,0 +1,294 @@
+package data
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		t.Fatalf("bad hex %s: %v", s, err)
+	}
+	return b
+}
+
+func mustAddress(t *testing.T, s string) *Address {
+	t.Helper()
+	addr, err := ParseAddress(s)
+	if err != nil {
+		t.Fatalf("ParseAddress(%s) error = %v", s, err)
+	}
+	return &addr
+}
+
+// Signing payloads and hashes from EIP-155 and from the go-ethereum core/types transaction tests. The dynamic fee and blob
+// transactions were signed with go-ethereum v1.16.3, the hash is types.LatestSignerForChainID(chainId).Hash(tx).
+func TestDecodeUnsignedTransaction_Vectors(t *testing.T) {
+	tests := []struct {
+		name           string
+		raw            string
+		wantHash       string
+		wantType       TxType
+		wantChain      string
+		wantNonce      uint64
+		wantGas        uint64
+		wantTo         string
+		wantValue      string
+		wantData       string
+		wantTipCap     string
+		wantFeeCap     string
+		wantBlobFeeCap string
+		wantAccessKeys int
+		wantBlobHashes []string
+	}{
+		{
+			name:      "EIP-155 example",
+			raw:       "ec098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080018080",
+			wantHash:  "0xdaf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53",
+			wantType:  LegacyTxType,
+			wantChain: "1",
+			wantNonce: 9,
+			wantGas:   21000,
+			wantTo:    "0x3535353535353535353535353535353535353535",
+			wantValue: "1000000000000000000",
+			wantData:  "0x",
+		},
+		{
+			name:      "homestead empty transaction",
+			raw:       "da80808094095e7baea6a6c7c4c2dfeb977efac326af552d878080",
+			wantHash:  "0xc775b99e7ad12f50d819fcd602390467e28141316969f4b57f0626f74fe3b386",
+			wantType:  LegacyTxType,
+			wantTo:    "0x095e7baea6a6c7c4c2dfeb977efac326af552d87",
+			wantValue: "0",
+			wantData:  "0x",
+		},
+		{
+			name:      "homestead transaction",
+			raw:       "de03018207d094b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544",
+			wantHash:  "0xfe7a79529ed5f7c3375d06b26b186a8644e0e16c373d7a12be41c62d6042b77a",
+			wantType:  LegacyTxType,
+			wantNonce: 3,
+			wantGas:   2000,
+			wantTo:    "0xb94f5374fce5edbc8e2a8697c15331677e6ebf0b",
+			wantValue: "10",
+			wantData:  "0x5544",
+		},
+		{
+			name:      "EIP-2930 access list transaction",
+			raw:       "01e00103018261a894b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544c0",
+			wantHash:  "0x49b486f0ec0a60dfbbca2d30cb07c9e8ffb2a2ff41f29a1ab6737475f6ff69f3",
+			wantType:  AccessListTxType,
+			wantChain: "1",
+			wantNonce: 3,
+			wantGas:   25000,
+			wantTo:    "0xb94f5374fce5edbc8e2a8697c15331677e6ebf0b",
+			wantValue: "10",
+			wantData:  "0x5544",
+		},
+		{
+			name: "EIP-1559 dynamic fee transaction",
+			raw: "02f888822105078459682f008506fc23ac0082c35094b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544f85bf859945aaeb6053f3e94c9b9a09f" +
+				"33669435e7ef1beaedf842a00000000000000000000000000000000000000000000000000000000000000001a000000000000000000000000000000000" +
+				"00000000000000000000000000000002",
+			wantHash:       "0xb73a6f93e26a385a38026e22d9a274fdaae45e993108defcd56370e2deff4171",
+			wantType:       DynamicFeeTxType,
+			wantChain:      "8453",
+			wantNonce:      7,
+			wantGas:        50000,
+			wantTo:         "0xb94f5374fce5edbc8e2a8697c15331677e6ebf0b",
+			wantValue:      "10",
+			wantData:       "0x5544",
+			wantTipCap:     "1500000000",
+			wantFeeCap:     "30000000000",
+			wantAccessKeys: 2,
+		},
+		{
+			name: "EIP-4844 blob transaction",
+			raw: "03f8cd010b84773594008509502f900082520894b94f5374fce5edbc8e2a8697c15331677e6ebf0b8080f85bf859945aaeb6053f3e94c9b9a09f3366" +
+				"9435e7ef1beaedf842a00000000000000000000000000000000000000000000000000000000000000001a0000000000000000000000000000000000000" +
+				"000000000000000000000000000284b2d05e00f842a001aa000000000000000000000000000000000000000000000000000000000001a001bb0000000000" +
+				"00000000000000000000000000000000000000000000000002",
+			wantHash:       "0x6098fa2dfcd5a50a8899ad469b69e21604e43e6100ff05e4917a50da478d0a48",
+			wantType:       BlobTxType,
+			wantChain:      "1",
+			wantNonce:      11,
+			wantGas:        21000,
+			wantTo:         "0xb94f5374fce5edbc8e2a8697c15331677e6ebf0b",
+			wantValue:      "0",
+			wantData:       "0x",
+			wantTipCap:     "2000000000",
+			wantFeeCap:     "40000000000",
+			wantBlobFeeCap: "3000000000",
+			wantAccessKeys: 2,
+			wantBlobHashes: []string{
+				"0x01aa000000000000000000000000000000000000000000000000000000000001",
+				"0x01bb000000000000000000000000000000000000000000000000000000000002",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := mustHex(t, tt.raw)
+			tx, err := DecodeUnsignedTransaction(raw)
+			if err != nil {
+				t.Fatalf("DecodeUnsignedTransaction() error = %v", err)
+			}
+
+			if tx.Type != tt.wantType || tx.Nonce != tt.wantNonce || tx.Gas != tt.wantGas {
+				t.Errorf("tx = %+v, want type %d nonce %d gas %d", tx, tt.wantType, tt.wantNonce, tt.wantGas)
+			}
+			if chain := tx.ChainID; (tt.wantChain == "") != (chain == nil) || chain != nil && chain.String() != tt.wantChain {
+				t.Errorf("chain id = %v, want %q", chain, tt.wantChain)
+			}
+			if tx.To == nil || *tx.To != *mustAddress(t, tt.wantTo) {
+				t.Errorf("to = %v, want %s", tx.To, tt.wantTo)
+			}
+			if tx.Value.String() != tt.wantValue || tx.Data.String() != tt.wantData {
+				t.Errorf("value, data = %s, %s, want %s, %s", tx.Value, tx.Data, tt.wantValue, tt.wantData)
+			}
+			if tt.wantTipCap != "" && (tx.GasTipCap.String() != tt.wantTipCap || tx.GasFeeCap.String() != tt.wantFeeCap) {
+				t.Errorf("tip cap, fee cap = %s, %s, want %s, %s", tx.GasTipCap, tx.GasFeeCap, tt.wantTipCap, tt.wantFeeCap)
+			}
+			if tt.wantBlobFeeCap != "" && tx.BlobFeeCap.String() != tt.wantBlobFeeCap {
+				t.Errorf("blob fee cap = %s, want %s", tx.BlobFeeCap, tt.wantBlobFeeCap)
+			}
+			accessKeys := 0
+			for _, tuple := range tx.AccessList {
+				accessKeys += len(tuple.StorageKeys)
+			}
+			if accessKeys != tt.wantAccessKeys {
+				t.Errorf("access list = %+v, want %d storage keys", tx.AccessList, tt.wantAccessKeys)
+			}
+			if len(tx.BlobHashes) != len(tt.wantBlobHashes) {
+				t.Errorf("blob hashes = %v, want %v", tx.BlobHashes, tt.wantBlobHashes)
+			}
+			for i := range tx.BlobHashes {
+				if i < len(tt.wantBlobHashes) && tx.BlobHashes[i].String() != tt.wantBlobHashes[i] {
+					t.Errorf("blob hash %d = %s, want %s", i, tx.BlobHashes[i], tt.wantBlobHashes[i])
+				}
+			}
+
+			hash, err := tx.SigningHash()
+			if err != nil || hash.String() != tt.wantHash {
+				t.Errorf("SigningHash() = %s, %v, want %s", hash, err, tt.wantHash)
+			}
+
+			encoded, err := tx.Encode()
+			if err != nil || !bytes.Equal(encoded, raw) {
+				t.Errorf("Encode() = %x, %v, want the decoded payload %x", encoded, err, raw)
+			}
+		})
+	}
+}
+
+func TestUnsignedTransaction_RoundTrip(t *testing.T) {
+	to := mustAddress(t, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	key := Hash32{31: 0x01}
+	accessList := []AccessTuple{{Address: *to, StorageKeys: []Hash32{key, {}}}, {Address: Address{19: 0xff}}}
+	blobHash := Hash32{0: 0x01, 31: 0xaa}
+	wei, _ := ParseEther("1.5")
+	gwei, _ := ParseGwei("30")
+	tip, _ := ParseGwei("1.5")
+
+	tests := []struct {
+		name string
+		tx   *UnsignedTransaction
+	}{
+		{
+			name: "legacy contract creation",
+			tx:   &UnsignedTransaction{Type: LegacyTxType, ChainID: NewBigIntFromInt64(8453), Nonce: 1, GasPrice: gwei, Gas: 1_000_000, Value: NewBigIntFromInt64(0), Data: bytes.Repeat([]byte{0x60}, 100)},
+		},
+		{
+			name: "access list",
+			tx:   &UnsignedTransaction{Type: AccessListTxType, ChainID: NewBigIntFromInt64(1), Nonce: 0, GasPrice: gwei, Gas: 50000, To: to, Value: wei, Data: HexBytes{}, AccessList: accessList},
+		},
+		{
+			name: "dynamic fee",
+			tx:   &UnsignedTransaction{Type: DynamicFeeTxType, ChainID: NewBigIntFromInt64(10), Nonce: 1 << 40, GasTipCap: tip, GasFeeCap: gwei, Gas: 21000, To: to, Value: wei, Data: HexBytes{0xa9, 0x05, 0x9c, 0xbb}, AccessList: accessList},
+		},
+		{
+			name: "blob",
+			tx: &UnsignedTransaction{
+				Type: BlobTxType, ChainID: NewBigIntFromInt64(1), Nonce: 7, GasTipCap: tip, GasFeeCap: gwei, Gas: 21000, To: to,
+				Value: NewBigIntFromInt64(0), Data: HexBytes{}, BlobFeeCap: gwei, BlobHashes: []Hash32{blobHash, blobHash},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.tx.Encode()
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			decoded, err := DecodeUnsignedTransaction(encoded)
+			if err != nil {
+				t.Fatalf("DecodeUnsignedTransaction() error = %v", err)
+			}
+
+			reencoded, err := decoded.Encode()
+			if err != nil || !bytes.Equal(reencoded, encoded) {
+				t.Fatalf("Encode(Decode(x)) = %x, %v, want %x", reencoded, err, encoded)
+			}
+
+			if decoded.Type != tt.tx.Type || decoded.Nonce != tt.tx.Nonce || decoded.Gas != tt.tx.Gas || !decoded.Value.Equals(tt.tx.Value) {
+				t.Errorf("decoded = %+v, want %+v", decoded, tt.tx)
+			}
+			if len(decoded.AccessList) != len(tt.tx.AccessList) || len(decoded.BlobHashes) != len(tt.tx.BlobHashes) {
+				t.Errorf("decoded access list %d, blob hashes %d, want %d, %d", len(decoded.AccessList), len(decoded.BlobHashes), len(tt.tx.AccessList), len(tt.tx.BlobHashes))
+			}
+			if tt.tx.BlobFeeCap != nil && !decoded.BlobFeeCap.Equals(tt.tx.BlobFeeCap) {
+				t.Errorf("blob fee cap = %s, want %s", decoded.BlobFeeCap, tt.tx.BlobFeeCap)
+			}
+			if (decoded.To == nil) != (tt.tx.To == nil) {
+				t.Errorf("to = %v, want %v", decoded.To, tt.tx.To)
+			}
+		})
+	}
+}
+
+func TestDecodeUnsignedTransaction_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{name: "empty", raw: ""},
+		{name: "signed legacy", raw: "f86103018207d094b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a8255441ca098ff921201554726367d2be8c804a7ff89ccf285ebc57dff8ae4c44b9c19ac4aa08887321be575c8095f789dd4c743dfe42c1820f9231f98a962b210e3ac2452a3"},
+		{name: "EIP-155 with a signature", raw: "ec098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080010101"},
+		{name: "EIP-155 with chain id zero", raw: "ec098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080808080"},
+		{name: "trailing bytes", raw: "de03018207d094b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a82554400"},
+		{name: "nonce with leading zeros", raw: "e0820003018207d094b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544"},
+		{name: "single byte not encoded as itself", raw: "df8103018207d094b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544"},
+		{name: "short recipient", raw: "dd03018207d093b94f5374fce5edbc8e2a8697c15331677e6ebf0a825544"},
+		{name: "list longer than the input", raw: "df03018207d094b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544"},
+		{name: "unknown type", raw: "04c0"},
+		{name: "access list missing a field", raw: "01df0103018261a894b94f5374fce5edbc8e2a8697c15331677e6ebf0b0a825544"},
+		{name: "blob contract creation", raw: "03ec0101010101808080c080e1a0" + strings.Repeat("00", 32)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tx, err := DecodeUnsignedTransaction(mustHex(t, tt.raw)); err == nil {
+				t.Errorf("DecodeUnsignedTransaction() = %+v, want an error", tx)
+			}
+		})
+	}
+}
+
+func TestDecodeUnsignedTransaction_BlobNeedsHashes(t *testing.T) {
+	to := mustAddress(t, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	tx := &UnsignedTransaction{Type: BlobTxType, ChainID: NewBigIntFromInt64(1), To: to, Value: NewBigIntFromInt64(0)}
+
+	encoded, err := tx.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if _, err := DecodeUnsignedTransaction(encoded); err == nil {
+		t.Errorf("DecodeUnsignedTransaction() accepted a blob transaction without blob hashes")
+	}
+}
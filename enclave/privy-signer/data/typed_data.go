@@ -0,0 +1,378 @@
+package data
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getaxal/verified-signer/enclave/validate"
+	"golang.org/x/crypto/sha3"
+)
+
+// Type of the EIP-712 domain struct, every typed data must define it
+const eip712DomainType = "EIP712Domain"
+
+// Deepest nesting of structs and arrays a typed data message may have
+const maxTypedDataDepth = 16
+
+var (
+	typeNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	arrayPattern    = regexp.MustCompile(`^(.+)\[([0-9]*)\]$`)
+)
+
+// TypedDataField is one member of an EIP-712 struct type
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is EIP-712 typed data in the eth_signTypedData_v4 format. Numbers in the domain and message are kept as json.Number so
+// no precision is lost.
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (td *TypedData) UnmarshalJSON(b []byte) error {
+	type plain TypedData
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode((*plain)(td))
+}
+
+// Returns the EIP-712 digest a wallet signs for the typed data, keccak256(0x19 0x01 || domainSeparator || hashStruct(message)).
+// Encoding is strict, every field of a struct must be present with a value of its type and no other fields are allowed.
+func (td *TypedData) Hash() (Hash32, error) {
+	if td.PrimaryType == "" {
+		return Hash32{}, errors.New("primaryType is missing")
+	}
+	if err := td.validateTypes(); err != nil {
+		return Hash32{}, err
+	}
+
+	domainSeparator, err := td.hashStruct(eip712DomainType, td.Domain, "domain", 0)
+	if err != nil {
+		return Hash32{}, err
+	}
+
+	preimage := append([]byte{0x19, 0x01}, domainSeparator...)
+	// A message of the domain type alone only signs the domain separator
+	if td.PrimaryType != eip712DomainType {
+		messageHash, err := td.hashStruct(td.PrimaryType, td.Message, "message", 0)
+		if err != nil {
+			return Hash32{}, err
+		}
+		preimage = append(preimage, messageHash...)
+	}
+
+	var hash Hash32
+	copy(hash[:], keccak256(preimage))
+	return hash, nil
+}
+
+// Returns the domain chain id, ok is false if the domain has none
+func (td *TypedData) ChainID() (*BigInt, bool, error) {
+	value, ok := td.Domain["chainId"]
+	if !ok {
+		return nil, false, nil
+	}
+	n, err := typedDataInt(value, 256, false)
+	if err != nil {
+		return nil, true, fmt.Errorf("domain.chainId %w", err)
+	}
+	return &BigInt{n}, true, nil
+}
+
+// Checks every struct type is well formed and only refers to known types
+func (td *TypedData) validateTypes() error {
+	if _, ok := td.Types[eip712DomainType]; !ok {
+		return fmt.Errorf("types must define %s", eip712DomainType)
+	}
+	if _, ok := td.Types[td.PrimaryType]; !ok {
+		return fmt.Errorf("primaryType %s is not defined in types", td.PrimaryType)
+	}
+
+	for name, fields := range td.Types {
+		if !typeNamePattern.MatchString(name) || isAtomicType(name) {
+			return fmt.Errorf("invalid type name %q", name)
+		}
+		seen := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			if field.Name == "" || seen[field.Name] {
+				return fmt.Errorf("type %s has an empty or duplicate field name %q", name, field.Name)
+			}
+			seen[field.Name] = true
+
+			base := field.Type
+			for {
+				match := arrayPattern.FindStringSubmatch(base)
+				if match == nil {
+					break
+				}
+				base = match[1]
+			}
+			if _, ok := td.Types[base]; !ok && !isAtomicType(base) {
+				return fmt.Errorf("field %s.%s has unknown type %s", name, field.Name, field.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns encodeType of a struct type, the type followed by the types it refers to sorted by name
+func (td *TypedData) encodeType(primaryType string) string {
+	deps := map[string]bool{}
+	td.dependencies(primaryType, deps)
+	delete(deps, primaryType)
+
+	sorted := make([]string, 0, len(deps))
+	for dep := range deps {
+		sorted = append(sorted, dep)
+	}
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	for _, name := range append([]string{primaryType}, sorted...) {
+		fields := make([]string, len(td.Types[name]))
+		for i, field := range td.Types[name] {
+			fields[i] = field.Type + " " + field.Name
+		}
+		b.WriteString(name + "(" + strings.Join(fields, ",") + ")")
+	}
+	return b.String()
+}
+
+func (td *TypedData) dependencies(typeName string, deps map[string]bool) {
+	typeName = strings.SplitN(typeName, "[", 2)[0]
+	if _, ok := td.Types[typeName]; !ok || deps[typeName] {
+		return
+	}
+	deps[typeName] = true
+	for _, field := range td.Types[typeName] {
+		td.dependencies(field.Type, deps)
+	}
+}
+
+// Returns hashStruct of a struct value, keccak256(typeHash || encodeData)
+func (td *TypedData) hashStruct(typeName string, value interface{}, path string, depth int) ([]byte, error) {
+	if depth > maxTypedDataDepth {
+		return nil, fmt.Errorf("%s is nested too deep", path)
+	}
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object of type %s", path, typeName)
+	}
+
+	fields := td.Types[typeName]
+	if len(data) != len(fields) {
+		for name := range data {
+			if !hasField(fields, name) {
+				return nil, fmt.Errorf("%s.%s is not a field of %s", path, name, typeName)
+			}
+		}
+	}
+
+	encoded := keccak256([]byte(td.encodeType(typeName)))
+	for _, field := range fields {
+		fieldValue, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s.%s is missing", path, field.Name)
+		}
+		word, err := td.encodeValue(field.Type, fieldValue, path+"."+field.Name, depth)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, word...)
+	}
+	return keccak256(encoded), nil
+}
+
+// Encodes one value as the 32 byte word encodeData uses for it
+func (td *TypedData) encodeValue(typeName string, value interface{}, path string, depth int) ([]byte, error) {
+	if match := arrayPattern.FindStringSubmatch(typeName); match != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must be an array", path)
+		}
+		if match[2] != "" {
+			if size, err := strconv.Atoi(match[2]); err != nil || size != len(items) {
+				return nil, fmt.Errorf("%s must have %s items, got %d", path, match[2], len(items))
+			}
+		}
+
+		var encoded []byte
+		for i, item := range items {
+			word, err := td.encodeValue(match[1], item, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			encoded = append(encoded, word...)
+		}
+		return keccak256(encoded), nil
+	}
+
+	if _, ok := td.Types[typeName]; ok {
+		return td.hashStruct(typeName, value, path, depth+1)
+	}
+
+	switch {
+	case typeName == "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", path)
+		}
+		return keccak256([]byte(s)), nil
+
+	case typeName == "bytes":
+		b, err := typedDataBytes(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", path, err)
+		}
+		return keccak256(b), nil
+
+	case typeName == "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a boolean", path)
+		}
+		word := make([]byte, 32)
+		if b {
+			word[31] = 1
+		}
+		return word, nil
+
+	case typeName == "address":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an address string", path)
+		}
+		addr, err := ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return append(make([]byte, 12), addr[:]...), nil
+
+	case strings.HasPrefix(typeName, "bytes"):
+		size, _ := strconv.Atoi(strings.TrimPrefix(typeName, "bytes"))
+		b, err := typedDataBytes(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", path, err)
+		}
+		if len(b) != size {
+			return nil, fmt.Errorf("%s must be %d bytes, got %d", path, size, len(b))
+		}
+		return append(b, make([]byte, 32-size)...), nil
+
+	default:
+		signed := strings.HasPrefix(typeName, "int")
+		bits, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(typeName, "u"), "int"))
+		n, err := typedDataInt(value, bits, signed)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", path, err)
+		}
+		// Negative numbers are encoded as 256 bit two's complement
+		if n.Sign() < 0 {
+			n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return n.FillBytes(make([]byte, 32)), nil
+	}
+}
+
+// Reports whether typeName is an EIP-712 atomic or dynamic type
+func isAtomicType(typeName string) bool {
+	switch typeName {
+	case "address", "bool", "string", "bytes":
+		return true
+	}
+	for _, prefix := range []string{"bytes", "uint", "int"} {
+		if !strings.HasPrefix(typeName, prefix) {
+			continue
+		}
+		size := strings.TrimPrefix(typeName, prefix)
+		n, err := strconv.Atoi(size)
+		if err != nil || strconv.Itoa(n) != size {
+			return false
+		}
+		if prefix == "bytes" {
+			return n >= 1 && n <= 32
+		}
+		return n >= 8 && n <= 256 && n%8 == 0
+	}
+	return false
+}
+
+func hasField(fields []TypedDataField, name string) bool {
+	for _, field := range fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads an integer value, a JSON number, a decimal string or a hex quantity, and checks it fits in bits
+func typedDataInt(value interface{}, bits int, signed bool) (*big.Int, error) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return nil, errors.New("must be a number or a numeric string")
+	}
+
+	var n *big.Int
+	var ok bool
+	if strings.HasPrefix(s, "0x") {
+		if err := validate.Quantity(s); err != nil {
+			return nil, err
+		}
+		n, ok = new(big.Int).SetString(s[2:], 16)
+	} else {
+		n, ok = new(big.Int).SetString(s, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("must be an integer, got %s", s)
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if signed {
+		limit.Rsh(limit, 1)
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("does not fit in int%d", bits)
+		}
+	} else if n.Sign() < 0 || n.Cmp(limit) >= 0 {
+		return nil, fmt.Errorf("does not fit in uint%d", bits)
+	}
+	return n, nil
+}
+
+// Reads a 0x prefixed hex bytes value
+func typedDataBytes(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, "0x") {
+		return nil, errors.New("must be 0x prefixed hex bytes")
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, errors.New("must be 0x prefixed hex bytes")
+	}
+	return b, nil
+}
+
+func keccak256(b []byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(b)
+	return hasher.Sum(nil)
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// The Mail example of EIP-712
+const mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func mustTypedData(t *testing.T, raw string) *TypedData {
+	t.Helper()
+	var td TypedData
+	if err := json.Unmarshal([]byte(raw), &td); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	return &td
+}
+
+func TestTypedData_Hash(t *testing.T) {
+	td := mustTypedData(t, mailTypedData)
+
+	if got, want := td.encodeType("Mail"), "Mail(Person from,Person to,string contents)Person(string name,address wallet)"; got != want {
+		t.Errorf("encodeType() = %s, want %s", got, want)
+	}
+
+	hash, err := td.Hash()
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if want := "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; hash.String() != want {
+		t.Errorf("Hash() = %s, want %s", hash, want)
+	}
+
+	chainId, ok, err := td.ChainID()
+	if err != nil || !ok || chainId.String() != "1" {
+		t.Errorf("ChainID() = %v, %v, %v, want 1", chainId, ok, err)
+	}
+}
+
+func TestTypedData_NumberForms(t *testing.T) {
+	var hashes []Hash32
+	for _, chainId := range []string{`1`, `"1"`, `"0x1"`} {
+		td := mustTypedData(t, strings.Replace(mailTypedData, `"chainId": 1`, `"chainId": `+chainId, 1))
+		hash, err := td.Hash()
+		if err != nil {
+			t.Fatalf("Hash() with chainId %s error = %v", chainId, err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if hashes[0] != hashes[1] || hashes[0] != hashes[2] {
+		t.Errorf("Hash() differs between number forms: %v", hashes)
+	}
+}
+
+func TestTypedData_Arrays(t *testing.T) {
+	raw := `{
+		"types": {
+			"EIP712Domain": [{"name": "chainId", "type": "uint256"}],
+			"Group": [
+				{"name": "members", "type": "Member[]"},
+				{"name": "ids", "type": "int64[2]"},
+				{"name": "salt", "type": "bytes4"},
+				{"name": "payload", "type": "bytes"},
+				{"name": "open", "type": "bool"}
+			],
+			"Member": [{"name": "wallet", "type": "address"}]
+		},
+		"primaryType": "Group",
+		"domain": {"chainId": 8453},
+		"message": {
+			"members": [{"wallet": "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}, {"wallet": "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"}],
+			"ids": [-1, "9223372036854775807"],
+			"salt": "0xdeadbeef",
+			"payload": "0x",
+			"open": true
+		}
+	}`
+	td := mustTypedData(t, raw)
+
+	if got, want := td.encodeType("Group"), "Group(Member[] members,int64[2] ids,bytes4 salt,bytes payload,bool open)Member(address wallet)"; got != want {
+		t.Errorf("encodeType() = %s, want %s", got, want)
+	}
+
+	hash, err := td.Hash()
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	td.Message["open"] = false
+	if changed, err := td.Hash(); err != nil || changed == hash {
+		t.Errorf("Hash() = %s, %v after changing the message, want a different hash", changed, err)
+	}
+}
+
+func TestTypedData_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{name: "missing field", old: `"contents": "Hello, Bob!"`, new: `"other": "Hello, Bob!"`},
+		{name: "extra field", old: `"contents": "Hello, Bob!"`, new: `"contents": "Hello, Bob!", "cc": "Alice"`},
+		{name: "wrong value type", old: `"contents": "Hello, Bob!"`, new: `"contents": 1`},
+		{name: "bad address checksum", old: `0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826`, new: `0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD827`},
+		{name: "negative uint", old: `"chainId": 1`, new: `"chainId": -1`},
+		{name: "fraction", old: `"chainId": 1`, new: `"chainId": 1.5`},
+		{name: "unknown type", old: `{"name": "contents", "type": "string"}`, new: `{"name": "contents", "type": "Letter"}`},
+		{name: "invalid atomic type", old: `{"name": "contents", "type": "string"}`, new: `{"name": "contents", "type": "uint7"}`},
+		{name: "undefined primary type", old: `"primaryType": "Mail"`, new: `"primaryType": "Letter"`},
+		{name: "no domain type", old: `"EIP712Domain"`, new: `"Domain"`},
+		{name: "struct is not an object", old: `"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"}`, new: `"to": "Bob"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := strings.Replace(mailTypedData, tt.old, tt.new, 1)
+			if raw == mailTypedData {
+				t.Fatalf("%s is not in the typed data", tt.old)
+			}
+			if hash, err := mustTypedData(t, raw).Hash(); err == nil {
+				t.Errorf("Hash() = %s, want an error", hash)
+			}
+		})
+	}
+}
+
+func TestTypedData_FixedSizes(t *testing.T) {
+	tests := []struct {
+		typeName string
+		value    interface{}
+		wantErr  bool
+	}{
+		{typeName: "uint8", value: json.Number("255")},
+		{typeName: "uint8", value: json.Number("256"), wantErr: true},
+		{typeName: "int8", value: json.Number("-128")},
+		{typeName: "int8", value: json.Number("128"), wantErr: true},
+		{typeName: "bytes2", value: "0xabcd"},
+		{typeName: "bytes2", value: "0xab", wantErr: true},
+		{typeName: "uint256[2]", value: []interface{}{json.Number("1")}, wantErr: true},
+		{typeName: "bool", value: "true", wantErr: true},
+	}
+
+	td := &TypedData{Types: map[string][]TypedDataField{}}
+	for _, tt := range tests {
+		if _, err := td.encodeValue(tt.typeName, tt.value, "value", 0); (err != nil) != tt.wantErr {
+			t.Errorf("encodeValue(%s, %v) error = %v, wantErr %v", tt.typeName, tt.value, err, tt.wantErr)
+		}
+	}
+}
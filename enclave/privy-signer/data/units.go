@@ -0,0 +1,96 @@
+package data
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimals of the Ethereum units, amounts are held in wei
+const (
+	WeiDecimals   = 0
+	GweiDecimals  = 9
+	EtherDecimals = 18
+)
+
+// Parses a decimal ether amount like "1.5" into wei
+func ParseEther(s string) (*BigInt, error) {
+	return ParseUnits(s, EtherDecimals)
+}
+
+// Parses a decimal gwei amount like "0.1" into wei
+func ParseGwei(s string) (*BigInt, error) {
+	return ParseUnits(s, GweiDecimals)
+}
+
+// Parses a decimal amount of a unit with the given decimals into its smallest unit. Parsing is exact, amounts with more fractional
+// digits than the unit has, exponents or anything but digits and one point are rejected.
+func ParseUnits(s string, decimals int) (*BigInt, error) {
+	amount := s
+	negative := strings.HasPrefix(amount, "-")
+	if negative {
+		amount = amount[1:]
+	}
+
+	whole, fraction, hasPoint := strings.Cut(amount, ".")
+	if whole == "" && fraction == "" || hasPoint && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return nil, fmt.Errorf("invalid decimal amount: %s", s)
+	}
+	if len(fraction) > decimals {
+		return nil, fmt.Errorf("amount %s has more than %d decimals", s, decimals)
+	}
+
+	digits := whole + fraction + strings.Repeat("0", decimals-len(fraction))
+	n, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal amount: %s", s)
+	}
+	if negative {
+		n.Neg(n)
+	}
+	return &BigInt{n}, nil
+}
+
+// Formats wei as a decimal ether amount like "1.5"
+func FormatEther(wei *BigInt) string {
+	return FormatUnits(wei, EtherDecimals)
+}
+
+// Formats wei as a decimal gwei amount like "0.1"
+func FormatGwei(wei *BigInt) string {
+	return FormatUnits(wei, GweiDecimals)
+}
+
+// Formats an amount in the smallest unit as a decimal amount of a unit with the given decimals. The result is exact, without
+// trailing zeros in the fraction and without a point for whole amounts.
+func FormatUnits(amount *BigInt, decimals int) string {
+	if amount.IsNil() {
+		return "0"
+	}
+
+	digits := new(big.Int).Abs(amount.Int).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	whole := digits[:len(digits)-decimals]
+	fraction := strings.TrimRight(digits[len(digits)-decimals:], "0")
+
+	sign := ""
+	if amount.Int.Sign() < 0 {
+		sign = "-"
+	}
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
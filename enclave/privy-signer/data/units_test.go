@@ -0,0 +1,79 @@
+package data
+
+import "testing"
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		amount   string
+		decimals int
+		want     string
+		wantErr  bool
+	}{
+		{amount: "1", decimals: EtherDecimals, want: "1000000000000000000"},
+		{amount: "1.5", decimals: EtherDecimals, want: "1500000000000000000"},
+		{amount: "0.000000000000000001", decimals: EtherDecimals, want: "1"},
+		{amount: ".5", decimals: EtherDecimals, want: "500000000000000000"},
+		{amount: "-2.25", decimals: GweiDecimals, want: "-2250000000"},
+		{amount: "30", decimals: GweiDecimals, want: "30000000000"},
+		{amount: "42", decimals: WeiDecimals, want: "42"},
+		{amount: "123456789012345678901234567890", decimals: EtherDecimals, want: "123456789012345678901234567890000000000000000000"},
+		{amount: "0.0000000000000000001", decimals: EtherDecimals, wantErr: true},
+		{amount: "1.5", decimals: WeiDecimals, wantErr: true},
+		{amount: "1e18", decimals: EtherDecimals, wantErr: true},
+		{amount: "1.", decimals: EtherDecimals, wantErr: true},
+		{amount: "1.2.3", decimals: EtherDecimals, wantErr: true},
+		{amount: "+1", decimals: EtherDecimals, wantErr: true},
+		{amount: "", decimals: EtherDecimals, wantErr: true},
+		{amount: "-", decimals: EtherDecimals, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseUnits(tt.amount, tt.decimals)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseUnits(%q, %d) error = %v, wantErr %v", tt.amount, tt.decimals, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got.String() != tt.want {
+			t.Errorf("ParseUnits(%q, %d) = %s, want %s", tt.amount, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		wei      string
+		decimals int
+		want     string
+	}{
+		{wei: "1000000000000000000", decimals: EtherDecimals, want: "1"},
+		{wei: "1500000000000000000", decimals: EtherDecimals, want: "1.5"},
+		{wei: "1", decimals: EtherDecimals, want: "0.000000000000000001"},
+		{wei: "0", decimals: EtherDecimals, want: "0"},
+		{wei: "-2250000000", decimals: GweiDecimals, want: "-2.25"},
+		{wei: "42", decimals: WeiDecimals, want: "42"},
+	}
+
+	for _, tt := range tests {
+		wei, _ := NewBigIntFromString(tt.wei)
+		if got := FormatUnits(wei, tt.decimals); got != tt.want {
+			t.Errorf("FormatUnits(%s, %d) = %s, want %s", tt.wei, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestEtherAndGwei_RoundTrip(t *testing.T) {
+	for _, amount := range []string{"0", "1", "0.1", "21.000000000000000001", "1000000"} {
+		wei, err := ParseEther(amount)
+		if err != nil {
+			t.Fatalf("ParseEther(%q) error = %v", amount, err)
+		}
+		if got := FormatEther(wei); got != amount {
+			t.Errorf("FormatEther(ParseEther(%q)) = %s", amount, got)
+		}
+	}
+
+	wei, _ := ParseGwei("1.5")
+	if got := FormatGwei(wei); got != "1.5" || wei.String() != "1500000000" {
+		t.Errorf("ParseGwei(1.5) = %s wei, formats as %s", wei, got)
+	}
+}
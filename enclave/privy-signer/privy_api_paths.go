@@ -8,6 +8,8 @@ const (
 	GET_USER_PATH      Path = "/v1/users/%s"
 	SIGN_TX_PATH       Path = "/v1/wallets/%s/rpc"
 	CREATE_WALLET_PATH Path = "/v1/users/%s/wallets"
+	CREATE_POLICY_PATH Path = "/v1/policies"
+	POLICY_PATH        Path = "/v1/policies/%s"
 )
 
 func (p Path) Build(args ...interface{}) string {
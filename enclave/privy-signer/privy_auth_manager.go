@@ -1,40 +1,30 @@
 package privysigner
 
 import (
+	"github.com/getaxal/verified-signer/enclave/apierror"
 	"github.com/getaxal/verified-signer/enclave/privy-signer/auth"
 	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
 	log "github.com/sirupsen/logrus"
 )
 
 // For user signing requests - JWT validation only
-func (cli *PrivyClient) ValidateUserAuthForSigningRequest(authString string) (string, *data.HttpError) {
-	privyId, err := auth.ValidateJWTAndExtractPrivyID(authString, cli.teeConfig)
+func (cli *PrivyClient) ValidateUserAuthForSigningRequest(authString string) (string, *apierror.Error) {
+	privyConfig := cli.getPrivyConfig()
+	privyId, err := auth.ValidateJWTWithKeys(authString, cli.jwtKeys.Keys(), privyConfig.AppID, cli.teeConfig.Environment)
 	if err != nil {
-		log.Errorf("invalid privy jwt: %s with err: %v", authString, err)
-		httpErr := &data.HttpError{
-			Code: 401,
-			Message: data.Message{
-				Message: "Unauthorized User",
-			},
-		}
-		return "", httpErr
+		log.Errorf("invalid privy jwt with err: %v", err)
+		return "", apierror.Wrap(apierror.AuthFailed, "Unauthorized User", err)
 	}
 	return privyId, nil
 }
 
 // For axal signing requests - HMAC validation only
-func (cli *PrivyClient) ValidateAxalAuthForSigningRequest(hmacSignature string, signReq *data.AxalEthSecp256k1SignRequest) (string, *data.HttpError) {
+func (cli *PrivyClient) ValidateAxalAuthForSigningRequest(hmacSignature string, signReq *data.AxalEthSecp256k1SignRequest) (string, *apierror.Error) {
 	// Validate HMAC signature
-	verified := auth.VerifyAxalSignature(signReq.Params.Hash, hmacSignature, cli.teeConfig.Axal.AxalRequestSecretKey)
+	verified := auth.VerifyAxalSignatureWithKeyStore(signReq.Params.Hash, hmacSignature, cli.keys)
 	if !verified {
 		log.Errorf("invalid HMAC signature for payload: %s", signReq.Params.Hash)
-		httpErr := &data.HttpError{
-			Code: 401,
-			Message: data.Message{
-				Message: "Unauthorized User - Invalid HMAC",
-			},
-		}
-		return "", httpErr
+		return "", apierror.New(apierror.AuthFailed, "Unauthorized User - Invalid HMAC")
 	}
 
 	// Return privy_id from request body (backend already authenticated the user)
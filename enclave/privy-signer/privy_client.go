@@ -1,14 +1,21 @@
 package privysigner
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"encoding/base64"
-	"encoding/json"
-	"io"
+	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/keystore"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/auth"
+	authorizationsignature "github.com/getaxal/verified-signer/enclave/privy-signer/authorization_signature"
 	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/resilience"
+	"github.com/getaxal/verified-signer/enclave/signer"
 	"github.com/jellydator/ttlcache/v3"
 
 	"github.com/getaxal/verified-signer/common/network"
@@ -18,13 +25,34 @@ import (
 
 var PrivyCli *PrivyClient
 
+var _ signer.Signer = (*PrivyClient)(nil)
+
 type PrivyClient struct {
-	Environment   string
-	baseUrl       string
-	client        *http.Client
-	teeConfig     *enclave.TEEConfig
-	authorization string
-	userCache     *ttlcache.Cache[string, data.PrivyUser]
+	Environment string
+	baseUrl     string
+	client      *http.Client
+	teeConfig   *enclave.TEEConfig
+	userCache   *ttlcache.Cache[string, data.PrivyUser]
+	userCallsMu sync.Mutex
+	userCalls   map[string]*userCall // Coalesces concurrent fetches and wallet creations of the same user
+	breaker     *resilience.CircuitBreaker
+
+	// Privy config and JWT verification keys, replaced when the secrets are rotated. The privy credentials and the HMAC keys are
+	// only kept in the key store.
+	credsMu     sync.RWMutex
+	privyConfig enclave.PrivyConfig
+	signingKeys []string // Key store names of the parsed authorization keys every signing request is signed with
+	keys        *keystore.KeyStore
+	jwtKeys     *auth.Keyring
+}
+
+// Base url of the Privy API
+const PRIVY_BASE_URL = "https://api.privy.io"
+
+// Where a PrivyClient sends its requests. Unset fields default to the Privy API through the privy vsock port.
+type PrivyClientOptions struct {
+	BaseUrl   string
+	Transport http.RoundTripper
 }
 
 // Inits a new Privy Client with a custom Transport Layer service that routes https through the privyAPIVsockPort. It initates it to privysigner.PrivyCli.
@@ -32,83 +60,149 @@ func InitNewPrivyClient(configPath string, cfg *enclave.TEEConfig) error {
 	// Setup Privy Config for privy api details
 	log.Infof("Setting up privy cfg in %s env", cfg.GetEnv())
 
-	// Setup a new Http client for Privy API calls
-	privyClient := network.InitHttpsClientWithTLSVsockTransport(cfg.Ports.PrivyAPIVsockPort, "api.privy.io")
+	privyCli, err := NewPrivyClient(cfg, PrivyClientOptions{})
+	if err != nil {
+		return err
+	}
+	PrivyCli = privyCli
+
+	// New wallets get the configured policies, make sure they are still the ones the image was built for
+	if err := PrivyCli.ReconcilePolicies(context.Background(), cfg.Policies); err != nil {
+		return err
+	}
+
+	// Pick up rotated secrets without a restart
+	if secretCache := cfg.SecretCache(); secretCache != nil {
+		if err := PrivyCli.subscribeToSecretRotation(secretCache); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	username := cfg.Privy.AppID
-	password := cfg.Privy.AppSecret
+// Creates a new Privy Client for the privy secrets sealed in the key store of cfg. Tests point it at a fake Privy server with opts.
+func NewPrivyClient(cfg *enclave.TEEConfig, opts PrivyClientOptions) (*PrivyClient, error) {
+	baseUrl := opts.BaseUrl
+	if baseUrl == "" {
+		baseUrl = PRIVY_BASE_URL
+	}
+
+	// Setup a new Http client for Privy API calls
+	var privyClient *http.Client
+	if opts.Transport != nil {
+		privyClient = &http.Client{Transport: opts.Transport, Timeout: 30 * time.Second}
+	} else {
+		privyClient = network.InitHttpsClientWithTLSVsockTransport(cfg.Ports.PrivyAPIVsockPort, "api.privy.io")
+	}
 
-	authorization := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
 	cache := ttlcache.New(
 		ttlcache.WithTTL[string, data.PrivyUser](30*time.Minute),
 		ttlcache.WithCapacity[string, data.PrivyUser](1000),
 	)
 
-	PrivyCli = &PrivyClient{
-		Environment:   cfg.GetEnv(),
-		baseUrl:       "https://api.privy.io",
-		client:        privyClient,
-		teeConfig:     cfg,
-		authorization: authorization,
-		userCache:     cache,
+	cli := &PrivyClient{
+		Environment: cfg.GetEnv(),
+		baseUrl:     baseUrl,
+		client:      privyClient,
+		teeConfig:   cfg,
+		userCache:   cache,
+		breaker:     resilience.NewCircuitBreaker(privyBreakerThreshold, privyBreakerCooldown),
+		keys:        cfg.KeyStore(),
+		jwtKeys:     auth.NewKeyring(cfg.Privy.JWTVerificationKey),
 	}
-
-	return nil
+	if err := cli.loadPrivyCredentials(cfg.Privy); err != nil {
+		return nil, err
+	}
+	return cli, nil
 }
 
-// Adds the standard API headers for most Privy API calls
-func (cli *PrivyClient) addStandardPrivyHeaders(req *http.Request) {
-	req.Header.Add("privy-app-id", cli.teeConfig.Privy.AppID)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Basic "+cli.authorization)
+// Derives the privy credentials used for Privy API calls from the secrets sealed in the key store: the delegated actions key and
+// key quorum authorization keys are parsed into ECDSA keys once and the basic authorization is built once.
+func (cli *PrivyClient) loadPrivyCredentials(privyConfig enclave.PrivyConfig) error {
+	names := []string{keystore.PrivyAppSecret, keystore.PrivyDelegatedActionsKey}
+	for i := 0; cli.keys.Has(keystore.PrivyQuorumKey(i)); i++ {
+		names = append(names, keystore.PrivyQuorumKey(i))
+	}
+
+	return cli.keys.WithSecrets(names, func(secrets [][]byte) error {
+		return cli.setPrivyCredentials(privyConfig, secrets[0], secrets[1:])
+	})
 }
 
-// Simple function to get just the error message from the privy error message
-func getSimplePrivyErrorMessage(responseBody []byte) string {
-	var errorResp struct {
-		Error string `json:"error"`
+// Replaces the privy credentials used for Privy API calls. authorizationKeys starts with the delegated actions key, followed by
+// the other authorization keys of the key quorum. Nothing is replaced if any of them can not be parsed.
+func (cli *PrivyClient) setPrivyCredentials(privyConfig enclave.PrivyConfig, appSecret []byte, authorizationKeys [][]byte) error {
+	parsed := make([]*ecdsa.PrivateKey, len(authorizationKeys))
+	for i, authorizationKey := range authorizationKeys {
+		key, err := authorizationsignature.ParseAuthorizationKey(authorizationKey)
+		if err != nil {
+			if i == 0 {
+				return fmt.Errorf("invalid privy delegated actions key: %w", err)
+			}
+			return fmt.Errorf("invalid privy authorization key %d: %w", i-1, err)
+		}
+		parsed[i] = key
 	}
 
-	log.Infof("err: %s", string(responseBody))
+	credentials := make([]byte, 0, len(privyConfig.AppID)+1+len(appSecret))
+	credentials = append(credentials, privyConfig.AppID...)
+	credentials = append(credentials, ':')
+	credentials = append(credentials, appSecret...)
 
-	err := json.Unmarshal(responseBody, &errorResp)
-	if err != nil {
-		return "Unable to parse Privy Error"
-	}
+	authorization := make([]byte, base64.StdEncoding.EncodedLen(len(credentials)))
+	base64.StdEncoding.Encode(authorization, credentials)
+	clear(credentials)
 
-	return errorResp.Error // Return raw response if parsing fails
-}
+	privyConfig.AppSecret = ""
+	privyConfig.DelegatedActionsKey = ""
+	privyConfig.AuthorizationKeys = nil
 
-// A simple way to handle privy errors
-func handlePrivyError(res *http.Response) *data.HttpError {
-	body, err := io.ReadAll(res.Body)
+	cli.credsMu.Lock()
+	defer cli.credsMu.Unlock()
 
-	if err != nil {
-		log.Errorf("Error reading body: %v", err)
-		return &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
+	signingKeys := make([]string, len(parsed))
+	for i, key := range parsed {
+		signingKeys[i] = keystore.PrivyAuthorizationKey
+		if i > 0 {
+			signingKeys[i] = keystore.PrivyQuorumAuthorizationKey(i - 1)
 		}
+		cli.keys.PutECDSAKey(signingKeys[i], key)
+	}
+	for i := len(parsed) - 1; cli.keys.Has(keystore.PrivyQuorumAuthorizationKey(i)); i++ {
+		cli.keys.Delete(keystore.PrivyQuorumAuthorizationKey(i))
 	}
 
-	errorMessage := getSimplePrivyErrorMessage(body)
+	cli.keys.Put(keystore.PrivyBasicAuthorization, authorization)
+	cli.privyConfig = privyConfig
+	cli.signingKeys = signingKeys
+	return nil
+}
 
-	return &data.HttpError{
-		Code: res.StatusCode,
-		Message: data.Message{
-			Message: errorMessage,
-		},
-	}
+// Returns the current privy config, without its secrets
+func (cli *PrivyClient) getPrivyConfig() enclave.PrivyConfig {
+	cli.credsMu.RLock()
+	defer cli.credsMu.RUnlock()
+
+	return cli.privyConfig
 }
 
-// Helper function to create internal server error
-func (cli *PrivyClient) createInternalServerError() *data.HttpError {
-	return &data.HttpError{
-		Code: 500,
-		Message: data.Message{
-			Message: "Internal Server Error",
-		},
-	}
+// Returns the key store names of the authorization keys signing requests are signed with
+func (cli *PrivyClient) getSigningKeys() []string {
+	cli.credsMu.RLock()
+	defer cli.credsMu.RUnlock()
+
+	return cli.signingKeys
+}
+
+// Adds the standard API headers for most Privy API calls
+func (cli *PrivyClient) addStandardPrivyHeaders(req *http.Request) error {
+	privyConfig := cli.getPrivyConfig()
+
+	req.Header.Add("privy-app-id", privyConfig.AppID)
+	req.Header.Add("Content-Type", "application/json")
+	return cli.keys.WithSecret(keystore.PrivyBasicAuthorization, func(authorization []byte) error {
+		req.Header.Add("Authorization", "Basic "+string(authorization))
+		return nil
+	})
 }
@@ -0,0 +1,155 @@
+package privysigner
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/privytest"
+	"github.com/getaxal/verified-signer/enclave/secp256k1"
+)
+
+// Creates a PrivyClient talking to a fake Privy server that knows the client's credentials
+func newFakePrivyClient(t *testing.T) (*PrivyClient, *privytest.Server) {
+	t.Helper()
+
+	key, delegatedActionsKey := newTestAuthorizationKey(t)
+	server := privytest.NewServer("app-id", "app-secret", &key.PublicKey)
+	t.Cleanup(server.Close)
+
+	cfg := &enclave.TEEConfig{
+		Environment: "dev",
+		Axal:        enclave.AxalConfig{AxalRequestSecretKey: "hmac-key"},
+		Privy: enclave.PrivyConfig{
+			AppID:                 "app-id",
+			AppSecret:             "app-secret",
+			DelegatedActionsKey:   delegatedActionsKey,
+			DelegatedActionsKeyId: "key-id",
+			JWTVerificationKey:    "jwt-key",
+		},
+	}
+	if err := cfg.SealSecrets(); err != nil {
+		t.Fatalf("SealSecrets() error = %v", err)
+	}
+
+	cli, err := NewPrivyClient(cfg, PrivyClientOptions{BaseUrl: server.URL, Transport: server.Transport()})
+	if err != nil {
+		t.Fatalf("NewPrivyClient() error = %v", err)
+	}
+	return cli, server
+}
+
+func TestGetUser_CreatesDelegatedWallet(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+
+	user, httpErr := cli.GetUser(context.Background(), "did:privy:alice")
+	if httpErr != nil {
+		t.Fatalf("GetUser() error = %v", httpErr)
+	}
+	wallet := user.GetUsersEthDelegatedWallet()
+	if wallet == nil || wallet.WalletID == "" {
+		t.Fatalf("GetUser() = %+v, want a delegated eth wallet", user)
+	}
+
+	stored, _ := server.User("did:privy:alice")
+	if len(stored.LinkedAccounts) != 1 || stored.LinkedAccounts[0].Address != wallet.Address {
+		t.Errorf("privy has linked accounts %+v, want the one created wallet", stored.LinkedAccounts)
+	}
+
+	// The user is read again after the wallet is created, the second lookup is served from the user cache
+	if _, httpErr := cli.GetUser(context.Background(), "did:privy:alice"); httpErr != nil {
+		t.Fatalf("GetUser() error = %v", httpErr)
+	}
+	if got := server.Requests(privytest.GetUser); got != 2 {
+		t.Errorf("GetUser requests = %d, want 2", got)
+	}
+	if got := server.Requests(privytest.CreateWallet); got != 1 {
+		t.Errorf("CreateWallet requests = %d, want 1", got)
+	}
+}
+
+func TestExecutePrivySigningRequest(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+
+	hash := strings.Repeat("42", 32)
+	var resp data.EthSecp256k1SignResponse
+	if httpErr := cli.executePrivySigningRequest(context.Background(), *data.NewUserEthSecp256k1SignRequest("0x" + hash), "did:privy:alice", &resp); httpErr != nil {
+		t.Fatalf("executePrivySigningRequest() error = %v", httpErr)
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(resp.Data.Signature, "0x"))
+	if err != nil {
+		t.Fatalf("signature is not hex: %v", err)
+	}
+	hashBytes, _ := hex.DecodeString(hash)
+	pub, err := secp256k1.Recover(hashBytes, signature)
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+
+	user, _ := server.User("did:privy:alice")
+	if got, want := secp256k1.Address(pub), user.GetUsersEthDelegatedWallet().Address; got != want {
+		t.Errorf("signature recovers to %s, want the wallet %s", got, want)
+	}
+}
+
+func TestPrivyClient_FakeServerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   func(t *testing.T, cli *PrivyClient, server *privytest.Server)
+		wantCode apierror.Code
+	}{
+		{
+			name:     "unknown user",
+			script:   func(t *testing.T, cli *PrivyClient, server *privytest.Server) {},
+			wantCode: apierror.NotFound,
+		},
+		{
+			name: "privy error is mapped",
+			script: func(t *testing.T, cli *PrivyClient, server *privytest.Server) {
+				server.AddUser("did:privy:alice")
+				server.FailNext(privytest.WalletRpc, privytest.Fault{Code: http.StatusTooManyRequests, Message: "Too many requests."})
+			},
+			wantCode: apierror.RateLimited,
+		},
+		{
+			name: "wrong authorization key",
+			script: func(t *testing.T, cli *PrivyClient, server *privytest.Server) {
+				server.AddUser("did:privy:alice")
+				other, _ := newTestAuthorizationKey(t)
+				server.SetAuthorizationKeys(&other.PublicKey)
+			},
+			wantCode: apierror.UpstreamError,
+		},
+		{
+			name: "privy too slow",
+			script: func(t *testing.T, cli *PrivyClient, server *privytest.Server) {
+				server.AddUser("did:privy:alice")
+				server.SetLatency(privytest.GetUser, time.Second)
+				cli.client.Timeout = 50 * time.Millisecond
+			},
+			wantCode: apierror.UpstreamTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, server := newFakePrivyClient(t)
+			tt.script(t, cli, server)
+
+			var resp data.EthSecp256k1SignResponse
+			httpErr := cli.executePrivySigningRequest(context.Background(), *data.NewUserEthSecp256k1SignRequest("0x" + strings.Repeat("42", 32)), "did:privy:alice", &resp)
+			if httpErr == nil || httpErr.Code != tt.wantCode {
+				t.Errorf("executePrivySigningRequest() error = %v, want %s", httpErr, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package privysigner
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	log "github.com/sirupsen/logrus"
+)
+
+// privyErrorBody is the body of a Privy error response
+type privyErrorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// Maps a non 200 Privy response to an API error. The Privy message is logged but not passed on, clients get a stable code and a
+// message that does not change when Privy rewords theirs.
+func privyError(res *http.Response) *apierror.Error {
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		log.Errorf("Error reading privy error body: %v", err)
+		return apierror.InternalError(err)
+	}
+	return mapPrivyError(res.StatusCode, body)
+}
+
+// Maps a Privy error status and body to an API error
+func mapPrivyError(status int, body []byte) *apierror.Error {
+	var privyErr privyErrorBody
+	if err := json.Unmarshal(body, &privyErr); err != nil {
+		log.Errorf("Unable to parse privy error %d: %s", status, string(body))
+	} else {
+		log.Errorf("Privy error %d: %s %s", status, privyErr.Code, privyErr.Error)
+	}
+
+	switch {
+	case isPolicyViolation(privyErr) && (status == http.StatusBadRequest || status == http.StatusForbidden):
+		return apierror.New(apierror.PolicyDenied, "Request denied by wallet policy")
+	case status == http.StatusBadRequest:
+		return apierror.New(apierror.InvalidRequest, "Privy rejected the request")
+	case status == http.StatusNotFound:
+		return apierror.New(apierror.NotFound, "Not found")
+	case status == http.StatusTooManyRequests:
+		return apierror.New(apierror.RateLimited, "Privy is rate limiting requests")
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		// Privy rejecting our own credentials or authorization signatures is our problem, not the clients
+		return apierror.New(apierror.UpstreamError, "Privy rejected the enclave credentials")
+	case status == http.StatusBadGateway || status == http.StatusServiceUnavailable:
+		return apierror.New(apierror.UpstreamUnavailable, "Privy is unavailable")
+	case status == http.StatusGatewayTimeout:
+		return apierror.New(apierror.UpstreamTimeout, "Privy timed out")
+	default:
+		return apierror.New(apierror.UpstreamError, "Privy returned an error")
+	}
+}
+
+// Reports whether Privy refused a request because of a wallet policy
+func isPolicyViolation(privyErr privyErrorBody) bool {
+	return strings.Contains(strings.ToLower(privyErr.Code), "polic") || strings.Contains(strings.ToLower(privyErr.Error), "polic")
+}
@@ -0,0 +1,40 @@
+package privysigner
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+)
+
+func TestMapPrivyError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   apierror.Code
+	}{
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error":"Invalid hash"}`, want: apierror.InvalidRequest},
+		{name: "policy violation", status: http.StatusBadRequest, body: `{"error":"Policy violation: denied by rule","code":"policy_violation"}`, want: apierror.PolicyDenied},
+		{name: "forbidden by policy", status: http.StatusForbidden, body: `{"error":"Request denied by policy"}`, want: apierror.PolicyDenied},
+		{name: "our credentials", status: http.StatusUnauthorized, body: `{"error":"Invalid app secret"}`, want: apierror.UpstreamError},
+		{name: "not found", status: http.StatusNotFound, body: `{"error":"User not found"}`, want: apierror.NotFound},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{"error":"Too many requests."}`, want: apierror.RateLimited},
+		{name: "unavailable", status: http.StatusServiceUnavailable, body: `<html>bad gateway</html>`, want: apierror.UpstreamUnavailable},
+		{name: "timeout", status: http.StatusGatewayTimeout, want: apierror.UpstreamTimeout},
+		{name: "internal", status: http.StatusInternalServerError, body: `{"error":"oops"}`, want: apierror.UpstreamError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mapPrivyError(tt.status, []byte(tt.body))
+			if got.Code != tt.want {
+				t.Errorf("mapPrivyError(%d) = %s, want %s", tt.status, got.Code, tt.want)
+			}
+			if strings.Contains(got.Message, "oops") || strings.Contains(got.Message, "app secret") {
+				t.Errorf("mapPrivyError(%d) message %q passes on the privy message", tt.status, got.Message)
+			}
+		})
+	}
+}
@@ -1,39 +1,56 @@
 package privysigner
 
 import (
+	"context"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/controls"
 	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/signer"
+	"github.com/getaxal/verified-signer/enclave/verifier"
 	log "github.com/sirupsen/logrus"
 )
 
 // User signing - JWT auth only, privy_id extracted from JWT
-func (cli *PrivyClient) UserEthSecp256k1Sign(signReq *data.UserEthSecp256k1SignRequest, authString string) (*data.EthSecp256k1SignResponse, *data.HttpError) {
+func (cli *PrivyClient) UserEthSecp256k1Sign(ctx context.Context, signReq *data.UserEthSecp256k1SignRequest, authString string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
 	// Validate JWT and get privy_id
 	privyId, httpErr := cli.ValidateUserAuthForSigningRequest(authString)
 	if httpErr != nil {
-		log.Errorf("invalid user auth with err: %v", httpErr.Message.Message)
+		log.Errorf("invalid user auth with err: %v", httpErr)
 		return nil, httpErr
 	}
 
 	// Execute privy signing directly with user request
 	var resp data.EthSecp256k1SignResponse
-	if err := cli.executePrivySigningRequest(*signReq, privyId, &resp); err != nil {
+	if err := cli.executePrivySigningRequest(ctx, *signReq, privyId, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // Axal signing - HMAC auth only, privy_id from request body
-func (cli *PrivyClient) AxalEthSecp256k1Sign(signReq *data.AxalEthSecp256k1SignRequest, hmacSignature string) (*data.EthSecp256k1SignResponse, *data.HttpError) {
+func (cli *PrivyClient) AxalEthSecp256k1Sign(ctx context.Context, signReq *data.AxalEthSecp256k1SignRequest, hmacSignature string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
 	// Validate HMAC and get privy_id from request
 	privyId, httpErr := cli.ValidateAxalAuthForSigningRequest(hmacSignature, signReq)
 	if httpErr != nil {
-		log.Errorf("invalid axal auth with err: %v", httpErr.Message.Message)
+		log.Errorf("invalid axal auth with err: %v", httpErr)
+		return nil, httpErr
+	}
+
+	// Axal signing can be paused by the operators or disabled by the user with their kill switch, this never affects user initiated signing
+	if err := controls.CheckAxalSigningAllowed(privyId); err != nil {
+		log.Errorf("axal signing not allowed for user %s with err: %v", privyId, err)
+		return nil, signer.ControlError(err)
+	}
+
+	// The preimage is checked against the hash and the policies run on it before anything is signed
+	if httpErr := verifier.VerifyAxalRequest(ctx, signReq); httpErr != nil {
 		return nil, httpErr
 	}
 
-	// Execute privy signing directly with axal request
+	// Execute privy signing with the axal request, Privy only gets the hash
 	var resp data.EthSecp256k1SignResponse
-	if err := cli.executePrivySigningRequest(*signReq, privyId, &resp); err != nil {
+	if err := cli.executePrivySigningRequest(ctx, *signReq.WithoutPreimage(), privyId, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
@@ -0,0 +1,128 @@
+package privysigner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	authorizationsignature "github.com/getaxal/verified-signer/enclave/privy-signer/authorization_signature"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// Creates a Privy policy, signed with the authorization keys
+func (cli *PrivyClient) CreatePolicy(ctx context.Context, policy *data.PrivyPolicy) (*data.PrivyPolicy, *apierror.Error) {
+	url := fmt.Sprintf("%s%s", cli.baseUrl, CREATE_POLICY_PATH.Build())
+	return cli.executePrivyPolicyRequest(ctx, http.MethodPost, url, policy)
+}
+
+// Updates a Privy policy, signed with the authorization keys. The keys have to satisfy the owner of the policy.
+func (cli *PrivyClient) UpdatePolicy(ctx context.Context, policyId string, update *data.UpdatePolicyRequest) (*data.PrivyPolicy, *apierror.Error) {
+	url := fmt.Sprintf("%s%s", cli.baseUrl, POLICY_PATH.Build(policyId))
+	return cli.executePrivyPolicyRequest(ctx, http.MethodPatch, url, update)
+}
+
+// Gets a Privy policy given its id
+func (cli *PrivyClient) GetPolicy(ctx context.Context, policyId string) (*data.PrivyPolicy, *apierror.Error) {
+	url := fmt.Sprintf("%s%s", cli.baseUrl, POLICY_PATH.Build(policyId))
+	return cli.executePrivyPolicyRequest(ctx, http.MethodGet, url, nil)
+}
+
+// Checks that the configured policies exist at Privy and hash to the policy hash baked into the image, so new wallets are not
+// created with policies that were changed or removed behind the enclave's back
+func (cli *PrivyClient) ReconcilePolicies(ctx context.Context, cfg enclave.PolicyConfig) error {
+	if len(cfg.IDs) == 0 {
+		return nil
+	}
+
+	policies := make([]*data.PrivyPolicy, 0, len(cfg.IDs))
+	for _, policyId := range cfg.IDs {
+		policy, httpErr := cli.GetPolicy(ctx, policyId)
+		if httpErr != nil {
+			return fmt.Errorf("failed to fetch privy policy %s: %v", policyId, httpErr)
+		}
+		policies = append(policies, policy)
+	}
+
+	hash, err := data.HashPolicies(policies)
+	if err != nil {
+		return err
+	}
+
+	if hash != cfg.Hash {
+		return fmt.Errorf("privy policies %v hash to %s, expected %s", cfg.IDs, hash, cfg.Hash)
+	}
+
+	log.Infof("Privy policies %v match the configured policy hash", cfg.IDs)
+	return nil
+}
+
+// Sends a policy request and decodes the policy in the response. Requests with a body change the policy and are signed with the
+// authorization keys, only reads are retried.
+func (cli *PrivyClient) executePrivyPolicyRequest(ctx context.Context, method string, url string, body interface{}) (*data.PrivyPolicy, *apierror.Error) {
+	var jsonData []byte
+	if body != nil {
+		var err error
+		jsonData, err = json.Marshal(body)
+		if err != nil {
+			log.Errorf("Error marshalling policy request: %v", err)
+			return nil, apierror.InternalError(err)
+		}
+	}
+
+	res, httpErr := cli.doPrivyRequest(ctx, body == nil, func(ctx context.Context) (*http.Request, error) {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewBuffer(jsonData)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cli.addStandardPrivyHeaders(req); err != nil {
+			return nil, err
+		}
+
+		if body != nil {
+			privyConfig := cli.getPrivyConfig()
+			signature, err := authorizationsignature.GetAuthorizationSignature(body, method, cli.keys, cli.getSigningKeys(), url, privyConfig.AppID)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Add("privy-authorization-signature", signature)
+		}
+		return req, nil
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	defer res.Body.Close()
+
+	// Check status code
+	if res.StatusCode != http.StatusOK {
+		log.Errorf("Received status code %d", res.StatusCode)
+		return nil, privyError(res)
+	}
+
+	// Read response body
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		log.Errorf("Error reading response body: %v", err)
+		return nil, apierror.InternalError(err)
+	}
+
+	var policy data.PrivyPolicy
+	if err := json.Unmarshal(resBody, &policy); err != nil {
+		log.Errorf("Error unmarshalling policy: %v", err)
+		return nil, apierror.InternalError(err)
+	}
+
+	return &policy, nil
+}
@@ -0,0 +1,171 @@
+package privysigner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+	"github.com/getaxal/verified-signer/enclave"
+	authorizationsignature "github.com/getaxal/verified-signer/enclave/privy-signer/authorization_signature"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+)
+
+func testPolicy() *data.PrivyPolicy {
+	return &data.PrivyPolicy{
+		Version:   "1.0",
+		Name:      "Allow transfers to the vault",
+		ChainType: "ethereum",
+		Rules: []*data.PolicyRule{{
+			Name:   "vault only",
+			Method: "eth_signTransaction",
+			Action: "ALLOW",
+			Conditions: []*data.PolicyCondition{{
+				FieldSource: "ethereum_transaction",
+				Field:       "to",
+				Operator:    "eq",
+				Value:       json.RawMessage(`"0x3535353535353535353535353535353535353535"`),
+			}},
+		}},
+	}
+}
+
+// Serves policies from a map like the Privy policy API, checking the authorization signature of writes against key
+func newPolicyTestServer(t *testing.T, key *ecdsa.PublicKey, policies map[string]*data.PrivyPolicy) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("privy-app-id") != "app-id" || !strings.HasPrefix(r.Header.Get("Authorization"), "Basic ") {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		policyId := strings.TrimPrefix(r.URL.Path, "/v1/policies/")
+		if r.Method == http.MethodGet {
+			policy, ok := policies[policyId]
+			if !ok {
+				http.Error(w, `{"error":"policy not found"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(policy)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var decoded interface{}
+		json.Unmarshal(body, &decoded)
+		payload, _ := json.Marshal(map[string]interface{}{
+			"body":    decoded,
+			"headers": map[string]string{"privy-app-id": "app-id"},
+			"method":  r.Method,
+			"url":     "http://" + r.Host + r.URL.Path,
+			"version": 1,
+		})
+		canonical, _ := jsoncanonicalizer.Transform(payload)
+		valid, err := authorizationsignature.VerifySignature(key, canonical, []byte(r.Header.Get("privy-authorization-signature")))
+		if err != nil || !valid {
+			http.Error(w, `{"error":"invalid authorization signature"}`, http.StatusUnauthorized)
+			return
+		}
+
+		var policy data.PrivyPolicy
+		if r.Method == http.MethodPost {
+			json.Unmarshal(body, &policy)
+			policy.ID = "policy-new"
+		} else {
+			var update data.UpdatePolicyRequest
+			json.Unmarshal(body, &update)
+			policy = *policies[policyId]
+			policy.Name = update.Name
+		}
+		policies[policy.ID] = &policy
+		json.NewEncoder(w).Encode(&policy)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newPolicyTestClient(t *testing.T, policies map[string]*data.PrivyPolicy) *PrivyClient {
+	t.Helper()
+
+	cli := newRotationTestClient(t)
+	key, delegatedActionsKey := newTestAuthorizationKey(t)
+	if err := cli.setPrivyCredentials(cli.getPrivyConfig(), []byte("secret"), [][]byte{[]byte(delegatedActionsKey)}); err != nil {
+		t.Fatalf("setPrivyCredentials() error = %v", err)
+	}
+
+	server := newPolicyTestServer(t, &key.PublicKey, policies)
+	cli.baseUrl = server.URL
+	cli.client = server.Client()
+	return cli
+}
+
+func TestPolicyRequests(t *testing.T) {
+	policies := map[string]*data.PrivyPolicy{}
+	cli := newPolicyTestClient(t, policies)
+
+	created, httpErr := cli.CreatePolicy(context.Background(), testPolicy())
+	if httpErr != nil {
+		t.Fatalf("CreatePolicy() error = %v", httpErr)
+	}
+	if created.ID != "policy-new" || len(created.Rules) != 1 {
+		t.Errorf("CreatePolicy() = %+v, want the created policy", created)
+	}
+
+	updated, httpErr := cli.UpdatePolicy(context.Background(), created.ID, &data.UpdatePolicyRequest{Name: "renamed"})
+	if httpErr != nil {
+		t.Fatalf("UpdatePolicy() error = %v", httpErr)
+	}
+	if updated.Name != "renamed" {
+		t.Errorf("UpdatePolicy() name = %s, want renamed", updated.Name)
+	}
+
+	fetched, httpErr := cli.GetPolicy(context.Background(), created.ID)
+	if httpErr != nil || fetched.Name != "renamed" {
+		t.Errorf("GetPolicy() = %+v, %v, want the updated policy", fetched, httpErr)
+	}
+
+	if _, httpErr := cli.GetPolicy(context.Background(), "missing"); httpErr == nil || httpErr.Status() != http.StatusNotFound {
+		t.Errorf("GetPolicy() error = %v, want not found", httpErr)
+	}
+}
+
+func TestReconcilePolicies(t *testing.T) {
+	policy := testPolicy()
+	hash, err := data.HashPolicies([]*data.PrivyPolicy{policy})
+	if err != nil {
+		t.Fatalf("HashPolicies() error = %v", err)
+	}
+
+	stored := *policy
+	stored.ID = "policy-1"
+	cli := newPolicyTestClient(t, map[string]*data.PrivyPolicy{"policy-1": &stored})
+
+	tests := []struct {
+		name    string
+		cfg     enclave.PolicyConfig
+		wantErr string
+	}{
+		{name: "no policies", cfg: enclave.PolicyConfig{}},
+		{name: "matching hash", cfg: enclave.PolicyConfig{IDs: []string{"policy-1"}, Hash: hash}},
+		{name: "changed policy", cfg: enclave.PolicyConfig{IDs: []string{"policy-1"}, Hash: strings.Repeat("0", 64)}, wantErr: "hash to " + hash},
+		{name: "missing policy", cfg: enclave.PolicyConfig{IDs: []string{"policy-1", "policy-2"}, Hash: hash}, wantErr: "policy-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cli.ReconcilePolicies(context.Background(), tt.cfg)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ReconcilePolicies() unexpected error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ReconcilePolicies() error = %v, want it to contain %s", err, tt.wantErr)
+			}
+		})
+	}
+}
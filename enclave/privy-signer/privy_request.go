@@ -0,0 +1,122 @@
+package privysigner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/resilience"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	privyMaxAttempts   = 3               // Attempts of an idempotent Privy call
+	privyMaxRetryAfter = 5 * time.Second // Longer Retry-After waits are left to the caller
+
+	privyBreakerThreshold = 5 // Failed Privy calls in a row that open the circuit breaker
+	privyBreakerCooldown  = 30 * time.Second
+)
+
+var privyBackoff = resilience.Backoff{Base: 100 * time.Millisecond, Max: 2 * time.Second}
+
+// Sends a request to Privy and returns its response whatever the status, the caller closes the body. newRequest builds the request
+// for each attempt with ctx, so a client cancelling its request aborts the Privy call. Idempotent calls are retried with jittered
+// backoff on transport errors and on 429, 502, 503 and 504, honoring Retry-After on a 429. Every attempt goes through the circuit
+// breaker, which fails fast with a 503 while Privy or the vsock proxy is down.
+func (cli *PrivyClient) doPrivyRequest(ctx context.Context, idempotent bool, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, *apierror.Error) {
+	attempts := 1
+	if idempotent {
+		attempts = privyMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := cli.breaker.Allow(); err != nil {
+			log.Errorf("Privy circuit breaker is open, failing fast")
+			return nil, apierror.Wrap(apierror.UpstreamUnavailable, "Privy is unavailable", err)
+		}
+
+		req, err := newRequest(ctx)
+		if err != nil {
+			cli.breaker.Cancel()
+			log.Errorf("Error creating request: %v", err)
+			return nil, apierror.InternalError(err)
+		}
+
+		res, err := cli.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				cli.breaker.Cancel()
+				return nil, contextError(ctx.Err())
+			}
+
+			cli.breaker.Failure()
+			log.Errorf("Error making request to privy, attempt %d of %d: %v", attempt, attempts, err)
+			if attempt >= attempts {
+				return nil, transportError(err)
+			}
+			if err := resilience.Sleep(ctx, privyBackoff.Delay(attempt)); err != nil {
+				return nil, contextError(err)
+			}
+			continue
+		}
+
+		if res.StatusCode >= http.StatusInternalServerError {
+			cli.breaker.Failure()
+		} else {
+			cli.breaker.Success()
+		}
+
+		if attempt >= attempts || !retryableStatus(res.StatusCode) {
+			return res, nil
+		}
+
+		wait := privyBackoff.Delay(attempt)
+		if res.StatusCode == http.StatusTooManyRequests {
+			if retryAfter, ok := resilience.ParseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				if retryAfter > privyMaxRetryAfter {
+					return res, nil
+				}
+				wait = retryAfter
+			}
+		}
+
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		log.Warnf("Privy answered %d, retrying in %v, attempt %d of %d", res.StatusCode, wait, attempt, attempts)
+
+		if err := resilience.Sleep(ctx, wait); err != nil {
+			return nil, contextError(err)
+		}
+	}
+}
+
+// Statuses worth another attempt, Privy is rate limiting or it or the proxy in front of it is briefly unavailable
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Maps a transport error talking to Privy, a timeout is a 504 and anything else means Privy or the vsock proxy is unreachable
+func transportError(err error) *apierror.Error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return apierror.Wrap(apierror.UpstreamTimeout, "Privy timed out", err)
+	}
+	return apierror.Wrap(apierror.UpstreamUnavailable, "Privy is unavailable", err)
+}
+
+// Maps the error of a request context that ended before Privy answered
+func contextError(err error) *apierror.Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apierror.Wrap(apierror.UpstreamTimeout, "Privy timed out", err)
+	}
+	return apierror.Wrap(apierror.RequestCancelled, "Request cancelled", err)
+}
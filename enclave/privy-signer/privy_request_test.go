@@ -0,0 +1,134 @@
+package privysigner
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/privytest"
+)
+
+func TestDoPrivyRequest_Retries(t *testing.T) {
+	unavailable := privytest.Fault{Code: http.StatusServiceUnavailable, Message: "Service unavailable."}
+
+	tests := []struct {
+		name         string
+		faults       []privytest.Fault
+		wantCode     int
+		wantRequests int
+	}{
+		{name: "no faults", wantRequests: 1},
+		{name: "recovers after retries", faults: []privytest.Fault{unavailable, unavailable}, wantRequests: 3},
+		{name: "gives up after the last attempt", faults: []privytest.Fault{unavailable, unavailable, unavailable}, wantCode: http.StatusServiceUnavailable, wantRequests: 3},
+		{
+			name:         "honors a short Retry-After",
+			faults:       []privytest.Fault{{Code: http.StatusTooManyRequests, Message: "Too many requests.", Header: http.Header{"Retry-After": {"0"}}}},
+			wantRequests: 2,
+		},
+		{
+			name:         "long Retry-After is returned to the caller",
+			faults:       []privytest.Fault{{Code: http.StatusTooManyRequests, Message: "Too many requests.", Header: http.Header{"Retry-After": {"60"}}}},
+			wantCode:     http.StatusTooManyRequests,
+			wantRequests: 1,
+		},
+		{name: "client errors are not retried", faults: []privytest.Fault{{Code: http.StatusBadRequest, Message: "Bad request."}}, wantCode: http.StatusBadRequest, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, server := newFakePrivyClient(t)
+			server.AddUser("did:privy:alice")
+			for _, fault := range tt.faults {
+				server.FailNext(privytest.GetUser, fault)
+			}
+
+			_, httpErr := cli.fetchUser(context.Background(), "did:privy:alice")
+			if tt.wantCode == 0 && httpErr != nil {
+				t.Errorf("fetchUser() error = %v", httpErr)
+			}
+			if tt.wantCode != 0 && (httpErr == nil || httpErr.Status() != tt.wantCode) {
+				t.Errorf("fetchUser() error = %v, want %d", httpErr, tt.wantCode)
+			}
+			if got := server.Requests(privytest.GetUser); got != tt.wantRequests {
+				t.Errorf("GetUser requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestDoPrivyRequest_SigningIsNotRetried(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+	server.FailNext(privytest.WalletRpc, privytest.Fault{Code: http.StatusServiceUnavailable, Message: "Service unavailable."})
+
+	var resp data.EthSecp256k1SignResponse
+	httpErr := cli.executePrivySigningRequest(context.Background(), *data.NewUserEthSecp256k1SignRequest("0x" + strings.Repeat("42", 32)), "did:privy:alice", &resp)
+	if httpErr == nil || httpErr.Status() != http.StatusServiceUnavailable {
+		t.Errorf("executePrivySigningRequest() error = %v, want 503", httpErr)
+	}
+	if got := server.Requests(privytest.WalletRpc); got != 1 {
+		t.Errorf("WalletRpc requests = %d, want 1", got)
+	}
+}
+
+func TestDoPrivyRequest_CircuitBreaker(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+
+	for i := 0; i < privyBreakerThreshold; i++ {
+		server.FailNext(privytest.GetUser, privytest.Fault{Code: http.StatusInternalServerError, Message: "Internal error."})
+		if _, httpErr := cli.fetchUser(context.Background(), "did:privy:alice"); httpErr == nil || httpErr.Status() != http.StatusBadGateway {
+			t.Fatalf("fetchUser() error = %v, want 502 for the privy 500", httpErr)
+		}
+	}
+
+	// The breaker is open, Privy is not called until the cooldown is over
+	_, httpErr := cli.fetchUser(context.Background(), "did:privy:alice")
+	if httpErr == nil || httpErr.Status() != http.StatusServiceUnavailable {
+		t.Errorf("fetchUser() error = %v, want 503 from the open breaker", httpErr)
+	}
+	if got := server.Requests(privytest.GetUser); got != privyBreakerThreshold {
+		t.Errorf("GetUser requests = %d, want %d", got, privyBreakerThreshold)
+	}
+}
+
+func TestDoPrivyRequest_Unreachable(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.Close()
+
+	_, httpErr := cli.fetchUser(context.Background(), "did:privy:alice")
+	if httpErr == nil || httpErr.Status() != http.StatusServiceUnavailable {
+		t.Errorf("fetchUser() error = %v, want 503", httpErr)
+	}
+}
+
+func TestDoPrivyRequest_ClientCancellation(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+	if _, httpErr := cli.GetUser(context.Background(), "did:privy:alice"); httpErr != nil {
+		t.Fatalf("GetUser() error = %v", httpErr)
+	}
+	server.SetLatency(privytest.WalletRpc, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var resp data.EthSecp256k1SignResponse
+	httpErr := cli.executePrivySigningRequest(ctx, *data.NewUserEthSecp256k1SignRequest("0x" + strings.Repeat("42", 32)), "did:privy:alice", &resp)
+	if httpErr == nil || httpErr.Status() != http.StatusGatewayTimeout {
+		t.Errorf("executePrivySigningRequest() error = %v, want 504", httpErr)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("executePrivySigningRequest() took %v, want it aborted with the context", elapsed)
+	}
+
+	// A cancelled request says nothing about Privy
+	if err := cli.breaker.Allow(); err != nil {
+		t.Errorf("breaker.Allow() error = %v after a cancelled request", err)
+	}
+	cli.breaker.Cancel()
+}
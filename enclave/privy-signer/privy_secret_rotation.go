@@ -0,0 +1,68 @@
+package privysigner
+
+import (
+	"context"
+
+	secretmanager "github.com/getaxal/verified-signer/common/aws/secret_manager"
+	"github.com/getaxal/verified-signer/enclave"
+	log "github.com/sirupsen/logrus"
+)
+
+// Subscribes the privy credentials, JWT verification key and HMAC key to rotations of their secrets
+func (cli *PrivyClient) subscribeToSecretRotation(cache *secretmanager.SecretCache) error {
+	privyRef, err := cli.teeConfig.PrivySecretRef()
+	if err != nil {
+		return err
+	}
+
+	if err := cache.Subscribe(context.Background(), privyRef, cli.onPrivySecretRotated); err != nil {
+		return err
+	}
+
+	// Axal config is only loaded from Secrets Manager outside of local
+	if cli.teeConfig.Environment == "local" {
+		return nil
+	}
+
+	return cache.Subscribe(context.Background(), cli.teeConfig.AxalSecretRef(), cli.onAxalSecretRotated)
+}
+
+// Switches Privy API calls to the new credentials. Incoming JWTs signed with the old verification key are accepted for the
+// rest of the grace window.
+func (cli *PrivyClient) onPrivySecretRotated(update secretmanager.SecretUpdate) {
+	privyConfig, err := cli.teeConfig.DecodePrivySecret(update.Current)
+	if err != nil {
+		log.Errorf("Ignoring rotated privy secret version %s: %v", update.Current.VersionId, err)
+		return
+	}
+
+	authorizationKeys := [][]byte{[]byte(privyConfig.DelegatedActionsKey)}
+	for _, key := range privyConfig.AuthorizationKeys {
+		authorizationKeys = append(authorizationKeys, []byte(key))
+	}
+
+	if err := cli.setPrivyCredentials(*privyConfig, []byte(privyConfig.AppSecret), authorizationKeys); err != nil {
+		log.Errorf("Ignoring rotated privy secret version %s: %v", update.Current.VersionId, err)
+		return
+	}
+	enclave.SealPrivySecrets(cli.keys, privyConfig)
+	cli.jwtKeys.Rotate(privyConfig.JWTVerificationKey, update.PreviousValidUntil)
+	log.Infof("Rotated privy credentials to version %s", update.Current.VersionId)
+}
+
+// Adds the new HMAC key, requests signed with the old key are accepted for the rest of the grace window
+func (cli *PrivyClient) onAxalSecretRotated(update secretmanager.SecretUpdate) {
+	axalConfig, err := cli.teeConfig.DecodeAxalSecret(update.Current)
+	if err != nil {
+		log.Errorf("Ignoring rotated axal secret version %s: %v", update.Current.VersionId, err)
+		return
+	}
+
+	if axalConfig.AxalRequestSecretKey == "" {
+		log.Errorf("Ignoring rotated axal secret version %s without a request secret key", update.Current.VersionId)
+		return
+	}
+
+	enclave.SealAxalSecrets(cli.keys, axalConfig, update.PreviousValidUntil)
+	log.Infof("Rotated axal request secret key to version %s", update.Current.VersionId)
+}
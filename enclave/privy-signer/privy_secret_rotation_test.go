@@ -0,0 +1,208 @@
+package privysigner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	secretmanager "github.com/getaxal/verified-signer/common/aws/secret_manager"
+	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/keystore"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/auth"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/resilience"
+)
+
+func hmacHex(payload string, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Generates a privy authorization key in its wallet-auth: format
+func newTestAuthorizationKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return key, "wallet-auth:" + base64.StdEncoding.EncodeToString(pkcs8)
+}
+
+func basicAuthorization(t *testing.T, keys *keystore.KeyStore) string {
+	t.Helper()
+
+	var authorization string
+	if err := keys.WithSecret(keystore.PrivyBasicAuthorization, func(secret []byte) error {
+		authorization = string(secret)
+		return nil
+	}); err != nil {
+		t.Fatalf("basic authorization missing from the key store: %v", err)
+	}
+	return authorization
+}
+
+func newRotationTestClient(t *testing.T) *PrivyClient {
+	t.Helper()
+
+	_, delegatedActionsKey := newTestAuthorizationKey(t)
+	cfg := &enclave.TEEConfig{
+		Environment: "dev",
+		Axal:        enclave.AxalConfig{AxalRequestSecretKey: "old-hmac-key"},
+		Privy: enclave.PrivyConfig{
+			AppID:               "app-id",
+			AppSecret:           "old-secret",
+			DelegatedActionsKey: delegatedActionsKey,
+			JWTVerificationKey:  "old-jwt-key",
+		},
+	}
+
+	keys := keystore.New()
+	enclave.SealAxalSecrets(keys, &cfg.Axal, time.Time{})
+	enclave.SealPrivySecrets(keys, &cfg.Privy)
+
+	cli := &PrivyClient{
+		teeConfig: cfg,
+		keys:      keys,
+		breaker:   resilience.NewCircuitBreaker(privyBreakerThreshold, privyBreakerCooldown),
+		jwtKeys:   auth.NewKeyring(cfg.Privy.JWTVerificationKey),
+	}
+	if err := cli.loadPrivyCredentials(cfg.Privy); err != nil {
+		t.Fatalf("loadPrivyCredentials() error = %v", err)
+	}
+	return cli
+}
+
+func TestOnAxalSecretRotated(t *testing.T) {
+	cli := newRotationTestClient(t)
+	signReq := &data.AxalEthSecp256k1SignRequest{PrivyID: "did:privy:user1"}
+	signReq.Params.Hash = "0xabc"
+
+	cli.onAxalSecretRotated(secretmanager.SecretUpdate{
+		Current:            secretmanager.Secret{Name: "dev/axal", VersionId: "v2", Value: `{"axal_request_secret_key":"new-hmac-key"}`},
+		PreviousValidUntil: time.Now().Add(time.Hour),
+	})
+
+	for _, key := range []string{"new-hmac-key", "old-hmac-key"} {
+		if _, httpErr := cli.ValidateAxalAuthForSigningRequest(hmacHex(signReq.Params.Hash, key), signReq); httpErr != nil {
+			t.Errorf("ValidateAxalAuthForSigningRequest() with %s inside the grace window error = %v", key, httpErr)
+		}
+	}
+
+	// A broken version is ignored and keeps the current keys
+	cli.onAxalSecretRotated(secretmanager.SecretUpdate{
+		Current:            secretmanager.Secret{Name: "dev/axal", VersionId: "v3", Value: `{}`},
+		PreviousValidUntil: time.Now().Add(time.Hour),
+	})
+	if _, httpErr := cli.ValidateAxalAuthForSigningRequest(hmacHex(signReq.Params.Hash, "new-hmac-key"), signReq); httpErr != nil {
+		t.Errorf("hmac key was replaced by a broken version: %v", httpErr)
+	}
+}
+
+func TestOnPrivySecretRotated(t *testing.T) {
+	cli := newRotationTestClient(t)
+	oldAuthorization := basicAuthorization(t, cli.keys)
+
+	newKey, newDelegatedActionsKey := newTestAuthorizationKey(t)
+	cli.onPrivySecretRotated(secretmanager.SecretUpdate{
+		Current: secretmanager.Secret{
+			Name:      "dev/privy",
+			VersionId: "v2",
+			Value:     `{"app_id":"app-id","app_secret":"new-secret","delegated_actions_key":"` + newDelegatedActionsKey + `","jwt_verification_key":"new-jwt-key","key_id":"key-id"}`,
+		},
+		PreviousValidUntil: time.Now().Add(time.Hour),
+	})
+
+	privyConfig := cli.getPrivyConfig()
+	if privyConfig.DelegatedActionsKeyId != "key-id" || privyConfig.AppSecret != "" || privyConfig.DelegatedActionsKey != "" {
+		t.Errorf("privy config = %+v, want the rotated config without its secrets", privyConfig)
+	}
+
+	authorization := basicAuthorization(t, cli.keys)
+	if authorization == oldAuthorization || authorization != base64.StdEncoding.EncodeToString([]byte("app-id:new-secret")) {
+		t.Errorf("basic authorization = %s, want it rebuilt from the new app secret", authorization)
+	}
+
+	publicKey, err := cli.keys.ECDSAPublicKey(keystore.PrivyAuthorizationKey)
+	if err != nil || !publicKey.Equal(&newKey.PublicKey) {
+		t.Errorf("authorization key = %v, %v, want the rotated delegated actions key", publicKey, err)
+	}
+
+	if signingKeys := cli.getSigningKeys(); len(signingKeys) != 1 || signingKeys[0] != keystore.PrivyAuthorizationKey {
+		t.Errorf("signing keys = %v, want only the delegated actions key", signingKeys)
+	}
+
+	keys := cli.jwtKeys.Keys()
+	if len(keys) != 2 || keys[0] != "new-jwt-key" || keys[1] != "old-jwt-key" {
+		t.Errorf("jwt keys = %v, want the new key and the old one in its grace window", keys)
+	}
+
+	// Versions missing required fields or with an unparseable delegated actions key are ignored
+	for _, value := range []string{
+		`{"app_id":"app-id"}`,
+		`{"app_id":"app-id","app_secret":"bad-secret","delegated_actions_key":"not-a-key","jwt_verification_key":"bad-jwt-key"}`,
+	} {
+		cli.onPrivySecretRotated(secretmanager.SecretUpdate{
+			Current: secretmanager.Secret{Name: "dev/privy", VersionId: "v3", Value: value},
+		})
+	}
+	if basicAuthorization(t, cli.keys) != authorization {
+		t.Error("an invalid privy secret version replaced the credentials")
+	}
+	if publicKey, _ := cli.keys.ECDSAPublicKey(keystore.PrivyAuthorizationKey); !publicKey.Equal(&newKey.PublicKey) {
+		t.Error("an invalid privy secret version replaced the authorization key")
+	}
+}
+
+func TestOnPrivySecretRotated_KeyQuorum(t *testing.T) {
+	cli := newRotationTestClient(t)
+
+	_, delegatedActionsKey := newTestAuthorizationKey(t)
+	quorumKey, authorizationKey := newTestAuthorizationKey(t)
+	cli.onPrivySecretRotated(secretmanager.SecretUpdate{
+		Current: secretmanager.Secret{
+			Name:      "dev/privy",
+			VersionId: "v2",
+			Value: `{"app_id":"app-id","app_secret":"secret","delegated_actions_key":"` + delegatedActionsKey + `",` +
+				`"authorization_keys":["` + authorizationKey + `"],"key_quorum_id":"quorum-id","jwt_verification_key":"jwt-key"}`,
+		},
+	})
+
+	signingKeys := cli.getSigningKeys()
+	if len(signingKeys) != 2 || signingKeys[1] != keystore.PrivyQuorumAuthorizationKey(0) {
+		t.Fatalf("signing keys = %v, want the delegated actions key and the quorum key", signingKeys)
+	}
+	if publicKey, err := cli.keys.ECDSAPublicKey(signingKeys[1]); err != nil || !publicKey.Equal(&quorumKey.PublicKey) {
+		t.Errorf("quorum key = %v, %v, want the rotated authorization key", publicKey, err)
+	}
+	if privyConfig := cli.getPrivyConfig(); privyConfig.AdditionalSignerId() != "quorum-id" || privyConfig.AuthorizationKeys != nil {
+		t.Errorf("privy config = %+v, want the key quorum without its keys", privyConfig)
+	}
+
+	// Dropping the quorum removes its keys
+	cli.onPrivySecretRotated(secretmanager.SecretUpdate{
+		Current: secretmanager.Secret{
+			Name:      "dev/privy",
+			VersionId: "v3",
+			Value:     `{"app_id":"app-id","app_secret":"secret","delegated_actions_key":"` + delegatedActionsKey + `","jwt_verification_key":"jwt-key"}`,
+		},
+	})
+	if signingKeys := cli.getSigningKeys(); len(signingKeys) != 1 {
+		t.Errorf("signing keys = %v, want only the delegated actions key", signingKeys)
+	}
+	if cli.keys.Has(keystore.PrivyQuorumKey(0)) || cli.keys.Has(keystore.PrivyQuorumAuthorizationKey(0)) {
+		t.Error("the dropped quorum key is still in the key store")
+	}
+}
@@ -2,13 +2,14 @@ package privysigner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/getaxal/verified-signer/enclave/apierror"
 	authorizationsignature "github.com/getaxal/verified-signer/enclave/privy-signer/authorization_signature"
-	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,9 +20,9 @@ import (
 //	    "privy-app_id" : "your-app-id"
 //	    "authorization" : "privy-app-id:privy-app-secret" //base64 encoded
 //		"Content-Type" : "application/json"
-//		"privy-authorization-signature" : "your-auth-signature" //get it using authorizationsignature.GetAuthorizationSignature
+//		"privy-authorization-signature" : "your-auth-signature" //get it using authorizationsignature.GetAuthorizationSignature, comma separated for a key quorum
 //	}
-func (cli *PrivyClient) prepSigningTxRequest(body interface{}, walletId string) (*http.Request, error) {
+func (cli *PrivyClient) prepSigningTxRequest(ctx context.Context, body interface{}, walletId string) (*http.Request, error) {
 	// format url
 	url := fmt.Sprintf("%s%s", cli.baseUrl, SIGN_TX_PATH.Build(walletId))
 
@@ -33,7 +34,7 @@ func (cli *PrivyClient) prepSigningTxRequest(body interface{}, walletId string)
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 
 	if err != nil {
 		log.Errorf("Error creating request: %v", err)
@@ -41,10 +42,14 @@ func (cli *PrivyClient) prepSigningTxRequest(body interface{}, walletId string)
 	}
 
 	// Add basic headers
-	cli.addStandardPrivyHeaders(req)
+	if err := cli.addStandardPrivyHeaders(req); err != nil {
+		log.Errorf("Error adding privy headers: %v", err)
+		return nil, err
+	}
 
 	// Add auth signature header
-	signature, err := authorizationsignature.GetAuthorizationSignature(body, req.Method, cli.teeConfig.Privy.DelegatedActionsKey, url, cli.teeConfig.Privy.AppID)
+	privyConfig := cli.getPrivyConfig()
+	signature, err := authorizationsignature.GetAuthorizationSignature(body, req.Method, cli.keys, cli.getSigningKeys(), url, privyConfig.AppID)
 	if err != nil {
 		log.Errorf("Error getting authorization signature: %v", err)
 		return nil, err
@@ -55,10 +60,11 @@ func (cli *PrivyClient) prepSigningTxRequest(body interface{}, walletId string)
 	return req, nil
 }
 
-// Generic function to handle HTTP requests and responses for signing requests
-func (cli *PrivyClient) executePrivySigningRequest(txRequest interface{}, privyId string, response interface{}) *data.HttpError {
+// Generic function to handle HTTP requests and responses for signing requests. Signing requests are not retried, a signature Privy
+// made for a request that timed out is not handed out twice.
+func (cli *PrivyClient) executePrivySigningRequest(ctx context.Context, txRequest interface{}, privyId string, response interface{}) *apierror.Error {
 	// Fetch the wallet id by fetching user
-	user, httpErr := cli.GetUser(privyId)
+	user, httpErr := cli.GetUser(ctx, privyId)
 	if httpErr != nil {
 		return httpErr
 	}
@@ -66,31 +72,21 @@ func (cli *PrivyClient) executePrivySigningRequest(txRequest interface{}, privyI
 	ethWallet := user.GetUsersEthDelegatedWallet()
 	if ethWallet == nil || ethWallet.WalletID == "" {
 		log.Errorf("Eth secp256k1 sign API error user %s does not have a delegated eth wallet", user.PrivyID)
-		return &data.HttpError{
-			Code: http.StatusBadRequest,
-			Message: data.Message{
-				Message: "user does not have an delegated eth wallet",
-			},
-		}
-	}
-
-	req, err := cli.prepSigningTxRequest(txRequest, ethWallet.WalletID)
-	if err != nil {
-		log.Errorf("Error initiating signing request: %v", err)
-		return cli.createInternalServerError()
+		return apierror.New(apierror.WalletMissing, "user does not have an delegated eth wallet")
 	}
 
-	res, err := cli.client.Do(req)
-	if err != nil {
-		log.Errorf("Error making request: %v", err)
-		return cli.createInternalServerError()
+	res, httpErr := cli.doPrivyRequest(ctx, false, func(ctx context.Context) (*http.Request, error) {
+		return cli.prepSigningTxRequest(ctx, txRequest, ethWallet.WalletID)
+	})
+	if httpErr != nil {
+		return httpErr
 	}
 	defer res.Body.Close()
 
 	// Check status code
 	if res.StatusCode != http.StatusOK {
 		log.Errorf("Received status code %d", res.StatusCode)
-		httpErr := handlePrivyError(res)
+		httpErr := privyError(res)
 		return httpErr
 	}
 
@@ -98,14 +94,14 @@ func (cli *PrivyClient) executePrivySigningRequest(txRequest interface{}, privyI
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		log.Errorf("Error reading response body: %v", err)
-		return cli.createInternalServerError()
+		return apierror.InternalError(err)
 	}
 
 	// Unmarshal response
 	err = json.Unmarshal(body, response)
 	if err != nil {
 		log.Errorf("Error unmarshalling response body: %v", err)
-		return cli.createInternalServerError()
+		return apierror.InternalError(err)
 	}
 
 	return nil
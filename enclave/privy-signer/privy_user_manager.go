@@ -2,56 +2,70 @@ package privysigner
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/getaxal/verified-signer/enclave/apierror"
 	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
 	"github.com/jellydator/ttlcache/v3"
 	log "github.com/sirupsen/logrus"
 )
 
+// Result of a user lookup shared by the concurrent lookups of the same user
+type userResult struct {
+	user    *data.PrivyUser
+	httpErr *apierror.Error
+}
+
 // Gets a user given a Privy userID. It also checks to see if the user already has a delagted eth wallet, if it does not it will create one for them.
-func (cli *PrivyClient) GetUser(privyId string) (*data.PrivyUser, *data.HttpError) {
+// Concurrent lookups of the same user share one fetch, so a new user does not get a wallet per request.
+func (cli *PrivyClient) GetUser(ctx context.Context, privyId string) (*data.PrivyUser, *apierror.Error) {
 	if item := cli.userCache.Get(privyId); item != nil {
 		log.Infof("Cache Hit: %s", privyId)
 		value := item.Value()
 		return &value, nil
 	}
 
-	url := fmt.Sprintf("%s%s", cli.baseUrl, GET_USER_PATH.Build(privyId))
-
-	req, err := http.NewRequest("GET", url, nil)
+	return cli.coalesceUser(ctx, privyId, func(ctx context.Context) userResult {
+		user, httpErr := cli.fetchUser(ctx, privyId)
+		if httpErr != nil {
+			return userResult{httpErr: httpErr}
+		}
 
-	if err != nil {
-		log.Errorf("Error creating request: %v", err)
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
+		userWithWallet, httpErr := cli.createUserWalletsIfNotExists(ctx, *user, privyId)
+		if httpErr != nil {
+			return userResult{httpErr: httpErr}
 		}
-	}
 
-	cli.addStandardPrivyHeaders(req)
+		cli.userCache.Set(privyId, *userWithWallet, ttlcache.DefaultTTL)
+		return userResult{user: userWithWallet}
+	})
+}
 
-	res, err := cli.client.Do(req)
-	if err != nil {
-		log.Errorf("Error making request: %v", err)
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
+// Fetches a user from Privy, bypassing the user cache
+func (cli *PrivyClient) fetchUser(ctx context.Context, privyId string) (*data.PrivyUser, *apierror.Error) {
+	url := fmt.Sprintf("%s%s", cli.baseUrl, GET_USER_PATH.Build(privyId))
+
+	res, httpErr := cli.doPrivyRequest(ctx, true, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
 		}
+		return req, cli.addStandardPrivyHeaders(req)
+	})
+	if httpErr != nil {
+		return nil, httpErr
 	}
 
 	defer res.Body.Close()
 
 	// Check status code
 	if res.StatusCode != http.StatusOK {
-		httpErr := handlePrivyError(res)
+		httpErr := privyError(res)
 		return nil, httpErr
 	}
 
@@ -59,37 +73,110 @@ func (cli *PrivyClient) GetUser(privyId string) (*data.PrivyUser, *data.HttpErro
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		log.Errorf("Error reading response body: %v", err)
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
-		}
+		return nil, apierror.InternalError(err)
 	}
 
 	var user data.PrivyUser
 	if err := json.Unmarshal(body, &user); err != nil {
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
+		return nil, apierror.InternalError(err)
+	}
+
+	return &user, nil
+}
+
+// Creates a delegated eth wallet for the user unless they already have one. It shares the wallet creation of a concurrent
+// lookup of the same user.
+func (cli *PrivyClient) CreateEthWallet(ctx context.Context, user data.PrivyUser) (*data.PrivyUser, *apierror.Error) {
+	return cli.coalesceUser(ctx, user.PrivyID, func(ctx context.Context) userResult {
+		userWithWallet, httpErr := cli.createUserWalletsIfNotExists(ctx, user, user.PrivyID)
+		return userResult{user: userWithWallet, httpErr: httpErr}
+	})
+}
+
+// A lookup of a user shared by its concurrent callers
+type userCall struct {
+	done    chan struct{}
+	result  userResult
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Runs fn once for the concurrent callers of the same user. One caller going away does not fail the others, fn is only cancelled
+// once every caller has stopped waiting for it. A caller arriving after that starts a new call.
+func (cli *PrivyClient) coalesceUser(ctx context.Context, privyId string, fn func(ctx context.Context) userResult) (*data.PrivyUser, *apierror.Error) {
+	cli.userCallsMu.Lock()
+	if cli.userCalls == nil {
+		cli.userCalls = make(map[string]*userCall)
+	}
+	call, ok := cli.userCalls[privyId]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &userCall{done: make(chan struct{}), cancel: cancel}
+		cli.userCalls[privyId] = call
+
+		go func() {
+			call.result = fn(callCtx)
+			cli.forgetUserCall(privyId, call)
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	cli.userCallsMu.Unlock()
+
+	select {
+	case <-call.done:
+		return copyUserResult(call.result)
+	case <-ctx.Done():
+		cli.userCallsMu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			log.Infof("Every lookup of user %s went away, cancelling it", privyId)
+			call.cancel()
+			cli.forgetUserCallLocked(privyId, call)
 		}
+		cli.userCallsMu.Unlock()
+		return nil, contextError(ctx.Err())
 	}
+}
 
-	userWithWallet, httpErr := cli.createUserWalletsIfNotExists(user, privyId)
+func (cli *PrivyClient) forgetUserCall(privyId string, call *userCall) {
+	cli.userCallsMu.Lock()
+	defer cli.userCallsMu.Unlock()
 
-	if httpErr != nil {
-		return nil, httpErr
+	cli.forgetUserCallLocked(privyId, call)
+}
+
+// Removes call unless a newer call of the user replaced it, userCallsMu must be held
+func (cli *PrivyClient) forgetUserCallLocked(privyId string, call *userCall) {
+	if cli.userCalls[privyId] == call {
+		delete(cli.userCalls, privyId)
 	}
+}
 
-	cli.userCache.Set(privyId, *userWithWallet, ttlcache.DefaultTTL)
+// Each caller gets its own copy of a shared user
+func copyUserResult(result userResult) (*data.PrivyUser, *apierror.Error) {
+	if result.httpErr != nil {
+		return nil, result.httpErr
+	}
+
+	user := *result.user
+	user.LinkedAccounts = append([]data.LinkedAccount(nil), result.user.LinkedAccounts...)
+	return &user, nil
+}
 
-	return userWithWallet, nil
+// Idempotency key of the eth wallet creation for a user. It is the same on every enclave so Privy creates one wallet however many
+// times the creation is retried, and covers the request body so a changed signer or policies is a new request and not a key reuse.
+func createEthWalletIdempotencyKey(privyId string, requestBody []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(privyId))
+	hash.Write([]byte{0})
+	hash.Write(requestBody)
+	return fmt.Sprintf("create-eth-wallet-%x", hash.Sum(nil))
 }
 
 // Checks to see if a user has a delegated eth wallet, if the user does not it will create one for them
-func (cli *PrivyClient) createUserWalletsIfNotExists(user data.PrivyUser, userId string) (*data.PrivyUser, *data.HttpError) {
+func (cli *PrivyClient) createUserWalletsIfNotExists(ctx context.Context, user data.PrivyUser, userId string) (*data.PrivyUser, *apierror.Error) {
 	if user.GetUsersEthDelegatedWallet() != nil {
 		log.Infof("User %s has a linked address", userId)
 		return &user, nil
@@ -99,51 +186,34 @@ func (cli *PrivyClient) createUserWalletsIfNotExists(user data.PrivyUser, userId
 
 	url := fmt.Sprintf("%s%s", cli.baseUrl, CREATE_WALLET_PATH.Build(userId))
 
-	walletCreateReq := data.NewCreateEthWalletRequest(cli.teeConfig.Privy.DelegatedActionsKeyId)
+	privyConfig := cli.getPrivyConfig()
+	walletCreateReq := data.NewCreateEthWalletRequest(privyConfig.AdditionalSignerId(), cli.teeConfig.Policies.IDs...)
 
 	requestBody, err := json.Marshal(walletCreateReq)
 
 	if err != nil {
 		log.Errorf("failed to marshal wallet create request: %v", err)
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
-		}
+		return nil, apierror.InternalError(err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-
-	if err != nil {
-		log.Errorf("failed to create request: %v", err)
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
-		}
-	}
-
-	cli.addStandardPrivyHeaders(req)
-
-	res, err := cli.client.Do(req)
-
-	if err != nil {
-		log.Errorf("error sending the client request: %v", err)
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
+	// The idempotency key makes the creation safe to retry
+	res, httpErr := cli.doPrivyRequest(ctx, true, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+		if err != nil {
+			return nil, err
 		}
+		req.Header.Add("privy-idempotency-key", createEthWalletIdempotencyKey(userId, requestBody))
+		return req, cli.addStandardPrivyHeaders(req)
+	})
+	if httpErr != nil {
+		return nil, httpErr
 	}
 
 	defer res.Body.Close()
 
 	// Check status code
 	if res.StatusCode != http.StatusOK {
-		httpErr := handlePrivyError(res)
+		httpErr := privyError(res)
 		return nil, httpErr
 	}
 
@@ -151,23 +221,20 @@ func (cli *PrivyClient) createUserWalletsIfNotExists(user data.PrivyUser, userId
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		log.Errorf("Error reading response body: %v", err)
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
-		}
+		return nil, apierror.InternalError(err)
 	}
 
 	var createWalletResp data.CreateWalletResponse
 	if err := json.Unmarshal(body, &createWalletResp); err != nil {
 		log.Errorf("unable to marshall response data:%v", string(body))
-		return nil, &data.HttpError{
-			Code: 500,
-			Message: data.Message{
-				Message: "Internal Server Error",
-			},
-		}
+		return nil, apierror.InternalError(err)
+	}
+
+	// Another enclave may have created a wallet for the user at the same time, the user as Privy has it now decides which wallet is
+	// the users so every enclave settles on the same one
+	if reread, httpErr := cli.fetchUser(ctx, userId); httpErr == nil && reread.GetUsersEthDelegatedWallet() != nil {
+		reconcileEthDelegatedWallets(reread)
+		return reread, nil
 	}
 
 	// We check the response for the delegated eth wallet and then we add it to the user
@@ -178,10 +245,15 @@ func (cli *PrivyClient) createUserWalletsIfNotExists(user data.PrivyUser, userId
 		}
 	}
 
-	return nil, &data.HttpError{
-		Code: 500,
-		Message: data.Message{
-			Message: "Internal Server Error",
-		},
+	return nil, apierror.InternalError(fmt.Errorf("privy created no delegated eth wallet for user %s", userId))
+}
+
+// Logs duplicate delegated eth wallets of a user. The first one Privy lists stays the users wallet, the others are left unused.
+func reconcileEthDelegatedWallets(user *data.PrivyUser) {
+	wallet := user.GetUsersEthDelegatedWallet()
+	for _, acc := range user.LinkedAccounts {
+		if acc.Delegated && acc.ChainType == "ethereum" && acc.WalletID != wallet.WalletID {
+			log.Warnf("User %s has a duplicate delegated eth wallet %s, using %s", user.PrivyID, acc.WalletID, wallet.WalletID)
+		}
 	}
 }
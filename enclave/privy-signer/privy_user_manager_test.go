@@ -0,0 +1,219 @@
+package privysigner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/privytest"
+)
+
+func delegatedEthWallets(user data.PrivyUser) int {
+	count := 0
+	for _, acc := range user.LinkedAccounts {
+		if acc.Delegated && acc.ChainType == "ethereum" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGetUser_ConcurrentLookupsCreateOneWallet(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+
+	// Keep the first lookup in flight while the others arrive
+	server.SetLatency(privytest.GetUser, 20*time.Millisecond)
+
+	const lookups = 50
+	addresses := make([]string, lookups)
+	var wg sync.WaitGroup
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			user, httpErr := cli.GetUser(context.Background(), "did:privy:alice")
+			if httpErr != nil {
+				t.Errorf("GetUser() error = %v", httpErr)
+				return
+			}
+			addresses[i] = user.GetUsersEthDelegatedWallet().Address
+		}(i)
+	}
+	wg.Wait()
+
+	stored, _ := server.User("did:privy:alice")
+	if got := delegatedEthWallets(stored); got != 1 {
+		t.Fatalf("privy has %d delegated eth wallets, want 1", got)
+	}
+	if got := server.Requests(privytest.CreateWallet); got != 1 {
+		t.Errorf("CreateWallet requests = %d, want 1", got)
+	}
+	for i, address := range addresses {
+		if address != stored.LinkedAccounts[0].Address {
+			t.Errorf("lookup %d got wallet %s, want %s", i, address, stored.LinkedAccounts[0].Address)
+		}
+	}
+}
+
+func TestCreateUserWallets_IdempotencyKey(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+
+	// Both creations start from a user without a wallet, like two enclaves that looked the user up at the same time
+	stale := data.PrivyUser{PrivyID: "did:privy:alice"}
+	first, httpErr := cli.createUserWalletsIfNotExists(context.Background(), stale, stale.PrivyID)
+	if httpErr != nil {
+		t.Fatalf("createUserWalletsIfNotExists() error = %v", httpErr)
+	}
+	second, httpErr := cli.createUserWalletsIfNotExists(context.Background(), stale, stale.PrivyID)
+	if httpErr != nil {
+		t.Fatalf("createUserWalletsIfNotExists() error = %v", httpErr)
+	}
+
+	stored, _ := server.User("did:privy:alice")
+	if got := delegatedEthWallets(stored); got != 1 {
+		t.Errorf("privy has %d delegated eth wallets, want 1", got)
+	}
+	if first.GetUsersEthDelegatedWallet().WalletID != second.GetUsersEthDelegatedWallet().WalletID {
+		t.Error("the retried creation returned a different wallet")
+	}
+}
+
+func TestCreateEthWalletIdempotencyKey(t *testing.T) {
+	body := []byte(`{"wallets":[{"chain_type":"ethereum","additional_signers":[{"signer_id":"key-id","override_policy_ids":["policy-1"]}]}]}`)
+	key := createEthWalletIdempotencyKey("did:privy:alice", body)
+
+	if got := createEthWalletIdempotencyKey("did:privy:alice", body); got != key {
+		t.Errorf("createEthWalletIdempotencyKey() = %s for the same request, want %s", got, key)
+	}
+	if got := createEthWalletIdempotencyKey("did:privy:bob", body); got == key {
+		t.Error("createEthWalletIdempotencyKey() is the same for another user")
+	}
+
+	// New policies make a new request Privy must not answer with the old wallet creation
+	changed := []byte(`{"wallets":[{"chain_type":"ethereum","additional_signers":[{"signer_id":"key-id","override_policy_ids":["policy-2"]}]}]}`)
+	if got := createEthWalletIdempotencyKey("did:privy:alice", changed); got == key {
+		t.Error("createEthWalletIdempotencyKey() is the same after the policies changed")
+	}
+}
+
+func TestCreateUserWallets_ReconcilesDuplicates(t *testing.T) {
+	cli, server := newFakePrivyClient(t)
+	server.AddUser("did:privy:alice")
+	existing := server.AddWallet("did:privy:alice")
+
+	// The user was read before the other wallet was created, so a second wallet gets created
+	user, httpErr := cli.createUserWalletsIfNotExists(context.Background(), data.PrivyUser{PrivyID: "did:privy:alice"}, "did:privy:alice")
+	if httpErr != nil {
+		t.Fatalf("createUserWalletsIfNotExists() error = %v", httpErr)
+	}
+
+	if got := user.GetUsersEthDelegatedWallet().WalletID; got != existing.WalletID {
+		t.Errorf("wallet = %s, want the first wallet %s", got, existing.WalletID)
+	}
+	if got := delegatedEthWallets(*user); got != 2 {
+		t.Errorf("user has %d delegated eth wallets, want both as Privy has them", got)
+	}
+}
+
+func TestCoalesceUser_CancelledOnceEveryCallerLeft(t *testing.T) {
+	cli := &PrivyClient{}
+	started := make(chan context.Context, 1)
+	finish := make(chan struct{})
+	fn := func(ctx context.Context) userResult {
+		started <- ctx
+		select {
+		case <-finish:
+			return userResult{user: &data.PrivyUser{PrivyID: "did:privy:alice"}}
+		case <-ctx.Done():
+			return userResult{httpErr: contextError(ctx.Err())}
+		}
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+
+	firstDone := make(chan *apierror.Error, 1)
+	go func() {
+		_, httpErr := cli.coalesceUser(firstCtx, "did:privy:alice", fn)
+		firstDone <- httpErr
+	}()
+	callCtx := <-started
+
+	secondDone := make(chan *data.PrivyUser, 1)
+	go func() {
+		user, _ := cli.coalesceUser(secondCtx, "did:privy:alice", fn)
+		secondDone <- user
+	}()
+	waitForWaiters(t, cli, "did:privy:alice", 2)
+
+	// One caller leaving does not cancel the call the other one waits for
+	cancelFirst()
+	if httpErr := <-firstDone; httpErr == nil || httpErr.Code != apierror.RequestCancelled {
+		t.Fatalf("coalesceUser() error = %v, want %s", httpErr, apierror.RequestCancelled)
+	}
+	if callCtx.Err() != nil {
+		t.Fatal("shared call was cancelled while a caller still waits for it")
+	}
+	close(finish)
+	if user := <-secondDone; user == nil || user.PrivyID != "did:privy:alice" {
+		t.Fatalf("coalesceUser() = %v, want the shared user", user)
+	}
+
+	// The last caller leaving cancels the call
+	finish = make(chan struct{})
+	thirdCtx, cancelThird := context.WithCancel(context.Background())
+	thirdDone := make(chan struct{})
+	go func() {
+		cli.coalesceUser(thirdCtx, "did:privy:alice", fn)
+		close(thirdDone)
+	}()
+	callCtx = <-started
+	cancelThird()
+	<-thirdDone
+
+	select {
+	case <-callCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("shared call was not cancelled after every caller left")
+	}
+
+	// A new caller starts a new call instead of joining the cancelled one
+	go cli.coalesceUser(context.Background(), "did:privy:alice", fn)
+	select {
+	case ctx := <-started:
+		if ctx.Err() != nil {
+			t.Error("new call started with a cancelled context")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("new caller joined the cancelled call")
+	}
+	close(finish)
+}
+
+// Waits until n callers wait for the call of a user
+func waitForWaiters(t *testing.T, cli *PrivyClient, privyId string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		cli.userCallsMu.Lock()
+		call := cli.userCalls[privyId]
+		waiters := 0
+		if call != nil {
+			waiters = call.waiters
+		}
+		cli.userCallsMu.Unlock()
+		if waiters == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d callers never waited for the call of %s", n, privyId)
+}
@@ -0,0 +1,393 @@
+// Package privytest is an in-memory fake of the Privy API for integration tests. It serves users, wallet creation and the
+// wallet rpc methods the enclave uses, checks the basic authorization and the privy-authorization-signature like Privy does, and
+// can be scripted to fail or slow down a route.
+package privytest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+	authorizationsignature "github.com/getaxal/verified-signer/enclave/privy-signer/authorization_signature"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/secp256k1"
+)
+
+// Route of the fake Privy API, used to script failures and latency
+type Route string
+
+const (
+	GetUser      Route = "GET /v1/users/{id}"
+	CreateWallet Route = "POST /v1/users/{id}/wallets"
+	WalletRpc    Route = "POST /v1/wallets/{id}/rpc"
+)
+
+// Error response returned instead of handling a request
+type Fault struct {
+	Code    int
+	Message string
+	Header  http.Header // Extra response headers, like Retry-After
+}
+
+type wallet struct {
+	id      string
+	privyId string
+	key     *big.Int
+}
+
+// Server is a fake Privy API listening on a local httptest server
+type Server struct {
+	URL   string
+	AppID string
+
+	server        *httptest.Server
+	authorization string
+
+	mu                sync.Mutex
+	authorizationKeys []*ecdsa.PublicKey
+	users             map[string]*data.PrivyUser
+	wallets           map[string]*wallet
+	idempotent        map[string][]byte // Responses of wallet creations by idempotency key
+	faults            map[Route][]Fault
+	latency           map[Route]time.Duration
+	requests          map[Route]int
+}
+
+// Starts a fake Privy API for the app credentials. Signed requests must carry a signature from each of authorizationKeys, in
+// order, like requests for a wallet owned by a key quorum.
+func NewServer(appId string, appSecret string, authorizationKeys ...*ecdsa.PublicKey) *Server {
+	s := &Server{
+		AppID:             appId,
+		authorization:     "Basic " + base64.StdEncoding.EncodeToString([]byte(appId+":"+appSecret)),
+		authorizationKeys: authorizationKeys,
+		users:             make(map[string]*data.PrivyUser),
+		wallets:           make(map[string]*wallet),
+		idempotent:        make(map[string][]byte),
+		faults:            make(map[Route][]Fault),
+		latency:           make(map[Route]time.Duration),
+		requests:          make(map[Route]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(string(GetUser), s.route(GetUser, s.getUser))
+	mux.HandleFunc(string(CreateWallet), s.route(CreateWallet, s.createWallet))
+	mux.HandleFunc(string(WalletRpc), s.route(WalletRpc, s.walletRpc))
+
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s
+}
+
+// Shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Returns the transport that reaches the server
+func (s *Server) Transport() http.RoundTripper {
+	return s.server.Client().Transport
+}
+
+// Replaces the keys signed requests are verified with, like a rotation of the authorization keys at Privy
+func (s *Server) SetAuthorizationKeys(authorizationKeys ...*ecdsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.authorizationKeys = authorizationKeys
+}
+
+// Adds a user without wallets, Privy only knows users that signed up
+func (s *Server) AddUser(privyId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[privyId] = &data.PrivyUser{PrivyID: privyId, CreatedAt: time.Now().Unix()}
+}
+
+// Creates a delegated eth wallet for a user outside of the API, like a wallet another enclave created
+func (s *Server) AddWallet(privyId string) data.LinkedAccount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addWallet(s.users[privyId], true)
+}
+
+// Returns a copy of a user as Privy has it
+func (s *Server) User(privyId string) (data.PrivyUser, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[privyId]
+	if !ok {
+		return data.PrivyUser{}, false
+	}
+	return copyUser(user), true
+}
+
+// Makes the next request to route fail with fault. Faults queue up in the order they were added.
+func (s *Server) FailNext(route Route, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[route] = append(s.faults[route], fault)
+}
+
+// Delays every request to route by latency, or until the client gives up
+func (s *Server) SetLatency(route Route, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency[route] = latency
+}
+
+// Returns the number of requests made to route, including rejected ones
+func (s *Server) Requests(route Route) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[route]
+}
+
+// Wraps a handler with the request count, latency, scripted faults and the app credential check every Privy route has
+func (s *Server) route(route Route, handler func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[route]++
+		latency := s.latency[route]
+		var fault *Fault
+		if faults := s.faults[route]; len(faults) > 0 {
+			fault = &faults[0]
+			s.faults[route] = faults[1:]
+		}
+		s.mu.Unlock()
+
+		// The server only notices a client going away once the request body is read
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body.")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if fault != nil {
+			for name, values := range fault.Header {
+				w.Header()[name] = values
+			}
+			writeError(w, fault.Code, fault.Message)
+			return
+		}
+
+		if r.Header.Get("privy-app-id") != s.AppID || r.Header.Get("Authorization") != s.authorization {
+			writeError(w, http.StatusUnauthorized, "Invalid app ID or app secret.")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.User(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "User not found.")
+		return
+	}
+	writeJSON(w, user)
+}
+
+func (s *Server) createWallet(w http.ResponseWriter, r *http.Request) {
+	var req data.CreateWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.PrivyWalletCreateRequestWallets) == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid wallet request.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Privy replays the response of a request it has seen the idempotency key of
+	idempotencyKey := r.Header.Get("privy-idempotency-key")
+	if response, ok := s.idempotent[idempotencyKey]; ok && idempotencyKey != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+		return
+	}
+
+	privyId := r.PathValue("id")
+	user, ok := s.users[privyId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "User not found.")
+		return
+	}
+
+	for _, walletReq := range req.PrivyWalletCreateRequestWallets {
+		if walletReq.ChainType != "ethereum" {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported chain type %s.", walletReq.ChainType))
+			return
+		}
+	}
+	for _, walletReq := range req.PrivyWalletCreateRequestWallets {
+		s.addWallet(user, len(walletReq.AdditionalSigners) > 0)
+	}
+
+	// Privy answers with the user and all of their linked accounts
+	response, _ := json.Marshal(copyUser(user))
+	if idempotencyKey != "" {
+		s.idempotent[idempotencyKey] = response
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+// Adds an eth wallet to user, the caller holds s.mu
+func (s *Server) addWallet(user *data.PrivyUser, delegated bool) data.LinkedAccount {
+	// Wallet keys are derived from the user and the number of wallets they have so tests get the same addresses every run
+	seed := sha256.Sum256([]byte(fmt.Sprintf("privytest/%s/%d", user.PrivyID, len(user.LinkedAccounts))))
+	wlt := &wallet{
+		id:      fmt.Sprintf("wallet-%d", len(s.wallets)+1),
+		privyId: user.PrivyID,
+		key:     secp256k1.PrivateKeyFromSeed(seed[:]),
+	}
+	s.wallets[wlt.id] = wlt
+
+	account := data.LinkedAccount{
+		WalletID:         wlt.id,
+		Type:             "wallet",
+		Address:          secp256k1.Address(secp256k1.PublicKey(wlt.key)),
+		ChainType:        "ethereum",
+		Delegated:        delegated,
+		WalletClientType: "privy",
+		ConnectorType:    "embedded",
+	}
+	user.LinkedAccounts = append(user.LinkedAccounts, account)
+	return account
+}
+
+func (s *Server) walletRpc(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+
+	if err := s.verifyAuthorizationSignature(r, body); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	wlt, ok := s.wallets[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Wallet not found.")
+		return
+	}
+
+	var req data.UserEthSecp256k1SignRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+	if req.Method != "secp256k1_sign" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported method %s.", req.Method))
+		return
+	}
+
+	hash, err := hex.DecodeString(strings.TrimPrefix(req.Params.Hash, "0x"))
+	if err != nil || len(hash) != 32 {
+		writeError(w, http.StatusBadRequest, "Invalid hash.")
+		return
+	}
+
+	signature, err := secp256k1.Sign(wlt.key, hash)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Signing failed.")
+		return
+	}
+
+	writeJSON(w, data.EthSecp256k1SignResponse{
+		Method: "secp256k1_sign",
+		Data: data.EthSecp256k1SignResponseData{
+			Signature: "0x" + hex.EncodeToString(signature),
+			Encoding:  "hex",
+		},
+	})
+}
+
+// Checks the privy-authorization-signature of a request carries one valid signature per authorization key, in order
+func (s *Server) verifyAuthorizationSignature(r *http.Request, body []byte) error {
+	s.mu.Lock()
+	keys := s.authorizationKeys
+	s.mu.Unlock()
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return errors.New("Invalid request body.")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"body":    decoded,
+		"headers": map[string]string{"privy-app-id": s.AppID},
+		"method":  r.Method,
+		"url":     s.URL + r.URL.Path,
+		"version": 1,
+	})
+	if err != nil {
+		return err
+	}
+	canonical, err := jsoncanonicalizer.Transform(payload)
+	if err != nil {
+		return err
+	}
+
+	signatures := strings.Split(r.Header.Get("privy-authorization-signature"), ",")
+	if len(keys) == 0 || len(signatures) != len(keys) {
+		return errors.New("Missing authorization signatures.")
+	}
+	for i, key := range keys {
+		valid, err := authorizationsignature.VerifySignature(key, canonical, []byte(signatures[i]))
+		if err != nil || !valid {
+			return errors.New("Invalid authorization signature.")
+		}
+	}
+	return nil
+}
+
+func copyUser(user *data.PrivyUser) data.PrivyUser {
+	copied := *user
+	copied.LinkedAccounts = append([]data.LinkedAccount(nil), user.LinkedAccounts...)
+	return copied
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// Writes an error the way Privy does, {"error": message}
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
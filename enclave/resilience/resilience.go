@@ -0,0 +1,140 @@
+// Package resilience holds the retry and circuit breaking used for calls the enclave makes through the host, where the upstream
+// service or the vsock proxy in front of it can be down.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Returned while a circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Backoff is an exponential backoff with full jitter
+type Backoff struct {
+	Base time.Duration // Upper bound of the first delay
+	Max  time.Duration // Upper bound of any delay
+}
+
+// Returns a random delay before retry number attempt, counting from 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	ceiling := b.Base
+	for i := 1; i < attempt && ceiling < b.Max; i++ {
+		ceiling *= 2
+	}
+	if ceiling > b.Max {
+		ceiling = b.Max
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// Parses a Retry-After header, given in seconds or as an http date
+func ParseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Waits for d, returns the context error if ctx is done first
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CircuitBreaker fails calls fast once an upstream has failed threshold times in a row. After cooldown it lets one call through,
+// which closes the breaker again if it succeeds.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// Creates a closed circuit breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Returns ErrCircuitOpen if the call should not be made. A nil error has to be followed by Success, Failure or Cancel.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.failures < cb.threshold {
+		return nil
+	}
+	if cb.probing || cb.now().Before(cb.openUntil) {
+		return ErrCircuitOpen
+	}
+
+	// Half open, this call is the probe
+	cb.probing = true
+	return nil
+}
+
+// Records a call that reached a healthy upstream
+func (cb *CircuitBreaker) Success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.probing = false
+}
+
+// Records a call that failed because of the upstream, opening the breaker at the threshold
+func (cb *CircuitBreaker) Failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.probing = false
+	if cb.failures >= cb.threshold {
+		cb.openUntil = cb.now().Add(cb.cooldown)
+	}
+}
+
+// Records a call that ended without telling anything about the upstream, like a cancelled request
+func (cb *CircuitBreaker) Cancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+}
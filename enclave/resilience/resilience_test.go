@@ -0,0 +1,119 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	backoff := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 1, ceiling: 100 * time.Millisecond},
+		{attempt: 2, ceiling: 200 * time.Millisecond},
+		{attempt: 3, ceiling: 400 * time.Millisecond},
+		{attempt: 10, ceiling: time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if delay := backoff.Delay(tt.attempt); delay < 0 || delay > tt.ceiling {
+				t.Fatalf("Delay(%d) = %v, want within [0, %v]", tt.attempt, delay, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "seconds", header: "3", want: 3 * time.Second, wantOk: true},
+		{name: "http date", header: "Wed, 01 Jan 2025 12:00:05 GMT", want: 5 * time.Second, wantOk: true},
+		{name: "date in the past", header: "Wed, 01 Jan 2025 11:00:00 GMT", want: 0, wantOk: true},
+		{name: "empty", header: ""},
+		{name: "negative", header: "-1"},
+		{name: "garbage", header: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.header, now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ParseRetryAfter(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSleep_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(3, 30*time.Second)
+	cb.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := cb.Allow(); err != nil {
+			t.Fatalf("Allow() error = %v before the threshold", err)
+		}
+		cb.Failure()
+	}
+
+	if err := cb.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() error = %v, want ErrCircuitOpen", err)
+	}
+
+	// After the cooldown one probe goes through
+	now = now.Add(31 * time.Second)
+	if err := cb.Allow(); err != nil {
+		t.Fatalf("Allow() error = %v, want the probe to go through", err)
+	}
+	if err := cb.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() error = %v, want only one probe", err)
+	}
+
+	// A failed probe opens the breaker for another cooldown
+	cb.Failure()
+	if err := cb.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() error = %v after a failed probe, want ErrCircuitOpen", err)
+	}
+
+	now = now.Add(31 * time.Second)
+	if err := cb.Allow(); err != nil {
+		t.Fatalf("Allow() error = %v, want the probe to go through", err)
+	}
+	cb.Success()
+	if err := cb.Allow(); err != nil {
+		t.Errorf("Allow() error = %v after a successful probe, want closed", err)
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Minute)
+
+	cb.Failure()
+	cb.Success()
+	cb.Failure()
+
+	if err := cb.Allow(); err != nil {
+		t.Errorf("Allow() error = %v, failures were not consecutive", err)
+	}
+}
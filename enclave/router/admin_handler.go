@@ -0,0 +1,65 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/controls"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler for fetching the global pause state
+func GetPauseStatusHandler(c *gin.Context) {
+	pauseState, err := controls.GlobalPause.Status()
+	if err != nil {
+		log.Errorf("Get pause status API error: %v", err)
+		abortWithError(c, apierror.Wrap(apierror.Unavailable, "Service Unavailable", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, pauseState)
+}
+
+// Handler for pausing all Axal initiated signing. The command must be signed by a quorum of operators.
+func PauseHandler(c *gin.Context) {
+	applyPauseCommand(c, controls.PauseActionPause)
+}
+
+// Handler for unpausing Axal initiated signing. The command must be signed by a quorum of operators.
+func UnpauseHandler(c *gin.Context) {
+	applyPauseCommand(c, controls.PauseActionUnpause)
+}
+
+func applyPauseCommand(c *gin.Context, action controls.PauseAction) {
+	var signedCmd controls.SignedPauseCommand
+	if err := c.ShouldBindJSON(&signedCmd); err != nil {
+		log.Errorf("Admin %s API error command is invalid with err: %v", action, err)
+		abortWithError(c, apierror.Wrap(apierror.InvalidRequest, "command is invalid", err))
+		return
+	}
+
+	if err := signedCmd.Validate(); err != nil {
+		log.Errorf("Admin %s API error command is invalid with err: %v", action, err)
+		abortWithError(c, invalidRequestError(err))
+		return
+	}
+
+	pauseState, err := controls.GlobalPause.Apply(action, &signedCmd)
+	if err != nil {
+		log.Errorf("Admin %s API error could not apply command with err: %v", action, err)
+
+		switch {
+		case errors.Is(err, controls.ErrInvalidPauseCommand):
+			abortWithError(c, apierror.Wrap(apierror.AuthFailed, err.Error(), err))
+		case errors.Is(err, controls.ErrPauseNotConfigured):
+			abortWithError(c, apierror.Wrap(apierror.NotImplemented, err.Error(), err))
+		default:
+			abortWithError(c, apierror.Wrap(apierror.Unavailable, "Service Unavailable", err))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, pauseState)
+}
@@ -6,8 +6,8 @@ import (
 	"strconv"
 
 	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/apierror"
 	"github.com/getaxal/verified-signer/enclave/attestation"
-	privydata "github.com/getaxal/verified-signer/enclave/privy-signer/data"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 )
@@ -18,10 +18,7 @@ func GetAttestationBytesHandler(c *gin.Context) {
 
 	if err != nil {
 		log.Error("Invalid nonce provided, could not parse to int")
-		resp := privydata.Message{
-			Message: "nonce is invalid",
-		}
-		c.JSON(http.StatusBadRequest, resp)
+		abortWithError(c, apierror.Invalid(apierror.FieldError{Field: "nonce", Message: "nonce must be an unsigned integer"}))
 		return
 	}
 
@@ -31,10 +28,7 @@ func GetAttestationBytesHandler(c *gin.Context) {
 
 	if err != nil {
 		log.Error("Unable to generate attestation")
-		resp := privydata.Message{
-			Message: "Internal server error",
-		}
-		c.JSON(http.StatusInternalServerError, resp)
+		abortWithError(c, apierror.InternalError(err))
 		return
 	}
 
@@ -53,10 +47,7 @@ func GetAttestationDocHandler(c *gin.Context) {
 
 	if err != nil {
 		log.Error("Invalid nonce provided, could not parse to int")
-		resp := privydata.Message{
-			Message: "nonce is invalid",
-		}
-		c.JSON(http.StatusBadRequest, resp)
+		abortWithError(c, apierror.Invalid(apierror.FieldError{Field: "nonce", Message: "nonce must be an unsigned integer"}))
 		return
 	}
 
@@ -66,10 +57,7 @@ func GetAttestationDocHandler(c *gin.Context) {
 
 	if err != nil {
 		log.Error("Unable to generate attestation")
-		resp := privydata.Message{
-			Message: "Internal server error",
-		}
-		c.JSON(http.StatusInternalServerError, resp)
+		abortWithError(c, apierror.InternalError(err))
 		return
 	}
 
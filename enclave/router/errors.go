@@ -0,0 +1,64 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	requestIDHeader = "X-Request-Id"
+	requestIDKey    = "request_id"
+)
+
+// Request ids the host or a client may pass in, anything else is replaced so it can not smuggle data into logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Tags every request with an id and writes the error response for the last error a handler added with c.Error. This is the only
+// place error responses are written, so every error a client sees has the same shape.
+func errorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(requestIDHeader)
+		if !requestIDPattern.MatchString(requestId) {
+			requestId = newRequestID()
+		}
+		c.Set(requestIDKey, requestId)
+		c.Header(requestIDHeader, requestId)
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		apiErr := apierror.From(c.Errors.Last().Err)
+		log.Errorf("Request %s %s %s failed: %v", requestId, c.Request.Method, c.Request.URL.Path, apiErr)
+		c.JSON(apiErr.Status(), apiErr.Response(requestId))
+	}
+}
+
+// Records err for the error middleware and stops the handler chain
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// Returns the validation error of a request as an API error, validation errors that are not API errors are invalid requests
+func invalidRequestError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return apierror.Wrap(apierror.InvalidRequest, err.Error(), err)
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -3,7 +3,7 @@ package router
 import (
 	"net/http"
 
-	privysigner "github.com/getaxal/verified-signer/enclave/privy-signer"
+	"github.com/getaxal/verified-signer/enclave/apierror"
 	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -16,10 +16,7 @@ func UserEthSecp256k1SignTxHandler(c *gin.Context) {
 
 	if auth == "" {
 		log.Errorf("User eth secp256k1 sign API error: missing auth")
-		resp := data.Message{
-			Message: "Unauthorized user",
-		}
-		c.JSON(http.StatusUnauthorized, resp)
+		abortWithError(c, apierror.New(apierror.AuthFailed, "Unauthorized user"))
 		return
 	}
 
@@ -28,28 +25,22 @@ func UserEthSecp256k1SignTxHandler(c *gin.Context) {
 
 	if err != nil {
 		log.Errorf("User eth secp256k1 sign API error tx data is invalid, sign req: %+v", secp256k1Sign)
-		resp := data.Message{
-			Message: "tx data is invalid",
-		}
-		c.JSON(http.StatusBadRequest, resp)
+		abortWithError(c, apierror.Wrap(apierror.InvalidRequest, "request body is invalid", err))
 		return
 	}
 
 	err = secp256k1Sign.ValidateTxRequest()
 	if err != nil {
 		log.Errorf("User eth secp256k1 sign API error tx data is invalid with err: %v", err)
-		resp := data.Message{
-			Message: "tx data is invalid",
-		}
-		c.JSON(http.StatusBadRequest, resp)
+		abortWithError(c, invalidRequestError(err))
 		return
 	}
 
 	// User handler - JWT auth only, privy_id extracted from JWT
-	resp, httpErr := privysigner.PrivyCli.UserEthSecp256k1Sign(&secp256k1Sign, auth)
+	resp, httpErr := appSigner.UserEthSecp256k1Sign(c.Request.Context(), &secp256k1Sign, auth)
 	if httpErr != nil {
-		log.Errorf("User eth secp256k1 sign API error could not sign tx with err: %v", httpErr.Message.Message)
-		c.JSON(httpErr.Code, httpErr.Message)
+		log.Errorf("User eth secp256k1 sign API error could not sign tx with err: %v", httpErr)
+		abortWithError(c, httpErr)
 		return
 	}
 
@@ -62,8 +53,7 @@ func AxalEthSecp256k1SignTxHandler(c *gin.Context) {
 	hmacSignature := c.GetHeader("auth")
 	if hmacSignature == "" {
 		log.Errorf("Axal eth secp256k1 sign API error: missing hmac signature")
-		resp := data.Message{Message: "Missing HMAC signature"}
-		c.JSON(http.StatusUnauthorized, resp)
+		abortWithError(c, apierror.New(apierror.AuthFailed, "Missing HMAC signature"))
 		return
 	}
 
@@ -71,24 +61,22 @@ func AxalEthSecp256k1SignTxHandler(c *gin.Context) {
 	err := c.ShouldBindJSON(&secp256k1Sign)
 	if err != nil {
 		log.Errorf("Axal eth secp256k1 sign API error: invalid request data: %+v", secp256k1Sign)
-		resp := data.Message{Message: "tx data is invalid"}
-		c.JSON(http.StatusBadRequest, resp)
+		abortWithError(c, apierror.Wrap(apierror.InvalidRequest, "request body is invalid", err))
 		return
 	}
 
 	err = secp256k1Sign.ValidateTxRequest()
 	if err != nil {
 		log.Errorf("Axal eth secp256k1 sign API error: validation failed: %v", err)
-		resp := data.Message{Message: "tx data is invalid"}
-		c.JSON(http.StatusBadRequest, resp)
+		abortWithError(c, invalidRequestError(err))
 		return
 	}
 
 	// Axal handler - privy_id comes from request body, HMAC auth only
-	resp, httpErr := privysigner.PrivyCli.AxalEthSecp256k1Sign(&secp256k1Sign, hmacSignature)
+	resp, httpErr := appSigner.AxalEthSecp256k1Sign(c.Request.Context(), &secp256k1Sign, hmacSignature)
 	if httpErr != nil {
-		log.Errorf("Axal eth secp256k1 sign API error: %v", httpErr.Message.Message)
-		c.JSON(httpErr.Code, httpErr.Message)
+		log.Errorf("Axal eth secp256k1 sign API error: %v", httpErr)
+		abortWithError(c, httpErr)
 		return
 	}
 
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/controls"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler for fetching the users kill switch state. JWT auth only.
+func GetKillSwitchHandler(c *gin.Context) {
+	privyId, ok := authenticateKillSwitchUser(c)
+	if !ok {
+		return
+	}
+
+	ksState, err := controls.UserKillSwitch.Status(privyId)
+	if err != nil {
+		log.Errorf("Get kill switch API error: %v", err)
+		abortWithError(c, apierror.Wrap(apierror.Unavailable, "Service Unavailable", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, ksState)
+}
+
+// Handler for engaging the users kill switch, this immediately disables all Axal initiated signing for the user. JWT auth only.
+func EngageKillSwitchHandler(c *gin.Context) {
+	privyId, ok := authenticateKillSwitchUser(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional, an empty body engages the switch with the configured cooldown
+	var engageReq controls.EngageKillSwitchRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&engageReq); err != nil {
+			log.Errorf("Engage kill switch API error request is invalid with err: %v", err)
+			abortWithError(c, apierror.Wrap(apierror.InvalidRequest, "request body is invalid", err))
+			return
+		}
+	}
+
+	if err := engageReq.Validate(); err != nil {
+		log.Errorf("Engage kill switch API error request is invalid with err: %v", err)
+		abortWithError(c, invalidRequestError(err))
+		return
+	}
+
+	ksState, err := controls.UserKillSwitch.Engage(privyId, time.Duration(engageReq.ReenableCooldownSeconds)*time.Second)
+	if err != nil {
+		log.Errorf("Engage kill switch API error: %v", err)
+		abortWithError(c, apierror.Wrap(apierror.Unavailable, "Service Unavailable", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, ksState)
+}
+
+// Handler for releasing the users kill switch. Axal initiated signing is re-enabled once the cooldown has passed. JWT auth only.
+func ReleaseKillSwitchHandler(c *gin.Context) {
+	privyId, ok := authenticateKillSwitchUser(c)
+	if !ok {
+		return
+	}
+
+	ksState, err := controls.UserKillSwitch.Release(privyId)
+	if err != nil {
+		log.Errorf("Release kill switch API error: %v", err)
+		abortWithError(c, apierror.Wrap(apierror.Unavailable, "Service Unavailable", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, ksState)
+}
+
+// Validates the privy jwt in the auth header and returns the users privy id. Records the error for the error middleware if it fails.
+func authenticateKillSwitchUser(c *gin.Context) (string, bool) {
+	auth := c.GetHeader("auth") // auth for this request is privy jwt
+
+	if auth == "" {
+		log.Errorf("Kill switch API error: missing auth")
+		abortWithError(c, apierror.New(apierror.AuthFailed, "Unauthorized user"))
+		return "", false
+	}
+
+	privyId, httpErr := appSigner.ValidateUserAuthForSigningRequest(auth)
+	if httpErr != nil {
+		abortWithError(c, httpErr)
+		return "", false
+	}
+
+	return privyId, true
+}
@@ -4,15 +4,21 @@ import (
 	"net/http"
 
 	"github.com/getaxal/verified-signer/common/vsock"
+	"github.com/getaxal/verified-signer/enclave/signer"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 )
 
-func InitRouter(routerVsockPort uint32) {
+// Signer the user and signing routes go through
+var appSigner signer.Signer
+
+// Serves the enclave API over vsock, signing through s
+func InitRouter(routerVsockPort uint32, s signer.Signer) {
 	// Initialize Gin router
 	r := gin.Default()
 
 	// Init routes
+	appSigner = s
 	initRoutes(r)
 
 	// Create vsock listener
@@ -33,6 +39,8 @@ func InitRouter(routerVsockPort uint32) {
 
 // Initiate the API routes here
 func initRoutes(r *gin.Engine) {
+	r.Use(errorMiddleware())
+
 	v1 := r.Group("/api/v1")
 	{
 		// User routes for user initiated signing
@@ -40,6 +48,14 @@ func initRoutes(r *gin.Engine) {
 		{
 			userGroup.GET("", GetUserHandler)
 
+			// Lets users opt out of axal initiated signing
+			killSwitchGroup := userGroup.Group("/killSwitch")
+			{
+				killSwitchGroup.GET("", GetKillSwitchHandler)
+				killSwitchGroup.POST("/engage", EngageKillSwitchHandler)
+				killSwitchGroup.POST("/release", ReleaseKillSwitchHandler)
+			}
+
 			signerGroup := userGroup.Group("/signer")
 			{
 				ethGroup := signerGroup.Group("/eth")
@@ -62,6 +78,14 @@ func initRoutes(r *gin.Engine) {
 			}
 		}
 
+		// Admin routes for operator quorum signed commands
+		adminGroup := v1.Group("/admin")
+		{
+			adminGroup.GET("/pause", GetPauseStatusHandler)
+			adminGroup.POST("/pause", PauseHandler)
+			adminGroup.POST("/unpause", UnpauseHandler)
+		}
+
 		//APIs for getting TEE attestation
 		attestationGroup := v1.Group("/attest")
 		{
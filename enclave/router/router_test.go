@@ -0,0 +1,209 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/signer"
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T, s signer.Signer) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	previous := appSigner
+	appSigner = s
+	t.Cleanup(func() { appSigner = previous })
+
+	r := gin.New()
+	initRoutes(r)
+	return r
+}
+
+func TestGetUserHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		auth      string
+		wantCode  int
+		wantCalls []string
+	}{
+		{name: "valid auth", auth: "did:privy:alice", wantCode: http.StatusOK, wantCalls: []string{"ValidateUserAuthForSigningRequest", "GetUser"}},
+		{name: "invalid auth", auth: "bad", wantCode: http.StatusUnauthorized, wantCalls: []string{"ValidateUserAuthForSigningRequest"}},
+		{name: "missing auth", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := signer.NewFake()
+			r := newTestRouter(t, fake)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/user", nil)
+			if tt.auth != "" {
+				req.Header.Set("auth", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if calls := fake.Calls(); len(calls) != len(tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+
+			if tt.wantCode == http.StatusOK {
+				var user data.PrivyUser
+				if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+					t.Fatalf("response is not a user: %v", err)
+				}
+				if user.PrivyID != tt.auth || user.GetUsersEthDelegatedWallet() == nil {
+					t.Errorf("user = %+v, want %s with a delegated wallet", user, tt.auth)
+				}
+			}
+		})
+	}
+}
+
+func TestUserEthSecp256k1SignTxHandler(t *testing.T) {
+	hash := "0x" + strings.Repeat("01", 32)
+	validBody := `{"method":"secp256k1_sign","params":{"hash":"` + hash + `"}}`
+
+	tests := []struct {
+		name     string
+		auth     string
+		body     string
+		signErr  *apierror.Error
+		wantCode int
+		wantErr  apierror.Code
+		wantSign bool
+	}{
+		{name: "signs", auth: "did:privy:alice", body: validBody, wantCode: http.StatusOK, wantSign: true},
+		{name: "missing auth", body: validBody, wantCode: http.StatusUnauthorized, wantErr: apierror.AuthFailed},
+		{name: "wrong method", auth: "did:privy:alice", body: `{"method":"eth_sign","params":{"hash":"` + hash + `"}}`, wantCode: http.StatusBadRequest, wantErr: apierror.InvalidRequest},
+		{name: "missing hash", auth: "did:privy:alice", body: `{"method":"secp256k1_sign"}`, wantCode: http.StatusBadRequest, wantErr: apierror.InvalidHash},
+		{name: "short hash", auth: "did:privy:alice", body: `{"method":"secp256k1_sign","params":{"hash":"0x01"}}`, wantCode: http.StatusBadRequest, wantErr: apierror.InvalidHash},
+		{
+			name:     "signer error",
+			auth:     "did:privy:alice",
+			body:     validBody,
+			signErr:  apierror.New(apierror.PolicyDenied, "denied"),
+			wantCode: http.StatusForbidden,
+			wantErr:  apierror.PolicyDenied,
+			wantSign: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := signer.NewFake()
+			if tt.signErr != nil {
+				fake.UserEthSecp256k1SignFunc = func(context.Context, *data.UserEthSecp256k1SignRequest, string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
+					return nil, tt.signErr
+				}
+			}
+			r := newTestRouter(t, fake)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/user/signer/eth/secp256k1Sign", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.auth != "" {
+				req.Header.Set("auth", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if signed := len(fake.Calls()) > 0; signed != tt.wantSign {
+				t.Errorf("signer called = %v, want %v", signed, tt.wantSign)
+			}
+
+			if tt.wantErr != "" {
+				var resp apierror.Response
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("response is not an error: %v", err)
+				}
+				if resp.Code != tt.wantErr || resp.RequestID == "" {
+					t.Errorf("error = %+v, want code %s with a request id", resp, tt.wantErr)
+				}
+			}
+
+			if tt.wantCode == http.StatusOK {
+				var resp data.EthSecp256k1SignResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("response is not a sign response: %v", err)
+				}
+				if resp.Data.Signature != fake.Signature {
+					t.Errorf("signature = %s, want %s", resp.Data.Signature, fake.Signature)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorMiddleware_RequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestId string
+		wantEcho  bool
+	}{
+		{name: "passed in id is kept", requestId: "req-123", wantEcho: true},
+		{name: "missing id is generated"},
+		{name: "unsafe id is replaced", requestId: "req\nforged log line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, signer.NewFake())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/user", nil)
+			if tt.requestId != "" {
+				req.Header.Set(requestIDHeader, tt.requestId)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var resp apierror.Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not an error: %v", err)
+			}
+			if resp.Code != apierror.AuthFailed || resp.Message == "" {
+				t.Errorf("error = %+v, want auth_failed", resp)
+			}
+			if header := w.Header().Get(requestIDHeader); header == "" || header != resp.RequestID {
+				t.Errorf("request id header = %q, body = %q, want the same id", header, resp.RequestID)
+			}
+			if echoed := resp.RequestID == tt.requestId; echoed != tt.wantEcho {
+				t.Errorf("request id = %q, passed in %q", resp.RequestID, tt.requestId)
+			}
+		})
+	}
+}
+
+func TestErrorMiddleware_PlainErrorsAreInternal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(errorMiddleware())
+	r.GET("/fail", func(c *gin.Context) {
+		abortWithError(c, errors.New("database password is hunter2"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("hunter2")) {
+		t.Errorf("body %s leaks the cause of an internal error", w.Body.String())
+	}
+}
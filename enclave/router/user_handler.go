@@ -3,8 +3,7 @@ package router
 import (
 	"net/http"
 
-	privysigner "github.com/getaxal/verified-signer/enclave/privy-signer"
-	privydata "github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/apierror"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 )
@@ -14,22 +13,19 @@ func GetUserHandler(c *gin.Context) {
 
 	if auth == "" {
 		log.Errorf("Get user API error: missing auth")
-		resp := privydata.Message{
-			Message: "Unauthorized user",
-		}
-		c.JSON(http.StatusUnauthorized, resp)
+		abortWithError(c, apierror.New(apierror.AuthFailed, "Unauthorized user"))
 		return
 	}
 
-	privyId, httpErr := privysigner.PrivyCli.ValidateUserAuthForSigningRequest(auth)
+	privyId, httpErr := appSigner.ValidateUserAuthForSigningRequest(auth)
 	if httpErr != nil {
-		c.JSON(httpErr.Code, httpErr.Message)
+		abortWithError(c, httpErr)
 		return
 	}
 
-	user, httpErr := privysigner.PrivyCli.GetUser(privyId)
+	user, httpErr := appSigner.GetUser(c.Request.Context(), privyId)
 	if httpErr != nil {
-		c.JSON(httpErr.Code, httpErr.Message)
+		abortWithError(c, httpErr)
 		return
 	}
 
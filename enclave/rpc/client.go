@@ -0,0 +1,325 @@
+// Package rpc is an Ethereum JSON-RPC client for the live chain state the verifier checks: balances, allowances, nonces, eth_call
+// simulations and oracle prices. Every provider is reached over HTTPS through its own host proxy vsock port, so TLS ends inside
+// the enclave and the host only relays ciphertext. A chain can have several providers. Calls fail over between them, or with a
+// quorum are read from all of them at one block and only succeed if enough providers give the same answer, so neither one lying
+// provider nor the host can forge chain state.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/network"
+	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/resilience"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrUnknownChain is returned for a chain without configured providers
+	ErrUnknownChain = errors.New("rpc: no providers for chain")
+	// ErrNoProviders is returned when no provider of a chain could answer a call
+	ErrNoProviders = errors.New("rpc: no provider answered")
+	// ErrProviderMismatch is returned when providers of a quorum gave different answers and no answer has a quorum. Nothing read
+	// may be trusted.
+	ErrProviderMismatch = errors.New("rpc: providers disagree")
+	// ErrNoQuorum is returned when fewer providers than the quorum answered
+	ErrNoQuorum = errors.New("rpc: too few providers answered for a quorum")
+)
+
+// Failures in a row after which a provider is skipped, and for how long
+const (
+	providerBreakerThreshold = 3
+	providerBreakerCooldown  = 30 * time.Second
+)
+
+// Clients by chain id, set by InitClients
+var Clients map[uint64]*Client
+
+// Provider is one JSON-RPC endpoint of a chain
+type Provider struct {
+	Name       string
+	url        string
+	serverName string // Pinned TLS server name, empty for test transports
+	vsockPort  uint32
+	client     *http.Client
+	breaker    *resilience.CircuitBreaker
+}
+
+// Client reads the state of one chain through its providers
+type Client struct {
+	ChainID   uint64
+	providers []*Provider
+	quorum    int // Providers that must agree on a read, zero fails over instead
+	nextId    atomic.Uint64
+}
+
+// Where a Client sends its requests. Unset fields default to the provider urls through their vsock ports.
+type ClientOptions struct {
+	Transport http.RoundTripper
+}
+
+// Inits a Client for every chain in the rpc config and initiates them to rpc.Clients
+func InitClients(cfg *enclave.TEEConfig) error {
+	clients := make(map[uint64]*Client, len(cfg.RPC.Chains))
+	for _, chain := range cfg.RPC.Chains {
+		if _, ok := clients[chain.ChainID]; ok {
+			return fmt.Errorf("rpc chain %d is configured twice", chain.ChainID)
+		}
+
+		client, err := NewClient(chain, ClientOptions{})
+		if err != nil {
+			return err
+		}
+		clients[chain.ChainID] = client
+		if chain.Quorum > 0 {
+			log.Infof("RPC client for chain %d reading from a quorum of %d of %d providers", chain.ChainID, chain.Quorum, len(chain.Providers))
+		} else {
+			log.Infof("RPC client for chain %d failing over between %d providers", chain.ChainID, len(chain.Providers))
+		}
+	}
+
+	Clients = clients
+	return nil
+}
+
+// Returns the Client of a chain from rpc.Clients
+func ForChain(chainId uint64) (*Client, error) {
+	client, ok := Clients[chainId]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownChain, chainId)
+	}
+	return client, nil
+}
+
+// Creates a Client for the providers of a chain. Tests point it at fake providers with opts.
+func NewClient(chain enclave.RPCChainConfig, opts ClientOptions) (*Client, error) {
+	if chain.ChainID == 0 {
+		return nil, errors.New("rpc chain id can not be zero")
+	}
+	if len(chain.Providers) == 0 {
+		return nil, fmt.Errorf("%w %d", ErrUnknownChain, chain.ChainID)
+	}
+
+	if chain.Quorum < 0 || chain.Quorum > len(chain.Providers) {
+		return nil, fmt.Errorf("rpc chain %d has a quorum of %d but %d providers", chain.ChainID, chain.Quorum, len(chain.Providers))
+	}
+	// With half the providers or less two different answers could both have a quorum
+	if chain.Quorum > 0 && 2*chain.Quorum <= len(chain.Providers) {
+		return nil, fmt.Errorf("rpc chain %d quorum of %d must be more than half of its %d providers", chain.ChainID, chain.Quorum, len(chain.Providers))
+	}
+
+	c := &Client{ChainID: chain.ChainID, quorum: chain.Quorum}
+	for _, providerCfg := range chain.Providers {
+		provider, err := newProvider(providerCfg, opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rpc provider %s for chain %d: %w", providerCfg.Name, chain.ChainID, err)
+		}
+		c.providers = append(c.providers, provider)
+	}
+
+	if c.quorum > 0 && opts.Transport == nil {
+		if err := c.checkIndependent(); err != nil {
+			return nil, fmt.Errorf("rpc chain %d: %w", chain.ChainID, err)
+		}
+	}
+	return c, nil
+}
+
+// Checks every provider of a quorum has its own TLS server name and vsock port, a provider listed twice would vote twice
+func (c *Client) checkIndependent() error {
+	serverNames := make(map[string]bool, len(c.providers))
+	vsockPorts := make(map[uint32]bool, len(c.providers))
+	for _, provider := range c.providers {
+		if serverNames[provider.serverName] {
+			return fmt.Errorf("tls_server_name %s is used by more than one provider of the quorum", provider.serverName)
+		}
+		if vsockPorts[provider.vsockPort] {
+			return fmt.Errorf("vsock_port %d is used by more than one provider of the quorum", provider.vsockPort)
+		}
+		serverNames[provider.serverName] = true
+		vsockPorts[provider.vsockPort] = true
+	}
+	return nil
+}
+
+func newProvider(cfg enclave.RPCProviderConfig, opts ClientOptions) (*Provider, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("url must be absolute")
+	}
+
+	var client *http.Client
+	if opts.Transport != nil {
+		client = &http.Client{Transport: opts.Transport, Timeout: 30 * time.Second}
+	} else {
+		if u.Scheme != "https" {
+			return nil, errors.New("url must be https")
+		}
+		// The certificate is checked against the pinned name, never against a name the host could influence
+		if cfg.TLSServerName == "" {
+			return nil, errors.New("tls_server_name is missing")
+		}
+		if !strings.EqualFold(u.Hostname(), cfg.TLSServerName) {
+			return nil, fmt.Errorf("url host %s is not the pinned tls_server_name %s", u.Hostname(), cfg.TLSServerName)
+		}
+		if cfg.VsockPort == 0 {
+			return nil, errors.New("vsock_port is missing")
+		}
+		client = network.InitHttpsClientWithTLSVsockTransport(cfg.VsockPort, cfg.TLSServerName)
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = u.Hostname()
+	}
+	return &Provider{
+		Name:       name,
+		url:        cfg.URL,
+		serverName: strings.ToLower(cfg.TLSServerName),
+		vsockPort:  cfg.VsockPort,
+		client:     client,
+		breaker:    resilience.NewCircuitBreaker(providerBreakerThreshold, providerBreakerCooldown),
+	}, nil
+}
+
+// Returns the providers that must agree on a read, zero when the client fails over between its providers instead
+func (c *Client) Quorum() int {
+	return c.quorum
+}
+
+// Calls method and unmarshals its result into result, which may be nil. A JSON-RPC error answer is returned as an *Error.
+func (c *Client) Call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	elems := []BatchElem{{Method: method, Params: params, Result: result}}
+	if err := c.BatchCall(ctx, elems); err != nil {
+		return err
+	}
+	return elems[0].Error
+}
+
+// Sends the calls in one JSON-RPC batch. The returned error is for the batch as a whole, the error of each call is set in its
+// Error field.
+func (c *Client) BatchCall(ctx context.Context, elems []BatchElem) error {
+	if len(elems) == 0 {
+		return nil
+	}
+
+	reqs := make([]request, len(elems))
+	for i, elem := range elems {
+		params := elem.Params
+		if params == nil {
+			params = []interface{}{}
+		}
+		reqs[i] = request{JSONRPC: "2.0", ID: c.nextId.Add(1), Method: elem.Method, Params: params}
+	}
+
+	var responses []response
+	var err error
+	if c.quorum > 0 {
+		responses, err = c.quorumRead(ctx, reqs)
+	} else {
+		responses, err = c.failover(ctx, reqs)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, resp := range responses {
+		if resp.Error != nil {
+			elems[i].Error = resp.Error
+			continue
+		}
+		if elems[i].Result != nil {
+			if err := json.Unmarshal(resp.Result, elems[i].Result); err != nil {
+				elems[i].Error = fmt.Errorf("invalid result of %s: %w", elems[i].Method, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Sends the requests to the providers in order until one answers, skipping providers that keep failing
+func (c *Client) failover(ctx context.Context, reqs []request) ([]response, error) {
+	var lastErr error
+	for _, provider := range c.providers {
+		if err := provider.breaker.Allow(); err != nil {
+			lastErr = fmt.Errorf("provider %s: %w", provider.Name, err)
+			continue
+		}
+
+		responses, err := provider.send(ctx, reqs)
+		if err == nil {
+			return responses, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Warnf("RPC provider %s of chain %d failed, trying the next one: %v", provider.Name, c.ChainID, err)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("%w for chain %d: %v", ErrNoProviders, c.ChainID, lastErr)
+}
+
+// Posts the requests to the provider and returns its responses in request order. A single request is sent on its own, more
+// are sent as a batch.
+func (p *Provider) send(ctx context.Context, reqs []request) ([]response, error) {
+	var payload interface{} = reqs
+	if len(reqs) == 1 {
+		payload = reqs[0]
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		p.breaker.Cancel()
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		p.breaker.Cancel()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			p.breaker.Cancel()
+		} else {
+			p.breaker.Failure()
+		}
+		return nil, fmt.Errorf("request to %s failed: %w", p.Name, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		p.breaker.Failure()
+		return nil, fmt.Errorf("%s answered with status %d", p.Name, res.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize+1))
+	if err != nil {
+		p.breaker.Failure()
+		return nil, fmt.Errorf("reading the response of %s failed: %w", p.Name, err)
+	}
+	if len(raw) > maxResponseSize {
+		p.breaker.Failure()
+		return nil, fmt.Errorf("response of %s is larger than %d bytes", p.Name, maxResponseSize)
+	}
+
+	responses, err := decodeResponses(raw, reqs)
+	if err != nil {
+		p.breaker.Failure()
+		return nil, fmt.Errorf("%s: %w", p.Name, err)
+	}
+	p.breaker.Success()
+	return responses, nil
+}
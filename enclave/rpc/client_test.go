@@ -0,0 +1,314 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/rpc/rpctest"
+)
+
+const testChainId = 8453
+
+var testAccount = data.Address{19: 0x01}
+
+// Starts n fake providers of the test chain and a client over them, a quorum of zero fails over between them
+func newTestClient(t *testing.T, n int, quorum int) (*Client, []*rpctest.Server) {
+	t.Helper()
+
+	chain := enclave.RPCChainConfig{ChainID: testChainId, Quorum: quorum}
+	servers := make([]*rpctest.Server, n)
+	for i := range servers {
+		servers[i] = rpctest.NewServer(testChainId)
+		t.Cleanup(servers[i].Close)
+		chain.Providers = append(chain.Providers, enclave.RPCProviderConfig{Name: "provider" + string(rune('a'+i)), URL: servers[i].URL})
+	}
+
+	client, err := NewClient(chain, ClientOptions{Transport: servers[0].Transport()})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client, servers
+}
+
+func TestNewClient_Validation(t *testing.T) {
+	alchemy := enclave.RPCProviderConfig{URL: "https://eth-mainnet.g.alchemy.com/v2/key", TLSServerName: "eth-mainnet.g.alchemy.com", VsockPort: 50007}
+	infura := enclave.RPCProviderConfig{URL: "https://mainnet.infura.io/v3/key", TLSServerName: "mainnet.infura.io", VsockPort: 50008}
+	other := enclave.RPCProviderConfig{URL: "https://rpc.example.com", TLSServerName: "rpc.example.com", VsockPort: 50011}
+	with := func(provider enclave.RPCProviderConfig, change func(*enclave.RPCProviderConfig)) enclave.RPCProviderConfig {
+		change(&provider)
+		return provider
+	}
+
+	tests := []struct {
+		name  string
+		chain enclave.RPCChainConfig
+	}{
+		{name: "no chain id", chain: enclave.RPCChainConfig{Providers: []enclave.RPCProviderConfig{alchemy}}},
+		{name: "no providers", chain: enclave.RPCChainConfig{ChainID: 1}},
+		{name: "plain http", chain: enclave.RPCChainConfig{ChainID: 1, Providers: []enclave.RPCProviderConfig{with(alchemy, func(p *enclave.RPCProviderConfig) { p.URL = "http://eth-mainnet.g.alchemy.com" })}}},
+		{name: "relative url", chain: enclave.RPCChainConfig{ChainID: 1, Providers: []enclave.RPCProviderConfig{with(alchemy, func(p *enclave.RPCProviderConfig) { p.URL = "/rpc" })}}},
+		{name: "no vsock port", chain: enclave.RPCChainConfig{ChainID: 1, Providers: []enclave.RPCProviderConfig{with(alchemy, func(p *enclave.RPCProviderConfig) { p.VsockPort = 0 })}}},
+		{name: "no tls server name", chain: enclave.RPCChainConfig{ChainID: 1, Providers: []enclave.RPCProviderConfig{with(alchemy, func(p *enclave.RPCProviderConfig) { p.TLSServerName = "" })}}},
+		{name: "url host is not the tls server name", chain: enclave.RPCChainConfig{ChainID: 1, Providers: []enclave.RPCProviderConfig{with(alchemy, func(p *enclave.RPCProviderConfig) { p.TLSServerName = "mainnet.infura.io" })}}},
+		{name: "quorum above providers", chain: enclave.RPCChainConfig{ChainID: 1, Quorum: 3, Providers: []enclave.RPCProviderConfig{alchemy, infura}}},
+		{name: "negative quorum", chain: enclave.RPCChainConfig{ChainID: 1, Quorum: -1, Providers: []enclave.RPCProviderConfig{alchemy}}},
+		{name: "quorum of half the providers", chain: enclave.RPCChainConfig{ChainID: 1, Quorum: 1, Providers: []enclave.RPCProviderConfig{alchemy, infura}}},
+		{name: "quorum with a tls server name twice", chain: enclave.RPCChainConfig{ChainID: 1, Quorum: 2, Providers: []enclave.RPCProviderConfig{alchemy, infura, with(alchemy, func(p *enclave.RPCProviderConfig) { p.VsockPort = 50011 })}}},
+		{name: "quorum with a vsock port twice", chain: enclave.RPCChainConfig{ChainID: 1, Quorum: 2, Providers: []enclave.RPCProviderConfig{alchemy, infura, with(other, func(p *enclave.RPCProviderConfig) { p.VsockPort = 50008 })}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.chain, ClientOptions{}); err == nil {
+				t.Errorf("NewClient() accepted %+v", tt.chain)
+			}
+		})
+	}
+
+	valid := enclave.RPCChainConfig{ChainID: 1, Quorum: 2, Providers: []enclave.RPCProviderConfig{alchemy, infura, other}}
+	client, err := NewClient(valid, ClientOptions{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if client.providers[0].Name != "eth-mainnet.g.alchemy.com" {
+		t.Errorf("provider name = %s, want the host of its url", client.providers[0].Name)
+	}
+}
+
+func TestInitClients(t *testing.T) {
+	provider := enclave.RPCProviderConfig{URL: "https://rpc.example.com", TLSServerName: "rpc.example.com", VsockPort: 50007}
+	cfg := &enclave.TEEConfig{RPC: enclave.RPCConfig{Chains: []enclave.RPCChainConfig{
+		{ChainID: 1, Providers: []enclave.RPCProviderConfig{provider}},
+		{ChainID: 8453, Providers: []enclave.RPCProviderConfig{provider}},
+	}}}
+	defer func() { Clients = nil }()
+
+	if err := InitClients(cfg); err != nil {
+		t.Fatalf("InitClients() error = %v", err)
+	}
+	if client, err := ForChain(8453); err != nil || client.ChainID != 8453 {
+		t.Errorf("ForChain(8453) = %v, %v", client, err)
+	}
+	if _, err := ForChain(10); !errors.Is(err, ErrUnknownChain) {
+		t.Errorf("ForChain(10) error = %v, want ErrUnknownChain", err)
+	}
+
+	cfg.RPC.Chains = append(cfg.RPC.Chains, cfg.RPC.Chains[0])
+	if err := InitClients(cfg); err == nil {
+		t.Errorf("InitClients() accepted a chain configured twice")
+	}
+}
+
+func TestClient_Failover(t *testing.T) {
+	client, servers := newTestClient(t, 2, 0)
+	servers[0].SetBlockNumber(100)
+	servers[1].SetBlockNumber(101)
+
+	if number, err := client.BlockNumber(context.Background()); err != nil || number != 100 {
+		t.Fatalf("BlockNumber() = %d, %v, want the first provider", number, err)
+	}
+
+	servers[0].FailNext(http.StatusBadGateway)
+	if number, err := client.BlockNumber(context.Background()); err != nil || number != 101 {
+		t.Fatalf("BlockNumber() = %d, %v, want the second provider once the first failed", number, err)
+	}
+
+	// The first provider is skipped once it failed providerBreakerThreshold times in a row
+	for i := 0; i < providerBreakerThreshold; i++ {
+		servers[0].FailNext(http.StatusServiceUnavailable)
+	}
+	for i := 0; i < providerBreakerThreshold; i++ {
+		client.BlockNumber(context.Background())
+	}
+	requests := servers[0].Requests()
+	if _, err := client.BlockNumber(context.Background()); err != nil {
+		t.Fatalf("BlockNumber() error = %v", err)
+	}
+	if servers[0].Requests() != requests {
+		t.Errorf("provider with an open breaker got a request")
+	}
+}
+
+func TestClient_AllProvidersDown(t *testing.T) {
+	client, servers := newTestClient(t, 2, 0)
+	servers[0].FailNext(http.StatusInternalServerError)
+	servers[1].FailNext(http.StatusTooManyRequests)
+
+	if _, err := client.BlockNumber(context.Background()); !errors.Is(err, ErrNoProviders) {
+		t.Errorf("BlockNumber() error = %v, want ErrNoProviders", err)
+	}
+}
+
+func TestClient_BatchCall(t *testing.T) {
+	client, servers := newTestClient(t, 1, 0)
+	servers[0].SetBlockNumber(7)
+	servers[0].SetBalance(testAccount, big.NewInt(1000))
+
+	var number, balance data.BigInt
+	elems := []BatchElem{
+		{Method: "eth_blockNumber", Result: &number},
+		{Method: "eth_getBalance", Params: []interface{}{testAccount, Latest}, Result: &balance},
+		{Method: "eth_sendRawTransaction", Params: []interface{}{"0x00"}},
+	}
+	if err := client.BatchCall(context.Background(), elems); err != nil {
+		t.Fatalf("BatchCall() error = %v", err)
+	}
+
+	if servers[0].Requests() != 1 {
+		t.Errorf("BatchCall() made %d requests, want one batch", servers[0].Requests())
+	}
+	if elems[0].Error != nil || number.String() != "7" || elems[1].Error != nil || balance.String() != "1000" {
+		t.Errorf("BatchCall() results = %s, %s, errors %v, %v", number.String(), balance.String(), elems[0].Error, elems[1].Error)
+	}
+
+	var rpcErr *Error
+	if !errors.As(elems[2].Error, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("unknown method error = %v, want a -32601 rpc error", elems[2].Error)
+	}
+}
+
+func TestClient_Quorum(t *testing.T) {
+	t.Run("providers agree", func(t *testing.T) {
+		client, servers := newTestClient(t, 3, 2)
+		for _, server := range servers {
+			server.SetBlockNumber(100)
+			server.SetBalance(testAccount, big.NewInt(42))
+		}
+
+		balance, err := client.BalanceAt(context.Background(), testAccount, Latest)
+		if err != nil || balance.String() != "42" {
+			t.Fatalf("BalanceAt() = %s, %v, want 42", balance.String(), err)
+		}
+		for i, server := range servers {
+			if server.Calls("eth_getBalance") != 1 || server.LastBlock("eth_getBalance") != "0x64" {
+				t.Errorf("provider %d got %d calls at %s, want one at the pinned block 0x64", i, server.Calls("eth_getBalance"), server.LastBlock("eth_getBalance"))
+			}
+		}
+	})
+
+	t.Run("pinned to the block a quorum reached", func(t *testing.T) {
+		client, servers := newTestClient(t, 3, 2)
+		// The last provider claims a block nobody else has
+		for i, number := range []uint64{100, 105, 1_000_000} {
+			servers[i].SetBlockNumber(number)
+			servers[i].SetNonce(testAccount, 9)
+		}
+
+		nonce, err := client.NonceAt(context.Background(), testAccount, Latest)
+		if err != nil || nonce != 9 {
+			t.Fatalf("NonceAt() = %d, %v, want 9 from the providers that have the block", nonce, err)
+		}
+		for i, server := range servers {
+			if block := server.LastBlock("eth_getTransactionCount"); block != "0x69" {
+				t.Errorf("provider %d was asked at %s, want 0x69", i, block)
+			}
+		}
+
+		if number, err := client.BlockNumber(context.Background()); err != nil || number != 105 {
+			t.Errorf("BlockNumber() = %d, %v, want 105", number, err)
+		}
+	})
+
+	t.Run("plain string tags are pinned", func(t *testing.T) {
+		client, servers := newTestClient(t, 3, 2)
+		for i, number := range []uint64{100, 105, 1_000_000} {
+			servers[i].SetBlockNumber(number)
+			servers[i].SetBalance(testAccount, big.NewInt(42))
+		}
+
+		var balance data.BigInt
+		if err := client.Call(context.Background(), &balance, "eth_getBalance", testAccount, "latest"); err != nil || balance.String() != "42" {
+			t.Fatalf("Call() = %s, %v, want 42", balance.String(), err)
+		}
+		for i, server := range servers {
+			if block := server.LastBlock("eth_getBalance"); block != "0x69" {
+				t.Errorf("provider %d was asked at %s, want 0x69", i, block)
+			}
+		}
+	})
+
+	t.Run("pending is refused", func(t *testing.T) {
+		client, servers := newTestClient(t, 3, 2)
+
+		if _, err := client.BalanceAt(context.Background(), testAccount, BlockTag("pending")); err == nil {
+			t.Error("BalanceAt() at the pending block succeeded, want an error")
+		}
+		var nonce data.BigInt
+		if err := client.Call(context.Background(), &nonce, "eth_getTransactionCount", testAccount, "pending"); err == nil {
+			t.Error("Call() at the pending block succeeded, want an error")
+		}
+		for i, server := range servers {
+			if server.Calls("eth_getBalance") != 0 || server.Calls("eth_getTransactionCount") != 0 {
+				t.Errorf("provider %d was asked at the pending block", i)
+			}
+		}
+	})
+
+	t.Run("lying provider is outvoted", func(t *testing.T) {
+		client, servers := newTestClient(t, 3, 2)
+		servers[0].SetBalance(testAccount, big.NewInt(42))
+		servers[1].SetBalance(testAccount, big.NewInt(1_000_000))
+		servers[2].SetBalance(testAccount, big.NewInt(42))
+
+		if balance, err := client.BalanceAt(context.Background(), testAccount, Latest); err != nil || balance.String() != "42" {
+			t.Errorf("BalanceAt() = %s, %v, want the 42 of the quorum", balance.String(), err)
+		}
+	})
+
+	t.Run("no answer has a quorum", func(t *testing.T) {
+		client, servers := newTestClient(t, 3, 3)
+		servers[0].SetBalance(testAccount, big.NewInt(42))
+		servers[1].SetBalance(testAccount, big.NewInt(42))
+		servers[2].SetBalance(testAccount, big.NewInt(1_000_000))
+
+		if balance, err := client.BalanceAt(context.Background(), testAccount, Latest); !errors.Is(err, ErrProviderMismatch) {
+			t.Errorf("BalanceAt() = %s, %v, want ErrProviderMismatch", balance.String(), err)
+		}
+	})
+
+	t.Run("too few providers answer", func(t *testing.T) {
+		client, servers := newTestClient(t, 3, 2)
+		servers[1].FailNext(http.StatusBadGateway)
+		servers[2].FailNext(http.StatusBadGateway)
+
+		if _, err := client.BalanceAt(context.Background(), testAccount, AtBlock(0)); !errors.Is(err, ErrNoQuorum) {
+			t.Errorf("BalanceAt() error = %v, want ErrNoQuorum", err)
+		}
+
+		// Failing while the block is pinned
+		servers[0].FailNext(http.StatusBadGateway)
+		servers[1].FailNext(http.StatusBadGateway)
+		if _, err := client.BalanceAt(context.Background(), testAccount, Latest); !errors.Is(err, ErrNoQuorum) {
+			t.Errorf("BalanceAt() error = %v, want ErrNoQuorum", err)
+		}
+	})
+
+	t.Run("errors agree", func(t *testing.T) {
+		client, servers := newTestClient(t, 2, 2)
+		calldata := []byte{0x01, 0x02, 0x03, 0x04}
+		for _, server := range servers {
+			server.SetCallRevert(testAccount, calldata)
+		}
+
+		_, err := client.CallContract(context.Background(), CallMsg{To: testAccount, Data: calldata}, Latest)
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			t.Errorf("CallContract() error = %v, want the revert of both providers", err)
+		}
+	})
+}
+
+func TestClient_ContextCancelled(t *testing.T) {
+	client, _ := newTestClient(t, 2, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.BlockNumber(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("BlockNumber() error = %v, want context.Canceled", err)
+	}
+}
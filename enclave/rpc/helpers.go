@@ -0,0 +1,235 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"golang.org/x/crypto/sha3"
+)
+
+// Function selectors of the contract calls the helpers make
+var (
+	selectorBalanceOf       = []byte{0x70, 0xa0, 0x82, 0x31} // balanceOf(address)
+	selectorAllowance       = []byte{0xdd, 0x62, 0xed, 0x3e} // allowance(address,address)
+	selectorDecimals        = []byte{0x31, 0x3c, 0xe5, 0x67} // decimals()
+	selectorLatestRoundData = []byte{0xfe, 0xaf, 0x96, 0x8c} // latestRoundData()
+)
+
+// BlockTag selects the block state is read at, a block number or one of the named blocks
+type BlockTag string
+
+const (
+	Latest    BlockTag = "latest"
+	Safe      BlockTag = "safe"
+	Finalized BlockTag = "finalized"
+)
+
+// Returns the tag of a block number
+func AtBlock(number uint64) BlockTag {
+	return BlockTag("0x" + strconv.FormatUint(number, 16))
+}
+
+// CallMsg is the call eth_call simulates
+type CallMsg struct {
+	From  *data.Address // Optional sender
+	To    data.Address
+	Data  data.HexBytes
+	Value *data.BigInt // Optional wei sent with the call
+}
+
+// MarshalJSON implements json.Marshaler, a call message is the eth_call transaction object with hex quantities
+func (msg CallMsg) MarshalJSON() ([]byte, error) {
+	arg := map[string]interface{}{
+		"to":   msg.To,
+		"data": msg.Data,
+	}
+	if msg.From != nil {
+		arg["from"] = *msg.From
+	}
+	if !msg.Value.IsNil() {
+		arg["value"] = msg.Value.Hex()
+	}
+	return json.Marshal(arg)
+}
+
+// OraclePrice is the latest answer of a Chainlink price feed
+type OraclePrice struct {
+	RoundID   *data.BigInt
+	Answer    *data.BigInt // Price scaled by 10^Decimals
+	Decimals  uint8
+	UpdatedAt time.Time
+}
+
+// Checks the chain the providers serve is the chain of the client
+func (c *Client) VerifyChainID(ctx context.Context) error {
+	var chainId data.BigInt
+	if err := c.Call(ctx, &chainId, "eth_chainId"); err != nil {
+		return err
+	}
+	if !chainId.Int.IsUint64() || chainId.Int.Uint64() != c.ChainID {
+		return fmt.Errorf("rpc providers serve chain %s, not chain %d", chainId.String(), c.ChainID)
+	}
+	return nil
+}
+
+// Returns the number of the latest block. With a quorum it is the latest block a quorum of providers has reached.
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	if c.quorum > 0 {
+		numbers, err := c.quorumBlocks(ctx, []BlockTag{Latest})
+		if err != nil {
+			return 0, err
+		}
+		return numbers[Latest], nil
+	}
+
+	var number data.BigInt
+	if err := c.Call(ctx, &number, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return quantityUint64(&number, "block number")
+}
+
+// Returns the wei balance of an account
+func (c *Client) BalanceAt(ctx context.Context, account data.Address, block BlockTag) (*data.BigInt, error) {
+	var balance data.BigInt
+	if err := c.Call(ctx, &balance, "eth_getBalance", account, block); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// Returns the nonce of an account, the number of transactions it sent
+func (c *Client) NonceAt(ctx context.Context, account data.Address, block BlockTag) (uint64, error) {
+	var nonce data.BigInt
+	if err := c.Call(ctx, &nonce, "eth_getTransactionCount", account, block); err != nil {
+		return 0, err
+	}
+	return quantityUint64(&nonce, "nonce")
+}
+
+// Returns the code of an account, empty for an account without code
+func (c *Client) CodeAt(ctx context.Context, account data.Address, block BlockTag) (data.HexBytes, error) {
+	var code data.HexBytes
+	if err := c.Call(ctx, &code, "eth_getCode", account, block); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// Returns the keccak256 hash of the code of an account, for an account without code the hash of no code
+func (c *Client) CodeHashAt(ctx context.Context, account data.Address, block BlockTag) (data.Hash32, error) {
+	code, err := c.CodeAt(ctx, account, block)
+	if err != nil {
+		return data.Hash32{}, err
+	}
+
+	var hash data.Hash32
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(code)
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
+
+// Simulates a call and returns what it returned. A reverted call is an *Error.
+func (c *Client) CallContract(ctx context.Context, msg CallMsg, block BlockTag) (data.HexBytes, error) {
+	var result data.HexBytes
+	if err := c.Call(ctx, &result, "eth_call", msg, block); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Returns the ERC-20 token balance of owner
+func (c *Client) ERC20BalanceOf(ctx context.Context, token, owner data.Address, block BlockTag) (*data.BigInt, error) {
+	result, err := c.CallContract(ctx, CallMsg{To: token, Data: encodeCall(selectorBalanceOf, owner)}, block)
+	if err != nil {
+		return nil, err
+	}
+	return decodeUint256(result, 0, "balanceOf")
+}
+
+// Returns how much of owners ERC-20 tokens spender may transfer
+func (c *Client) ERC20Allowance(ctx context.Context, token, owner, spender data.Address, block BlockTag) (*data.BigInt, error) {
+	result, err := c.CallContract(ctx, CallMsg{To: token, Data: encodeCall(selectorAllowance, owner, spender)}, block)
+	if err != nil {
+		return nil, err
+	}
+	return decodeUint256(result, 0, "allowance")
+}
+
+// Returns the latest price of a Chainlink price feed, reading its decimals and latest round in one batch. Callers decide how
+// old a price they accept from UpdatedAt.
+func (c *Client) OraclePrice(ctx context.Context, feed data.Address, block BlockTag) (*OraclePrice, error) {
+	var decimals, round data.HexBytes
+	elems := []BatchElem{
+		{Method: "eth_call", Params: []interface{}{CallMsg{To: feed, Data: encodeCall(selectorDecimals)}, block}, Result: &decimals},
+		{Method: "eth_call", Params: []interface{}{CallMsg{To: feed, Data: encodeCall(selectorLatestRoundData)}, block}, Result: &round},
+	}
+	if err := c.BatchCall(ctx, elems); err != nil {
+		return nil, err
+	}
+	for _, elem := range elems {
+		if elem.Error != nil {
+			return nil, elem.Error
+		}
+	}
+
+	decimalsValue, err := decodeUint256(decimals, 0, "decimals")
+	if err != nil {
+		return nil, err
+	}
+	if decimalsValue.Int.Cmp(big.NewInt(255)) > 0 {
+		return nil, fmt.Errorf("decimals %s of price feed %s do not fit in uint8", decimalsValue, feed)
+	}
+
+	// latestRoundData returns (uint80 roundId, int256 answer, uint256 startedAt, uint256 updatedAt, uint80 answeredInRound)
+	if len(round) != 5*32 {
+		return nil, fmt.Errorf("latestRoundData of price feed %s returned %d bytes, want 160", feed, len(round))
+	}
+	roundId, _ := decodeUint256(round, 0, "roundId")
+	answer := new(big.Int).SetBytes(round[32:64])
+	if answer.Bit(255) == 1 {
+		answer.Sub(answer, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	updatedAt, _ := decodeUint256(round, 3, "updatedAt")
+	if !updatedAt.Int.IsInt64() {
+		return nil, fmt.Errorf("updatedAt of price feed %s is out of range", feed)
+	}
+
+	return &OraclePrice{
+		RoundID:   roundId,
+		Answer:    &data.BigInt{Int: answer},
+		Decimals:  uint8(decimalsValue.Int.Uint64()),
+		UpdatedAt: time.Unix(updatedAt.Int.Int64(), 0),
+	}, nil
+}
+
+// Returns the calldata of a call to a function taking addresses
+func encodeCall(selector []byte, args ...data.Address) data.HexBytes {
+	calldata := append([]byte{}, selector...)
+	for _, arg := range args {
+		calldata = append(calldata, make([]byte, 12)...)
+		calldata = append(calldata, arg[:]...)
+	}
+	return calldata
+}
+
+// Decodes word number index of an ABI encoded return value as a uint256
+func decodeUint256(result []byte, index int, name string) (*data.BigInt, error) {
+	if len(result) < (index+1)*32 {
+		return nil, fmt.Errorf("%s returned %d bytes, too short for a uint256", name, len(result))
+	}
+	return &data.BigInt{Int: new(big.Int).SetBytes(result[index*32 : (index+1)*32])}, nil
+}
+
+func quantityUint64(n *data.BigInt, name string) (uint64, error) {
+	if n.IsNil() || !n.Int.IsUint64() {
+		return 0, fmt.Errorf("%s %s does not fit in 64 bits", name, n)
+	}
+	return n.Int.Uint64(), nil
+}
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+)
+
+func TestClient_VerifyChainID(t *testing.T) {
+	client, _ := newTestClient(t, 1, 0)
+	if err := client.VerifyChainID(context.Background()); err != nil {
+		t.Errorf("VerifyChainID() error = %v", err)
+	}
+
+	client.ChainID = 1
+	if err := client.VerifyChainID(context.Background()); err == nil {
+		t.Errorf("VerifyChainID() accepted providers of another chain")
+	}
+}
+
+func TestClient_StateHelpers(t *testing.T) {
+	client, servers := newTestClient(t, 1, 0)
+	server := servers[0]
+
+	token := data.Address{0: 0xaa, 19: 0x01}
+	spender := data.Address{0: 0xbb, 19: 0x02}
+	wei, _ := data.ParseEther("1.25")
+
+	server.SetBlockNumber(19_000_000)
+	server.SetBalance(testAccount, wei.Int)
+	server.SetNonce(testAccount, 17)
+	server.SetERC20Balance(token, testAccount, big.NewInt(5_000_000))
+	server.SetERC20Allowance(token, testAccount, spender, new(big.Int).Lsh(big.NewInt(1), 255))
+
+	ctx := context.Background()
+	if number, err := client.BlockNumber(ctx); err != nil || number != 19_000_000 {
+		t.Errorf("BlockNumber() = %d, %v", number, err)
+	}
+	if balance, err := client.BalanceAt(ctx, testAccount, Finalized); err != nil || !balance.Equals(wei) {
+		t.Errorf("BalanceAt() = %s, %v, want %s", balance.String(), err, wei)
+	}
+	if nonce, err := client.NonceAt(ctx, testAccount, AtBlock(19_000_000)); err != nil || nonce != 17 {
+		t.Errorf("NonceAt() = %d, %v, want 17", nonce, err)
+	}
+	if balance, err := client.ERC20BalanceOf(ctx, token, testAccount, Latest); err != nil || balance.String() != "5000000" {
+		t.Errorf("ERC20BalanceOf() = %s, %v, want 5000000", balance.String(), err)
+	}
+	if allowance, err := client.ERC20Allowance(ctx, token, testAccount, spender, Latest); err != nil || allowance.Int.Cmp(new(big.Int).Lsh(big.NewInt(1), 255)) != 0 {
+		t.Errorf("ERC20Allowance() = %s, %v, want 2^255", allowance.String(), err)
+	}
+
+	code := data.HexBytes{0x60, 0x80, 0x60, 0x40}
+	server.SetCode(token, code)
+	if got, err := client.CodeAt(ctx, token, Latest); err != nil || got.String() != code.String() {
+		t.Errorf("CodeAt() = %s, %v, want %s", got, err, code)
+	}
+	// keccak256 of no code, the code hash of every account without code
+	if hash, err := client.CodeHashAt(ctx, testAccount, Latest); err != nil || hash.String() != "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470" {
+		t.Errorf("CodeHashAt() = %s, %v, want the empty code hash", hash, err)
+	}
+
+	// A token that is not a contract returns no data
+	if _, err := client.ERC20BalanceOf(ctx, spender, testAccount, Latest); err == nil {
+		t.Errorf("ERC20BalanceOf() of an account without code did not fail")
+	}
+}
+
+func TestClient_CallContractRevert(t *testing.T) {
+	client, servers := newTestClient(t, 1, 0)
+	calldata := data.HexBytes{0xde, 0xad, 0xbe, 0xef}
+	servers[0].SetCallRevert(testAccount, calldata)
+
+	_, err := client.CallContract(context.Background(), CallMsg{To: testAccount, Data: calldata, Value: data.NewBigIntFromInt64(1)}, Latest)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != 3 {
+		t.Errorf("CallContract() error = %v, want the revert", err)
+	}
+}
+
+func TestClient_OraclePrice(t *testing.T) {
+	client, servers := newTestClient(t, 1, 0)
+	feed := data.Address{0: 0x5f, 19: 0x19}
+	updatedAt := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name   string
+		answer *big.Int
+	}{
+		{name: "price", answer: big.NewInt(301_234_567_890)},
+		{name: "negative answer", answer: big.NewInt(-5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers[0].SetOraclePrice(feed, 42, tt.answer, 8, updatedAt)
+			requests := servers[0].Requests()
+
+			price, err := client.OraclePrice(context.Background(), feed, Latest)
+			if err != nil {
+				t.Fatalf("OraclePrice() error = %v", err)
+			}
+			if price.Answer.Int.Cmp(tt.answer) != 0 || price.Decimals != 8 || !price.UpdatedAt.Equal(updatedAt) || price.RoundID.String() != "42" {
+				t.Errorf("OraclePrice() = %+v", price)
+			}
+			if servers[0].Requests()-requests != 1 {
+				t.Errorf("OraclePrice() made %d requests, want one batch", servers[0].Requests()-requests)
+			}
+		})
+	}
+
+	if _, err := client.OraclePrice(context.Background(), testAccount, Latest); err == nil {
+		t.Errorf("OraclePrice() of an account without code did not fail")
+	}
+}
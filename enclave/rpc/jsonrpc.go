@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Most bytes read from a provider response
+const maxResponseSize = 16 << 20
+
+// A JSON-RPC 2.0 request
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// A JSON-RPC 2.0 response, exactly one of Result and Error is set
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error a provider answered a call with, like a reverted eth_call
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// BatchElem is one call of a batch. Result is unmarshalled into on success, Error is set if the provider answered the call with
+// an error or its result could not be unmarshalled.
+type BatchElem struct {
+	Method string
+	Params []interface{}
+	Result interface{}
+	Error  error
+}
+
+// Decodes a response body, a single response or a batch, and orders the responses like reqs
+func decodeResponses(body []byte, reqs []request) ([]response, error) {
+	var responses []response
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &responses); err != nil {
+			return nil, fmt.Errorf("invalid batch response: %w", err)
+		}
+	} else {
+		var single response
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		responses = []response{single}
+	}
+
+	byId := make(map[uint64]response, len(responses))
+	for _, resp := range responses {
+		byId[resp.ID] = resp
+	}
+
+	ordered := make([]response, len(reqs))
+	for i, req := range reqs {
+		resp, ok := byId[req.ID]
+		if !ok {
+			return nil, fmt.Errorf("no response to %s with id %d", req.Method, req.ID)
+		}
+		if resp.Error == nil && resp.Result == nil {
+			return nil, fmt.Errorf("response to %s with id %d has neither a result nor an error", req.Method, req.ID)
+		}
+		ordered[i] = resp
+	}
+	return ordered, nil
+}
+
+// Reports whether two providers gave the same answer. Results must be equal JSON, errors agree with each other whatever their
+// message since clients word them differently.
+func sameResponse(a, b response) bool {
+	if a.Error != nil || b.Error != nil {
+		return a.Error != nil && b.Error != nil
+	}
+
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a.Result) != nil || json.Compact(&compactB, b.Result) != nil {
+		return false
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
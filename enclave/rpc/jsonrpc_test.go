@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeResponses(t *testing.T) {
+	reqs := []request{{ID: 1, Method: "eth_chainId"}, {ID: 2, Method: "eth_blockNumber"}}
+
+	tests := []struct {
+		name    string
+		body    string
+		reqs    []request
+		want    []string
+		wantErr bool
+	}{
+		{name: "single", body: `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, reqs: reqs[:1], want: []string{`"0x1"`}},
+		{name: "batch out of order", body: `[{"jsonrpc":"2.0","id":2,"result":"0x10"},{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, reqs: reqs, want: []string{`"0x1"`, `"0x10"`}},
+		{name: "null result", body: `{"jsonrpc":"2.0","id":1,"result":null}`, reqs: reqs[:1], want: []string{`null`}},
+		{name: "missing response", body: `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, reqs: reqs, wantErr: true},
+		{name: "no result or error", body: `{"jsonrpc":"2.0","id":1}`, reqs: reqs[:1], wantErr: true},
+		{name: "not json", body: `<html>`, reqs: reqs[:1], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses, err := decodeResponses([]byte(tt.body), tt.reqs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeResponses() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for i, want := range tt.want {
+				if string(responses[i].Result) != want {
+					t.Errorf("response %d = %s, want %s", i, responses[i].Result, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSameResponse(t *testing.T) {
+	result := func(raw string) response { return response{Result: json.RawMessage(raw)} }
+	failure := func(message string) response { return response{Error: &Error{Code: 3, Message: message}} }
+
+	tests := []struct {
+		name string
+		a, b response
+		want bool
+	}{
+		{name: "equal results", a: result(`"0x1"`), b: result(`"0x1"`), want: true},
+		{name: "equal apart from whitespace", a: result(`{"a": 1}`), b: result(`{"a":1}`), want: true},
+		{name: "different results", a: result(`"0x1"`), b: result(`"0x2"`)},
+		{name: "both errors", a: failure("execution reverted"), b: failure("revert"), want: true},
+		{name: "error and result", a: failure("execution reverted"), b: result(`"0x"`)},
+	}
+
+	for _, tt := range tests {
+		if got := sameResponse(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: sameResponse() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
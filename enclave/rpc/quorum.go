@@ -0,0 +1,229 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reads the requests from every provider, each over its own TLS connection, and returns the answer a quorum of them agree on.
+// Named blocks in the params are pinned to one block number first so all providers answer for the same state.
+func (c *Client) quorumRead(ctx context.Context, reqs []request) ([]response, error) {
+	pinned, err := c.pinBlocks(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	answers, errs := c.sendAll(ctx, pinned)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return c.vote(pinned, answers, errs)
+}
+
+// Returns a copy of reqs with every named block param replaced by the block number a quorum of providers has reached
+func (c *Client) pinBlocks(ctx context.Context, reqs []request) ([]request, error) {
+	var tags []BlockTag
+	for _, req := range reqs {
+		for _, param := range req.Params {
+			tag, ok := namedBlock(param)
+			if !ok || containsTag(tags, tag) {
+				continue
+			}
+			// Every provider has its own pending transactions, there is no pending block a quorum could agree on
+			if tag == "pending" {
+				return nil, fmt.Errorf("rpc: %s of chain %d can not be read at the pending block with a quorum", req.Method, c.ChainID)
+			}
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return reqs, nil
+	}
+
+	numbers, err := c.quorumBlocks(ctx, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := make([]request, len(reqs))
+	for i, req := range reqs {
+		pinned[i] = req
+		pinned[i].Params = make([]interface{}, len(req.Params))
+		for j, param := range req.Params {
+			if tag, ok := namedBlock(param); ok {
+				param = AtBlock(numbers[tag])
+			}
+			pinned[i].Params[j] = param
+		}
+	}
+	return pinned, nil
+}
+
+// Returns the number of each named block a quorum of providers has reached. That is the quorum-th highest number the providers
+// report, so providers lying about how far the chain is can not pin a block the honest ones do not have.
+func (c *Client) quorumBlocks(ctx context.Context, tags []BlockTag) (map[BlockTag]uint64, error) {
+	reqs := make([]request, len(tags))
+	for i, tag := range tags {
+		reqs[i] = request{JSONRPC: "2.0", ID: c.nextId.Add(1), Method: "eth_getBlockByNumber", Params: []interface{}{tag, false}}
+	}
+
+	answers, errs := c.sendAll(ctx, reqs)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	numbers := make(map[BlockTag]uint64, len(tags))
+	for i, tag := range tags {
+		var reported []uint64
+		for j, answer := range answers {
+			if errs[j] != nil {
+				continue
+			}
+			number, err := blockNumberOf(answer[i])
+			if err != nil {
+				log.Warnf("RPC provider %s of chain %d gave no %s block: %v", c.providers[j].Name, c.ChainID, tag, err)
+				continue
+			}
+			reported = append(reported, number)
+		}
+
+		if len(reported) < c.quorum {
+			return nil, fmt.Errorf("%w for chain %d, %d of %d providers reported the %s block", ErrNoQuorum, c.ChainID, len(reported), len(c.providers), tag)
+		}
+		sort.Slice(reported, func(a, b int) bool { return reported[a] > reported[b] })
+		numbers[tag] = reported[c.quorum-1]
+		log.Debugf("Pinned the %s block of chain %d to %d", tag, c.ChainID, numbers[tag])
+	}
+	return numbers, nil
+}
+
+// Sends the requests to every provider at once. A provider that is skipped or fails has an error instead of an answer.
+func (c *Client) sendAll(ctx context.Context, reqs []request) ([][]response, []error) {
+	answers := make([][]response, len(c.providers))
+	errs := make([]error, len(c.providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range c.providers {
+		wg.Add(1)
+		go func(i int, provider *Provider) {
+			defer wg.Done()
+			if errs[i] = provider.breaker.Allow(); errs[i] == nil {
+				answers[i], errs[i] = provider.send(ctx, reqs)
+			}
+		}(i, provider)
+	}
+	wg.Wait()
+	return answers, errs
+}
+
+// Groups the answers of the providers by what they say and returns the answer of the group of at least quorum providers
+func (c *Client) vote(reqs []request, answers [][]response, errs []error) ([]response, error) {
+	type group struct {
+		answer    []response
+		providers []string
+	}
+
+	var groups []*group
+	answered := 0
+	for i, answer := range answers {
+		if errs[i] != nil {
+			log.Warnf("RPC provider %s of chain %d failed, it does not count towards the quorum: %v", c.providers[i].Name, c.ChainID, errs[i])
+			continue
+		}
+		answered++
+
+		var match *group
+		for _, g := range groups {
+			if sameAnswer(g.answer, answer) {
+				match = g
+				break
+			}
+		}
+		if match == nil {
+			match = &group{answer: answer}
+			groups = append(groups, match)
+		}
+		match.providers = append(match.providers, c.providers[i].Name)
+	}
+
+	for _, g := range groups {
+		if len(g.providers) >= c.quorum {
+			if len(groups) > 1 {
+				log.Errorf("RPC providers of chain %d disagree on %s, accepting the answer of %s", c.ChainID, methods(reqs), strings.Join(g.providers, ", "))
+			}
+			return g.answer, nil
+		}
+	}
+
+	if len(groups) > 1 {
+		log.Errorf("RPC providers of chain %d disagree on %s and no answer has a quorum of %d", c.ChainID, methods(reqs), c.quorum)
+		return nil, fmt.Errorf("%w on %s for chain %d", ErrProviderMismatch, methods(reqs), c.ChainID)
+	}
+	return nil, fmt.Errorf("%w for chain %d, %d of %d providers answered", ErrNoQuorum, c.ChainID, answered, len(c.providers))
+}
+
+// Reports whether two providers answered every request the same
+func sameAnswer(a, b []response) bool {
+	for i := range a {
+		if !sameResponse(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the block number in an eth_getBlockByNumber response
+func blockNumberOf(resp response) (uint64, error) {
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	var block struct {
+		Number *data.BigInt `json:"number"`
+	}
+	if err := json.Unmarshal(resp.Result, &block); err != nil {
+		return 0, fmt.Errorf("invalid block: %w", err)
+	}
+	if block.Number == nil {
+		return 0, fmt.Errorf("block not found")
+	}
+	return quantityUint64(block.Number, "block number")
+}
+
+// Returns the tag of a param that names a block, like latest, rather than giving its number. Besides a BlockTag a plain string
+// naming a block is a tag too, so callers passing "latest" are pinned as well.
+func namedBlock(param interface{}) (BlockTag, bool) {
+	switch param := param.(type) {
+	case BlockTag:
+		return param, !strings.HasPrefix(string(param), "0x")
+	case string:
+		switch param {
+		case "latest", "safe", "finalized", "earliest", "pending":
+			return BlockTag(param), true
+		}
+	}
+	return "", false
+}
+
+func containsTag(tags []BlockTag, tag BlockTag) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func methods(reqs []request) string {
+	names := make([]string, len(reqs))
+	for i, req := range reqs {
+		names[i] = req.Method
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,390 @@
+// Package rpctest is an in-memory fake of an Ethereum JSON-RPC provider for tests. It serves the methods the rpc helpers use,
+// single and batched, from state the test sets, and can be scripted to fail requests. State is the same at every block up to the
+// latest one, reads at a later block fail like on a provider that has not seen that block yet.
+package rpctest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+)
+
+// Function selectors the ERC-20 and price feed helpers answer
+var (
+	selectorBalanceOf       = []byte{0x70, 0xa0, 0x82, 0x31}
+	selectorAllowance       = []byte{0xdd, 0x62, 0xed, 0x3e}
+	selectorDecimals        = []byte{0x31, 0x3c, 0xe5, 0x67}
+	selectorLatestRoundData = []byte{0xfe, 0xaf, 0x96, 0x8c}
+)
+
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type callArg struct {
+	To   data.Address  `json:"to"`
+	Data data.HexBytes `json:"data"`
+}
+
+// Server is a fake JSON-RPC provider listening on a local httptest server
+type Server struct {
+	URL     string
+	ChainID uint64
+
+	server *httptest.Server
+
+	mu          sync.Mutex
+	blockNumber uint64
+	balances    map[data.Address]*big.Int
+	nonces      map[data.Address]uint64
+	code        map[data.Address][]byte
+	calls       map[string][]byte // eth_call results by target and calldata
+	reverts     map[string]bool   // eth_calls that revert by target and calldata
+	faults      []int             // Statuses the next requests fail with
+	requests    int
+	methods     map[string]int
+	blocks      map[string]string // Last block param by method
+}
+
+// Starts a fake provider of a chain
+func NewServer(chainId uint64) *Server {
+	s := &Server{
+		ChainID:  chainId,
+		balances: make(map[data.Address]*big.Int),
+		nonces:   make(map[data.Address]uint64),
+		code:     make(map[data.Address][]byte),
+		calls:    make(map[string][]byte),
+		reverts:  make(map[string]bool),
+		methods:  make(map[string]int),
+		blocks:   make(map[string]string),
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+	return s
+}
+
+// Shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Returns the transport that reaches the server
+func (s *Server) Transport() http.RoundTripper {
+	return s.server.Client().Transport
+}
+
+// Sets the latest block number
+func (s *Server) SetBlockNumber(number uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blockNumber = number
+}
+
+// Sets the wei balance of an account
+func (s *Server) SetBalance(account data.Address, wei *big.Int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.balances[account] = wei
+}
+
+// Sets the nonce of an account
+func (s *Server) SetNonce(account data.Address, nonce uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nonces[account] = nonce
+}
+
+// Sets the code of an account
+func (s *Server) SetCode(account data.Address, code []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.code[account] = code
+}
+
+// Sets what an eth_call of calldata on to returns, calls nothing was set for return no data like a call to an account without code
+func (s *Server) SetCallResult(to data.Address, calldata []byte, result []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[callKey(to, calldata)] = result
+}
+
+// Makes an eth_call of calldata on to revert
+func (s *Server) SetCallRevert(to data.Address, calldata []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reverts[callKey(to, calldata)] = true
+}
+
+// Sets the ERC-20 balanceOf(owner) of token
+func (s *Server) SetERC20Balance(token, owner data.Address, amount *big.Int) {
+	s.SetCallResult(token, call(selectorBalanceOf, owner), word(amount))
+}
+
+// Sets the ERC-20 allowance(owner, spender) of token
+func (s *Server) SetERC20Allowance(token, owner, spender data.Address, amount *big.Int) {
+	s.SetCallResult(token, call(selectorAllowance, owner, spender), word(amount))
+}
+
+// Sets the decimals and latest round of a Chainlink price feed, answer may be negative
+func (s *Server) SetOraclePrice(feed data.Address, roundId uint64, answer *big.Int, decimals uint8, updatedAt time.Time) {
+	encodedAnswer := new(big.Int).Set(answer)
+	if encodedAnswer.Sign() < 0 {
+		encodedAnswer.Add(encodedAnswer, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	updated := big.NewInt(updatedAt.Unix())
+	round := new(big.Int).SetUint64(roundId)
+
+	var latestRound []byte
+	for _, n := range []*big.Int{round, encodedAnswer, updated, updated, round} {
+		latestRound = append(latestRound, word(n)...)
+	}
+
+	s.SetCallResult(feed, selectorDecimals, word(big.NewInt(int64(decimals))))
+	s.SetCallResult(feed, selectorLatestRoundData, latestRound)
+}
+
+// Makes the next request fail with an http status. Faults queue up in the order they were added.
+func (s *Server) FailNext(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, status)
+}
+
+// Returns the number of http requests the server got
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// Returns the number of calls of a method the server got, counting each call of a batch
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.methods[method]
+}
+
+// Returns the block param of the last call of a method, empty if it was never called
+func (s *Server) LastBlock(method string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.blocks[method]
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if len(s.faults) > 0 {
+		status := s.faults[0]
+		s.faults = s.faults[1:]
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "json-rpc needs POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var reqs []rpcRequest
+		if err := json.Unmarshal(trimmed, &reqs); err != nil {
+			json.NewEncoder(w).Encode(parseError())
+			return
+		}
+		responses := make([]rpcResponse, len(reqs))
+		for i, req := range reqs {
+			responses[i] = s.answer(req)
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(trimmed, &req); err != nil {
+		json.NewEncoder(w).Encode(parseError())
+		return
+	}
+	json.NewEncoder(w).Encode(s.answer(req))
+}
+
+// Answers one call, the lock is held
+func (s *Server) answer(req rpcRequest) rpcResponse {
+	s.methods[req.Method]++
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+
+	fail := func(code int, message string) rpcResponse {
+		resp.Error = &rpcError{Code: code, Message: message}
+		return resp
+	}
+
+	switch req.Method {
+	case "eth_chainId":
+		resp.Result = quantity(new(big.Int).SetUint64(s.ChainID))
+
+	case "eth_blockNumber":
+		resp.Result = quantity(new(big.Int).SetUint64(s.blockNumber))
+
+	case "eth_getBlockByNumber":
+		if len(req.Params) != 2 {
+			return fail(-32602, "invalid params")
+		}
+		number, ok := s.block(req.Params[0])
+		if !ok {
+			return fail(-32602, "invalid block")
+		}
+		if number > s.blockNumber {
+			resp.Result = json.RawMessage("null")
+			break
+		}
+		resp.Result = map[string]string{
+			"number": quantity(new(big.Int).SetUint64(number)),
+			"hash":   fmt.Sprintf("0x%064x", number),
+		}
+
+	case "eth_getBalance", "eth_getTransactionCount", "eth_getCode":
+		var account data.Address
+		if len(req.Params) != 2 || json.Unmarshal(req.Params[0], &account) != nil {
+			return fail(-32602, "invalid params")
+		}
+		if err := s.stateAt(req.Method, req.Params[1]); err != nil {
+			return fail(err.Code, err.Message)
+		}
+		switch req.Method {
+		case "eth_getBalance":
+			balance := s.balances[account]
+			if balance == nil {
+				balance = new(big.Int)
+			}
+			resp.Result = quantity(balance)
+		case "eth_getTransactionCount":
+			resp.Result = quantity(new(big.Int).SetUint64(s.nonces[account]))
+		default:
+			resp.Result = data.HexBytes(s.code[account]).String()
+		}
+
+	case "eth_call":
+		var arg callArg
+		if len(req.Params) != 2 || json.Unmarshal(req.Params[0], &arg) != nil {
+			return fail(-32602, "invalid params")
+		}
+		if err := s.stateAt(req.Method, req.Params[1]); err != nil {
+			return fail(err.Code, err.Message)
+		}
+		key := callKey(arg.To, arg.Data)
+		if s.reverts[key] {
+			return fail(3, "execution reverted")
+		}
+		resp.Result = data.HexBytes(s.calls[key]).String()
+
+	default:
+		return fail(-32601, fmt.Sprintf("the method %s does not exist/is not available", req.Method))
+	}
+	return resp
+}
+
+func parseError() rpcResponse {
+	return rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: -32700, Message: "parse error"}}
+}
+
+// Records the block a state read is at and fails it if the block is invalid or later than the latest block
+func (s *Server) stateAt(method string, raw json.RawMessage) *rpcError {
+	number, ok := s.block(raw)
+	if !ok {
+		return &rpcError{Code: -32602, Message: "invalid block"}
+	}
+	var tag string
+	json.Unmarshal(raw, &tag)
+	s.blocks[method] = tag
+	if number > s.blockNumber {
+		return &rpcError{Code: -32000, Message: "header not found"}
+	}
+	return nil
+}
+
+// Returns the number of a block param, a named block is the latest block
+func (s *Server) block(raw json.RawMessage) (uint64, bool) {
+	var block string
+	if json.Unmarshal(raw, &block) != nil {
+		return 0, false
+	}
+	switch block {
+	case "latest", "safe", "finalized", "pending":
+		return s.blockNumber, true
+	case "earliest":
+		return 0, true
+	}
+	if !strings.HasPrefix(block, "0x") {
+		return 0, false
+	}
+	number, err := strconv.ParseUint(strings.TrimPrefix(block, "0x"), 16, 64)
+	return number, err == nil
+}
+
+func callKey(to data.Address, calldata []byte) string {
+	return to.String() + "/" + hex.EncodeToString(calldata)
+}
+
+func call(selector []byte, args ...data.Address) []byte {
+	calldata := append([]byte{}, selector...)
+	for _, arg := range args {
+		calldata = append(calldata, make([]byte, 12)...)
+		calldata = append(calldata, arg[:]...)
+	}
+	return calldata
+}
+
+func word(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+func quantity(n *big.Int) string {
+	return "0x" + n.Text(16)
+}
@@ -0,0 +1,206 @@
+// Package secp256k1 is a minimal secp256k1 for Ethereum signatures, used by the local signer and the fake Privy server. It is
+// not constant time, which is fine for dev and tests but is why production signing stays with Privy.
+package secp256k1
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Curve parameters
+var (
+	fieldP, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	order, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	gx, _     = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	gy, _     = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+	curveB    = big.NewInt(7)
+	halfN     = new(big.Int).Rsh(order, 1)
+)
+
+// Affine point, nil coordinates are the point at infinity
+type Point struct {
+	X, Y *big.Int
+}
+
+func (pt Point) isInfinity() bool {
+	return pt.X == nil
+}
+
+func add(a, b Point) Point {
+	if a.isInfinity() {
+		return b
+	}
+	if b.isInfinity() {
+		return a
+	}
+
+	var lambda *big.Int
+	if a.X.Cmp(b.X) == 0 {
+		sum := new(big.Int).Add(a.Y, b.Y)
+		if sum.Mod(sum, fieldP).Sign() == 0 {
+			return Point{}
+		}
+		// Doubling, lambda = 3x^2 / 2y
+		num := new(big.Int).Mul(a.X, a.X)
+		num.Mul(num, big.NewInt(3))
+		den := new(big.Int).Lsh(a.Y, 1)
+		lambda = num.Mul(num, den.ModInverse(den, fieldP))
+	} else {
+		num := new(big.Int).Sub(b.Y, a.Y)
+		den := new(big.Int).Sub(b.X, a.X)
+		den.Mod(den, fieldP)
+		lambda = num.Mul(num, den.ModInverse(den, fieldP))
+	}
+	lambda.Mod(lambda, fieldP)
+
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, a.X).Sub(x, b.X).Mod(x, fieldP)
+
+	y := new(big.Int).Sub(a.X, x)
+	y.Mul(y, lambda).Sub(y, a.Y).Mod(y, fieldP)
+
+	return Point{X: x, Y: y}
+}
+
+func mul(pt Point, k *big.Int) Point {
+	result := Point{}
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = add(result, result)
+		if k.Bit(i) == 1 {
+			result = add(result, pt)
+		}
+	}
+	return result
+}
+
+// Returns the public key of the private key d
+func PublicKey(d *big.Int) Point {
+	return mul(Point{X: gx, Y: gy}, d)
+}
+
+// Derives a private key from seed, reduced into [1, n-1]
+func PrivateKeyFromSeed(seed []byte) *big.Int {
+	d := new(big.Int).SetBytes(seed)
+	return d.Mod(d, new(big.Int).Sub(order, big.NewInt(1))).Add(d, big.NewInt(1))
+}
+
+// Returns the point with the given x and y parity, if x is on the curve
+func decompress(x *big.Int, odd bool) (Point, error) {
+	if x.Cmp(fieldP) >= 0 {
+		return Point{}, errors.New("x is not a field element")
+	}
+
+	// y^2 = x^3 + 7, fieldP = 3 mod 4 so y = (y^2)^((fieldP+1)/4)
+	ySquared := new(big.Int).Exp(x, big.NewInt(3), fieldP)
+	ySquared.Add(ySquared, curveB).Mod(ySquared, fieldP)
+
+	exp := new(big.Int).Add(fieldP, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(ySquared, exp, fieldP)
+	if new(big.Int).Exp(y, big.NewInt(2), fieldP).Cmp(ySquared) != 0 {
+		return Point{}, errors.New("x is not on the curve")
+	}
+
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(fieldP, y)
+	}
+	return Point{X: x, Y: y}, nil
+}
+
+// Signs a 32 byte hash and returns the 65 byte [R || S || V] signature with V = 27 + recovery id, S normalized to the lower half
+// of the curve order like Ethereum requires
+func Sign(d *big.Int, hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("hash must be 32 bytes, got %d", len(hash))
+	}
+	e := new(big.Int).SetBytes(hash)
+
+	for {
+		k, err := rand.Int(rand.Reader, new(big.Int).Sub(order, big.NewInt(1)))
+		if err != nil {
+			return nil, err
+		}
+		k.Add(k, big.NewInt(1))
+
+		point := PublicKey(k)
+		r := new(big.Int).Mod(point.X, order)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, e).Mul(s, new(big.Int).ModInverse(k, order)).Mod(s, order)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		recoveryId := byte(point.Y.Bit(0))
+		if point.X.Cmp(order) >= 0 {
+			recoveryId |= 2
+		}
+		if s.Cmp(halfN) > 0 {
+			s.Sub(order, s)
+			recoveryId ^= 1
+		}
+
+		signature := make([]byte, 65)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:64])
+		signature[64] = 27 + recoveryId
+		return signature, nil
+	}
+}
+
+// Recovers the public key of a 65 byte [R || S || V] signature over hash, V being 0, 1, 27 or 28
+func Recover(hash []byte, signature []byte) (Point, error) {
+	if len(hash) != 32 || len(signature) != 65 {
+		return Point{}, errors.New("invalid hash or signature length")
+	}
+
+	v := signature[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return Point{}, fmt.Errorf("unsupported recovery id %d", v)
+	}
+
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:64])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(order) >= 0 || s.Cmp(order) >= 0 {
+		return Point{}, errors.New("signature values out of range")
+	}
+
+	R, err := decompress(r, v == 1)
+	if err != nil {
+		return Point{}, err
+	}
+
+	// Q = r^-1 (sR - eG)
+	rInv := new(big.Int).ModInverse(r, order)
+	e := new(big.Int).SetBytes(hash)
+	negE := new(big.Int).Neg(e)
+	negE.Mod(negE, order)
+
+	q := add(mul(R, s), PublicKey(negE))
+	q = mul(q, rInv)
+	if q.isInfinity() {
+		return Point{}, errors.New("recovered the point at infinity")
+	}
+	return q, nil
+}
+
+// Ethereum address of a public key, the last 20 bytes of the keccak256 of its uncompressed coordinates
+func Address(pub Point) string {
+	coordinates := make([]byte, 64)
+	pub.X.FillBytes(coordinates[:32])
+	pub.Y.FillBytes(coordinates[32:])
+
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(coordinates)
+	return fmt.Sprintf("0x%x", hasher.Sum(nil)[12:])
+}
@@ -0,0 +1,90 @@
+package secp256k1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+func TestAddress_KnownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		d    int64
+		want string
+	}{
+		{name: "key 1", d: 1, want: "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf"},
+		{name: "key 2", d: 2, want: "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf"},
+		{name: "key 3", d: 3, want: "0x6813eb9362372eef6200f3b1dbc3f819671cba69"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Address(PublicKey(big.NewInt(tt.d))); got != tt.want {
+				t.Errorf("Address() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign_Recover(t *testing.T) {
+	d, _ := new(big.Int).SetString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", 16)
+	pub := PublicKey(d)
+	hash := sha256.Sum256([]byte("local signer"))
+
+	for i := 0; i < 8; i++ {
+		signature, err := Sign(d, hash[:])
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		if len(signature) != 65 {
+			t.Fatalf("len(signature) = %d, want 65", len(signature))
+		}
+		if v := signature[64]; v != 27 && v != 28 {
+			t.Fatalf("v = %d, want 27 or 28", v)
+		}
+		if s := new(big.Int).SetBytes(signature[32:64]); s.Cmp(halfN) > 0 {
+			t.Fatal("s is not in the lower half of the curve order")
+		}
+
+		recovered, err := Recover(hash[:], signature)
+		if err != nil {
+			t.Fatalf("Recover() error = %v", err)
+		}
+		if recovered.X.Cmp(pub.X) != 0 || recovered.Y.Cmp(pub.Y) != 0 {
+			t.Fatal("Recover() did not recover the signing key")
+		}
+	}
+}
+
+func TestRecover_Invalid(t *testing.T) {
+	hash := bytes.Repeat([]byte{1}, 32)
+	valid, err := Sign(big.NewInt(7), hash)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	badV := append([]byte(nil), valid...)
+	badV[64] = 30
+	zeroR := append([]byte(nil), valid...)
+	copy(zeroR[:32], make([]byte, 32))
+
+	tests := []struct {
+		name      string
+		hash      []byte
+		signature []byte
+	}{
+		{name: "short hash", hash: hash[:31], signature: valid},
+		{name: "short signature", hash: hash, signature: valid[:64]},
+		{name: "bad recovery id", hash: hash, signature: badV},
+		{name: "zero r", hash: hash, signature: zeroR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Recover(tt.hash, tt.signature); err == nil {
+				t.Error("Recover() error = nil, want error")
+			}
+		})
+	}
+}
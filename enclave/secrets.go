@@ -0,0 +1,214 @@
+package enclave
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	secretmanager "github.com/getaxal/verified-signer/common/aws/secret_manager"
+	"github.com/getaxal/verified-signer/enclave/keystore"
+)
+
+// Config for where the privy and axal secrets are loaded from and how they are cached. Secrets are refreshed in the background and
+// subscribers are told about rotations, the replaced version stays valid for the grace window.
+type SecretsConfig struct {
+	Provider               string            `yaml:"provider"`                 // "aws" (default), "file", "env" or "memory"
+	File                   string            `yaml:"file"`                     // JSON file of secret name to value, for the file provider
+	EnvPrefix              string            `yaml:"env_prefix"`               // Prefix of the variables read by the env provider, AXAL_SECRET_ if unset
+	Values                 map[string]string `yaml:"values" secret:"true"`     // Secret name to value, for the memory provider
+	PrivySecretName        string            `yaml:"privy_secret_name"`        // Name of the privy secret, <env>/privy if unset
+	PrivySecretPath        string            `yaml:"privy_secret_path"`        // JSON path of the privy config in the secret, the whole secret if unset
+	AxalSecretName         string            `yaml:"axal_secret_name"`         // Name of the axal secret, <env>/axal if unset
+	AxalSecretPath         string            `yaml:"axal_secret_path"`         // JSON path of the axal config in the secret, the whole secret if unset
+	RefreshIntervalSeconds int64             `yaml:"refresh_interval_seconds"` // How often secrets are refetched, 5 minutes if unset
+	GraceWindowSeconds     int64             `yaml:"grace_window_seconds"`     // How long a rotated out secret is still accepted, 1 hour if unset
+	VersionStage           string            `yaml:"version_stage"`            // Staging label to follow, AWSCURRENT if unset
+	PrivyVersionId         string            `yaml:"privy_version_id"`         // Pins the privy secret to a version, pinned secrets are never refreshed
+	AxalVersionId          string            `yaml:"axal_version_id"`          // Pins the axal secret to a version
+}
+
+// Creates the secret provider selected by the secrets config. Only the aws provider talks to AWS, the others let the enclave run
+// on a laptop and in tests.
+func NewSecretProvider(configPath string, cfg *TEEConfig) (secretmanager.SecretProvider, error) {
+	switch cfg.Secrets.Provider {
+	case "", "aws":
+		if cfg.Ports.AWSSecretManagerVsockPort == 0 {
+			return nil, fmt.Errorf("the aws secret provider needs aws_secret_manager_vsock_port")
+		}
+		creds, err := cfg.awsCredentials(configPath)
+		if err != nil {
+			return nil, err
+		}
+		return secretmanager.NewSecretManagerWithProvider(creds, cfg.Environment, aws.AWSRegion(cfg.Region), cfg.Ports.AWSSecretManagerVsockPort)
+	case "file":
+		if cfg.Secrets.File == "" {
+			return nil, fmt.Errorf("the file secret provider needs secrets.file")
+		}
+		return secretmanager.NewFileProvider(cfg.Secrets.File), nil
+	case "env":
+		return secretmanager.NewEnvProvider(cfg.Secrets.EnvPrefix), nil
+	case "memory":
+		return secretmanager.NewMemoryProvider(cfg.Secrets.Values), nil
+	default:
+		return nil, fmt.Errorf("unknown secret provider: %s", cfg.Secrets.Provider)
+	}
+}
+
+// SecretCache returns the cache the config secrets were loaded through, nil if the config was not loaded with LoadTEEConfig
+func (cfg *TEEConfig) SecretCache() *secretmanager.SecretCache {
+	return cfg.secretCache
+}
+
+// PrivySecretRef returns the secret holding the privy config, the configured name or the one for the environment
+func (cfg *TEEConfig) PrivySecretRef() (secretmanager.SecretRef, error) {
+	name := cfg.Secrets.PrivySecretName
+	if name == "" {
+		switch cfg.Environment {
+		case "prod":
+			name = "prod/privy"
+		case "dev", "local":
+			name = "dev/privy"
+		case "staging":
+			name = "staging/privy"
+		default:
+			return secretmanager.SecretRef{}, fmt.Errorf("invalid environment, no such env: %s", cfg.Environment)
+		}
+	}
+
+	return secretmanager.SecretRef{
+		Name:         name,
+		VersionId:    cfg.Secrets.PrivyVersionId,
+		VersionStage: cfg.Secrets.VersionStage,
+	}, nil
+}
+
+// AxalSecretRef returns the secret holding the axal config, the configured name or the one for the environment
+func (cfg *TEEConfig) AxalSecretRef() secretmanager.SecretRef {
+	name := cfg.Secrets.AxalSecretName
+	if name == "" {
+		name = fmt.Sprintf("%s/%s", cfg.Environment, "axal")
+	}
+
+	return secretmanager.SecretRef{
+		Name:         name,
+		VersionId:    cfg.Secrets.AxalVersionId,
+		VersionStage: cfg.Secrets.VersionStage,
+	}
+}
+
+// DecodePrivySecret parses and validates a version of the privy secret, decrypting its KMS encrypted fields if configured
+func (cfg *TEEConfig) DecodePrivySecret(secret secretmanager.Secret) (*PrivyConfig, error) {
+	config, err := secretmanager.DecodeSecret[PrivyConfig](secret, cfg.Secrets.PrivySecretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := map[string]*string{
+		"privy app_secret":            &config.AppSecret,
+		"privy delegated_actions_key": &config.DelegatedActionsKey,
+	}
+	for i := range config.AuthorizationKeys {
+		secrets[fmt.Sprintf("privy authorization_keys[%d]", i)] = &config.AuthorizationKeys[i]
+	}
+
+	err = cfg.decryptSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// DecodeAxalSecret parses a version of the axal secret, decrypting its KMS encrypted fields if configured
+func (cfg *TEEConfig) DecodeAxalSecret(secret secretmanager.Secret) (*AxalConfig, error) {
+	config, err := secretmanager.DecodeSecret[AxalConfig](secret, cfg.Secrets.AxalSecretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.decryptSecrets(map[string]*string{
+		"axal_request_secret_key": &config.AxalRequestSecretKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Decrypts the given secrets with KMS when the config holds KMS encrypted secrets, otherwise leaves them as they are
+func (cfg *TEEConfig) decryptSecrets(secrets map[string]*string) error {
+	if !cfg.KMS.EncryptedSecrets {
+		return nil
+	}
+
+	if cfg.kmsClient == nil {
+		return fmt.Errorf("kms encrypted secrets configured without a kms client")
+	}
+
+	return decryptKMSSecrets(cfg.kmsClient, cfg.KMS.KeyID, secrets)
+}
+
+// Returns the key store holding the privy and axal secrets
+func (cfg *TEEConfig) KeyStore() *keystore.KeyStore {
+	return cfg.keys
+}
+
+// Moves the privy, axal and local signer secrets of the config into a new key store. LoadTEEConfig does this once the secrets
+// are loaded, tests building a TEEConfig by hand call it themselves.
+func (cfg *TEEConfig) SealSecrets() error {
+	cfg.keys = keystore.New()
+	SealAxalSecrets(cfg.keys, &cfg.Axal, time.Time{})
+	SealPrivySecrets(cfg.keys, &cfg.Privy)
+	return SealLocalSignerSeed(cfg.keys, &cfg.Signer)
+}
+
+// Moves the privy app secret, delegated actions key and key quorum authorization keys into the key store and clears them from
+// config
+func SealPrivySecrets(keys *keystore.KeyStore, config *PrivyConfig) {
+	if config.AppSecret != "" {
+		keys.PutString(keystore.PrivyAppSecret, config.AppSecret)
+	}
+	if config.DelegatedActionsKey != "" {
+		keys.PutString(keystore.PrivyDelegatedActionsKey, config.DelegatedActionsKey)
+	}
+
+	// Quorum keys dropped by a rotation are removed
+	for i, key := range config.AuthorizationKeys {
+		keys.PutString(keystore.PrivyQuorumKey(i), key)
+	}
+	for i := len(config.AuthorizationKeys); keys.Has(keystore.PrivyQuorumKey(i)); i++ {
+		keys.Delete(keystore.PrivyQuorumKey(i))
+	}
+
+	config.AppSecret = ""
+	config.DelegatedActionsKey = ""
+	config.AuthorizationKeys = nil
+}
+
+// Moves the axal request secret key into the key store and clears it from config. The key it replaces is still accepted until
+// previousUntil.
+func SealAxalSecrets(keys *keystore.KeyStore, config *AxalConfig, previousUntil time.Time) {
+	if config.AxalRequestSecretKey != "" {
+		keys.Rotate(keystore.AxalRequestSecretKey, []byte(config.AxalRequestSecretKey), previousUntil)
+	}
+
+	config.AxalRequestSecretKey = ""
+}
+
+// Moves the local signer seed into the key store and clears it from config
+func SealLocalSignerSeed(keys *keystore.KeyStore, config *SignerConfig) error {
+	if config.LocalSeed == "" {
+		return nil
+	}
+
+	seed, err := hex.DecodeString(config.LocalSeed)
+	if err != nil {
+		return fmt.Errorf("local signer seed is not hex: %w", err)
+	}
+	keys.Put(keystore.LocalSignerSeed, seed)
+
+	config.LocalSeed = ""
+	return nil
+}
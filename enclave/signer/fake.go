@@ -0,0 +1,121 @@
+package signer
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+)
+
+// Fake is a scriptable Signer for tests. Each method calls its Func when set and otherwise behaves like a permissive in-memory
+// backend: the auth string is taken as the privy id, users get a fixed wallet and signatures are a fixed value. Every call is
+// recorded by method name.
+type Fake struct {
+	ValidateUserAuthFunc     func(authString string) (string, *apierror.Error)
+	GetUserFunc              func(ctx context.Context, privyId string) (*data.PrivyUser, *apierror.Error)
+	CreateEthWalletFunc      func(ctx context.Context, user data.PrivyUser) (*data.PrivyUser, *apierror.Error)
+	UserEthSecp256k1SignFunc func(ctx context.Context, signReq *data.UserEthSecp256k1SignRequest, authString string) (*data.EthSecp256k1SignResponse, *apierror.Error)
+	AxalEthSecp256k1SignFunc func(ctx context.Context, signReq *data.AxalEthSecp256k1SignRequest, hmacSignature string) (*data.EthSecp256k1SignResponse, *apierror.Error)
+
+	// Signature returned by the default sign methods
+	Signature string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// Creates a new Fake with the default behaviour
+func NewFake() *Fake {
+	return &Fake{Signature: "0x" + strings.Repeat("ab", 65)}
+}
+
+// Returns the names of the methods called so far, in order
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.calls...)
+}
+
+func (f *Fake) record(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, method)
+}
+
+func (f *Fake) ValidateUserAuthForSigningRequest(authString string) (string, *apierror.Error) {
+	f.record("ValidateUserAuthForSigningRequest")
+	if f.ValidateUserAuthFunc != nil {
+		return f.ValidateUserAuthFunc(authString)
+	}
+	return fakeAuth(authString)
+}
+
+func (f *Fake) GetUser(ctx context.Context, privyId string) (*data.PrivyUser, *apierror.Error) {
+	f.record("GetUser")
+	if f.GetUserFunc != nil {
+		return f.GetUserFunc(ctx, privyId)
+	}
+	return f.createEthWallet(data.PrivyUser{PrivyID: privyId})
+}
+
+func (f *Fake) CreateEthWallet(ctx context.Context, user data.PrivyUser) (*data.PrivyUser, *apierror.Error) {
+	f.record("CreateEthWallet")
+	if f.CreateEthWalletFunc != nil {
+		return f.CreateEthWalletFunc(ctx, user)
+	}
+	return f.createEthWallet(user)
+}
+
+func (f *Fake) UserEthSecp256k1Sign(ctx context.Context, signReq *data.UserEthSecp256k1SignRequest, authString string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
+	f.record("UserEthSecp256k1Sign")
+	if f.UserEthSecp256k1SignFunc != nil {
+		return f.UserEthSecp256k1SignFunc(ctx, signReq, authString)
+	}
+	if _, httpErr := fakeAuth(authString); httpErr != nil {
+		return nil, httpErr
+	}
+	return f.signature(), nil
+}
+
+func (f *Fake) AxalEthSecp256k1Sign(ctx context.Context, signReq *data.AxalEthSecp256k1SignRequest, hmacSignature string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
+	f.record("AxalEthSecp256k1Sign")
+	if f.AxalEthSecp256k1SignFunc != nil {
+		return f.AxalEthSecp256k1SignFunc(ctx, signReq, hmacSignature)
+	}
+	return f.signature(), nil
+}
+
+func (f *Fake) createEthWallet(user data.PrivyUser) (*data.PrivyUser, *apierror.Error) {
+	if user.GetUsersEthDelegatedWallet() == nil {
+		user.LinkedAccounts = append(user.LinkedAccounts, data.LinkedAccount{
+			WalletID:  "fake-wallet",
+			Type:      "wallet",
+			Address:   "0x" + strings.Repeat("00", 20),
+			ChainType: "ethereum",
+			Delegated: true,
+		})
+	}
+	return &user, nil
+}
+
+func (f *Fake) signature() *data.EthSecp256k1SignResponse {
+	return &data.EthSecp256k1SignResponse{
+		Method: "secp256k1_sign",
+		Data: data.EthSecp256k1SignResponseData{
+			Signature: f.Signature,
+			Encoding:  "hex",
+		},
+	}
+}
+
+// Accepts any privy id as its own auth
+func fakeAuth(authString string) (string, *apierror.Error) {
+	if !strings.HasPrefix(authString, "did:privy:") {
+		return "", apierror.New(apierror.AuthFailed, "Unauthorized User")
+	}
+	return authString, nil
+}
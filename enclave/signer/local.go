@@ -0,0 +1,166 @@
+package signer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/keystore"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/secp256k1"
+	"github.com/getaxal/verified-signer/enclave/verifier"
+	log "github.com/sirupsen/logrus"
+)
+
+// Shorter seeds are rejected, they would make every user key guessable
+const minLocalSeedLength = 32
+
+// LocalSigner signs with a secp256k1 key per user held in the enclave. Keys are derived from a seed kept in the key store so dev
+// wallets keep their address across restarts. It is meant for dev and tests, its wallets never exist at Privy.
+type LocalSigner struct {
+	auth *RequestAuth
+	keys *keystore.KeyStore
+
+	mu    sync.Mutex
+	users map[string]data.PrivyUser
+}
+
+// Creates a new LocalSigner deriving user keys from the seed sealed in keys under keystore.LocalSignerSeed
+func NewLocalSigner(auth *RequestAuth, keys *keystore.KeyStore) (*LocalSigner, error) {
+	err := keys.WithSecret(keystore.LocalSignerSeed, func(seed []byte) error {
+		if len(seed) < minLocalSeedLength {
+			return fmt.Errorf("local signer seed must be at least %d bytes", minLocalSeedLength)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalSigner{
+		auth:  auth,
+		keys:  keys,
+		users: make(map[string]data.PrivyUser),
+	}, nil
+}
+
+func (ls *LocalSigner) ValidateUserAuthForSigningRequest(authString string) (string, *apierror.Error) {
+	return ls.auth.ValidateUserAuth(authString)
+}
+
+func (ls *LocalSigner) GetUser(ctx context.Context, privyId string) (*data.PrivyUser, *apierror.Error) {
+	ls.mu.Lock()
+	user, ok := ls.users[privyId]
+	ls.mu.Unlock()
+
+	if !ok {
+		user = data.PrivyUser{PrivyID: privyId, CreatedAt: time.Now().Unix()}
+	}
+	return ls.CreateEthWallet(ctx, user)
+}
+
+func (ls *LocalSigner) CreateEthWallet(ctx context.Context, user data.PrivyUser) (*data.PrivyUser, *apierror.Error) {
+	if user.GetUsersEthDelegatedWallet() == nil {
+		address, err := ls.address(user.PrivyID)
+		if err != nil {
+			log.Errorf("Error deriving local wallet for user %s: %v", user.PrivyID, err)
+			return nil, apierror.InternalError(err)
+		}
+
+		log.Infof("Creating local delegated eth wallet %s for user %s", address, user.PrivyID)
+		user.LinkedAccounts = append(user.LinkedAccounts, data.LinkedAccount{
+			WalletID:         "local-" + address,
+			Type:             "wallet",
+			Address:          address,
+			ChainType:        "ethereum",
+			Delegated:        true,
+			WalletClientType: "local",
+		})
+	}
+
+	ls.mu.Lock()
+	ls.users[user.PrivyID] = user
+	ls.mu.Unlock()
+
+	return &user, nil
+}
+
+func (ls *LocalSigner) UserEthSecp256k1Sign(ctx context.Context, signReq *data.UserEthSecp256k1SignRequest, authString string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
+	privyId, httpErr := ls.auth.ValidateUserAuth(authString)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return ls.sign(ctx, privyId, signReq.Params.Hash)
+}
+
+func (ls *LocalSigner) AxalEthSecp256k1Sign(ctx context.Context, signReq *data.AxalEthSecp256k1SignRequest, hmacSignature string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
+	privyId, httpErr := ls.auth.ValidateAxalAuth(hmacSignature, signReq)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if httpErr := verifier.VerifyAxalRequest(ctx, signReq); httpErr != nil {
+		return nil, httpErr
+	}
+	return ls.sign(ctx, privyId, signReq.Params.Hash)
+}
+
+// Signs a 0x prefixed 32 byte hash with the users key, creating their wallet first if needed
+func (ls *LocalSigner) sign(ctx context.Context, privyId string, hashHex string) (*data.EthSecp256k1SignResponse, *apierror.Error) {
+	hash, err := hex.DecodeString(strings.TrimPrefix(hashHex, "0x"))
+	if err != nil || len(hash) != 32 {
+		return nil, apierror.New(apierror.InvalidHash, "hash must be 32 hex encoded bytes")
+	}
+
+	if _, httpErr := ls.GetUser(ctx, privyId); httpErr != nil {
+		return nil, httpErr
+	}
+
+	var signature []byte
+	err = ls.withUserKey(privyId, func(d *big.Int) error {
+		signature, err = secp256k1.Sign(d, hash)
+		return err
+	})
+	if err != nil {
+		log.Errorf("Error signing for user %s: %v", privyId, err)
+		return nil, apierror.InternalError(err)
+	}
+
+	return &data.EthSecp256k1SignResponse{
+		Method: "secp256k1_sign",
+		Data: data.EthSecp256k1SignResponseData{
+			Signature: "0x" + hex.EncodeToString(signature),
+			Encoding:  "hex",
+		},
+	}, nil
+}
+
+// Derives the users key, HMAC-SHA256(seed, privy id) reduced into [1, n-1], and wipes it once fn returns
+func (ls *LocalSigner) withUserKey(privyId string, fn func(d *big.Int) error) error {
+	return ls.keys.WithSecret(keystore.LocalSignerSeed, func(seed []byte) error {
+		mac := hmac.New(sha256.New, seed)
+		mac.Write([]byte(privyId))
+		sum := mac.Sum(nil)
+		defer clear(sum)
+
+		d := secp256k1.PrivateKeyFromSeed(sum)
+		defer clear(d.Bits())
+
+		return fn(d)
+	})
+}
+
+func (ls *LocalSigner) address(privyId string) (string, error) {
+	var address string
+	err := ls.withUserKey(privyId, func(d *big.Int) error {
+		address = secp256k1.Address(secp256k1.PublicKey(d))
+		return nil
+	})
+	return address, err
+}
@@ -0,0 +1,139 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/keystore"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/secp256k1"
+)
+
+func newTestLocalSigner(t *testing.T, seed []byte) *LocalSigner {
+	t.Helper()
+
+	keys := keystore.New()
+	keys.Put(keystore.LocalSignerSeed, seed)
+
+	ls, err := NewLocalSigner(&RequestAuth{Keys: keys}, keys)
+	if err != nil {
+		t.Fatalf("NewLocalSigner() error = %v", err)
+	}
+	return ls
+}
+
+func TestNewLocalSigner_Seed(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    []byte
+		wantErr bool
+	}{
+		{name: "32 byte seed", seed: bytes.Repeat([]byte{1}, 32)},
+		{name: "short seed", seed: bytes.Repeat([]byte{1}, 31), wantErr: true},
+		{name: "no seed", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := keystore.New()
+			if tt.seed != nil {
+				keys.Put(keystore.LocalSignerSeed, tt.seed)
+			}
+
+			_, err := NewLocalSigner(&RequestAuth{Keys: keys}, keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewLocalSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalSigner_GetUser(t *testing.T) {
+	ls := newTestLocalSigner(t, bytes.Repeat([]byte{1}, 32))
+
+	user, httpErr := ls.GetUser(context.Background(), "did:privy:alice")
+	if httpErr != nil {
+		t.Fatalf("GetUser() error = %v", httpErr)
+	}
+	wallet := user.GetUsersEthDelegatedWallet()
+	if wallet == nil {
+		t.Fatal("GetUser() did not create a delegated eth wallet")
+	}
+	if !strings.HasPrefix(wallet.Address, "0x") || len(wallet.Address) != 42 {
+		t.Errorf("wallet address = %s, want a 20 byte hex address", wallet.Address)
+	}
+
+	again, _ := ls.GetUser(context.Background(), "did:privy:alice")
+	if len(again.LinkedAccounts) != 1 || again.GetUsersEthDelegatedWallet().Address != wallet.Address {
+		t.Error("GetUser() created a second wallet for the same user")
+	}
+
+	other, _ := ls.GetUser(context.Background(), "did:privy:bob")
+	if other.GetUsersEthDelegatedWallet().Address == wallet.Address {
+		t.Error("two users got the same wallet")
+	}
+
+	// The same seed derives the same wallets after a restart
+	restarted := newTestLocalSigner(t, bytes.Repeat([]byte{1}, 32))
+	if got, _ := restarted.GetUser(context.Background(), "did:privy:alice"); got.GetUsersEthDelegatedWallet().Address != wallet.Address {
+		t.Error("wallet address changed with the same seed")
+	}
+}
+
+func TestLocalSigner_Sign(t *testing.T) {
+	ls := newTestLocalSigner(t, bytes.Repeat([]byte{2}, 32))
+	hash := strings.Repeat("11", 32)
+
+	t.Run("signature recovers to the users wallet", func(t *testing.T) {
+		resp, httpErr := ls.sign(context.Background(), "did:privy:alice", "0x"+hash)
+		if httpErr != nil {
+			t.Fatalf("sign() error = %v", httpErr)
+		}
+
+		signature, err := hex.DecodeString(strings.TrimPrefix(resp.Data.Signature, "0x"))
+		if err != nil {
+			t.Fatalf("signature is not hex: %v", err)
+		}
+		hashBytes, _ := hex.DecodeString(hash)
+		pub, err := secp256k1.Recover(hashBytes, signature)
+		if err != nil {
+			t.Fatalf("Recover() error = %v", err)
+		}
+
+		user, _ := ls.GetUser(context.Background(), "did:privy:alice")
+		if got, want := secp256k1.Address(pub), user.GetUsersEthDelegatedWallet().Address; got != want {
+			t.Errorf("signature recovers to %s, want %s", got, want)
+		}
+	})
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "not hex", hash: "0xzz"},
+		{name: "short hash", hash: "0x" + hash[:62]},
+		{name: "long hash", hash: "0x" + hash + "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, httpErr := ls.sign(context.Background(), "did:privy:alice", tt.hash)
+			if httpErr == nil || httpErr.Status() != http.StatusBadRequest {
+				t.Errorf("sign() error = %v, want 400", httpErr)
+			}
+		})
+	}
+}
+
+func TestLocalSigner_UserSignRejectsInvalidJWT(t *testing.T) {
+	ls := newTestLocalSigner(t, bytes.Repeat([]byte{3}, 32))
+
+	_, httpErr := ls.UserEthSecp256k1Sign(context.Background(), data.NewUserEthSecp256k1SignRequest("0x"+strings.Repeat("11", 32)), "not-a-jwt")
+	if httpErr == nil || httpErr.Status() != http.StatusUnauthorized {
+		t.Errorf("UserEthSecp256k1Sign() error = %v, want 401", httpErr)
+	}
+}
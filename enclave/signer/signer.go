@@ -0,0 +1,80 @@
+// Package signer defines the custody backend the router signs through. The Privy client is the production Signer, the local key
+// signer and the fake let the enclave and its HTTP layer run without Privy.
+package signer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/controls"
+	"github.com/getaxal/verified-signer/enclave/keystore"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/auth"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// Signer holds the users delegated wallets and signs with them. Methods that may call out take the context of the request they
+// serve, so a client going away aborts the call.
+type Signer interface {
+	// Validates a users privy JWT and returns their privy id
+	ValidateUserAuthForSigningRequest(authString string) (string, *apierror.Error)
+
+	// Gets a user, creating their delegated eth wallet if they do not have one yet
+	GetUser(ctx context.Context, privyId string) (*data.PrivyUser, *apierror.Error)
+
+	// Creates a delegated eth wallet for the user unless they already have one
+	CreateEthWallet(ctx context.Context, user data.PrivyUser) (*data.PrivyUser, *apierror.Error)
+
+	// User initiated secp256k1_sign, authenticated with the users privy JWT
+	UserEthSecp256k1Sign(ctx context.Context, signReq *data.UserEthSecp256k1SignRequest, authString string) (*data.EthSecp256k1SignResponse, *apierror.Error)
+
+	// Axal initiated secp256k1_sign, authenticated with the axal HMAC, subject to the kill switch and emergency pause and checked by
+	// the verifier
+	AxalEthSecp256k1Sign(ctx context.Context, signReq *data.AxalEthSecp256k1SignRequest, hmacSignature string) (*data.EthSecp256k1SignResponse, *apierror.Error)
+}
+
+// RequestAuth authenticates signing requests the way the Privy signer does, for signers that do not talk to Privy
+type RequestAuth struct {
+	AppID               string
+	Environment         string
+	JWTVerificationKeys []string
+	Keys                *keystore.KeyStore // Holds the axal request secret key
+}
+
+// Validates a users privy JWT and returns their privy id
+func (a *RequestAuth) ValidateUserAuth(authString string) (string, *apierror.Error) {
+	privyId, err := auth.ValidateJWTWithKeys(authString, a.JWTVerificationKeys, a.AppID, a.Environment)
+	if err != nil {
+		log.Errorf("invalid privy jwt with err: %v", err)
+		return "", apierror.Wrap(apierror.AuthFailed, "Unauthorized User", err)
+	}
+	return privyId, nil
+}
+
+// Validates the axal HMAC of a signing request and checks axal signing is allowed for the user, returns the users privy id
+func (a *RequestAuth) ValidateAxalAuth(hmacSignature string, signReq *data.AxalEthSecp256k1SignRequest) (string, *apierror.Error) {
+	if !auth.VerifyAxalSignatureWithKeyStore(signReq.Params.Hash, hmacSignature, a.Keys) {
+		log.Errorf("invalid HMAC signature for payload: %s", signReq.Params.Hash)
+		return "", apierror.New(apierror.AuthFailed, "Unauthorized User - Invalid HMAC")
+	}
+
+	if err := controls.CheckAxalSigningAllowed(signReq.PrivyID); err != nil {
+		log.Errorf("axal signing not allowed for user %s with err: %v", signReq.PrivyID, err)
+		return "", ControlError(err)
+	}
+	return signReq.PrivyID, nil
+}
+
+// Maps kill switch and emergency pause errors to API errors
+func ControlError(err error) *apierror.Error {
+	if errors.Is(err, controls.ErrAxalSigningDisabled) {
+		return apierror.Wrap(apierror.AxalSigningDisabled, "Axal signing is disabled by the user", err)
+	}
+
+	if errors.Is(err, controls.ErrAxalSigningPaused) {
+		return apierror.Wrap(apierror.AxalSigningPaused, "Axal signing is paused", err)
+	}
+
+	return apierror.Wrap(apierror.Unavailable, "Service Unavailable", err)
+}
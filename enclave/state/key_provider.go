@@ -0,0 +1,74 @@
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// DataKeySize is the size of the data key the sealed store derives its record keys from
+const DataKeySize = 32
+
+// DataKeyProvider creates and unwraps the data key of the sealed store. The wrapped key is stored on the host, so unwrapping it
+// must only be possible from inside the attested enclave.
+type DataKeyProvider interface {
+	// GenerateDataKey returns a new data key and a wrapped copy of it that is safe to hand to the host
+	GenerateDataKey() (dataKey []byte, wrappedKey []byte, err error)
+	// UnwrapDataKey recovers the data key from its wrapped copy
+	UnwrapDataKey(wrappedKey []byte) ([]byte, error)
+}
+
+// LocalKeyProvider wraps the data key with a static key from config. Anyone with the config can unwrap the state, so it must
+// only be used for local runs and tests.
+type LocalKeyProvider struct {
+	aead cipher.AEAD
+}
+
+// Creates a new LocalKeyProvider from a 32 byte wrapping key
+func NewLocalKeyProvider(wrappingKey []byte) (*LocalKeyProvider, error) {
+	if len(wrappingKey) != DataKeySize {
+		return nil, fmt.Errorf("local wrapping key must be %d bytes, got %d", DataKeySize, len(wrappingKey))
+	}
+
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wrapping cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wrapping cipher: %w", err)
+	}
+
+	return &LocalKeyProvider{aead: aead}, nil
+}
+
+func (p *LocalKeyProvider) GenerateDataKey() ([]byte, []byte, error) {
+	dataKey := make([]byte, DataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	wrapped := p.aead.Seal(nonce, nonce, dataKey, []byte("datakey"))
+	return dataKey, wrapped, nil
+}
+
+func (p *LocalKeyProvider) UnwrapDataKey(wrappedKey []byte) ([]byte, error) {
+	nonceSize := p.aead.NonceSize()
+	if len(wrappedKey) < nonceSize {
+		return nil, fmt.Errorf("wrapped data key is too short")
+	}
+
+	dataKey, err := p.aead.Open(nil, wrappedKey[:nonceSize], wrappedKey[nonceSize:], []byte("datakey"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	return dataKey, nil
+}
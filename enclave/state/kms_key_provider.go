@@ -0,0 +1,66 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// KMSClient is the part of the KMS client the KMSKeyProvider needs. Both calls must only return plaintext to the attested
+// enclave, which the recipient flow in common/aws/kms does.
+type KMSClient interface {
+	GenerateDataKey(ctx context.Context, keyId string) (plaintext []byte, ciphertextBlob []byte, err error)
+	Decrypt(ctx context.Context, ciphertextBlob []byte, keyId string) ([]byte, error)
+}
+
+// KMSKeyProvider wraps the data key with a KMS key whose policy only allows decryption from a measured enclave
+type KMSKeyProvider struct {
+	client  KMSClient
+	keyId   string
+	timeout time.Duration
+}
+
+// Creates a new KMSKeyProvider for the KMS key keyId
+func NewKMSKeyProvider(client KMSClient, keyId string) (*KMSKeyProvider, error) {
+	if keyId == "" {
+		return nil, fmt.Errorf("kms key provider needs a key id")
+	}
+
+	return &KMSKeyProvider{
+		client:  client,
+		keyId:   keyId,
+		timeout: 30 * time.Second,
+	}, nil
+}
+
+func (p *KMSKeyProvider) GenerateDataKey() ([]byte, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	dataKey, wrappedKey, err := p.client.GenerateDataKey(ctx, p.keyId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	if len(dataKey) != DataKeySize {
+		return nil, nil, fmt.Errorf("kms data key must be %d bytes, got %d", DataKeySize, len(dataKey))
+	}
+
+	return dataKey, wrappedKey, nil
+}
+
+func (p *KMSKeyProvider) UnwrapDataKey(wrappedKey []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	dataKey, err := p.client.Decrypt(ctx, wrappedKey, p.keyId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	if len(dataKey) != DataKeySize {
+		return nil, fmt.Errorf("kms data key must be %d bytes, got %d", DataKeySize, len(dataKey))
+	}
+
+	return dataKey, nil
+}
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/credentials"
+	"github.com/getaxal/verified-signer/common/aws/kms"
+	"github.com/getaxal/verified-signer/common/aws/kms/kmstest"
+)
+
+func TestKMSKeyProvider_SealedStore(t *testing.T) {
+	server := kmstest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddKey("state-key")
+
+	client := &kms.KMSClient{
+		KmsClient: server.Client(),
+		Config:    &kms.KMSConfig{Credentials: credentials.NewStaticProvider(aws.AWSCredentials{AccessKey: "AKIDEXAMPLE", AccessSecret: "secret"}), Region: aws.USEast2},
+		Attester:  kmstest.FakeAttester,
+	}
+
+	provider, err := NewKMSKeyProvider(client, "state-key")
+	if err != nil {
+		t.Fatalf("NewKMSKeyProvider() error = %v", err)
+	}
+
+	host, _ := newTestHost(t)
+	store := openTestStore(t, host, provider)
+	if err := store.Put("pause/state", []byte("paused")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// Reopening unwraps the stored data key through KMS
+	reopened := openTestStore(t, host, provider)
+	got, err := reopened.Get("pause/state")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(got) != "paused" {
+		t.Errorf("Get() = %q, want %q", got, "paused")
+	}
+
+	// A different KMS key can not unwrap the data key
+	server.AddKey("other-key")
+	otherProvider, err := NewKMSKeyProvider(client, "other-key")
+	if err != nil {
+		t.Fatalf("NewKMSKeyProvider() error = %v", err)
+	}
+	if _, err := OpenSealedStore(host, otherProvider, true); err == nil {
+		t.Error("OpenSealedStore() with another KMS key should fail")
+	}
+
+	if _, err := NewKMSKeyProvider(client, ""); err == nil {
+		t.Error("NewKMSKeyProvider() without a key id should fail")
+	}
+}
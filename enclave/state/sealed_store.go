@@ -0,0 +1,371 @@
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/getaxal/verified-signer/common/blobstore"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrRollback is returned when a record or the manifest is older than the version the enclave expects
+var ErrRollback = errors.New("state: record has been rolled back")
+
+// ErrTampered is returned when a blob fails authentication
+var ErrTampered = errors.New("state: record failed authentication")
+
+const (
+	dataKeyBlob      = "datakey"
+	manifestBlob     = "manifest"
+	recordBlobPrefix = "r-"
+)
+
+// manifest tracks the current version of every record. It is sealed like any other record and is the source of truth for
+// which records exist, so the host can not bring back a deleted record or swap in an older copy of one.
+type manifest struct {
+	Generation uint64            `json:"generation"`
+	Versions   map[string]uint64 `json:"versions"`
+}
+
+// SealedStore is a Store that keeps its records on the host as opaque blobs. Every record is encrypted and authenticated with
+// AES-256-GCM under a key derived from a data key that only the enclave can unwrap, and is bound to its key name and version.
+// Blob names are an HMAC of the key name and version so the host does not learn which users have state.
+//
+// Every write of a record takes the next manifest generation as its version and goes to a new blob, the previous one is only
+// deleted once the manifest points at the new version. A write that fails leaves the previous version readable, blobs the
+// manifest does not point at are deleted when the store is opened. Version counters
+// in the manifest catch the host replaying an older copy of a record. Replaying the whole store to an older
+// snapshot (manifest included) can only be caught while the enclave is running, since the enclave has no trusted monotonic
+// counter across restarts.
+type SealedStore struct {
+	backend  blobstore.Backend
+	aead     cipher.AEAD
+	nameKey  []byte
+	manifest *manifest
+	mu       sync.Mutex
+}
+
+// Opens the sealed store on backend. On first use it generates and stores a new wrapped data key, afterwards it unwraps the
+// existing one and loads the manifest. Starting without any state is only allowed with initialize, otherwise the host could wipe
+// the data key and manifest to clear every kill switch and the emergency pause.
+func OpenSealedStore(backend blobstore.Backend, keyProvider DataKeyProvider, initialize bool) (*SealedStore, error) {
+	var dataKey []byte
+	fresh := false
+
+	wrappedKey, err := backend.Get(dataKeyBlob)
+	switch {
+	case errors.Is(err, blobstore.ErrNotFound):
+		// A manifest without a data key means the host has removed the key, refuse to silently start over
+		if _, err := backend.Get(manifestBlob); err == nil {
+			return nil, fmt.Errorf("%w: data key is missing but a manifest exists", ErrTampered)
+		}
+		if !initialize {
+			return nil, fmt.Errorf("%w: no sealed state found and starting with empty state is not allowed", ErrTampered)
+		}
+
+		log.Info("No sealed state found, generating a new data key")
+		dataKey, wrappedKey, err = keyProvider.GenerateDataKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+
+		if err := backend.Put(dataKeyBlob, wrappedKey); err != nil {
+			return nil, fmt.Errorf("failed to store wrapped data key: %w", err)
+		}
+		fresh = true
+	case err != nil:
+		return nil, fmt.Errorf("failed to read wrapped data key: %w", err)
+	default:
+		dataKey, err = keyProvider.UnwrapDataKey(wrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+		}
+	}
+
+	store, err := newSealedStore(backend, dataKey)
+	clear(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if fresh {
+		store.manifest = &manifest{Versions: make(map[string]uint64)}
+		if err := store.writeManifest(store.manifest); err != nil {
+			return nil, err
+		}
+	} else {
+		store.manifest, err = store.readManifest()
+		if err != nil {
+			return nil, err
+		}
+		store.deleteUnreferenced()
+	}
+
+	log.Infof("Opened sealed state store at manifest generation %d with %d records", store.manifest.Generation, len(store.manifest.Versions))
+	return store, nil
+}
+
+// Derives separate encryption and naming keys from the data key
+func newSealedStore(backend blobstore.Backend, dataKey []byte) (*SealedStore, error) {
+	encKey, err := hkdf.Key(sha256.New, dataKey, nil, "verified-signer state record encryption", 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	defer clear(encKey)
+
+	nameKey, err := hkdf.Key(sha256.New, dataKey, nil, "verified-signer state record names", 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive naming key: %w", err)
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create record cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create record cipher: %w", err)
+	}
+
+	return &SealedStore{
+		backend: backend,
+		aead:    aead,
+		nameKey: nameKey,
+	}, nil
+}
+
+func (s *SealedStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, ok := s.manifest.Versions[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	blob, err := s.backend.Get(s.blobName(key, version))
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, fmt.Errorf("%w: record is missing", ErrRollback)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read record: %w", err)
+	}
+
+	blobVersion, value, err := s.open(blob, recordAAD(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if blobVersion != version {
+		log.Errorf("Sealed record has version %d but the manifest expects %d", blobVersion, version)
+		return nil, ErrRollback
+	}
+
+	return value, nil
+}
+
+func (s *SealedStore) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Versions are never reused, not even for a key that was deleted and written again, so no old blob can pass as current
+	previous, existed := s.manifest.Versions[key]
+	version := s.manifest.Generation + 1
+
+	blob, err := s.seal(version, value, recordAAD(key))
+	if err != nil {
+		return err
+	}
+
+	if err := s.backend.Put(s.blobName(key, version), blob); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	next := s.manifest.copy()
+	next.Versions[key] = version
+	if err := s.writeManifest(next); err != nil {
+		// The host may have stored the manifest and only lost the response, so the new blob is kept. If the manifest does not
+		// point at it the blob is deleted the next time the store is opened.
+		return err
+	}
+	s.manifest = next
+
+	if existed {
+		if err := s.backend.Delete(s.blobName(key, previous)); err != nil {
+			log.Warnf("Unable to delete previous sealed record blob with err: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *SealedStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, ok := s.manifest.Versions[key]
+	if !ok {
+		return nil
+	}
+
+	// The manifest is updated first, once the key is gone from it the old blob can never be read again
+	next := s.manifest.copy()
+	delete(next.Versions, key)
+	if err := s.writeManifest(next); err != nil {
+		return err
+	}
+	s.manifest = next
+
+	if err := s.backend.Delete(s.blobName(key, version)); err != nil {
+		log.Warnf("Unable to delete sealed record blob with err: %v", err)
+	}
+
+	return nil
+}
+
+// Deletes the record blobs the manifest does not point at, left behind by writes whose manifest update failed or was never
+// confirmed. Failing to list or delete them only leaves unreadable blobs on the host.
+func (s *SealedStore) deleteUnreferenced() {
+	names, err := s.backend.List()
+	if err != nil {
+		log.Warnf("Unable to list sealed record blobs with err: %v", err)
+		return
+	}
+
+	referenced := make(map[string]bool, len(s.manifest.Versions))
+	for key, version := range s.manifest.Versions {
+		referenced[s.blobName(key, version)] = true
+	}
+
+	deleted := 0
+	for _, name := range names {
+		if !strings.HasPrefix(name, recordBlobPrefix) || referenced[name] {
+			continue
+		}
+		if err := s.backend.Delete(name); err != nil {
+			log.Warnf("Unable to delete unreferenced sealed record blob with err: %v", err)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Infof("Deleted %d sealed record blobs the manifest does not point at", deleted)
+	}
+}
+
+// Seals and writes the manifest with the next generation
+func (s *SealedStore) writeManifest(next *manifest) error {
+	next.Generation++
+
+	raw, err := json.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	blob, err := s.seal(next.Generation, raw, []byte("manifest"))
+	if err != nil {
+		return err
+	}
+
+	if err := s.backend.Put(manifestBlob, blob); err != nil {
+		next.Generation--
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SealedStore) readManifest() (*manifest, error) {
+	blob, err := s.backend.Get(manifestBlob)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, fmt.Errorf("%w: manifest is missing", ErrRollback)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	generation, raw, err := s.open(blob, []byte("manifest"))
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	if m.Generation != generation {
+		return nil, ErrTampered
+	}
+	if m.Versions == nil {
+		m.Versions = make(map[string]uint64)
+	}
+
+	return &m, nil
+}
+
+// Seals a value as version || nonce || ciphertext. The version is authenticated along with the additional data.
+func (s *SealedStore) seal(version uint64, value []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	blob := binary.BigEndian.AppendUint64(nil, version)
+	blob = append(blob, nonce...)
+	return s.aead.Seal(blob, nonce, value, versionedAAD(version, additionalData)), nil
+}
+
+// Opens a sealed blob and returns its version and value
+func (s *SealedStore) open(blob []byte, additionalData []byte) (uint64, []byte, error) {
+	nonceSize := s.aead.NonceSize()
+	if len(blob) < 8+nonceSize {
+		return 0, nil, ErrTampered
+	}
+
+	version := binary.BigEndian.Uint64(blob[:8])
+	nonce := blob[8 : 8+nonceSize]
+
+	value, err := s.aead.Open(nil, nonce, blob[8+nonceSize:], versionedAAD(version, additionalData))
+	if err != nil {
+		return 0, nil, ErrTampered
+	}
+
+	return version, value, nil
+}
+
+// Blob names are an HMAC of the version and key so records can not be linked to users by the host
+func (s *SealedStore) blobName(key string, version uint64) string {
+	mac := hmac.New(sha256.New, s.nameKey)
+	mac.Write(binary.BigEndian.AppendUint64(nil, version))
+	mac.Write([]byte(key))
+	return recordBlobPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *manifest) copy() *manifest {
+	versions := make(map[string]uint64, len(m.Versions))
+	for key, version := range m.Versions {
+		versions[key] = version
+	}
+
+	return &manifest{Generation: m.Generation, Versions: versions}
+}
+
+func recordAAD(key string) []byte {
+	return append([]byte("record\x00"), key...)
+}
+
+func versionedAAD(version uint64, additionalData []byte) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, version), additionalData...)
+}
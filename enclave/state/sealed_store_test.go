@@ -0,0 +1,418 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getaxal/verified-signer/common/blobstore"
+)
+
+// Starts an in-memory stand-in for the host blob server and returns a client for it along with the backing blobs
+func newTestHost(t *testing.T) (*blobstore.Client, *blobstore.MemoryBackend) {
+	t.Helper()
+
+	backend := blobstore.NewMemoryBackend()
+	server := httptest.NewServer(blobstore.NewHandler(backend))
+	t.Cleanup(server.Close)
+
+	return blobstore.NewClient(server.Client(), server.URL), backend
+}
+
+func newTestKeyProvider(t *testing.T) *LocalKeyProvider {
+	t.Helper()
+
+	provider, err := NewLocalKeyProvider(bytes.Repeat([]byte{0x42}, DataKeySize))
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider() error = %v", err)
+	}
+	return provider
+}
+
+func openTestStore(t *testing.T, client blobstore.Backend, provider DataKeyProvider) *SealedStore {
+	t.Helper()
+
+	store, err := OpenSealedStore(client, provider, true)
+	if err != nil {
+		t.Fatalf("OpenSealedStore() error = %v", err)
+	}
+	return store
+}
+
+// Returns the name of the blob holding the current version of a record
+func currentBlobName(store *SealedStore, key string) string {
+	return store.blobName(key, store.manifest.Versions[key])
+}
+
+// Backend that fails writes of one blob. With stored the blob is written anyway, like a host that drops the response.
+type failingBackend struct {
+	blobstore.Backend
+	failPut string
+	stored  bool
+}
+
+func (b *failingBackend) Put(name string, data []byte) error {
+	if name != b.failPut {
+		return b.Backend.Put(name, data)
+	}
+	if b.stored {
+		if err := b.Backend.Put(name, data); err != nil {
+			return err
+		}
+	}
+	return errors.New("host is gone")
+}
+
+func TestSealedStore_RoundTrip(t *testing.T) {
+	client, _ := newTestHost(t)
+	store := openTestStore(t, client, newTestKeyProvider(t))
+
+	if _, err := store.Get("killswitch/did:privy:user1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() missing key error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := store.Put("killswitch/did:privy:user1", []byte("engaged")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := store.Put("killswitch/did:privy:user1", []byte("released")); err != nil {
+		t.Fatalf("Put() overwrite error = %v", err)
+	}
+
+	got, err := store.Get("killswitch/did:privy:user1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !bytes.Equal(got, []byte("released")) {
+		t.Errorf("Get() = %q, want %q", got, "released")
+	}
+
+	if err := store.Delete("killswitch/did:privy:user1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get("killswitch/did:privy:user1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestSealedStore_PersistsAcrossRestart(t *testing.T) {
+	client, _ := newTestHost(t)
+	provider := newTestKeyProvider(t)
+
+	store := openTestStore(t, client, provider)
+	if err := store.Put("pause/state", []byte(`{"paused":true}`)); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	restarted := openTestStore(t, client, provider)
+	got, err := restarted.Get("pause/state")
+	if err != nil {
+		t.Fatalf("Get() after restart error = %v", err)
+	}
+	if !bytes.Equal(got, []byte(`{"paused":true}`)) {
+		t.Errorf("Get() after restart = %q", got)
+	}
+}
+
+func TestSealedStore_HostOnlySeesCiphertext(t *testing.T) {
+	client, backend := newTestHost(t)
+	store := openTestStore(t, client, newTestKeyProvider(t))
+
+	key := "killswitch/did:privy:user1"
+	value := []byte("a very recognisable plaintext value")
+	if err := store.Put(key, value); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	for _, name := range backend.Names() {
+		blob, _ := backend.Get(name)
+		if bytes.Contains(blob, value) {
+			t.Errorf("blob %s contains the plaintext value", name)
+		}
+		if bytes.Contains([]byte(name), []byte("did:privy")) || bytes.Contains(blob, []byte("did:privy")) {
+			t.Errorf("blob %s leaks the record key", name)
+		}
+	}
+}
+
+func TestSealedStore_DetectsTampering(t *testing.T) {
+	client, backend := newTestHost(t)
+	store := openTestStore(t, client, newTestKeyProvider(t))
+
+	if err := store.Put("key", []byte("value")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	name := currentBlobName(store, "key")
+	blob, _ := backend.Get(name)
+	blob[len(blob)-1] ^= 0x01
+	backend.Put(name, blob)
+
+	if _, err := store.Get("key"); !errors.Is(err, ErrTampered) {
+		t.Errorf("Get() tampered record error = %v, want %v", err, ErrTampered)
+	}
+}
+
+func TestSealedStore_DetectsSwappedRecords(t *testing.T) {
+	client, backend := newTestHost(t)
+	store := openTestStore(t, client, newTestKeyProvider(t))
+
+	if err := store.Put("killswitch/did:privy:user1", []byte("engaged")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := store.Put("killswitch/did:privy:user2", []byte("released")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// The host copies user2's record over user1's
+	blob, _ := backend.Get(currentBlobName(store, "killswitch/did:privy:user2"))
+	backend.Put(currentBlobName(store, "killswitch/did:privy:user1"), blob)
+
+	if _, err := store.Get("killswitch/did:privy:user1"); !errors.Is(err, ErrTampered) {
+		t.Errorf("Get() swapped record error = %v, want %v", err, ErrTampered)
+	}
+}
+
+func TestSealedStore_DetectsRollback(t *testing.T) {
+	client, backend := newTestHost(t)
+	provider := newTestKeyProvider(t)
+	store := openTestStore(t, client, provider)
+
+	if err := store.Put("killswitch/did:privy:user1", []byte("released")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	oldBlob, _ := backend.Get(currentBlobName(store, "killswitch/did:privy:user1"))
+
+	if err := store.Put("killswitch/did:privy:user1", []byte("engaged")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// The host replays the older record
+	backend.Put(currentBlobName(store, "killswitch/did:privy:user1"), oldBlob)
+
+	if _, err := store.Get("killswitch/did:privy:user1"); !errors.Is(err, ErrRollback) {
+		t.Errorf("Get() rolled back record error = %v, want %v", err, ErrRollback)
+	}
+
+	// The rollback is also caught after a restart
+	restarted := openTestStore(t, client, provider)
+	if _, err := restarted.Get("killswitch/did:privy:user1"); !errors.Is(err, ErrRollback) {
+		t.Errorf("Get() rolled back record after restart error = %v, want %v", err, ErrRollback)
+	}
+}
+
+func TestSealedStore_DeletedRecordCanNotBeRestored(t *testing.T) {
+	client, backend := newTestHost(t)
+	store := openTestStore(t, client, newTestKeyProvider(t))
+
+	if err := store.Put("key", []byte("value")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	name := currentBlobName(store, "key")
+	blob, _ := backend.Get(name)
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	backend.Put(name, blob)
+
+	if _, err := store.Get("key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() restored record error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestSealedStore_MissingRecord(t *testing.T) {
+	client, backend := newTestHost(t)
+	store := openTestStore(t, client, newTestKeyProvider(t))
+
+	if err := store.Put("key", []byte("value")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	backend.Delete(currentBlobName(store, "key"))
+
+	if _, err := store.Get("key"); !errors.Is(err, ErrRollback) {
+		t.Errorf("Get() missing record error = %v, want %v", err, ErrRollback)
+	}
+}
+
+func TestSealedStore_FailedManifestWrite(t *testing.T) {
+	client, memory := newTestHost(t)
+	backend := &failingBackend{Backend: client}
+	provider := newTestKeyProvider(t)
+	store := openTestStore(t, backend, provider)
+
+	if err := store.Put("pause", []byte("running")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	blobs := len(memory.Names())
+
+	backend.failPut = manifestBlob
+	if err := store.Put("pause", []byte("paused")); err == nil {
+		t.Fatal("Put() error = nil when the manifest could not be written")
+	}
+
+	// The record keeps its previous value, the new blob stays until the store is opened again
+	if got, err := store.Get("pause"); err != nil || string(got) != "running" {
+		t.Errorf("Get() after a failed write = %q, %v, want the previous value", got, err)
+	}
+	if len(memory.Names()) != blobs+1 {
+		t.Errorf("failed write left %d blobs, want %d", len(memory.Names()), blobs+1)
+	}
+
+	backend.failPut = ""
+	restarted := openTestStore(t, backend, provider)
+	if got, err := restarted.Get("pause"); err != nil || string(got) != "running" {
+		t.Errorf("Get() after restart = %q, %v, want the previous value", got, err)
+	}
+	if len(memory.Names()) != blobs {
+		t.Errorf("store holds %d blobs after a restart, want the unreferenced blob deleted and %d left", len(memory.Names()), blobs)
+	}
+
+	if err := restarted.Put("pause", []byte("paused")); err != nil {
+		t.Fatalf("Put() retry error = %v", err)
+	}
+	restarted = openTestStore(t, backend, provider)
+	if got, err := restarted.Get("pause"); err != nil || string(got) != "paused" {
+		t.Errorf("Get() after restart = %q, %v, want the retried value", got, err)
+	}
+	if len(memory.Names()) != blobs {
+		t.Errorf("store holds %d blobs after overwriting a record, want %d", len(memory.Names()), blobs)
+	}
+}
+
+func TestSealedStore_ManifestStoredWithoutResponse(t *testing.T) {
+	client, memory := newTestHost(t)
+	backend := &failingBackend{Backend: client}
+	provider := newTestKeyProvider(t)
+	store := openTestStore(t, backend, provider)
+
+	if err := store.Put("pause", []byte("running")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// The host stores the new manifest but the enclave never hears back
+	backend.failPut = manifestBlob
+	backend.stored = true
+	if err := store.Put("pause", []byte("paused")); err == nil {
+		t.Fatal("Put() error = nil when the manifest write was not confirmed")
+	}
+
+	backend.failPut = ""
+	restarted := openTestStore(t, backend, provider)
+	if got, err := restarted.Get("pause"); err != nil || string(got) != "paused" {
+		t.Errorf("Get() after restart = %q, %v, want the value the stored manifest points at", got, err)
+	}
+	if got := len(memory.Names()); got != 3 {
+		t.Errorf("store holds %d blobs after a restart, want the data key, manifest and one record", got)
+	}
+}
+
+func TestSealedStore_RecreatedRecordCanNotBeRolledBack(t *testing.T) {
+	client, backend := newTestHost(t)
+	store := openTestStore(t, client, newTestKeyProvider(t))
+
+	if err := store.Put("key", []byte("old")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	oldBlob, _ := backend.Get(currentBlobName(store, "key"))
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Put("key", []byte("new")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// The record written before the delete can not stand in for the new one
+	backend.Put(currentBlobName(store, "key"), oldBlob)
+	if _, err := store.Get("key"); !errors.Is(err, ErrRollback) {
+		t.Errorf("Get() replayed record error = %v, want %v", err, ErrRollback)
+	}
+}
+
+func TestOpenSealedStore_MissingDataKey(t *testing.T) {
+	client, backend := newTestHost(t)
+	provider := newTestKeyProvider(t)
+
+	store := openTestStore(t, client, provider)
+	if err := store.Put("key", []byte("value")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	backend.Delete(dataKeyBlob)
+
+	if _, err := OpenSealedStore(client, provider, true); !errors.Is(err, ErrTampered) {
+		t.Errorf("OpenSealedStore() missing data key error = %v, want %v", err, ErrTampered)
+	}
+}
+
+func TestOpenSealedStore_WipedState(t *testing.T) {
+	client, backend := newTestHost(t)
+	provider := newTestKeyProvider(t)
+
+	store := openTestStore(t, client, provider)
+	if err := store.Put("pause", []byte("paused")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// The host deletes the data key and the manifest to start the enclave over without its pause
+	backend.Delete("datakey")
+	backend.Delete("manifest")
+
+	if _, err := OpenSealedStore(client, provider, false); !errors.Is(err, ErrTampered) {
+		t.Errorf("OpenSealedStore() of wiped state error = %v, want %v", err, ErrTampered)
+	}
+	if _, err := backend.Get("datakey"); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("OpenSealedStore() stored a new data key for wiped state, error = %v", err)
+	}
+}
+
+func TestOpenSealedStore_MissingManifest(t *testing.T) {
+	client, backend := newTestHost(t)
+	provider := newTestKeyProvider(t)
+
+	openTestStore(t, client, provider)
+	backend.Delete(manifestBlob)
+
+	if _, err := OpenSealedStore(client, provider, true); !errors.Is(err, ErrRollback) {
+		t.Errorf("OpenSealedStore() missing manifest error = %v, want %v", err, ErrRollback)
+	}
+}
+
+func TestOpenSealedStore_WrongKey(t *testing.T) {
+	client, _ := newTestHost(t)
+	openTestStore(t, client, newTestKeyProvider(t))
+
+	otherProvider, err := NewLocalKeyProvider(bytes.Repeat([]byte{0x07}, DataKeySize))
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider() error = %v", err)
+	}
+
+	if _, err := OpenSealedStore(client, otherProvider, true); err == nil {
+		t.Error("OpenSealedStore() expected error with the wrong wrapping key")
+	}
+}
+
+func TestLocalKeyProvider(t *testing.T) {
+	if _, err := NewLocalKeyProvider([]byte("short")); err == nil {
+		t.Error("NewLocalKeyProvider() expected error for a short key")
+	}
+
+	provider := newTestKeyProvider(t)
+	dataKey, wrapped, err := provider.GenerateDataKey()
+	if err != nil {
+		t.Fatalf("GenerateDataKey() error = %v", err)
+	}
+	if len(dataKey) != DataKeySize {
+		t.Errorf("GenerateDataKey() key length = %d, want %d", len(dataKey), DataKeySize)
+	}
+
+	unwrapped, err := provider.UnwrapDataKey(wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapDataKey() error = %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Error("UnwrapDataKey() returned a different key")
+	}
+}
@@ -0,0 +1,66 @@
+package state
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned when a key has no record in the store
+var ErrNotFound = errors.New("state: record not found")
+
+// Store is a simple key value store for enclave state that has to outlive a single request, such as user kill switches.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the value stored at key, or ErrNotFound if there is none
+	Get(key string) ([]byte, error)
+	// Put stores value at key, replacing any previous value
+	Put(key string, value []byte) error
+	// Delete removes the record at key. Deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// MemoryStore is an in-memory Store. Its contents are lost when the enclave restarts so it should only be used for tests
+// and local runs.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string][]byte
+}
+
+// Creates a new empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.records[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	out := make([]byte, len(value))
+	copy(out, value)
+	return out, nil
+}
+
+func (s *MemoryStore) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := make([]byte, len(value))
+	copy(stored, value)
+	s.records[key] = stored
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,42 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() missing key error = %v, want %v", err, ErrNotFound)
+	}
+
+	value := []byte("value")
+	if err := store.Put("key", value); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// Mutating the callers slice must not change the stored value
+	value[0] = 'X'
+
+	got, err := store.Get("key")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !bytes.Equal(got, []byte("value")) {
+		t.Errorf("Get() = %s, want value", got)
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get("key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Errorf("Delete() missing key error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package validate checks the Ethereum and Privy primitives the enclave accepts from clients. Requests are validated before anything
+// reaches Privy, so a malformed hash is reported against its field instead of coming back as an upstream error.
+package validate
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"golang.org/x/crypto/sha3"
+)
+
+// Largest chain id accepted, wallets cap chain ids so they stay exact as JavaScript numbers
+var maxChainID = new(big.Int).SetUint64(1<<53 - 1)
+
+// Privy user DIDs are did:privy: followed by a lower case alphanumeric id, a cuid for real users
+var privyDIDPattern = regexp.MustCompile(`^did:privy:[a-z0-9]{1,64}$`)
+
+// Checks s is a 0x prefixed 32 byte hex string
+func Hash32(s string) error {
+	if s == "" {
+		return errors.New("is required")
+	}
+	b, err := hexBytes(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != 32 {
+		return fmt.Errorf("must be 32 bytes, got %d", len(b))
+	}
+	return nil
+}
+
+// Checks s is a 0x prefixed 20 byte address. Mixed case addresses must carry a valid EIP-55 checksum, all lower or all upper case
+// addresses have no checksum to check.
+func Address(s string) error {
+	if s == "" {
+		return errors.New("is required")
+	}
+	b, err := hexBytes(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != 20 {
+		return fmt.Errorf("must be 20 bytes, got %d", len(b))
+	}
+
+	digits := s[2:]
+	if digits == strings.ToLower(digits) || digits == strings.ToUpper(digits) {
+		return nil
+	}
+	if s != ChecksumAddress(b) {
+		return errors.New("has an invalid EIP-55 checksum")
+	}
+	return nil
+}
+
+// Returns the EIP-55 checksummed form of a 20 byte address
+func ChecksumAddress(addr []byte) string {
+	lower := hex.EncodeToString(addr)
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(lower))
+	hash := hasher.Sum(nil)
+
+	out := []byte(lower)
+	for i, c := range out {
+		// A letter is upper case when the matching nibble of the hash of the lower case address is 8 or more
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
+
+// Checks s is a JSON-RPC hex quantity, 0x followed by the shortest hex form of the number
+func Quantity(s string) error {
+	_, err := parseQuantity(s)
+	return err
+}
+
+// Checks s is a chain id, a hex quantity or a decimal number between 1 and 2^53 - 1
+func ChainID(s string) error {
+	var id *big.Int
+	if strings.HasPrefix(s, "0x") {
+		q, err := parseQuantity(s)
+		if err != nil {
+			return err
+		}
+		id = q
+	} else {
+		d, ok := new(big.Int).SetString(s, 10)
+		if !ok || (len(s) > 1 && s[0] == '0') {
+			return errors.New("must be a hex quantity or a decimal number")
+		}
+		id = d
+	}
+
+	if id.Sign() <= 0 || id.Cmp(maxChainID) > 0 {
+		return errors.New("must be between 1 and 2^53 - 1")
+	}
+	return nil
+}
+
+// Checks s is a Privy user DID like did:privy:cm3np4u9j001rc8b73seqmqqk
+func PrivyDID(s string) error {
+	if s == "" {
+		return errors.New("is required")
+	}
+	if !privyDIDPattern.MatchString(s) {
+		return errors.New("must be a privy DID like did:privy:<id>")
+	}
+	return nil
+}
+
+func hexBytes(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, errors.New("must start with 0x")
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, errors.New("must be hex encoded bytes")
+	}
+	return b, nil
+}
+
+func parseQuantity(s string) (*big.Int, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, errors.New("must start with 0x")
+	}
+	digits := s[2:]
+	if digits == "" {
+		return nil, errors.New("must have at least one hex digit")
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		return nil, errors.New("must not have leading zeros")
+	}
+	q, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return nil, errors.New("must be hex digits")
+	}
+	return q, nil
+}
+
+// Fields collects the field errors of a request
+type Fields struct {
+	details []apierror.FieldError
+}
+
+// Records err against field, nil errors are ignored
+func (f *Fields) Check(field string, err error) {
+	if err != nil {
+		f.details = append(f.details, apierror.FieldError{Field: field, Message: err.Error()})
+	}
+}
+
+// Returns an invalid request error listing every recorded field, nil if there is none
+func (f *Fields) Err() error {
+	if len(f.details) == 0 {
+		return nil
+	}
+	return apierror.Invalid(f.details...)
+}
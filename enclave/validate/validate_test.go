@@ -0,0 +1,166 @@
+package validate
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+)
+
+func TestHash32(t *testing.T) {
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr bool
+	}{
+		{name: "valid", hash: "0x" + strings.Repeat("ab", 32)},
+		{name: "upper case", hash: "0x" + strings.Repeat("AB", 32)},
+		{name: "empty", hash: "", wantErr: true},
+		{name: "no prefix", hash: strings.Repeat("ab", 32), wantErr: true},
+		{name: "short", hash: "0x" + strings.Repeat("ab", 31), wantErr: true},
+		{name: "long", hash: "0x" + strings.Repeat("ab", 33), wantErr: true},
+		{name: "odd length", hash: "0x" + strings.Repeat("ab", 31) + "a", wantErr: true},
+		{name: "not hex", hash: "0x" + strings.Repeat("zz", 32), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Hash32(tt.hash); (err != nil) != tt.wantErr {
+				t.Errorf("Hash32(%q) error = %v, wantErr %v", tt.hash, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		// Checksummed addresses from EIP-55
+		{name: "checksummed", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{name: "checksummed 2", address: "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"},
+		{name: "checksummed 3", address: "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"},
+		{name: "checksummed 4", address: "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb"},
+		{name: "all lower case", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"},
+		{name: "all upper case", address: "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED"},
+		{name: "bad checksum", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", wantErr: true},
+		{name: "short", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1bea", wantErr: true},
+		{name: "no prefix", address: "5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", wantErr: true},
+		{name: "empty", address: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Address(tt.address); (err != nil) != tt.wantErr {
+				t.Errorf("Address(%q) error = %v, wantErr %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChecksumAddress(t *testing.T) {
+	want := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	addr, _ := hex.DecodeString(strings.ToLower(want[2:]))
+
+	if got := ChecksumAddress(addr); got != want {
+		t.Errorf("ChecksumAddress() = %s, want %s", got, want)
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		wantErr  bool
+	}{
+		{quantity: "0x0"},
+		{quantity: "0x1"},
+		{quantity: "0x400"},
+		{quantity: "0xDeadBeef"},
+		{quantity: "0x", wantErr: true},
+		{quantity: "0x0400", wantErr: true},
+		{quantity: "0x00", wantErr: true},
+		{quantity: "400", wantErr: true},
+		{quantity: "0xg", wantErr: true},
+		{quantity: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := Quantity(tt.quantity); (err != nil) != tt.wantErr {
+			t.Errorf("Quantity(%q) error = %v, wantErr %v", tt.quantity, err, tt.wantErr)
+		}
+	}
+}
+
+func TestChainID(t *testing.T) {
+	tests := []struct {
+		chainId string
+		wantErr bool
+	}{
+		{chainId: "1"},
+		{chainId: "0x1"},
+		{chainId: "8453"},
+		{chainId: "0x2105"},
+		{chainId: "9007199254740991"},
+		{chainId: "0", wantErr: true},
+		{chainId: "0x0", wantErr: true},
+		{chainId: "9007199254740992", wantErr: true},
+		{chainId: "-1", wantErr: true},
+		{chainId: "01", wantErr: true},
+		{chainId: "mainnet", wantErr: true},
+		{chainId: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ChainID(tt.chainId); (err != nil) != tt.wantErr {
+			t.Errorf("ChainID(%q) error = %v, wantErr %v", tt.chainId, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPrivyDID(t *testing.T) {
+	tests := []struct {
+		did     string
+		wantErr bool
+	}{
+		{did: "did:privy:cm3np4u9j001rc8b73seqmqqk"},
+		{did: "did:privy:alice"},
+		{did: "did:privy:", wantErr: true},
+		{did: "did:privy:Alice", wantErr: true},
+		{did: "did:privy:alice/../bob", wantErr: true},
+		{did: "cm3np4u9j001rc8b73seqmqqk", wantErr: true},
+		{did: "did:ethr:0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", wantErr: true},
+		{did: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := PrivyDID(tt.did); (err != nil) != tt.wantErr {
+			t.Errorf("PrivyDID(%q) error = %v, wantErr %v", tt.did, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	var fields Fields
+	if err := fields.Err(); err != nil {
+		t.Fatalf("Err() = %v with no field errors", err)
+	}
+
+	fields.Check("params.hash", Hash32("0x12"))
+	fields.Check("chain_id", nil)
+	fields.Check("privy_id", PrivyDID("alice"))
+
+	var apiErr *apierror.Error
+	if !errors.As(fields.Err(), &apiErr) {
+		t.Fatalf("Err() = %v, want an API error", fields.Err())
+	}
+	if apiErr.Code != apierror.InvalidRequest || len(apiErr.Details) != 2 {
+		t.Errorf("Err() = %+v, want invalid_request for params.hash and privy_id", apiErr)
+	}
+	if apiErr.Details[0].Field != "params.hash" || apiErr.Details[1].Field != "privy_id" {
+		t.Errorf("Err() details = %+v, want them in check order", apiErr.Details)
+	}
+}
@@ -0,0 +1,56 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+)
+
+// ChainPolicy only lets Axal sign for a set of chains. Content without a chain id could be replayed on any chain and is refused.
+type ChainPolicy struct {
+	allowed map[string]bool
+}
+
+// Creates a ChainPolicy allowing the given chain ids
+func NewChainPolicy(chainIds []uint64) *ChainPolicy {
+	allowed := make(map[string]bool, len(chainIds))
+	for _, chainId := range chainIds {
+		allowed[strconv.FormatUint(chainId, 10)] = true
+	}
+	return &ChainPolicy{allowed: allowed}
+}
+
+func (p *ChainPolicy) Name() string {
+	return "chain"
+}
+
+// Checks the chain id of a transaction or of the EIP-712 domain is allowed
+func (p *ChainPolicy) Check(ctx context.Context, content *Content) error {
+	var chainId *data.BigInt
+	switch {
+	case content.Transaction != nil:
+		chainId = content.Transaction.ChainID
+		if chainId == nil {
+			return errors.New("legacy transactions without a chain id are not allowed")
+		}
+	case content.TypedData != nil:
+		id, ok, err := content.TypedData.ChainID()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("typed data without a domain chainId is not allowed")
+		}
+		chainId = id
+	default:
+		return errors.New("nothing to check")
+	}
+
+	if !p.allowed[chainId.String()] {
+		return fmt.Errorf("chain id %s is not allowed", chainId)
+	}
+	return nil
+}
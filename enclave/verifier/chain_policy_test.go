@@ -0,0 +1,42 @@
+package verifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+)
+
+func TestChainPolicy_Check(t *testing.T) {
+	legacy := &data.UnsignedTransaction{Type: data.LegacyTxType}
+	mainnet := &data.UnsignedTransaction{Type: data.DynamicFeeTxType, ChainID: data.NewBigIntFromInt64(1)}
+	base := &data.UnsignedTransaction{Type: data.DynamicFeeTxType, ChainID: data.NewBigIntFromInt64(8453)}
+
+	typedData := func(domain map[string]interface{}) *data.TypedData {
+		return &data.TypedData{Domain: domain}
+	}
+
+	tests := []struct {
+		name    string
+		content *Content
+		wantErr bool
+	}{
+		{name: "allowed transaction", content: &Content{Transaction: mainnet}},
+		{name: "other chain", content: &Content{Transaction: base}, wantErr: true},
+		{name: "legacy without chain id", content: &Content{Transaction: legacy}, wantErr: true},
+		{name: "allowed typed data", content: &Content{TypedData: typedData(map[string]interface{}{"chainId": "0x1"})}},
+		{name: "typed data on another chain", content: &Content{TypedData: typedData(map[string]interface{}{"chainId": "8453"})}, wantErr: true},
+		{name: "typed data without chain id", content: &Content{TypedData: typedData(map[string]interface{}{"name": "Permit2"})}, wantErr: true},
+		{name: "typed data with a bad chain id", content: &Content{TypedData: typedData(map[string]interface{}{"chainId": "one"})}, wantErr: true},
+		{name: "no content", content: &Content{}, wantErr: true},
+	}
+
+	policy := NewChainPolicy([]uint64{1, 10})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Check(context.Background(), tt.content); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
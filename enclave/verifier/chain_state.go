@@ -0,0 +1,50 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/rpc"
+)
+
+// Reads the live chain state a policy checks with the rpc client of a chain. Failures are API errors a policy returns as is:
+// providers disagreeing on the state are a chain_state_disputed, since the host or a provider may be lying, and providers that
+// can not be reached are upstream_unavailable. A chain without a quorum of at least enclave.MinRPCQuorum providers is denied, a
+// single provider could forge its state.
+func ReadChainState(ctx context.Context, chainId uint64, read func(client *rpc.Client) error) error {
+	client, err := rpc.ForChain(chainId)
+	if err == nil && client.Quorum() < enclave.MinRPCQuorum {
+		return apierror.New(apierror.PolicyDenied, fmt.Sprintf("The state of chain %d can not be trusted without a quorum of RPC providers", chainId))
+	}
+	if err == nil {
+		err = read(client)
+	}
+	if err != nil {
+		return chainStateError(chainId, err)
+	}
+	return nil
+}
+
+// Maps an error reading chain state to an API error
+func chainStateError(chainId uint64, err error) *apierror.Error {
+	var apiErr *apierror.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, rpc.ErrProviderMismatch):
+		return apierror.Wrap(apierror.ChainStateDisputed, fmt.Sprintf("RPC providers of chain %d disagree on its state", chainId), err)
+	case errors.Is(err, rpc.ErrUnknownChain):
+		return apierror.Wrap(apierror.PolicyDenied, fmt.Sprintf("The state of chain %d can not be read", chainId), err)
+	case errors.Is(err, rpc.ErrNoQuorum), errors.Is(err, rpc.ErrNoProviders):
+		return apierror.Wrap(apierror.UpstreamUnavailable, fmt.Sprintf("RPC providers of chain %d are unavailable", chainId), err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return apierror.Wrap(apierror.UpstreamTimeout, fmt.Sprintf("RPC providers of chain %d timed out", chainId), err)
+	case errors.Is(err, context.Canceled):
+		return apierror.Wrap(apierror.RequestCancelled, "Request cancelled", err)
+	default:
+		return apierror.Wrap(apierror.UpstreamError, fmt.Sprintf("Reading the state of chain %d failed", chainId), err)
+	}
+}
@@ -0,0 +1,106 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave"
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	"github.com/getaxal/verified-signer/enclave/rpc"
+	"github.com/getaxal/verified-signer/enclave/rpc/rpctest"
+)
+
+// Policy that reads the balance of the recipient of a transaction
+type balancePolicy struct{}
+
+func (p balancePolicy) Name() string {
+	return "balance"
+}
+
+func (p balancePolicy) Check(ctx context.Context, content *Content) error {
+	return ReadChainState(ctx, content.Transaction.ChainID.Int.Uint64(), func(client *rpc.Client) error {
+		_, err := client.BalanceAt(ctx, *content.Transaction.To, rpc.Latest)
+		return err
+	})
+}
+
+func TestReadChainState_Quorum(t *testing.T) {
+	servers := make([]*rpctest.Server, 3)
+	chain := enclave.RPCChainConfig{ChainID: 1, Quorum: 2}
+	for i := range servers {
+		servers[i] = rpctest.NewServer(1)
+		defer servers[i].Close()
+		chain.Providers = append(chain.Providers, enclave.RPCProviderConfig{Name: fmt.Sprintf("provider%d", i), URL: servers[i].URL})
+	}
+	client, err := rpc.NewClient(chain, rpc.ClientOptions{Transport: servers[0].Transport()})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	rpc.Clients = map[uint64]*rpc.Client{1: client}
+	defer func() { rpc.Clients = nil }()
+
+	v := NewVerifier(true, balancePolicy{})
+	req := txRequest(t, eip155Hash, eip155Tx)
+	if err := v.VerifyAxalRequest(context.Background(), req); err != nil {
+		t.Fatalf("VerifyAxalRequest() error = %v with agreeing providers", err)
+	}
+
+	// Every provider tells a different story about the recipient
+	var recipient data.Address
+	for i := range recipient {
+		recipient[i] = 0x35
+	}
+	for i, server := range servers {
+		server.SetBalance(recipient, big.NewInt(int64(i+1)))
+	}
+	if err := v.VerifyAxalRequest(context.Background(), req); err == nil || err.Code != apierror.ChainStateDisputed {
+		t.Errorf("VerifyAxalRequest() error = %v, want %s", err, apierror.ChainStateDisputed)
+	}
+
+	// A single provider decides the state without a quorum and with a quorum of one of one
+	for _, single := range []enclave.RPCChainConfig{
+		{ChainID: 1, Providers: chain.Providers},
+		{ChainID: 1, Quorum: 1, Providers: chain.Providers[:1]},
+	} {
+		client, err := rpc.NewClient(single, rpc.ClientOptions{Transport: servers[0].Transport()})
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		rpc.Clients = map[uint64]*rpc.Client{1: client}
+		if err := v.VerifyAxalRequest(context.Background(), req); err == nil || err.Code != apierror.PolicyDenied {
+			t.Errorf("VerifyAxalRequest() error = %v with a quorum of %d of %d, want %s", err, single.Quorum, len(single.Providers), apierror.PolicyDenied)
+		}
+	}
+
+	rpc.Clients = nil
+	if err := v.VerifyAxalRequest(context.Background(), req); err == nil || err.Code != apierror.PolicyDenied {
+		t.Errorf("VerifyAxalRequest() error = %v without providers for the chain, want %s", err, apierror.PolicyDenied)
+	}
+}
+
+func TestChainStateError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want apierror.Code
+	}{
+		{err: fmt.Errorf("%w on eth_getBalance", rpc.ErrProviderMismatch), want: apierror.ChainStateDisputed},
+		{err: fmt.Errorf("%w for chain 1", rpc.ErrNoQuorum), want: apierror.UpstreamUnavailable},
+		{err: fmt.Errorf("%w for chain 1", rpc.ErrNoProviders), want: apierror.UpstreamUnavailable},
+		{err: fmt.Errorf("%w 1", rpc.ErrUnknownChain), want: apierror.PolicyDenied},
+		{err: context.DeadlineExceeded, want: apierror.UpstreamTimeout},
+		{err: context.Canceled, want: apierror.RequestCancelled},
+		{err: &rpc.Error{Code: 3, Message: "execution reverted"}, want: apierror.UpstreamError},
+		{err: apierror.New(apierror.PolicyDenied, "no"), want: apierror.PolicyDenied},
+		{err: errors.New("boom"), want: apierror.UpstreamError},
+	}
+
+	for _, tt := range tests {
+		if got := chainStateError(1, tt.err); got.Code != tt.want || !errors.Is(got, tt.err) {
+			t.Errorf("chainStateError(%v) = %v, want %s", tt.err, got, tt.want)
+		}
+	}
+}
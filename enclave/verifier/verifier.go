@@ -0,0 +1,131 @@
+// Package verifier checks what Axal asks the enclave to sign. An Axal request can carry the preimage of its hash, an unsigned
+// transaction or EIP-712 typed data. The verifier recomputes the digest from it, refuses a preimage that does not hash to the
+// requested hash and runs its policies on the decoded content before anything is signed.
+package verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrVerifierUnavailable is returned when the verifier was never initiated. Callers must not sign.
+var ErrVerifierUnavailable = errors.New("axal request verifier is unavailable")
+
+var AxalVerifier *Verifier
+
+// Content is the decoded preimage of an Axal signing request, exactly one of Transaction and TypedData is set
+type Content struct {
+	PrivyID     string
+	Hash        data.Hash32
+	Transaction *data.UnsignedTransaction
+	TypedData   *data.TypedData
+}
+
+// Policy decides whether Axal may sign some content. An error refuses the signature, an *apierror.Error is returned to the caller
+// as is and any other error is a policy denial.
+type Policy interface {
+	Name() string
+	Check(ctx context.Context, content *Content) error
+}
+
+// Verifier checks the preimage of Axal signing requests and runs policies on it
+type Verifier struct {
+	requirePreimage bool
+	policies        []Policy
+}
+
+// Inits a new Verifier and initiates it to verifier.AxalVerifier
+func InitVerifier(requirePreimage bool, policies ...Policy) {
+	AxalVerifier = NewVerifier(requirePreimage, policies...)
+}
+
+// Creates a new Verifier. With requirePreimage Axal requests that only carry a hash are rejected, otherwise they are signed without
+// running any policy.
+func NewVerifier(requirePreimage bool, policies ...Policy) *Verifier {
+	return &Verifier{
+		requirePreimage: requirePreimage,
+		policies:        policies,
+	}
+}
+
+// Verifies an Axal signing request. The request must have been validated and authenticated already.
+func (v *Verifier) VerifyAxalRequest(ctx context.Context, req *data.AxalEthSecp256k1SignRequest) *apierror.Error {
+	if req.Preimage == nil {
+		if v.requirePreimage {
+			log.Errorf("Axal signing request for user %s has no preimage", req.PrivyID)
+			return apierror.New(apierror.PreimageRequired, "Axal signing requests must carry the preimage of their hash")
+		}
+		log.Warnf("Signing raw hash %s for user %s without a preimage, no policies were run", req.Params.Hash, req.PrivyID)
+		return nil
+	}
+
+	content, apiErr := decodeContent(req)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	for _, policy := range v.policies {
+		if err := policy.Check(ctx, content); err != nil {
+			log.Errorf("Policy %s refused hash %s for user %s with err: %v", policy.Name(), content.Hash, content.PrivyID, err)
+			return policyError(policy, err)
+		}
+	}
+	return nil
+}
+
+// Verifies an Axal signing request with verifier.AxalVerifier. Fails closed if it was never initiated.
+func VerifyAxalRequest(ctx context.Context, req *data.AxalEthSecp256k1SignRequest) *apierror.Error {
+	if AxalVerifier == nil {
+		log.Error("Axal request verifier has not been initiated")
+		return apierror.Wrap(apierror.Unavailable, "Service Unavailable", ErrVerifierUnavailable)
+	}
+	return AxalVerifier.VerifyAxalRequest(ctx, req)
+}
+
+// Decodes the preimage of a request and checks it hashes to the requested hash
+func decodeContent(req *data.AxalEthSecp256k1SignRequest) (*Content, *apierror.Error) {
+	hash, err := data.ParseHash32(req.Params.Hash)
+	if err != nil {
+		return nil, apierror.Invalid(apierror.FieldError{Field: "params.hash", Message: err.Error()})
+	}
+	content := &Content{PrivyID: req.PrivyID, Hash: hash}
+
+	var digest data.Hash32
+	switch {
+	case len(req.Preimage.Transaction) > 0:
+		content.Transaction, err = data.DecodeUnsignedTransaction(req.Preimage.Transaction)
+		if err == nil {
+			digest, err = content.Transaction.SigningHash()
+		}
+		if err != nil {
+			return nil, apierror.Invalid(apierror.FieldError{Field: "preimage.transaction", Message: err.Error()})
+		}
+	case req.Preimage.TypedData != nil:
+		content.TypedData = req.Preimage.TypedData
+		if digest, err = content.TypedData.Hash(); err != nil {
+			return nil, apierror.Invalid(apierror.FieldError{Field: "preimage.typed_data", Message: err.Error()})
+		}
+	default:
+		return nil, apierror.Invalid(apierror.FieldError{Field: "preimage", Message: "must hold exactly one of transaction and typed_data"})
+	}
+
+	if digest != hash {
+		log.Errorf("Preimage for user %s hashes to %s, not to the requested hash %s", req.PrivyID, digest, hash)
+		return nil, apierror.New(apierror.PreimageMismatch, fmt.Sprintf("preimage hashes to %s, not to params.hash", digest))
+	}
+	return content, nil
+}
+
+// Maps a policy error to an API error, policies may return their own
+func policyError(policy Policy, err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return apierror.Wrap(apierror.PolicyDenied, fmt.Sprintf("Request denied by the %s policy: %v", policy.Name(), err), err)
+}
@@ -0,0 +1,172 @@
+package verifier
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/getaxal/verified-signer/enclave/apierror"
+	"github.com/getaxal/verified-signer/enclave/privy-signer/data"
+)
+
+// EIP-155 example transaction and its signing hash
+const (
+	eip155Tx   = "ec098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080018080"
+	eip155Hash = "0xdaf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53"
+)
+
+// EIP-712 Mail example and its digest
+const (
+	mailTypedData = `{"types":{"EIP712Domain":[{"name":"name","type":"string"},{"name":"version","type":"string"},{"name":"chainId","type":"uint256"},{"name":"verifyingContract","type":"address"}],"Person":[{"name":"name","type":"string"},{"name":"wallet","type":"address"}],"Mail":[{"name":"from","type":"Person"},{"name":"to","type":"Person"},{"name":"contents","type":"string"}]},"primaryType":"Mail","domain":{"name":"Ether Mail","version":"1","chainId":1,"verifyingContract":"0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"},"message":{"from":{"name":"Cow","wallet":"0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},"to":{"name":"Bob","wallet":"0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},"contents":"Hello, Bob!"}}`
+	mailHash      = "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"
+)
+
+const privyId = "did:privy:alice"
+
+func txRequest(t *testing.T, hash, rawTx string) *data.AxalEthSecp256k1SignRequest {
+	t.Helper()
+	tx, err := hex.DecodeString(rawTx)
+	if err != nil {
+		t.Fatalf("bad transaction hex: %v", err)
+	}
+	req := data.NewAxalEthSecp256k1SignRequest(hash, privyId)
+	req.Preimage = &data.SigningPreimage{Transaction: tx}
+	return req
+}
+
+func typedDataRequest(t *testing.T, hash string) *data.AxalEthSecp256k1SignRequest {
+	t.Helper()
+	var td data.TypedData
+	if err := json.Unmarshal([]byte(mailTypedData), &td); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	req := data.NewAxalEthSecp256k1SignRequest(hash, privyId)
+	req.Preimage = &data.SigningPreimage{TypedData: &td}
+	return req
+}
+
+// Policy that records the content it saw and returns err
+type recordingPolicy struct {
+	err  error
+	seen []*Content
+}
+
+func (p *recordingPolicy) Name() string {
+	return "recording"
+}
+
+func (p *recordingPolicy) Check(ctx context.Context, content *Content) error {
+	p.seen = append(p.seen, content)
+	return p.err
+}
+
+func TestVerifier_VerifyAxalRequest(t *testing.T) {
+	otherHash := "0x" + eip155Hash[4:] + "00"
+
+	tests := []struct {
+		name            string
+		requirePreimage bool
+		req             func(t *testing.T) *data.AxalEthSecp256k1SignRequest
+		policyErr       error
+		wantCode        apierror.Code
+		wantChecked     bool
+	}{
+		{
+			name: "raw hash allowed",
+			req: func(t *testing.T) *data.AxalEthSecp256k1SignRequest {
+				return data.NewAxalEthSecp256k1SignRequest(eip155Hash, privyId)
+			},
+		},
+		{
+			name:            "raw hash required to carry a preimage",
+			requirePreimage: true,
+			req: func(t *testing.T) *data.AxalEthSecp256k1SignRequest {
+				return data.NewAxalEthSecp256k1SignRequest(eip155Hash, privyId)
+			},
+			wantCode: apierror.PreimageRequired,
+		},
+		{
+			name:            "transaction",
+			requirePreimage: true,
+			req:             func(t *testing.T) *data.AxalEthSecp256k1SignRequest { return txRequest(t, eip155Hash, eip155Tx) },
+			wantChecked:     true,
+		},
+		{
+			name:            "typed data",
+			requirePreimage: true,
+			req:             func(t *testing.T) *data.AxalEthSecp256k1SignRequest { return typedDataRequest(t, mailHash) },
+			wantChecked:     true,
+		},
+		{
+			name:     "transaction hashing to another hash",
+			req:      func(t *testing.T) *data.AxalEthSecp256k1SignRequest { return txRequest(t, otherHash, eip155Tx) },
+			wantCode: apierror.PreimageMismatch,
+		},
+		{
+			name:     "typed data hashing to another hash",
+			req:      func(t *testing.T) *data.AxalEthSecp256k1SignRequest { return typedDataRequest(t, eip155Hash) },
+			wantCode: apierror.PreimageMismatch,
+		},
+		{
+			name:     "undecodable transaction",
+			req:      func(t *testing.T) *data.AxalEthSecp256k1SignRequest { return txRequest(t, eip155Hash, eip155Tx+"00") },
+			wantCode: apierror.InvalidRequest,
+		},
+		{
+			name:        "policy denial",
+			req:         func(t *testing.T) *data.AxalEthSecp256k1SignRequest { return txRequest(t, eip155Hash, eip155Tx) },
+			policyErr:   errors.New("not today"),
+			wantCode:    apierror.PolicyDenied,
+			wantChecked: true,
+		},
+		{
+			name:        "policy api error",
+			req:         func(t *testing.T) *data.AxalEthSecp256k1SignRequest { return txRequest(t, eip155Hash, eip155Tx) },
+			policyErr:   apierror.New(apierror.UpstreamUnavailable, "rpc is down"),
+			wantCode:    apierror.UpstreamUnavailable,
+			wantChecked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &recordingPolicy{err: tt.policyErr}
+			v := NewVerifier(tt.requirePreimage, policy)
+
+			err := v.VerifyAxalRequest(context.Background(), tt.req(t))
+			if tt.wantCode == "" && err != nil {
+				t.Fatalf("VerifyAxalRequest() error = %v", err)
+			}
+			if tt.wantCode != "" && (err == nil || err.Code != tt.wantCode) {
+				t.Fatalf("VerifyAxalRequest() error = %v, want %s", err, tt.wantCode)
+			}
+
+			if checked := len(policy.seen) == 1; checked != tt.wantChecked {
+				t.Fatalf("policy checked = %v, want %v", checked, tt.wantChecked)
+			}
+			if tt.wantChecked {
+				content := policy.seen[0]
+				if content.PrivyID != privyId || (content.Transaction == nil) == (content.TypedData == nil) {
+					t.Errorf("policy got content %+v, want the privy id and one decoded preimage", content)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAxalRequest_NotInitiated(t *testing.T) {
+	AxalVerifier = nil
+
+	err := VerifyAxalRequest(context.Background(), data.NewAxalEthSecp256k1SignRequest(eip155Hash, privyId))
+	if err == nil || err.Code != apierror.Unavailable || !errors.Is(err, ErrVerifierUnavailable) {
+		t.Errorf("VerifyAxalRequest() error = %v, want it to fail closed", err)
+	}
+
+	InitVerifier(false)
+	defer func() { AxalVerifier = nil }()
+	if err := VerifyAxalRequest(context.Background(), data.NewAxalEthSecp256k1SignRequest(eip155Hash, privyId)); err != nil {
+		t.Errorf("VerifyAxalRequest() error = %v once initiated", err)
+	}
+}
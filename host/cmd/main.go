@@ -2,28 +2,61 @@ package main
 
 import (
 	"context"
+	"flag"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/getaxal/verified-signer/common/aws"
+	"github.com/getaxal/verified-signer/common/aws/imds"
+	"github.com/getaxal/verified-signer/common/redact"
 	"github.com/getaxal/verified-signer/host/network"
 	log "github.com/sirupsen/logrus"
 )
 
 func main() {
 	ctx := context.Background()
+	redact.Install(log.StandardLogger())
 	log.Info("Starting Verified signer host service")
 
+	regionFlag := flag.String("region", os.Getenv("AWS_REGION"), "AWS region to proxy to, discovered from instance metadata if empty")
+	flag.Parse()
+
+	region, err := resolveRegion(ctx, *regionFlag)
+	if err != nil {
+		log.Fatalf("Could not resolve aws region: %v", err)
+	}
+	log.Infof("Proxying aws services in region %s", region)
+
 	// Proxy for Vsock to TCP for aws secret manager
-	go network.InitVsockToTcpProxy(ctx, 50001, 443, "https://secretsmanager."+aws.USEast2.String()+".amazonaws.com")
+	go network.InitVsockToTcpProxy(ctx, 50001, 443, "https://secretsmanager."+region.String()+".amazonaws.com")
 	// Proxy for Vsock to TCP for privy APIs
 	go network.InitVsockToTcpProxy(ctx, 50002, 443, "https://api.privy.io")
 	// Proxy for TCP to Vsock for Backend to reach the enclave
 	go network.InitSimpleHTTPToVsockProxy(ctx, 8080, 50003, 5)
 
 	go network.InitVsockToTcpProxy(ctx, 50004, 80, "http://169.254.169.254")
+	// Proxy for Vsock to TCP for aws kms
+	go network.InitVsockToTcpProxy(ctx, 50006, 443, "https://kms."+region.String()+".amazonaws.com")
+	// Blob server for the enclave sealed state store
+	go network.InitVsockBlobServer(ctx, 50005, "/var/lib/verified-signer/state")
+	// Proxies for Vsock to TCP for the chain rpc providers, the enclave ends TLS so the host only relays ciphertext
+	go network.InitVsockToTcpProxy(ctx, 50007, 443, "https://eth-mainnet.g.alchemy.com")
+	go network.InitVsockToTcpProxy(ctx, 50008, 443, "https://mainnet.infura.io")
+	go network.InitVsockToTcpProxy(ctx, 50009, 443, "https://base-mainnet.g.alchemy.com")
+	go network.InitVsockToTcpProxy(ctx, 50010, 443, "https://base-mainnet.infura.io")
 
 	for {
 		time.Sleep(time.Hour)
 	}
 
 }
+
+// Resolves the region from the flag, or from the instance metadata of the host
+func resolveRegion(ctx context.Context, configured string) (aws.AWSRegion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	imdsClient := imds.NewClient(&http.Client{Timeout: 5 * time.Second}, imds.DefaultEndpoint)
+	return imds.ResolveRegion(ctx, configured, imdsClient)
+}
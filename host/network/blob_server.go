@@ -0,0 +1,48 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/getaxal/verified-signer/common/blobstore"
+	"github.com/getaxal/verified-signer/common/vsock"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// This function serves the enclave state blobs from dir on the vsock port provided. The enclave seals every blob before sending
+// it, so the host only ever stores ciphertext.
+func InitVsockBlobServer(ctx context.Context, vsockPort uint32, dir string) {
+	backend, err := blobstore.NewFileBackend(dir)
+	if err != nil {
+		log.Errorf("Unable to create blob store at %s with err: %v", dir, err)
+		return
+	}
+
+	listener, err := vsock.Listen(vsockPort, nil)
+	if err != nil {
+		log.Errorf("Unable to listen to vsock port %d with err: %v", vsockPort, err)
+		return
+	}
+
+	server := &http.Server{
+		Handler:      blobstore.NewHandler(backend),
+		ReadTimeout:  20 * time.Second,
+		WriteTimeout: 20 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	// Handle graceful shutdown
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("Serving enclave state blobs from %s on vsock port %d", dir, vsockPort)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		log.Errorf("Blob server error: %v", err)
+	}
+}